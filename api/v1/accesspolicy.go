@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Access is the access to services and AWS that an AwsAccount or User asks for
+// +kubebuilder:object:generate=false
+type Access struct {
+	Services          []string
+	Groups            []string
+	ManagedPolicyArns []string
+}
+
+// IsEmpty reports whether no access is asked for
+func (a Access) IsEmpty() bool {
+	return len(a.Services) == 0 && len(a.Groups) == 0 && len(a.ManagedPolicyArns) == 0
+}
+
+// String describes the access, e.g. "services github, IAM groups admins"
+func (a Access) String() string {
+	var parts []string
+	if len(a.Services) > 0 {
+		parts = append(parts, "services "+strings.Join(a.Services, ", "))
+	}
+	if len(a.Groups) > 0 {
+		parts = append(parts, "IAM groups "+strings.Join(a.Groups, ", "))
+	}
+	if len(a.ManagedPolicyArns) > 0 {
+		parts = append(parts, "managed policies "+strings.Join(a.ManagedPolicyArns, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// DeniedAccess returns the part of the access that none of the AccessPolicies selecting the namespace
+// allows. Nothing is denied as long as there are no AccessPolicies at all.
+func DeniedAccess(policies []AccessPolicy, namespaceLabels map[string]string, access Access) (Access, error) {
+	if len(policies) == 0 {
+		return Access{}, nil
+	}
+	var allowed Access
+	for _, policy := range policies {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.NamespaceSelector)
+		if err != nil {
+			return Access{}, err
+		}
+		if !selector.Matches(labels.Set(namespaceLabels)) {
+			continue
+		}
+		allowed.Services = append(allowed.Services, policy.Spec.Services...)
+		allowed.Groups = append(allowed.Groups, policy.Spec.Groups...)
+		allowed.ManagedPolicyArns = append(allowed.ManagedPolicyArns, policy.Spec.ManagedPolicyArns...)
+	}
+	return Access{
+		Services:          unmatched(access.Services, allowed.Services),
+		Groups:            unmatched(access.Groups, allowed.Groups),
+		ManagedPolicyArns: unmatched(access.ManagedPolicyArns, allowed.ManagedPolicyArns),
+	}, nil
+}
+
+// DeniedAccessInNamespace returns the part of the access that the AccessPolicies deny in the namespace
+func DeniedAccessInNamespace(ctx context.Context, c client.Reader, namespace string, access Access) (Access, error) {
+	var policies AccessPolicyList
+	if err := c.List(ctx, &policies); err != nil {
+		return Access{}, err
+	}
+	if len(policies.Items) == 0 {
+		return Access{}, nil
+	}
+	var ns corev1.Namespace
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, &ns); client.IgnoreNotFound(err) != nil {
+		return Access{}, err
+	}
+	return DeniedAccess(policies.Items, ns.Labels, access)
+}
+
+// unmatched returns the values that match none of the patterns
+func unmatched(values []string, patterns []string) []string {
+	var result []string
+	for _, value := range values {
+		if !matchesAny(value, patterns) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func matchesAny(value string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// accessPolicyErrors reports the access that the AccessPolicies deny in the namespace on the fields
+// asking for it: the services on their servicePaths, the IAM groups and managed policies on the
+// fields of the AwsAccount spec at awsAccountPath
+func accessPolicyErrors(ctx context.Context, c client.Reader, namespace string, access Access, servicePaths map[string]*field.Path, awsAccountPath *field.Path) (field.ErrorList, error) {
+	if access.IsEmpty() {
+		return nil, nil
+	}
+	denied, err := DeniedAccessInNamespace(ctx, c, namespace, access)
+	if err != nil {
+		return nil, err
+	}
+	suffix := " is not allowed in namespace " + namespace + " by any AccessPolicy"
+	var allErrs field.ErrorList
+	for _, service := range denied.Services {
+		allErrs = append(allErrs, field.Forbidden(servicePaths[service], "service "+service+suffix))
+	}
+	for _, group := range denied.Groups {
+		allErrs = append(allErrs, field.Forbidden(awsAccountPath.Child("groups"), "IAM group "+group+suffix))
+	}
+	for _, arn := range denied.ManagedPolicyArns {
+		allErrs = append(allErrs, field.Forbidden(awsAccountPath.Child("managedPolicyArns"), "managed policy "+arn+suffix))
+	}
+	return allErrs, nil
+}
+
+// addedValues returns the values that are not in old
+func addedValues(values []string, old []string) []string {
+	var added []string
+	for _, value := range values {
+		if !containsString(old, value) {
+			added = append(added, value)
+		}
+	}
+	return added
+}
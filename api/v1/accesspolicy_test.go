@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("AccessPolicy", func() {
+
+	access := Access{
+		Services:          []string{ServiceAws, ServiceGithub},
+		Groups:            []string{"developers", "admins"},
+		ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::aws:policy/AdministratorAccess"},
+	}
+	developers := AccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "developers"},
+		Spec: AccessPolicySpec{
+			Services:          []string{"*"},
+			Groups:            []string{"developers"},
+			ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/ReadOnly*"},
+		},
+	}
+	platform := AccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+		Spec: AccessPolicySpec{
+			NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+			Groups:            []string{"admins"},
+			ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/AdministratorAccess"},
+		},
+	}
+
+	It("Should deny nothing while there are no AccessPolicies", func() {
+		Expect(DeniedAccess(nil, nil, access)).Should(Equal(Access{}))
+	})
+
+	It("Should only allow what the AccessPolicies selecting the namespace allow", func() {
+		denied, err := DeniedAccess([]AccessPolicy{developers, platform}, map[string]string{"team": "dns"}, access)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(denied).Should(Equal(Access{
+			Groups:            []string{"admins"},
+			ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/AdministratorAccess"},
+		}))
+
+		denied, err = DeniedAccess([]AccessPolicy{developers, platform}, map[string]string{"team": "platform"}, access)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(denied.IsEmpty()).Should(BeTrue())
+	})
+
+	It("Should deny everything in namespaces no AccessPolicy selects", func() {
+		denied, err := DeniedAccess([]AccessPolicy{platform}, nil, access)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(denied).Should(Equal(access))
+		Expect(denied.String()).Should(ContainSubstring("services aws, github; IAM groups developers, admins"))
+	})
+
+	Context("When validating an AwsAccount", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-dns", Labels: map[string]string{"team": "dns"}}}
+
+		It("Should reject the groups and managed policies no AccessPolicy allows", func() {
+			validator := &awsAccountValidator{Client: newFakeClient(namespace, developers.DeepCopy(), platform.DeepCopy())}
+			awsAccount := &AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "team-dns"},
+				Spec: AwsAccountSpec{
+					UserName:          "jdoe",
+					Groups:            []string{"developers", "admins"},
+					ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/AdministratorAccess"},
+				},
+			}
+			err := validator.ValidateCreate(context.Background(), awsAccount)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("IAM group admins is not allowed in namespace team-dns by any AccessPolicy"))
+			Expect(err.Error()).Should(ContainSubstring("managed policy arn:aws:iam::aws:policy/AdministratorAccess is not allowed"))
+			Expect(err.Error()).ShouldNot(ContainSubstring("IAM group developers"))
+
+			By("Only checking what an update adds")
+			old := awsAccount.DeepCopy()
+			awsAccount.Spec.Groups = append(awsAccount.Spec.Groups, "qa")
+			err = validator.ValidateUpdate(context.Background(), old, awsAccount)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("IAM group qa"))
+			Expect(err.Error()).ShouldNot(ContainSubstring("IAM group admins"))
+		})
+
+		It("Should allow anything while there are no AccessPolicies", func() {
+			validator := &awsAccountValidator{Client: newFakeClient(namespace)}
+			awsAccount := &AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "team-dns"},
+				Spec:       AwsAccountSpec{UserName: "jdoe", Groups: []string{"admins"}},
+			}
+			Expect(validator.ValidateCreate(context.Background(), awsAccount)).Should(Succeed())
+		})
+
+		It("Should reject the User services no AccessPolicy allows", func() {
+			validator := &userValidator{Client: newFakeClient(namespace, platform.DeepCopy())}
+			user := &User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "team-dns"},
+				Spec:       UserSpec{Github: &GithubAccountSpec{Login: "jdoe"}},
+			}
+			err := validator.ValidateCreate(context.Background(), user)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.github: Forbidden: service github is not allowed"))
+		})
+	})
+})
@@ -0,0 +1,74 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessPolicySpec lists the access that AwsAccounts and Users in the selected namespaces may ask for.
+// Once any AccessPolicy exists, a namespace is only allowed what the AccessPolicies selecting it allow.
+// Entries are names, or patterns where * matches any sequence of characters but /.
+type AccessPolicySpec struct {
+	// NamespaceSelector selects the namespaces the policy applies to. An empty selector selects every namespace.
+	// +optional
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Services are the services Users may have accounts for, e.g. aws or github. AwsAccounts need aws.
+	// +optional
+	Services []string `json:"services,omitempty"`
+
+	// Groups are the IAM groups AwsAccounts may join
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+
+	// ManagedPolicyArns are the managed IAM policies AwsAccounts may attach
+	// +optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// AccessPolicy is the Schema for the accesspolicies API
+type AccessPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AccessPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AccessPolicyList contains a list of AccessPolicy
+type AccessPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessPolicy{}, &AccessPolicyList{})
+}
+
+const (
+	// ConditionAccessAllowed is True when the AccessPolicies allow everything an AwsAccount asks for
+	ConditionAccessAllowed = "AccessAllowed"
+
+	// ReasonAccessPolicyDenied means no AccessPolicy allows part of the access asked for
+	ReasonAccessPolicyDenied = "AccessPolicyDenied"
+)
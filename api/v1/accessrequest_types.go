@@ -0,0 +1,174 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessRequestSpec asks for temporary membership of IAM groups and managed policies for an AwsAccount.
+// The access is granted once another user approves the request, and lasts for the requested duration.
+type AccessRequestSpec struct {
+	// AwsAccount is the name of the AwsAccount in the AccessRequest's namespace the access is for
+	// +kubebuilder:validation:MinLength=1
+	AwsAccount string `json:"awsAccount"`
+
+	// Groups are the IAM groups the IAM user is added to while the access lasts
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+
+	// ManagedPolicyArns are the managed IAM policies attached to the IAM user while the access lasts
+	// +optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
+
+	// Justification explains to the approvers why the access is needed
+	// +kubebuilder:validation:MinLength=1
+	Justification string `json:"justification"`
+
+	// Duration is how long the access lasts once approved
+	Duration metav1.Duration `json:"duration"`
+
+	// RequestedBy is the user who created the AccessRequest. It is set by the webhook.
+	// +optional
+	RequestedBy string `json:"requestedBy,omitempty"`
+}
+
+// AccessRequestApproval records an approver's decision on an AccessRequest. It is set through the
+// status subresource and cannot be changed once given.
+type AccessRequestApproval struct {
+	// Approved grants the access when true and denies it when false
+	Approved bool `json:"approved"`
+
+	// Approver is the user who decided. It is set by the webhook.
+	// +optional
+	Approver string `json:"approver,omitempty"`
+
+	// Time is when the decision was made, from which the duration of the access counts.
+	// It is set by the webhook.
+	// +optional
+	Time metav1.Time `json:"time,omitempty"`
+
+	// Comment explains the decision
+	// +optional
+	Comment string `json:"comment,omitempty"`
+}
+
+// AccessRequestPhase is the stage an AccessRequest is in
+// +kubebuilder:validation:Enum=Pending;Active;Denied;Expired
+type AccessRequestPhase string
+
+const (
+	// AccessRequestPending means the AccessRequest waits for approval
+	AccessRequestPending AccessRequestPhase = "Pending"
+	// AccessRequestActive means the AccessRequest is approved and the access is granted
+	AccessRequestActive AccessRequestPhase = "Active"
+	// AccessRequestDenied means the AccessRequest was denied
+	AccessRequestDenied AccessRequestPhase = "Denied"
+	// AccessRequestExpired means the duration of the approved access has passed
+	AccessRequestExpired AccessRequestPhase = "Expired"
+)
+
+// Event reasons reported on AccessRequests and the AwsAccounts they grant access to
+const (
+	ReasonAccessRequested = "AccessRequested"
+	ReasonAccessApproved  = "AccessApproved"
+	ReasonAccessDenied    = "AccessDenied"
+	ReasonAccessExpired   = "AccessExpired"
+	ReasonAccessGranted   = "AccessGranted"
+	ReasonAccessRevoked   = "AccessRevoked"
+	// ReasonApprovalNotTrusted means an approved AccessRequest grants nothing as its approval did not go through the webhook
+	ReasonApprovalNotTrusted = "ApprovalNotTrusted"
+)
+
+// AccessRequestStatus defines the observed state of AccessRequest
+type AccessRequestStatus struct {
+	// Approval is the approver's decision
+	// +optional
+	Approval *AccessRequestApproval `json:"approval,omitempty"`
+
+	// Phase is the stage the AccessRequest is in
+	// +optional
+	Phase AccessRequestPhase `json:"phase,omitempty"`
+
+	// ExpiresAt is when the approved access ends
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="AwsAccount",type="string",JSONPath=".spec.awsAccount"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Expires",type="date",JSONPath=".status.expiresAt"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AccessRequest is the Schema for the accessrequests API
+type AccessRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessRequestSpec   `json:"spec,omitempty"`
+	Status AccessRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AccessRequestList contains a list of AccessRequest
+type AccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessRequest{}, &AccessRequestList{})
+}
+
+// Approved reports whether an approver granted the AccessRequest
+func (r *AccessRequest) Approved() bool {
+	return r.Status.Approval != nil && r.Status.Approval.Approved
+}
+
+// GrantExpiresAt returns when the access of an approved AccessRequest ends, counting the duration
+// from the approval
+func (r *AccessRequest) GrantExpiresAt() time.Time {
+	if !r.Approved() {
+		return time.Time{}
+	}
+	return r.Status.Approval.Time.Add(r.Spec.Duration.Duration)
+}
+
+// GrantActive reports whether the AccessRequest is approved and its access has not ended at now
+func (r *AccessRequest) GrantActive(now time.Time) bool {
+	return r.Approved() && now.Before(r.GrantExpiresAt())
+}
+
+// Phase returns the phase the AccessRequest is in at now
+func (r *AccessRequest) Phase(now time.Time) AccessRequestPhase {
+	switch {
+	case r.Status.Approval == nil:
+		return AccessRequestPending
+	case !r.Status.Approval.Approved:
+		return AccessRequestDenied
+	case r.GrantActive(now):
+		return AccessRequestActive
+	default:
+		return AccessRequestExpired
+	}
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var accessrequestlog = logf.Log.WithName("accessrequest-resource")
+
+// AccessRequestApproveVerb is the verb a user needs on accessrequests in a namespace to approve or
+// deny the AccessRequests in it. It is checked with a SubjectAccessReview, as Kubernetes has no
+// built-in use for it.
+const AccessRequestApproveVerb = "approve"
+
+// SetupWebhookWithManager registers the AccessRequest webhooks
+func (r *AccessRequest) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&accessRequestDefaulter{Now: time.Now}).
+		WithValidator(&accessRequestValidator{
+			Client:     mgr.GetClient(),
+			Authorizer: &subjectAccessReviewAuthorizer{Client: mgr.GetClient()},
+		}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-kuadra-kuadrant-io-v1-accessrequest,mutating=true,failurePolicy=fail,sideEffects=None,groups=kuadra.kuadrant.io,resources=accessrequests;accessrequests/status,verbs=create;update,versions=v1,name=maccessrequest.kb.io,admissionReviewVersions=v1
+
+// accessRequestDefaulter records who requested an AccessRequest and who decided on it, and when
+type accessRequestDefaulter struct {
+	Now func() time.Time
+}
+
+var _ webhook.CustomDefaulter = &accessRequestDefaulter{}
+
+// Default implements webhook.CustomDefaulter. The requester and approver are taken from the user
+// making the request, so that they cannot be forged.
+func (d *accessRequestDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	accessRequest, ok := obj.(*AccessRequest)
+	if !ok {
+		return fmt.Errorf("expected an AccessRequest but got a %T", obj)
+	}
+	accessrequestlog.Info("default", "name", accessRequest.Name)
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if req.Operation == admissionv1.Create {
+		accessRequest.Spec.RequestedBy = req.UserInfo.Username
+		return nil
+	}
+	if accessRequest.Status.Approval == nil {
+		return nil
+	}
+	var oldAccessRequest AccessRequest
+	if err := json.Unmarshal(req.OldObject.Raw, &oldAccessRequest); err != nil {
+		return err
+	}
+	// A decision that was already given is left for the validating webhook to refuse changes to
+	if oldAccessRequest.Status.Approval == nil {
+		accessRequest.Status.Approval.Approver = req.UserInfo.Username
+		accessRequest.Status.Approval.Time = metav1.NewTime(d.Now())
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-kuadra-kuadrant-io-v1-accessrequest,mutating=false,failurePolicy=fail,sideEffects=None,groups=kuadra.kuadrant.io,resources=accessrequests;accessrequests/status,verbs=create;update,versions=v1,name=vaccessrequest.kb.io,admissionReviewVersions=v1
+
+// approvalAuthorizer decides whether a user may approve or deny an AccessRequest
+// +kubebuilder:object:generate=false
+type approvalAuthorizer interface {
+	CanApprove(ctx context.Context, user authenticationv1.UserInfo, accessRequest *AccessRequest) (bool, error)
+}
+
+// accessRequestValidator checks the AccessRequest spec, which cannot change once created, and that
+// a decision is given once, by a user other than the requester who is allowed to approve
+type accessRequestValidator struct {
+	Client     client.Reader
+	Authorizer approvalAuthorizer
+}
+
+var _ webhook.CustomValidator = &accessRequestValidator{}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *accessRequestValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	accessRequest, ok := obj.(*AccessRequest)
+	if !ok {
+		return fmt.Errorf("expected an AccessRequest but got a %T", obj)
+	}
+	accessrequestlog.Info("validate create", "name", accessRequest.Name)
+
+	allErrs := validateAccessRequestSpec(&accessRequest.Spec, field.NewPath("spec"))
+	if len(allErrs) == 0 {
+		awsAccountErrs, err := accessRequest.validateAwsAccountExists(ctx, v.Client)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = awsAccountErrs
+	}
+	return accessRequest.toInvalidError(allErrs)
+}
+
+// validateAccessRequestSpec checks the AwsAccount name, the IAM group names and the duration of an
+// AccessRequest spec
+func validateAccessRequestSpec(spec *AccessRequestSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, msg := range validation.IsDNS1123Subdomain(spec.AwsAccount) {
+		allErrs = append(allErrs, field.Invalid(path.Child("awsAccount"), spec.AwsAccount, msg))
+	}
+	if len(spec.Groups) == 0 && len(spec.ManagedPolicyArns) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("groups"), "at least one group or managed policy is required"))
+	}
+	allErrs = append(allErrs, validateGroups(spec.Groups, path.Child("groups"))...)
+	if spec.Duration.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("duration"), spec.Duration.String(), "must be positive"))
+	}
+	return allErrs
+}
+
+// validateAwsAccountExists refuses an AccessRequest for an AwsAccount that does not exist
+func (r *AccessRequest) validateAwsAccountExists(ctx context.Context, c client.Reader) (field.ErrorList, error) {
+	err := c.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: r.Spec.AwsAccount}, &AwsAccount{})
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{field.NotFound(field.NewPath("spec", "awsAccount"), r.Spec.AwsAccount)}, nil
+	}
+	return nil, err
+}
+
+// ValidateUpdate implements webhook.CustomValidator
+func (v *accessRequestValidator) ValidateUpdate(ctx context.Context, oldObj runtime.Object, newObj runtime.Object) error {
+	accessRequest, ok := newObj.(*AccessRequest)
+	if !ok {
+		return fmt.Errorf("expected an AccessRequest but got a %T", newObj)
+	}
+	oldAccessRequest, ok := oldObj.(*AccessRequest)
+	if !ok {
+		return fmt.Errorf("expected an AccessRequest but got a %T", oldObj)
+	}
+	accessrequestlog.Info("validate update", "name", accessRequest.Name)
+
+	var allErrs field.ErrorList
+	if !reflect.DeepEqual(accessRequest.Spec, oldAccessRequest.Spec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "is immutable, create a new AccessRequest instead"))
+	}
+	if !reflect.DeepEqual(accessRequest.Status.Approval, oldAccessRequest.Status.Approval) {
+		approvalErrs, err := v.validateApproval(ctx, accessRequest, oldAccessRequest)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		allErrs = append(allErrs, approvalErrs...)
+	}
+	return accessRequest.toInvalidError(allErrs)
+}
+
+// validateApproval checks a decision given on an AccessRequest
+func (v *accessRequestValidator) validateApproval(ctx context.Context, accessRequest *AccessRequest, oldAccessRequest *AccessRequest) (field.ErrorList, error) {
+	path := field.NewPath("status", "approval")
+	if oldAccessRequest.Status.Approval != nil {
+		return field.ErrorList{field.Forbidden(path, "cannot be changed once given")}, nil
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	approval := accessRequest.Status.Approval
+	user := req.UserInfo
+	if approval.Approver != user.Username {
+		return field.ErrorList{field.Invalid(path.Child("approver"), approval.Approver, "must be the user giving the decision, "+user.Username)}, nil
+	}
+	if approval.Approver == accessRequest.Spec.RequestedBy {
+		return field.ErrorList{field.Forbidden(path, "must be given by another user than the requester")}, nil
+	}
+	allowed, err := v.Authorizer.CanApprove(ctx, user, accessRequest)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return field.ErrorList{field.Forbidden(path, "user "+user.Username+" cannot "+AccessRequestApproveVerb+
+			" accessrequests in namespace "+accessRequest.Namespace)}, nil
+	}
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *accessRequestValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+// toInvalidError wraps validation errors in an Invalid API error, or returns nil when there are none
+func (r *AccessRequest) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("AccessRequest").GroupKind(), r.Name, allErrs)
+}
+
+// subjectAccessReviewAuthorizer asks the API server whether a user may approve an AccessRequest or
+// bind a role
+type subjectAccessReviewAuthorizer struct {
+	Client client.Client
+}
+
+// CanApprove implements approvalAuthorizer
+func (a *subjectAccessReviewAuthorizer) CanApprove(ctx context.Context, user authenticationv1.UserInfo, accessRequest *AccessRequest) (bool, error) {
+	return a.allowed(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: accessRequest.Namespace,
+		Verb:      AccessRequestApproveVerb,
+		Group:     GroupVersion.Group,
+		Version:   GroupVersion.Version,
+		Resource:  "accessrequests",
+		Name:      accessRequest.Name,
+	})
+}
+
+// CanBind implements roleBinder. Only a cluster wide bind permission is accepted, as the namespace
+// the role is bound in is only known to the controller.
+func (a *subjectAccessReviewAuthorizer) CanBind(ctx context.Context, user authenticationv1.UserInfo, roleRef rbacv1.RoleRef) (bool, error) {
+	resource := "clusterroles"
+	if roleRef.Kind == "Role" {
+		resource = "roles"
+	}
+	return a.allowed(ctx, user, &authorizationv1.ResourceAttributes{
+		Verb:     "bind",
+		Group:    rbacv1.GroupName,
+		Resource: resource,
+		Name:     roleRef.Name,
+	})
+}
+
+// allowed reviews the access of the user to the resource
+func (a *subjectAccessReviewAuthorizer) allowed(ctx context.Context, user authenticationv1.UserInfo, attributes *authorizationv1.ResourceAttributes) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, values := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(values)
+	}
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+			ResourceAttributes: attributes,
+		},
+	}
+	if err := a.Client.Create(ctx, review); err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
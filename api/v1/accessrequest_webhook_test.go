@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("AccessRequest webhook", func() {
+
+	approvedAt := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	newAccessRequest := func() *AccessRequest {
+		return &AccessRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dns-admin",
+				Namespace: "default",
+			},
+			Spec: AccessRequestSpec{
+				AwsAccount:    "awsaccount-webhook",
+				Groups:        []string{"dns-admins"},
+				Justification: "Delegating a hosted zone",
+				Duration:      metav1.Duration{Duration: time.Hour},
+				RequestedBy:   "jdoe",
+			},
+		}
+	}
+
+	// requestContext returns a context carrying the admission request of the user
+	requestContext := func(operation admissionv1.Operation, userName string, oldObject runtime.Object) context.Context {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: operation,
+			UserInfo:  authenticationv1.UserInfo{Username: userName, Groups: []string{"system:authenticated"}},
+		}}
+		if oldObject != nil {
+			raw, err := json.Marshal(oldObject)
+			Expect(err).ShouldNot(HaveOccurred())
+			req.OldObject = runtime.RawExtension{Raw: raw}
+			req.SubResource = "status"
+		}
+		return admission.NewContextWithRequest(context.Background(), req)
+	}
+
+	Context("When defaulting an AccessRequest", func() {
+		defaulter := &accessRequestDefaulter{Now: func() time.Time { return approvedAt }}
+
+		It("Should record the requester, whatever the spec says", func() {
+			accessRequest := newAccessRequest()
+			Expect(defaulter.Default(requestContext(admissionv1.Create, "jane", nil), accessRequest)).Should(Succeed())
+			Expect(accessRequest.Spec.RequestedBy).Should(Equal("jane"))
+		})
+
+		It("Should record the approver and the time of a new decision only", func() {
+			old := newAccessRequest()
+			approved := old.DeepCopy()
+			approved.Status.Approval = &AccessRequestApproval{Approved: true, Approver: "someone-else"}
+			Expect(defaulter.Default(requestContext(admissionv1.Update, "jane", old), approved)).Should(Succeed())
+			Expect(approved.Status.Approval.Approver).Should(Equal("jane"))
+			Expect(approved.Status.Approval.Time.Time).Should(Equal(approvedAt))
+
+			changed := approved.DeepCopy()
+			changed.Status.Approval.Approved = false
+			Expect(defaulter.Default(requestContext(admissionv1.Update, "max", approved), changed)).Should(Succeed())
+			Expect(changed.Status.Approval.Approver).Should(Equal("jane"))
+		})
+	})
+
+	Context("When creating an AccessRequest", func() {
+		awsAccount := &AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-webhook", Namespace: "default"},
+			Spec:       AwsAccountSpec{UserName: "webhook-user"},
+		}
+
+		It("Should accept a request for an existing AwsAccount", func() {
+			validator := &accessRequestValidator{Client: newFakeClient(awsAccount)}
+			Expect(validator.ValidateCreate(context.Background(), newAccessRequest())).Should(Succeed())
+		})
+
+		It("Should reject a request for an AwsAccount that does not exist", func() {
+			validator := &accessRequestValidator{Client: newFakeClient()}
+			err := validator.ValidateCreate(context.Background(), newAccessRequest())
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.awsAccount: Not found"))
+		})
+
+		It("Should reject a request without access or duration", func() {
+			validator := &accessRequestValidator{Client: newFakeClient(awsAccount)}
+			accessRequest := newAccessRequest()
+			accessRequest.Spec.Groups = nil
+			accessRequest.Spec.Duration = metav1.Duration{}
+			err := validator.ValidateCreate(context.Background(), accessRequest)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.groups: Required value"))
+			Expect(err.Error()).Should(ContainSubstring("spec.duration"))
+		})
+	})
+
+	Context("When updating an AccessRequest", func() {
+		authorizer := &fakeApprovalAuthorizer{approvers: []string{"jane", "jdoe"}}
+		validator := &accessRequestValidator{Client: newFakeClient(), Authorizer: authorizer}
+
+		approve := func(old *AccessRequest, approver string) *AccessRequest {
+			approved := old.DeepCopy()
+			approved.Status.Approval = &AccessRequestApproval{Approved: true, Approver: approver, Time: metav1.NewTime(approvedAt)}
+			return approved
+		}
+
+		It("Should reject a change to the spec", func() {
+			old := newAccessRequest()
+			updated := old.DeepCopy()
+			updated.Spec.Duration = metav1.Duration{Duration: 24 * time.Hour}
+			err := validator.ValidateUpdate(requestContext(admissionv1.Update, "jdoe", old), old, updated)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec: Forbidden: is immutable"))
+		})
+
+		It("Should accept an approval by another user allowed to approve", func() {
+			old := newAccessRequest()
+			Expect(validator.ValidateUpdate(requestContext(admissionv1.Update, "jane", old), old, approve(old, "jane"))).Should(Succeed())
+		})
+
+		It("Should reject the requester approving their own AccessRequest", func() {
+			old := newAccessRequest()
+			err := validator.ValidateUpdate(requestContext(admissionv1.Update, "jdoe", old), old, approve(old, "jdoe"))
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("must be given by another user than the requester"))
+		})
+
+		It("Should reject an approval by a user not allowed to approve", func() {
+			old := newAccessRequest()
+			err := validator.ValidateUpdate(requestContext(admissionv1.Update, "max", old), old, approve(old, "max"))
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("user max cannot approve accessrequests in namespace default"))
+		})
+
+		It("Should reject an approval on behalf of another user", func() {
+			old := newAccessRequest()
+			err := validator.ValidateUpdate(requestContext(admissionv1.Update, "max", old), old, approve(old, "jane"))
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("status.approval.approver"))
+		})
+
+		It("Should reject changing a decision once given", func() {
+			approved := approve(newAccessRequest(), "jane")
+			denied := approved.DeepCopy()
+			denied.Status.Approval.Approved = false
+			err := validator.ValidateUpdate(requestContext(admissionv1.Update, "jane", approved), approved, denied)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("cannot be changed once given"))
+
+			updated := approved.DeepCopy()
+			updated.Status.Phase = AccessRequestActive
+			Expect(validator.ValidateUpdate(requestContext(admissionv1.Update, "system:serviceaccount:kuadra-system:controller-manager", approved), approved, updated)).Should(Succeed())
+		})
+	})
+
+	Context("When an AccessRequest is approved", func() {
+		It("Should be active for the requested duration from the approval", func() {
+			accessRequest := newAccessRequest()
+			Expect(accessRequest.Phase(approvedAt)).Should(Equal(AccessRequestPending))
+
+			accessRequest.Status.Approval = &AccessRequestApproval{Approved: true, Approver: "jane", Time: metav1.NewTime(approvedAt)}
+			Expect(accessRequest.GrantExpiresAt()).Should(Equal(approvedAt.Add(time.Hour)))
+			Expect(accessRequest.Phase(approvedAt.Add(59 * time.Minute))).Should(Equal(AccessRequestActive))
+			Expect(accessRequest.Phase(approvedAt.Add(time.Hour))).Should(Equal(AccessRequestExpired))
+
+			accessRequest.Status.Approval.Approved = false
+			Expect(accessRequest.Phase(approvedAt)).Should(Equal(AccessRequestDenied))
+			Expect(accessRequest.GrantActive(approvedAt)).Should(BeFalse())
+		})
+	})
+})
+
+// fakeApprovalAuthorizer allows a fixed list of users to approve AccessRequests
+type fakeApprovalAuthorizer struct {
+	approvers []string
+}
+
+func (a *fakeApprovalAuthorizer) CanApprove(ctx context.Context, user authenticationv1.UserInfo, accessRequest *AccessRequest) (bool, error) {
+	return containsString(a.approvers, user.Username), nil
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,8 +29,254 @@ type AwsAccountSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	UserName string   `json:"userName"`
-	Groups   []string `json:"groups"`
+	// UserName is the name of the IAM user, up to 64 alphanumeric characters or any of '_+=,.@-'.
+	// It cannot be changed once the AwsAccount is created.
+	UserName string `json:"userName"`
+	// Groups are the IAM groups the user is a member of, each listed once
+	Groups []string `json:"groups"`
+	// GroupWindows are the IAM groups the user is a member of during a window of time only. A group
+	// is listed either here or in groups.
+	// +optional
+	GroupWindows []GroupWindow `json:"groupWindows,omitempty"`
+
+	// AccessKeyRotation enables periodic rotation of the IAM user's access key
+	// +optional
+	AccessKeyRotation *AccessKeyRotation `json:"accessKeyRotation,omitempty"`
+
+	// HostedZone is a Route53 hosted zone created for the user
+	// +optional
+	HostedZone *HostedZone `json:"hostedZone,omitempty"`
+
+	// ManagedPolicyArns are the managed IAM policies attached to the user
+	// +optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
+
+	// InlinePolicies are the inline IAM policies embedded in the user
+	// +optional
+	InlinePolicies []InlinePolicy `json:"inlinePolicies,omitempty"`
+
+	// PermissionsBoundaryArn is the managed policy used as the user's permissions boundary.
+	// Defaults to the boundary configured on the controller, and can only be one of the
+	// boundaries the controller allows instead.
+	// +optional
+	PermissionsBoundaryArn string `json:"permissionsBoundaryArn,omitempty"`
+
+	// Tags are added to the IAM user alongside the tags kuadra uses to mark the users it manages
+	// +kubebuilder:validation:MaxProperties=40
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Adopt allows taking over an existing IAM user that was not created by kuadra.
+	// IAM users managed by another AwsAccount are never adopted.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+
+	// DeletionPolicy determines what happens to the IAM user and namespace when the AwsAccount is deleted
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Namespace configures the namespace holding the user's Secrets. Defaults to a namespace
+	// named after userName, sanitized to a valid namespace name.
+	// +optional
+	Namespace *NamespaceSpec `json:"namespace,omitempty"`
+
+	// Suspended deactivates the IAM user's access keys, deletes its login profile and removes it
+	// from its groups, keeping the user itself. Clearing it restores them.
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// GroupWindow is the membership of an IAM group limited to a window of time. Unknown fields are
+// kept so that a misspelled bound is refused rather than dropped.
+// +kubebuilder:pruning:PreserveUnknownFields
+type GroupWindow struct {
+	// Name is the name of the IAM group
+	Name string `json:"name"`
+	// NotBefore is when the user is added to the group
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+	// NotAfter is when the user is removed from the group
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// invalidValue is the value the window was decoded from when it has fields other than the ones
+	// above or is not a window at all. It is written back as it was, so that the window is refused
+	// rather than losing a misspelled bound.
+	invalidValue []byte `json:"-"`
+}
+
+// NamespaceSpec configures the namespace holding an AwsAccount's Secrets
+type NamespaceSpec struct {
+	// Name is the namespace to use. It is created by kuadra, a namespace that kuadra did not
+	// create for the AwsAccount is refused unless the operator allows it, in which case it is
+	// used as it is, without the labels and annotations below.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	Name string `json:"name,omitempty"`
+	// UseAccountNamespace puts the Secrets in the AwsAccount's own namespace instead of creating one
+	// +optional
+	UseAccountNamespace bool `json:"useAccountNamespace,omitempty"`
+	// Labels are added to the namespace
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the namespace
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Template is the name of a NamespaceTemplate whose objects are rendered into the namespace.
+	// Whoever sets it or kubernetesUser must be allowed to bind the roles the template binds.
+	// +optional
+	Template string `json:"template,omitempty"`
+	// KubernetesUser is the user's Kubernetes identity, bound by the template's RoleBindings.
+	// Defaults to userName. Teams always bind the userName.
+	// +optional
+	KubernetesUser string `json:"kubernetesUser,omitempty"`
+}
+
+// DeletionPolicy determines what happens to the resources of an AwsAccount when it is deleted
+// +kubebuilder:validation:Enum=Delete;Retain;DisableOnly
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the IAM user, the namespace and the hosted zone
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps every resource and only removes the finalizer
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDisableOnly deletes the IAM user's access keys, login profile and credential Secrets,
+	// keeping the user for audit along with the namespace and the hosted zone
+	DeletionPolicyDisableOnly DeletionPolicy = "DisableOnly"
+)
+
+// HostedZonePolicyName is the inline policy granting a user DNS record rights on their own hosted
+// zone. It is managed alongside the hosted zone, so inline policies in the spec cannot use the name.
+const HostedZonePolicyName = "kuadra-hosted-zone"
+
+// InlinePolicy is an inline IAM policy, given either as a document or a reference to a ConfigMap key
+type InlinePolicy struct {
+	// Name is the name of the inline policy. kuadra-hosted-zone is reserved for the hosted zone policy.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=128
+	Name string `json:"name"`
+	// Document is the JSON policy document
+	// +optional
+	Document string `json:"document,omitempty"`
+	// ConfigMapKeyRef selects the policy document from a ConfigMap in the AwsAccount's namespace
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// AccessKeyRotation configures when the access key in the aws-credentials Secret is replaced
+type AccessKeyRotation struct {
+	// MaxAge is the age at which the current access key is replaced by a new one
+	MaxAge metav1.Duration `json:"maxAge"`
+	// GracePeriod is how long the previous access key is kept active after a rotation,
+	// before it is deactivated and deleted
+	// +optional
+	GracePeriod metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// Condition types reported on AwsAccountStatus.Conditions
+const (
+	// ConditionReady is True once every resource managed for the account is provisioned
+	ConditionReady = "Ready"
+	// ConditionIamUserReady is True once the IAM user exists
+	ConditionIamUserReady = "IamUserReady"
+	// ConditionLoginProfileReady is True once the IAM user has a console login profile
+	ConditionLoginProfileReady = "LoginProfileReady"
+	// ConditionAccessKeyReady is True once the IAM user has an access key stored in a Secret
+	ConditionAccessKeyReady = "AccessKeyReady"
+	// ConditionGroupsSynced is True once the IAM user's group membership matches the spec
+	ConditionGroupsSynced = "GroupsSynced"
+	// ConditionNamespaceReady is True once the namespace holding the user's Secrets exists
+	ConditionNamespaceReady = "NamespaceReady"
+	// ConditionPoliciesSynced is True once the IAM user's managed and inline policies match the spec
+	ConditionPoliciesSynced = "PoliciesSynced"
+	// ConditionHostedZoneReady is True once the hosted zone exists, is published in the user's namespace
+	// and the user has been granted DNS record rights on it
+	ConditionHostedZoneReady = "HostedZoneReady"
+	// ConditionNamespaceTemplateApplied is True once the objects of spec.namespace.template are rendered into the namespace
+	ConditionNamespaceTemplateApplied = "NamespaceTemplateApplied"
+	// ConditionSuspended is True while the account's access is suspended. It is reported by every
+	// account kind once it has been suspended.
+	ConditionSuspended = "Suspended"
+	// ConditionConflict is True when an older AwsAccount claims the same IAM user, in which case
+	// the AwsAccount is not reconciled until the conflict is resolved
+	ConditionConflict = "Conflict"
+)
+
+// Condition reasons shared by the AwsAccount conditions. Failures use the
+// error code returned by the AWS API where one is available.
+const (
+	ReasonProvisioned  = "Provisioned"
+	ReasonProvisioning = "Provisioning"
+	ReasonFailed       = "Failed"
+	// ReasonUserNotManaged means the IAM user exists but is not tagged as managed by this AwsAccount
+	ReasonUserNotManaged = "UserNotManaged"
+	// ReasonSuspended means the account's access is suspended by spec.suspended
+	ReasonSuspended = "Suspended"
+	// ReasonExpired means the User's spec.expiresAt has passed
+	ReasonExpired = "Expired"
+	// ReasonReactivated means the account's access was restored after a suspension
+	ReasonReactivated = "Reactivated"
+	// ReasonConflict means another AwsAccount claims the same IAM user
+	ReasonConflict = "Conflict"
+	// ReasonGroupNotFound means a group in the spec does not exist in IAM
+	ReasonGroupNotFound = "GroupNotFound"
+	// ReasonNotAllowed means the spec asks for something the operator's configuration does not allow
+	ReasonNotAllowed = "NotAllowed"
+)
+
+// HostedZone configures the Route53 hosted zone created for the user
+type HostedZone struct {
+	// Name is the domain name of the hosted zone, e.g. jdoe.example.com
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// ParentHostedZoneId is the id of an existing hosted zone to delegate Name from.
+	// When set, an NS record pointing at the new zone's name servers is created in it.
+	// The zone must be one the operator allows delegating from, and Name a subdomain of it.
+	// +optional
+	ParentHostedZoneId string `json:"parentHostedZoneId,omitempty"`
+}
+
+// GroupWindowState is where a time-bound group membership stands relative to its window
+type GroupWindowState string
+
+const (
+	// GroupWindowScheduled means the window has not started yet
+	GroupWindowScheduled GroupWindowState = "Scheduled"
+	// GroupWindowActive means the user is a member of the group for as long as the window lasts
+	GroupWindowActive GroupWindowState = "Active"
+	// GroupWindowEnded means the window has ended and the user is no longer a member
+	GroupWindowEnded GroupWindowState = "Ended"
+)
+
+// GroupWindowStatus reports the window of a time-bound group membership
+type GroupWindowStatus struct {
+	// Name is the name of the IAM group
+	Name string `json:"name"`
+	// NotBefore is when the membership starts
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+	// NotAfter is when the membership ends
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+	// State is where the membership stands relative to its window
+	State GroupWindowState `json:"state"`
+}
+
+// AccessGrant is temporary access granted to an AwsAccount by an approved AccessRequest
+type AccessGrant struct {
+	// AccessRequest is the name of the AccessRequest granting the access
+	AccessRequest string `json:"accessRequest"`
+	// Groups are the IAM groups the IAM user is added to
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+	// ManagedPolicyArns are the managed IAM policies attached to the IAM user
+	// +optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
+	// ExpiresAt is when the access ends
+	ExpiresAt metav1.Time `json:"expiresAt"`
 }
 
 // AwsAccountStatus defines the observed state of AwsAccount
@@ -49,12 +296,105 @@ type AwsAccountStatus struct {
 	// +optional
 	UserGroups []string `json:"userGroups"`
 
+	// GroupWindows are the windows of the time-bound group memberships in the spec
+	// +optional
+	GroupWindows []GroupWindowStatus `json:"groupWindows,omitempty"`
+
 	// +optional
 	NamespaceCreated bool `json:"namespaceCreated"`
+
+	// Namespace is the namespace holding the user's Secrets
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// +optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
+	// +optional
+	InlinePolicies []string `json:"inlinePolicies,omitempty"`
+
+	// PermissionsBoundaryArn is the permissions boundary in effect on the IAM user
+	// +optional
+	PermissionsBoundaryArn string `json:"permissionsBoundaryArn,omitempty"`
+
+	// Tags are the tags from spec.tags applied to the IAM user
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// AccessKeyRotation records the access keys managed by rotation
+	// +optional
+	AccessKeyRotation *AccessKeyRotationStatus `json:"accessKeyRotation,omitempty"`
+
+	// HostedZone describes the Route53 hosted zone created for the user
+	// +optional
+	HostedZone *HostedZoneStatus `json:"hostedZone,omitempty"`
+
+	// Suspended is true while the IAM user's access is suspended
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+
+	// SuspendedAccessKeyIds are the access keys deactivated by the suspension, which are
+	// activated again when it ends
+	// +optional
+	SuspendedAccessKeyIds []string `json:"suspendedAccessKeyIds,omitempty"`
+
+	// Grants are the accesses granted by approved AccessRequests that the IAM user currently has
+	// +optional
+	Grants []AccessGrant `json:"grants,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of each resource managed for the account
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// AccessKeyRotationStatus defines the observed state of access key rotation
+type AccessKeyRotationStatus struct {
+	// CurrentAccessKeyId is the access key stored in the aws-credentials Secret
+	// +optional
+	CurrentAccessKeyId string `json:"currentAccessKeyId,omitempty"`
+	// CurrentAccessKeyCreated is when the current access key was created
+	// +optional
+	CurrentAccessKeyCreated *metav1.Time `json:"currentAccessKeyCreated,omitempty"`
+	// PreviousAccessKeyId is the replaced access key, kept active until the grace period ends
+	// +optional
+	PreviousAccessKeyId string `json:"previousAccessKeyId,omitempty"`
+	// LastRotationTime is when the access key was last replaced
+	// +optional
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// PreviousAccessKeyDeleted is when the previous access key was deactivated and deleted
+	// +optional
+	PreviousAccessKeyDeleted *metav1.Time `json:"previousAccessKeyDeleted,omitempty"`
+}
+
+// HostedZoneStatus defines the observed state of the user's hosted zone
+type HostedZoneStatus struct {
+	// Id is the Route53 id of the hosted zone
+	Id string `json:"id"`
+	// Name is the fully qualified domain name of the hosted zone
+	Name string `json:"name"`
+	// NameServers are the name servers Route53 assigned to the hosted zone
+	// +optional
+	NameServers []string `json:"nameServers,omitempty"`
+	// ParentHostedZoneId is the hosted zone holding the delegation NS record, if any
+	// +optional
+	ParentHostedZoneId string `json:"parentHostedZoneId,omitempty"`
+	// PolicyName is the inline IAM policy granting the user DNS record rights on the hosted zone
+	// +optional
+	PolicyName string `json:"policyName,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="User Name",type="string",JSONPath=".spec.userName"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AwsAccount is the Schema for the awsaccounts API
 type AwsAccount struct {
@@ -17,18 +17,66 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 // log is for logging in this package.
 var awsaccountlog = logf.Log.WithName("awsaccount-resource")
 
-func (r *AwsAccount) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// AwsAccountUserNameField indexes AwsAccounts by the IAM user they claim. It is registered by the
+// AwsAccount controller and used by the validating webhook to keep IAM user names unique.
+const AwsAccountUserNameField = "spec.userName"
+
+// AwsAccountUserNameIndex returns the AwsAccountUserNameField of an AwsAccount. IAM user names are
+// case-insensitive, so the name is lowercased.
+func AwsAccountUserNameIndex(obj client.Object) []string {
+	awsAccount, ok := obj.(*AwsAccount)
+	if !ok || awsAccount.Spec.UserName == "" {
+		return nil
+	}
+	return []string{strings.ToLower(awsAccount.Spec.UserName)}
+}
+
+// IamGroups looks up the IAM groups that spec.groups refers to
+// +kubebuilder:object:generate=false
+type IamGroups interface {
+	GroupExists(ctx context.Context, groupName string) (bool, error)
+	ClosestGroupName(ctx context.Context, groupName string) (string, error)
+}
+
+// roleBinder decides whether a user may bind a role, which the RoleBindings of a NamespaceTemplate
+// do on behalf of whoever references the template
+// +kubebuilder:object:generate=false
+type roleBinder interface {
+	CanBind(ctx context.Context, user authenticationv1.UserInfo, roleRef rbacv1.RoleRef) (bool, error)
+}
+
+// SetupWebhookWithManager registers the AwsAccount webhooks. The groups of an AwsAccount are checked
+// against IAM unless groups is nil.
+func (r *AwsAccount) SetupWebhookWithManager(mgr ctrl.Manager, groups IamGroups) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
+		WithValidator(&awsAccountValidator{
+			Client: mgr.GetClient(),
+			Groups: groups,
+			Binder: &subjectAccessReviewAuthorizer{Client: mgr.GetClient()},
+		}).
 		Complete()
 }
 
@@ -42,7 +90,9 @@ var _ webhook.Defaulter = &AwsAccount{}
 func (r *AwsAccount) Default() {
 	awsaccountlog.Info("default", "name", r.Name)
 
-	// TODO(user): fill in your defaulting logic.
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyDelete
+	}
 }
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
@@ -50,20 +100,160 @@ func (r *AwsAccount) Default() {
 
 var _ webhook.Validator = &AwsAccount{}
 
+// IAM naming rules, see https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_iam-quotas.html
+const (
+	iamUserNameMaxLength  = 64
+	iamGroupNameMaxLength = 128
+)
+
+// iamNamePattern matches the characters IAM allows in user and group names
+var iamNamePattern = regexp.MustCompile(`^[\w+=,.@-]+$`)
+
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *AwsAccount) ValidateCreate() error {
 	awsaccountlog.Info("validate create", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object creation.
-	return nil
+	return r.toInvalidError(validateAwsAccountSpec(&r.Spec, field.NewPath("spec")))
+}
+
+// validateAwsAccountSpec checks the IAM user and group names and the namespace of an AwsAccount spec
+func validateAwsAccountSpec(spec *AwsAccountSpec, path *field.Path) field.ErrorList {
+	allErrs := validateIamName(spec.UserName, iamUserNameMaxLength, path.Child("userName"))
+	allErrs = append(allErrs, validateGroupMemberships(spec, path)...)
+	allErrs = append(allErrs, validateInlinePolicies(spec.InlinePolicies, path.Child("inlinePolicies"))...)
+	allErrs = append(allErrs, validateNamespace(spec.Namespace, path.Child("namespace"))...)
+	return allErrs
+}
+
+// validateIamName checks a name against the length and characters IAM allows
+func validateIamName(name string, maxLength int, path *field.Path) field.ErrorList {
+	if name == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	var allErrs field.ErrorList
+	if len(name) > maxLength {
+		allErrs = append(allErrs, field.TooLongMaxLength(path, name, maxLength))
+	}
+	if !iamNamePattern.MatchString(name) {
+		allErrs = append(allErrs, field.Invalid(path, name, "must consist of alphanumeric characters or any of '_+=,.@-'"))
+	}
+	return allErrs
+}
+
+// validateGroups checks that the IAM group names are valid and listed once
+func validateGroups(groups []string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[string]bool{}
+	for i, group := range groups {
+		allErrs = append(allErrs, validateIamName(group, iamGroupNameMaxLength, path.Index(i))...)
+		if seen[group] {
+			allErrs = append(allErrs, field.Duplicate(path.Index(i), group))
+		}
+		seen[group] = true
+	}
+	return allErrs
+}
+
+// validateGroupMemberships checks that the IAM group names of groups and groupWindows are valid and
+// listed once, that the windows are valid objects and that each window ends after it starts
+func validateGroupMemberships(spec *AwsAccountSpec, path *field.Path) field.ErrorList {
+	allErrs := validateGroups(spec.Groups, path.Child("groups"))
+	seen := map[string]bool{}
+	for _, group := range spec.Groups {
+		seen[group] = true
+	}
+	for i, window := range spec.GroupWindows {
+		windowPath := path.Child("groupWindows").Index(i)
+		if err := window.invalidError(); err != nil {
+			allErrs = append(allErrs, field.Invalid(windowPath, string(window.invalidValue), err.Error()))
+			continue
+		}
+		allErrs = append(allErrs, validateIamName(window.Name, iamGroupNameMaxLength, windowPath.Child("name"))...)
+		if seen[window.Name] {
+			allErrs = append(allErrs, field.Duplicate(windowPath.Child("name"), window.Name))
+		}
+		seen[window.Name] = true
+		switch {
+		case window.NotBefore == nil && window.NotAfter == nil:
+			allErrs = append(allErrs, field.Required(windowPath.Child("notAfter"), "a window needs notBefore or notAfter, list groups without one in groups"))
+		case window.NotBefore != nil && window.NotAfter != nil && !window.NotAfter.After(window.NotBefore.Time):
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("notAfter"), window.NotAfter.UTC().Format(time.RFC3339), "must be after notBefore"))
+		}
+	}
+	return allErrs
+}
+
+// validateInlinePolicies refuses inline policies named like the policy managed with the hosted zone,
+// which would overwrite each other on every reconcile
+func validateInlinePolicies(inlinePolicies []InlinePolicy, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, inlinePolicy := range inlinePolicies {
+		if inlinePolicy.Name == HostedZonePolicyName {
+			allErrs = append(allErrs, field.Invalid(path.Index(i).Child("name"), inlinePolicy.Name, "is reserved for the hosted zone policy"))
+		}
+	}
+	return allErrs
+}
+
+// validateNamespace checks that spec.namespace does not ask for both a named and the AwsAccount's own namespace
+func validateNamespace(namespace *NamespaceSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if namespace != nil && namespace.UseAccountNamespace && namespace.Name != "" {
+		allErrs = append(allErrs, field.Invalid(path.Child("name"), namespace.Name,
+			"must not be set together with useAccountNamespace"))
+	}
+	return allErrs
+}
+
+// toInvalidError wraps validation errors in an Invalid API error, or returns nil when there are none
+func (r *AwsAccount) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("AwsAccount").GroupKind(), r.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *AwsAccount) ValidateUpdate(old runtime.Object) error {
 	awsaccountlog.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
-	return nil
+	oldAwsAccount, ok := old.(*AwsAccount)
+	if !ok {
+		return nil
+	}
+
+	path := field.NewPath("spec")
+	var allErrs field.ErrorList
+	if r.Spec.UserName != oldAwsAccount.Spec.UserName {
+		allErrs = append(allErrs, field.Forbidden(path.Child("userName"),
+			"is immutable, changing it would leave the IAM user "+oldAwsAccount.Spec.UserName+" and its namespace behind; create a new AwsAccount instead"))
+	}
+	// Fields that did not change are not validated again, so that an AwsAccount created before a rule
+	// was introduced can still be updated, e.g. to remove its finalizer
+	if !reflect.DeepEqual(r.Spec.Groups, oldAwsAccount.Spec.Groups) || !reflect.DeepEqual(r.Spec.GroupWindows, oldAwsAccount.Spec.GroupWindows) {
+		allErrs = append(allErrs, validateGroupMemberships(&r.Spec, path)...)
+	}
+	if !reflect.DeepEqual(r.Spec.InlinePolicies, oldAwsAccount.Spec.InlinePolicies) {
+		allErrs = append(allErrs, validateInlinePolicies(r.Spec.InlinePolicies, path.Child("inlinePolicies"))...)
+	}
+	allErrs = append(allErrs, validateNamespace(r.Spec.Namespace, path.Child("namespace"))...)
+	if r.DeletionTimestamp != nil && deletionPolicyRank(r.Spec.DeletionPolicy) > deletionPolicyRank(oldAwsAccount.Spec.DeletionPolicy) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "deletionPolicy"),
+			"cannot change from "+string(oldAwsAccount.Spec.DeletionPolicy)+" to "+string(r.Spec.DeletionPolicy)+" while the AwsAccount is being deleted"))
+	}
+	return r.toInvalidError(allErrs)
+}
+
+// deletionPolicyRank orders deletion policies from the one keeping the most resources to the one keeping the least
+func deletionPolicyRank(deletionPolicy DeletionPolicy) int {
+	switch deletionPolicy {
+	case DeletionPolicyRetain:
+		return 0
+	case DeletionPolicyDisableOnly:
+		return 1
+	default:
+		return 2
+	}
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -73,3 +263,214 @@ func (r *AwsAccount) ValidateDelete() error {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil
 }
+
+// awsAccountValidator validates AwsAccounts like the AwsAccount's own webhook.Validator methods, and
+// also refuses an AwsAccount claiming an IAM user that another AwsAccount in the cluster claims,
+// referring to IAM groups that do not exist, or using a NamespaceTemplate that binds roles the
+// requesting user cannot bind
+type awsAccountValidator struct {
+	Client client.Reader
+	Groups IamGroups
+	Binder roleBinder
+}
+
+var _ webhook.CustomValidator = &awsAccountValidator{}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *awsAccountValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	awsAccount, ok := obj.(*AwsAccount)
+	if !ok {
+		return fmt.Errorf("expected an AwsAccount but got a %T", obj)
+	}
+	if err := awsAccount.ValidateCreate(); err != nil {
+		return err
+	}
+	if err := awsAccount.validateUniqueUserName(ctx, v.Client); err != nil {
+		return err
+	}
+	if err := awsAccount.validateAccess(ctx, v.Client, Access{
+		Services:          []string{ServiceAws},
+		Groups:            awsAccount.Spec.GroupNames(),
+		ManagedPolicyArns: awsAccount.Spec.ManagedPolicyArns,
+	}); err != nil {
+		return err
+	}
+	if err := awsAccount.validateNamespaceTemplate(ctx, v.Client, v.Binder); err != nil {
+		return err
+	}
+	return awsAccount.validateGroupsExist(ctx, v.Groups, nil)
+}
+
+// ValidateUpdate implements webhook.CustomValidator. As spec.userName cannot change, its uniqueness
+// is only checked on creation. Only the groups and managed policies added by the update are checked
+// against the AccessPolicies and IAM, and the NamespaceTemplate only when it or the Kubernetes user
+// it binds changes.
+func (v *awsAccountValidator) ValidateUpdate(ctx context.Context, oldObj runtime.Object, newObj runtime.Object) error {
+	awsAccount, ok := newObj.(*AwsAccount)
+	if !ok {
+		return fmt.Errorf("expected an AwsAccount but got a %T", newObj)
+	}
+	oldAwsAccount, ok := oldObj.(*AwsAccount)
+	if !ok {
+		return fmt.Errorf("expected an AwsAccount but got a %T", oldObj)
+	}
+	if err := awsAccount.ValidateUpdate(oldAwsAccount); err != nil {
+		return err
+	}
+	if err := awsAccount.validateAccess(ctx, v.Client, Access{
+		Groups:            addedValues(awsAccount.Spec.GroupNames(), oldAwsAccount.Spec.GroupNames()),
+		ManagedPolicyArns: addedValues(awsAccount.Spec.ManagedPolicyArns, oldAwsAccount.Spec.ManagedPolicyArns),
+	}); err != nil {
+		return err
+	}
+	if namespaceTemplateBinding(awsAccount.Spec.Namespace) != namespaceTemplateBinding(oldAwsAccount.Spec.Namespace) {
+		if err := awsAccount.validateNamespaceTemplate(ctx, v.Client, v.Binder); err != nil {
+			return err
+		}
+	}
+	return awsAccount.validateGroupsExist(ctx, v.Groups, oldAwsAccount.Spec.GroupNames())
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *awsAccountValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	awsAccount, ok := obj.(*AwsAccount)
+	if !ok {
+		return fmt.Errorf("expected an AwsAccount but got a %T", obj)
+	}
+	return awsAccount.ValidateDelete()
+}
+
+// validateUniqueUserName refuses an IAM user name that another AwsAccount already claims
+func (r *AwsAccount) validateUniqueUserName(ctx context.Context, c client.Reader) error {
+	var awsAccounts AwsAccountList
+	if err := c.List(ctx, &awsAccounts, client.MatchingFields{AwsAccountUserNameField: strings.ToLower(r.Spec.UserName)}); err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	for _, other := range awsAccounts.Items {
+		if other.Namespace == r.Namespace && other.Name == r.Name {
+			continue
+		}
+		duplicate := field.Duplicate(field.NewPath("spec", "userName"), r.Spec.UserName)
+		duplicate.Detail = "IAM user is already claimed by AwsAccount " + other.Namespace + "/" + other.Name
+		return r.toInvalidError(field.ErrorList{duplicate})
+	}
+	return nil
+}
+
+// validateAccess refuses the access that the AccessPolicies deny in the AwsAccount's namespace
+func (r *AwsAccount) validateAccess(ctx context.Context, c client.Reader, access Access) error {
+	allErrs, err := accessPolicyErrors(ctx, c, r.Namespace, access, map[string]*field.Path{ServiceAws: field.NewPath("spec")}, field.NewPath("spec"))
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	return r.toInvalidError(allErrs)
+}
+
+// validateNamespaceTemplate refuses a NamespaceTemplate binding roles that the requesting user cannot bind.
+// The AwsAccounts of Users are written by the User controller and admitted as its ServiceAccount, so for
+// them the check of the User webhook is the one that applies.
+func (r *AwsAccount) validateNamespaceTemplate(ctx context.Context, c client.Reader, binder roleBinder) error {
+	if r.Spec.Namespace == nil {
+		return nil
+	}
+	allErrs, err := validateNamespaceTemplateBindings(ctx, c, binder, r.Spec.Namespace.Template, field.NewPath("spec", "namespace", "template"))
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	return r.toInvalidError(allErrs)
+}
+
+// templateBinding is a NamespaceTemplate and the Kubernetes user its RoleBindings bind, which
+// together decide the roles granted to that user
+type templateBinding struct {
+	Template       string
+	KubernetesUser string
+}
+
+// namespaceTemplateBinding returns the templateBinding of spec.namespace
+func namespaceTemplateBinding(namespace *NamespaceSpec) templateBinding {
+	if namespace == nil {
+		return templateBinding{}
+	}
+	return templateBinding{Template: namespace.Template, KubernetesUser: namespace.KubernetesUser}
+}
+
+// validateNamespaceTemplateBindings refuses a NamespaceTemplate whose RoleBindings bind a role that
+// the requesting user cannot bind. Like for a RoleBinding created directly, referencing the template
+// must not grant more than the requesting user could grant themselves. The check is skipped when
+// binder is nil. The User webhook is the only place this is checked for the AwsAccounts of Users, as
+// the User controller writes them and the AwsAccount webhook sees the controller's ServiceAccount.
+func validateNamespaceTemplateBindings(ctx context.Context, c client.Reader, binder roleBinder, templateName string, path *field.Path) (field.ErrorList, error) {
+	if binder == nil || templateName == "" {
+		return nil, nil
+	}
+	template := &NamespaceTemplate{}
+	if err := c.Get(ctx, client.ObjectKey{Name: templateName}, template); err != nil {
+		if apierrors.IsNotFound(err) {
+			return field.ErrorList{field.NotFound(path, templateName)}, nil
+		}
+		return nil, err
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user := req.UserInfo
+	var allErrs field.ErrorList
+	for _, roleBinding := range template.Spec.RoleBindings {
+		allowed, err := binder.CanBind(ctx, user, roleBinding.RoleRef)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			allErrs = append(allErrs, field.Forbidden(path, "user "+user.Username+" cannot bind "+roleBinding.RoleRef.Kind+" "+
+				roleBinding.RoleRef.Name+", which NamespaceTemplate "+templateName+" binds"))
+		}
+	}
+	return allErrs, nil
+}
+
+// validateGroupsExist refuses IAM groups that do not exist, suggesting the closest existing group
+// name. The groups in knownGroups are not checked again. Failing to look up the groups does not
+// block admission, the AwsAccount controller reports unknown groups in that case.
+func (r *AwsAccount) validateGroupsExist(ctx context.Context, groups IamGroups, knownGroups []string) error {
+	if groups == nil {
+		return nil
+	}
+	var paths []*field.Path
+	for i := range r.Spec.Groups {
+		paths = append(paths, field.NewPath("spec", "groups").Index(i))
+	}
+	for i := range r.Spec.GroupWindows {
+		paths = append(paths, field.NewPath("spec", "groupWindows").Index(i).Child("name"))
+	}
+	var allErrs field.ErrorList
+	for i, group := range r.Spec.GroupNames() {
+		if containsString(knownGroups, group) {
+			continue
+		}
+		exists, err := groups.GroupExists(ctx, group)
+		if err != nil {
+			awsaccountlog.Error(err, "unable to look up IAM group, admitting the AwsAccount", "name", r.Name, "groupName", group)
+			return nil
+		}
+		if exists {
+			continue
+		}
+		detail := "IAM group does not exist"
+		if closest, err := groups.ClosestGroupName(ctx, group); err == nil && closest != "" {
+			detail += ", did you mean " + closest + "?"
+		}
+		allErrs = append(allErrs, field.Invalid(paths[i], group, detail))
+	}
+	return r.toInvalidError(allErrs)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package v1
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("AwsAccount webhook", func() {
+
+	newAwsAccount := func(deletionPolicy DeletionPolicy) *AwsAccount {
+		return &AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "awsaccount-webhook",
+				Namespace: "default",
+			},
+			Spec: AwsAccountSpec{
+				UserName:       "webhook-user",
+				DeletionPolicy: deletionPolicy,
+			},
+		}
+	}
+
+	Context("When defaulting an AwsAccount", func() {
+		It("Should default the deletion policy to Delete", func() {
+			awsAccount := newAwsAccount("")
+			awsAccount.Default()
+			Expect(awsAccount.Spec.DeletionPolicy).Should(Equal(DeletionPolicyDelete))
+		})
+	})
+
+	Context("When updating the deletion policy", func() {
+		It("Should allow any change before deletion", func() {
+			Expect(newAwsAccount(DeletionPolicyDelete).ValidateUpdate(newAwsAccount(DeletionPolicyRetain))).Should(Succeed())
+		})
+
+		It("Should only allow keeping more resources during deletion", func() {
+			old := newAwsAccount(DeletionPolicyRetain)
+			old.DeletionTimestamp = &metav1.Time{}
+
+			relaxed := old.DeepCopy()
+			relaxed.Spec.DeletionPolicy = DeletionPolicyDelete
+			err := relaxed.ValidateUpdate(old)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.deletionPolicy"))
+
+			old.Spec.DeletionPolicy = DeletionPolicyDelete
+			tightened := old.DeepCopy()
+			tightened.Spec.DeletionPolicy = DeletionPolicyDisableOnly
+			Expect(tightened.ValidateUpdate(old)).Should(Succeed())
+		})
+	})
+
+	Context("When validating IAM names", func() {
+		It("Should reject user names IAM does not allow", func() {
+			for _, userName := range []string{"", "jane smith", "jdoe/admin", strings.Repeat("a", 65)} {
+				awsAccount := newAwsAccount(DeletionPolicyDelete)
+				awsAccount.Spec.UserName = userName
+				err := awsAccount.ValidateCreate()
+				Expect(apierrors.IsInvalid(err)).Should(BeTrue(), userName)
+				Expect(err.Error()).Should(ContainSubstring("spec.userName"))
+			}
+
+			for _, userName := range []string{"jdoe", "Jane.Smith@example.com", "svc_ci+deploy=1,a-b", strings.Repeat("a", 64)} {
+				awsAccount := newAwsAccount(DeletionPolicyDelete)
+				awsAccount.Spec.UserName = userName
+				Expect(awsAccount.ValidateCreate()).Should(Succeed(), userName)
+			}
+		})
+
+		It("Should reject invalid and duplicate group names", func() {
+			awsAccount := newAwsAccount(DeletionPolicyDelete)
+			awsAccount.Spec.Groups = []string{"developers", "dns management", strings.Repeat("g", 129), "developers"}
+			err := awsAccount.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			causes := err.(*apierrors.StatusError).Status().Details.Causes
+			Expect(causes).Should(HaveLen(3))
+			Expect(err.Error()).Should(ContainSubstring("spec.groups[1]"))
+			Expect(err.Error()).Should(ContainSubstring("spec.groups[2]"))
+			Expect(err.Error()).Should(ContainSubstring(`spec.groups[3]: Duplicate value: "developers"`))
+		})
+
+		It("Should reject inline policies named like the hosted zone policy", func() {
+			awsAccount := newAwsAccount(DeletionPolicyDelete)
+			awsAccount.Spec.InlinePolicies = []InlinePolicy{{Name: "buckets", Document: "{}"}, {Name: HostedZonePolicyName, Document: "{}"}}
+			err := awsAccount.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.inlinePolicies[1].name"))
+			Expect(err.Error()).Should(ContainSubstring("reserved for the hosted zone policy"))
+		})
+	})
+
+	Context("When updating an AwsAccount", func() {
+		It("Should reject a change of user name", func() {
+			old := newAwsAccount(DeletionPolicyDelete)
+			renamed := old.DeepCopy()
+			renamed.Spec.UserName = "renamed-user"
+			err := renamed.ValidateUpdate(old)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.userName: Forbidden: is immutable"))
+		})
+
+		It("Should reject duplicate groups unless they are unchanged", func() {
+			old := newAwsAccount(DeletionPolicyDelete)
+			updated := old.DeepCopy()
+			updated.Spec.Groups = []string{"developers", "developers"}
+			err := updated.ValidateUpdate(old)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.groups[1]"))
+
+			unchanged := updated.DeepCopy()
+			unchanged.Finalizers = nil
+			Expect(unchanged.ValidateUpdate(updated)).Should(Succeed())
+		})
+	})
+
+	Context("When another AwsAccount claims the IAM user", func() {
+		It("Should reject the AwsAccount regardless of the case of the user name", func() {
+			existing := newAwsAccount(DeletionPolicyDelete)
+			validator := &awsAccountValidator{Client: newFakeClient(existing)}
+
+			claimant := newAwsAccount(DeletionPolicyDelete)
+			claimant.Namespace = "team-a"
+			claimant.Spec.UserName = "Webhook-User"
+			err := validator.ValidateCreate(context.Background(), claimant)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("claimed by AwsAccount default/awsaccount-webhook"))
+
+			claimant.Spec.UserName = "other-user"
+			Expect(validator.ValidateCreate(context.Background(), claimant)).Should(Succeed())
+			Expect(validator.ValidateCreate(context.Background(), existing)).Should(Succeed())
+		})
+	})
+
+	Context("When the groups are checked against IAM", func() {
+		groups := &fakeIamGroups{
+			names:       []string{"developers", "dns-management"},
+			suggestions: map[string]string{"dns-managment": "dns-management"},
+		}
+
+		It("Should reject unknown groups and suggest the closest one", func() {
+			awsAccount := newAwsAccount(DeletionPolicyDelete)
+			awsAccount.Spec.Groups = []string{"developers", "dns-managment"}
+			err := awsAccount.validateGroupsExist(context.Background(), groups, nil)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.groups[1]"))
+			Expect(err.Error()).Should(ContainSubstring("did you mean dns-management?"))
+			Expect(err.Error()).ShouldNot(ContainSubstring("spec.groups[0]"))
+		})
+
+		It("Should only check the groups added by an update", func() {
+			old := newAwsAccount(DeletionPolicyDelete)
+			old.Spec.Groups = []string{"deleted-group"}
+			updated := old.DeepCopy()
+			updated.Spec.Groups = append(updated.Spec.Groups, "developers")
+			validator := &awsAccountValidator{Client: newFakeClient(), Groups: groups}
+			Expect(validator.ValidateUpdate(context.Background(), old, updated)).Should(Succeed())
+
+			updated.Spec.Groups = append(updated.Spec.Groups, "qa")
+			Expect(apierrors.IsInvalid(validator.ValidateUpdate(context.Background(), old, updated))).Should(BeTrue())
+		})
+	})
+
+	Context("When validating the namespace", func() {
+		It("Should reject a namespace name together with useAccountNamespace", func() {
+			awsAccount := newAwsAccount(DeletionPolicyDelete)
+			awsAccount.Spec.Namespace = &NamespaceSpec{Name: "team-dns", UseAccountNamespace: true}
+			err := awsAccount.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.namespace.name"))
+
+			awsAccount.Spec.Namespace.Name = ""
+			Expect(awsAccount.ValidateCreate()).Should(Succeed())
+		})
+	})
+
+	Context("When the namespace template binds roles", func() {
+		template := &NamespaceTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin-template"},
+			Spec: NamespaceTemplateSpec{RoleBindings: []RoleBindingTemplate{{
+				Name:    "admin",
+				RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+			}}},
+		}
+		validator := &awsAccountValidator{
+			Client: newFakeClient(template),
+			Binder: &fakeRoleBinder{bindings: map[string][]string{"platform-admin": {"admin"}}},
+		}
+		userContext := func(userName string) context.Context {
+			return admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: userName},
+			}})
+		}
+
+		It("Should only accept users who can bind the roles themselves", func() {
+			awsAccount := newAwsAccount(DeletionPolicyDelete)
+			awsAccount.Spec.Namespace = &NamespaceSpec{Template: "admin-template"}
+			Expect(validator.ValidateCreate(userContext("platform-admin"), awsAccount)).Should(Succeed())
+
+			err := validator.ValidateCreate(userContext("developer"), awsAccount)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("user developer cannot bind ClusterRole admin"))
+		})
+
+		It("Should reject templates that do not exist", func() {
+			awsAccount := newAwsAccount(DeletionPolicyDelete)
+			awsAccount.Spec.Namespace = &NamespaceSpec{Template: "missing-template"}
+			err := validator.ValidateCreate(userContext("platform-admin"), awsAccount)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.namespace.template"))
+		})
+
+		It("Should check the template again when the bound Kubernetes user changes", func() {
+			old := newAwsAccount(DeletionPolicyDelete)
+			old.Spec.Namespace = &NamespaceSpec{Template: "admin-template"}
+			updated := old.DeepCopy()
+			updated.Spec.Suspended = true
+			Expect(validator.ValidateUpdate(userContext("developer"), old, updated)).Should(Succeed())
+
+			updated.Spec.Namespace.KubernetesUser = "developer"
+			Expect(apierrors.IsInvalid(validator.ValidateUpdate(userContext("developer"), old, updated))).Should(BeTrue())
+		})
+	})
+})
+
+// newFakeClient returns a fake client with the spec.userName index the webhooks rely on
+func newFakeClient(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	Expect(AddToScheme(scheme)).Should(Succeed())
+	Expect(corev1.AddToScheme(scheme)).Should(Succeed())
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithIndex(&AwsAccount{}, AwsAccountUserNameField, AwsAccountUserNameIndex).
+		WithObjects(objects...).
+		Build()
+}
+
+// fakeIamGroups knows the exact group names and suggests names from a fixed list
+type fakeIamGroups struct {
+	names       []string
+	suggestions map[string]string
+}
+
+func (g *fakeIamGroups) GroupExists(ctx context.Context, groupName string) (bool, error) {
+	return containsString(g.names, groupName), nil
+}
+
+func (g *fakeIamGroups) ClosestGroupName(ctx context.Context, groupName string) (string, error) {
+	return g.suggestions[groupName], nil
+}
+
+// fakeRoleBinder allows users to bind a fixed list of roles
+type fakeRoleBinder struct {
+	bindings map[string][]string
+}
+
+func (b *fakeRoleBinder) CanBind(ctx context.Context, user authenticationv1.UserInfo, roleRef rbacv1.RoleRef) (bool, error) {
+	return containsString(b.bindings[user.Username], roleRef.Name), nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GithubAccountSpec defines the desired state of GithubAccount
+type GithubAccountSpec struct {
+	// Login is the user's GitHub login
+	// +kubebuilder:validation:MaxLength=39
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?$`
+	Login string `json:"login"`
+
+	// Teams are the slugs of the organization teams the user is a member of
+	// +optional
+	Teams []string `json:"teams,omitempty"`
+
+	// Role is the user's role in the organization. admin is refused unless the operator allows it.
+	// +kubebuilder:validation:Enum=member;admin
+	// +kubebuilder:default=member
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// Suspended removes the user from the teams, and from the organization when kuadra invited them,
+	// until it is cleared
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// Condition types of a GithubAccount, on top of Ready
+const (
+	// ConditionMembershipReady is True when the user is a member of the organization
+	ConditionMembershipReady = "MembershipReady"
+	// ConditionTeamsSynced is True when the user is a member of every team in the spec
+	ConditionTeamsSynced = "TeamsSynced"
+
+	// ReasonInvitationPending means the user has not accepted the invitation to the organization yet
+	ReasonInvitationPending = "InvitationPending"
+)
+
+// GithubAccountStatus defines the observed state of GithubAccount
+type GithubAccountStatus struct {
+	// Invited is true when kuadra invited the user, who is then removed from the organization
+	// when the GithubAccount is deleted
+	// +optional
+	Invited bool `json:"invited,omitempty"`
+
+	// State is the user's organization membership state, active or pending
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// Role is the user's role in the organization
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// Teams are the teams the user was added to
+	// +optional
+	Teams []string `json:"teams,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the state of the user's organization and team memberships
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Login",type="string",JSONPath=".spec.login"
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// GithubAccount is the Schema for the githubaccounts API
+type GithubAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GithubAccountSpec   `json:"spec,omitempty"`
+	Status GithubAccountStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GithubAccountList contains a list of GithubAccount
+type GithubAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GithubAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GithubAccount{}, &GithubAccountList{})
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// groupWindow has the fields but not the methods of GroupWindow, so that it decodes as a struct
+type groupWindow GroupWindow
+
+// UnmarshalJSON decodes a window. A value that is not one, such as an object with a misspelled bound
+// or a number, still decodes so that the objects holding it can be read, but is kept as it was to be
+// refused by the webhooks and never be active.
+func (g *GroupWindow) UnmarshalJSON(data []byte) error {
+	var window groupWindow
+	if err := decodeStrict(data, &window); err == nil {
+		*g = GroupWindow(window)
+		return nil
+	}
+	// Keep the fields that do decode, so that the window can be named when it is refused
+	window = groupWindow{}
+	_ = json.Unmarshal(data, &window)
+	*g = GroupWindow(window)
+	g.invalidValue = append([]byte(nil), data...)
+	return nil
+}
+
+// decodeStrict decodes a window, failing on unknown fields
+func decodeStrict(data []byte, window *groupWindow) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(window)
+}
+
+// invalidError returns why the value the window was decoded from is not a valid window, or nil when
+// it is one
+func (g GroupWindow) invalidError() error {
+	if g.invalidValue == nil {
+		return nil
+	}
+	return decodeStrict(g.invalidValue, &groupWindow{})
+}
+
+// MarshalJSON writes an invalid window as the value it was decoded from
+func (g GroupWindow) MarshalJSON() ([]byte, error) {
+	if g.invalidValue != nil {
+		return g.invalidValue, nil
+	}
+	return json.Marshal(groupWindow(g))
+}
+
+// ActiveAt reports whether now falls within the window. An invalid window is never active, as its
+// bounds may be misspelled.
+func (g GroupWindow) ActiveAt(now time.Time) bool {
+	if g.invalidValue != nil {
+		return false
+	}
+	return (g.NotBefore == nil || !now.Before(g.NotBefore.Time)) && (g.NotAfter == nil || now.Before(g.NotAfter.Time))
+}
+
+// WindowState returns where the membership stands relative to its window at now
+func (g GroupWindow) WindowState(now time.Time) GroupWindowState {
+	switch {
+	case g.NotBefore != nil && now.Before(g.NotBefore.Time):
+		return GroupWindowScheduled
+	case g.ActiveAt(now):
+		return GroupWindowActive
+	default:
+		return GroupWindowEnded
+	}
+}
+
+// GroupNames returns the IAM groups of the spec, those of its windows included
+func (s *AwsAccountSpec) GroupNames() []string {
+	if s.Groups == nil && s.GroupWindows == nil {
+		return nil
+	}
+	names := append(make([]string, 0, len(s.Groups)+len(s.GroupWindows)), s.Groups...)
+	for _, window := range s.GroupWindows {
+		names = append(names, window.Name)
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("GroupWindow", func() {
+
+	start := time.Date(2023, 6, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+
+	Context("When decoding group windows", func() {
+		It("Should accept windows alongside groups", func() {
+			var spec AwsAccountSpec
+			Expect(json.Unmarshal([]byte(`{"userName": "jdoe", "groups": ["developers"], "groupWindows": [{"name": "break-glass", "notBefore": "2023-06-01T09:00:00Z", "notAfter": "2023-06-01T17:00:00Z"}]}`), &spec)).Should(Succeed())
+			Expect(spec.Groups).Should(Equal([]string{"developers"}))
+			Expect(spec.GroupWindows).Should(HaveLen(1))
+			Expect(spec.GroupWindows[0].Name).Should(Equal("break-glass"))
+			Expect(spec.GroupWindows[0].NotBefore.Time).Should(BeTemporally("==", start))
+			Expect(spec.GroupWindows[0].NotAfter.Time).Should(BeTemporally("==", end))
+			Expect(spec.GroupNames()).Should(Equal([]string{"developers", "break-glass"}))
+		})
+
+		It("Should keep objects with unknown fields as they were", func() {
+			var windows []GroupWindow
+			Expect(json.Unmarshal([]byte(`[{"name": "break-glass", "notAftr": "2023-06-01T17:00:00Z"}]`), &windows)).Should(Succeed())
+			Expect(windows[0].Name).Should(Equal("break-glass"))
+			Expect(windows[0].ActiveAt(start)).Should(BeFalse())
+			data, err := json.Marshal(windows)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(data)).Should(Equal(`[{"name":"break-glass","notAftr":"2023-06-01T17:00:00Z"}]`))
+		})
+
+		It("Should still list AwsAccounts holding values that are not windows", func() {
+			var list AwsAccountList
+			Expect(json.Unmarshal([]byte(`{"items": [{"metadata": {"name": "jdoe"}, "spec": {"userName": "jdoe", "groups": ["developers"], "groupWindows": [42, true, [{"name": "break-glass"}]]}}]}`), &list)).Should(Succeed())
+			Expect(list.Items).Should(HaveLen(1))
+			windows := list.Items[0].Spec.GroupWindows
+			Expect(windows).Should(HaveLen(3))
+			for _, window := range windows {
+				Expect(window.ActiveAt(start)).Should(BeFalse())
+			}
+			data, err := json.Marshal(windows)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(data)).Should(Equal(`[42,true,[{"name":"break-glass"}]]`))
+		})
+	})
+
+	Context("When evaluating the window", func() {
+		It("Should be active from notBefore until notAfter", func() {
+			window := GroupWindow{Name: "break-glass", NotBefore: &metav1.Time{Time: start}, NotAfter: &metav1.Time{Time: end}}
+			Expect(window.WindowState(start.Add(-time.Second))).Should(Equal(GroupWindowScheduled))
+			Expect(window.WindowState(start)).Should(Equal(GroupWindowActive))
+			Expect(window.ActiveAt(end.Add(-time.Second))).Should(BeTrue())
+			Expect(window.WindowState(end)).Should(Equal(GroupWindowEnded))
+		})
+	})
+
+	Context("When validating the window", func() {
+		newAwsAccount := func() *AwsAccount {
+			return &AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-webhook", Namespace: "default"},
+				Spec:       AwsAccountSpec{UserName: "webhook-user", Groups: []string{"developers"}},
+			}
+		}
+
+		It("Should reject a window that ends before it starts", func() {
+			awsAccount := newAwsAccount()
+			awsAccount.Spec.GroupWindows = []GroupWindow{{Name: "break-glass", NotBefore: &metav1.Time{Time: end}, NotAfter: &metav1.Time{Time: start}}}
+			err := awsAccount.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.groupWindows[0].notAfter"))
+		})
+
+		It("Should reject a window without bounds or for a group listed in groups", func() {
+			awsAccount := newAwsAccount()
+			awsAccount.Spec.GroupWindows = []GroupWindow{{Name: "break-glass"}, {Name: "developers", NotAfter: &metav1.Time{Time: end}}}
+			err := awsAccount.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.groupWindows[0].notAfter: Required value"))
+			Expect(err.Error()).Should(ContainSubstring(`spec.groupWindows[1].name: Duplicate value: "developers"`))
+		})
+
+		It("Should reject windows with unknown fields and values that are not windows", func() {
+			awsAccount := newAwsAccount()
+			Expect(json.Unmarshal([]byte(`{"userName": "webhook-user", "groupWindows": [{"name": "break-glass", "notAftr": "2023-06-01T17:00:00Z"}, 42]}`), &awsAccount.Spec)).Should(Succeed())
+			err := awsAccount.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.groupWindows[0]"))
+			Expect(err.Error()).Should(ContainSubstring(`unknown field "notAftr"`))
+			Expect(err.Error()).Should(ContainSubstring("spec.groupWindows[1]"))
+		})
+	})
+})
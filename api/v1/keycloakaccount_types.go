@@ -0,0 +1,141 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeycloakAccountSpec defines the desired state of KeycloakAccount
+type KeycloakAccountSpec struct {
+	// Username is the user's username in the realm
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=255
+	Username string `json:"username"`
+
+	// Email is the user's email address
+	// +optional
+	Email string `json:"email,omitempty"`
+
+	// FirstName is the user's first name
+	// +optional
+	FirstName string `json:"firstName,omitempty"`
+
+	// LastName is the user's last name
+	// +optional
+	LastName string `json:"lastName,omitempty"`
+
+	// RealmRoles are the realm roles assigned to the user, among the ones the operator allows
+	// +optional
+	RealmRoles []string `json:"realmRoles,omitempty"`
+
+	// ClientRoles are the roles of realm clients assigned to the user, among the ones the operator allows
+	// +optional
+	ClientRoles []KeycloakClientRoles `json:"clientRoles,omitempty"`
+
+	// SecretNamespace is the namespace the keycloak-login Secret holding the user's temporary password
+	// is written to, defaults to the namespace of the KeycloakAccount
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// Suspended disables the user in the realm, keeping its password and roles, until it is cleared
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// KeycloakClientRoles are roles of a client of the realm
+type KeycloakClientRoles struct {
+	// ClientId is the client id of the client defining the roles
+	// +kubebuilder:validation:MinLength=1
+	ClientId string `json:"clientId"`
+
+	// Roles are the names of the client roles
+	Roles []string `json:"roles"`
+}
+
+// Condition types of a KeycloakAccount, on top of Ready
+const (
+	// ConditionKeycloakUserReady is True when the user exists in the realm and matches the spec
+	ConditionKeycloakUserReady = "KeycloakUserReady"
+	// ConditionPasswordReady is True when the user's temporary password is set and stored in a Secret
+	ConditionPasswordReady = "PasswordReady"
+	// ConditionRolesSynced is True when the user has every realm and client role in the spec
+	ConditionRolesSynced = "RolesSynced"
+)
+
+// KeycloakAccountStatus defines the observed state of KeycloakAccount
+type KeycloakAccountStatus struct {
+	// UserId is the id of the user in the realm
+	// +optional
+	UserId string `json:"userId,omitempty"`
+
+	// PasswordSet is true once the temporary password stored in the Secret was set on the user
+	// +optional
+	PasswordSet bool `json:"passwordSet,omitempty"`
+
+	// SecretNamespace is the namespace holding the keycloak-login Secret
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// RealmRoles are the realm roles assigned to the user
+	// +optional
+	RealmRoles []string `json:"realmRoles,omitempty"`
+
+	// ClientRoles are the client roles assigned to the user
+	// +optional
+	ClientRoles []KeycloakClientRoles `json:"clientRoles,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the state of the user, its password and roles
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Username",type="string",JSONPath=".spec.username"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// KeycloakAccount is the Schema for the keycloakaccounts API
+type KeycloakAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeycloakAccountSpec   `json:"spec,omitempty"`
+	Status KeycloakAccountStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KeycloakAccountList contains a list of KeycloakAccount
+type KeycloakAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeycloakAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeycloakAccount{}, &KeycloakAccountList{})
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KubernetesAccountSpec defines the desired state of KubernetesAccount
+type KubernetesAccountSpec struct {
+	// Namespace is the user's own namespace, which holds the user's ServiceAccount and in which the
+	// user is granted the namespace role. Defaults to the namespace of the KubernetesAccount, other
+	// namespaces have to be allowed by the operator.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// NamespaceRole is the ClusterRole granted to the user in their own namespace
+	// +kubebuilder:default=admin
+	// +optional
+	NamespaceRole string `json:"namespaceRole,omitempty"`
+
+	// ClusterRoles are granted to the user cluster wide, among the ones the operator allows
+	// +optional
+	ClusterRoles []string `json:"clusterRoles,omitempty"`
+
+	// Roles are Roles or ClusterRoles granted to the user in other namespaces, among the ones the operator allows
+	// +optional
+	Roles []KubernetesRoleBinding `json:"roles,omitempty"`
+
+	// TokenExpiration is the lifetime of the ServiceAccount token in the kubeconfig. The token is
+	// rotated once two thirds of its lifetime have passed.
+	// +kubebuilder:default="24h"
+	// +optional
+	TokenExpiration *metav1.Duration `json:"tokenExpiration,omitempty"`
+
+	// SecretNamespace is the namespace the kubeconfig Secret is written to, defaults to the user's own namespace
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// Suspended deletes the ServiceAccount, which invalidates its tokens, along with the bindings and the
+	// kubeconfig Secret until it is cleared
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// DefaultKubernetesNamespaceRole is the ClusterRole granted to users in their own namespace unless the spec names another one
+const DefaultKubernetesNamespaceRole = "admin"
+
+// KubernetesRoleBinding grants a Role or ClusterRole in a namespace
+type KubernetesRoleBinding struct {
+	// Namespace is the namespace the role is granted in
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// Kind is the kind of the role
+	// +kubebuilder:validation:Enum=Role;ClusterRole
+	// +kubebuilder:default=ClusterRole
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name is the name of the role
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// RoleKind returns the kind of the role, which defaults to ClusterRole
+func (r *KubernetesRoleBinding) RoleKind() string {
+	if r.Kind == "" {
+		return "ClusterRole"
+	}
+	return r.Kind
+}
+
+// Condition types of a KubernetesAccount, on top of Ready
+const (
+	// ConditionServiceAccountReady is True when the user's ServiceAccount exists
+	ConditionServiceAccountReady = "ServiceAccountReady"
+	// ConditionBindingsSynced is True when the user is bound to every role in the spec
+	ConditionBindingsSynced = "BindingsSynced"
+	// ConditionKubeconfigReady is True when the kubeconfig Secret holds a valid token
+	ConditionKubeconfigReady = "KubeconfigReady"
+)
+
+// KubernetesAccountStatus defines the observed state of KubernetesAccount
+type KubernetesAccountStatus struct {
+	// ServiceAccount is the name of the user's ServiceAccount
+	// +optional
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+
+	// Namespace is the namespace holding the user's ServiceAccount
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SecretNamespace is the namespace holding the kubeconfig Secret
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// TokenExpirationTimestamp is the time the token in the kubeconfig expires
+	// +optional
+	TokenExpirationTimestamp *metav1.Time `json:"tokenExpirationTimestamp,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the state of the user's ServiceAccount, bindings and kubeconfig
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Namespace",type="string",JSONPath=".status.namespace"
+//+kubebuilder:printcolumn:name="Token Expires",type="date",JSONPath=".status.tokenExpirationTimestamp"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// KubernetesAccount is the Schema for the kubernetesaccounts API
+type KubernetesAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KubernetesAccountSpec   `json:"spec,omitempty"`
+	Status KubernetesAccountStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KubernetesAccountList contains a list of KubernetesAccount
+type KubernetesAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KubernetesAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubernetesAccount{}, &KubernetesAccountList{})
+}
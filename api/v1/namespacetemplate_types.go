@@ -0,0 +1,85 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceTemplateSpec defines the objects rendered into the namespace of each AwsAccount using the template
+type NamespaceTemplateSpec struct {
+	// RoleBindings bind the user's Kubernetes identity to roles in the namespace
+	// +optional
+	RoleBindings []RoleBindingTemplate `json:"roleBindings,omitempty"`
+
+	// ResourceQuota is rendered as a ResourceQuota named kuadra
+	// +optional
+	ResourceQuota *corev1.ResourceQuotaSpec `json:"resourceQuota,omitempty"`
+
+	// LimitRange is rendered as a LimitRange named kuadra
+	// +optional
+	LimitRange *corev1.LimitRangeSpec `json:"limitRange,omitempty"`
+
+	// NetworkPolicies are rendered as NetworkPolicies in the namespace
+	// +optional
+	NetworkPolicies []NetworkPolicyTemplate `json:"networkPolicies,omitempty"`
+}
+
+// RoleBindingTemplate is a RoleBinding whose subject is the user's Kubernetes identity
+type RoleBindingTemplate struct {
+	// Name is the name of the RoleBinding
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// RoleRef is the Role or ClusterRole bound to the user
+	RoleRef rbacv1.RoleRef `json:"roleRef"`
+}
+
+// NetworkPolicyTemplate is a named NetworkPolicy
+type NetworkPolicyTemplate struct {
+	// Name is the name of the NetworkPolicy
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Spec is the NetworkPolicy spec
+	Spec networkingv1.NetworkPolicySpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// NamespaceTemplate is the Schema for the namespacetemplates API
+type NamespaceTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NamespaceTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NamespaceTemplateList contains a list of NamespaceTemplate
+type NamespaceTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceTemplate{}, &NamespaceTemplateList{})
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// QuayAccountSpec defines the desired state of QuayAccount
+type QuayAccountSpec struct {
+	// Username is the user's Quay username, added to the teams in the spec
+	// +kubebuilder:validation:MaxLength=255
+	// +kubebuilder:validation:Pattern=`^[a-z0-9_]+$`
+	Username string `json:"username"`
+
+	// Teams are the organization teams the user is a member of
+	// +optional
+	Teams []string `json:"teams,omitempty"`
+
+	// Repositories are the organization repositories the user's robot account can push to
+	// +optional
+	Repositories []string `json:"repositories,omitempty"`
+
+	// SecretNamespace is the namespace the robot account's dockerconfigjson Secret is written to,
+	// defaults to the namespace of the QuayAccount
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// Suspended removes the user from the teams and deletes the robot account and its Secret until it is cleared
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// Condition types of a QuayAccount, on top of Ready and TeamsSynced
+const (
+	// ConditionRobotAccountReady is True when the robot account exists and its credentials are stored in a Secret
+	ConditionRobotAccountReady = "RobotAccountReady"
+	// ConditionRepositoriesSynced is True when the robot account can push to every repository in the spec
+	ConditionRepositoriesSynced = "RepositoriesSynced"
+)
+
+// QuayAccountStatus defines the observed state of QuayAccount
+type QuayAccountStatus struct {
+	// Robot is the full name of the user's robot account
+	// +optional
+	Robot string `json:"robot,omitempty"`
+
+	// SecretNamespace is the namespace holding the robot account's Secret
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// Teams are the teams the user was added to
+	// +optional
+	Teams []string `json:"teams,omitempty"`
+
+	// Repositories are the repositories the robot account can push to
+	// +optional
+	Repositories []string `json:"repositories,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the state of the user's teams and robot account
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Username",type="string",JSONPath=".spec.username"
+//+kubebuilder:printcolumn:name="Robot",type="string",JSONPath=".status.robot"
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// QuayAccount is the Schema for the quayaccounts API
+type QuayAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   QuayAccountSpec   `json:"spec,omitempty"`
+	Status QuayAccountStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// QuayAccountList contains a list of QuayAccount
+type QuayAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []QuayAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&QuayAccount{}, &QuayAccountList{})
+}
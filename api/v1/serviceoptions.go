@@ -0,0 +1,162 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// GithubRoleAdmin is the GitHub organization role that owns the organization
+const GithubRoleAdmin = "admin"
+
+// ServiceOptions are the operator settings limiting the accounts that can be asked for. The User
+// webhook checks them on admission, and the controllers again before creating the accounts so that
+// they also hold for account resources created directly or without webhooks.
+// +kubebuilder:object:generate=false
+type ServiceOptions struct {
+	// DisabledServices are the services the operator is not configured to manage
+	DisabledServices []string
+	// GithubAdmin allows GitHub accounts with the admin role
+	GithubAdmin bool
+	// KeycloakRealmRoles are the realm roles Keycloak accounts may be assigned
+	KeycloakRealmRoles []string
+	// KeycloakClientRoles are the client roles Keycloak accounts may be assigned, each as <clientId>/<role>
+	KeycloakClientRoles []string
+	// KubernetesClusterRoles are the ClusterRoles Kubernetes accounts may be granted cluster wide
+	KubernetesClusterRoles []string
+	// KubernetesNamespaceRoles are the ClusterRoles Kubernetes accounts may be granted in a namespace
+	KubernetesNamespaceRoles []string
+	// KubernetesRoles are the Roles Kubernetes accounts may be granted, each as <namespace>/<name>
+	KubernetesRoles []string
+	// KubernetesNamespaces are the namespaces Kubernetes accounts may be granted ClusterRoles in, on top
+	// of the namespace of their account
+	KubernetesNamespaces []string
+}
+
+// userServices are the services of a User spec, in the order they are validated
+var userServices = []string{ServiceAws, ServiceGithub, ServiceQuay, ServiceKeycloak, ServiceKubernetes}
+
+// ValidateUserSpec refuses the sections of a User spec asking for more than the options allow. Only
+// the sections that changed from oldSpec are checked, so that Users created before the options
+// changed can still be updated.
+func (o *ServiceOptions) ValidateUserSpec(spec *UserSpec, oldSpec *UserSpec) field.ErrorList {
+	if oldSpec == nil {
+		oldSpec = &UserSpec{}
+	}
+	changed := map[string]bool{
+		ServiceAws:        spec.AwsAccount != nil && !reflect.DeepEqual(spec.AwsAccount, oldSpec.AwsAccount),
+		ServiceGithub:     spec.Github != nil && !reflect.DeepEqual(spec.Github, oldSpec.Github),
+		ServiceQuay:       spec.Quay != nil && !reflect.DeepEqual(spec.Quay, oldSpec.Quay),
+		ServiceKeycloak:   spec.Keycloak != nil && !reflect.DeepEqual(spec.Keycloak, oldSpec.Keycloak),
+		ServiceKubernetes: spec.Kubernetes != nil && !reflect.DeepEqual(spec.Kubernetes, oldSpec.Kubernetes),
+	}
+	var allErrs field.ErrorList
+	for _, service := range userServices {
+		if changed[service] && o.ServiceDisabled(service) {
+			allErrs = append(allErrs, field.Forbidden(userServicePaths[service], "the operator does not manage "+service+" accounts"))
+		}
+	}
+	if changed[ServiceGithub] {
+		allErrs = append(allErrs, o.ValidateGithubAccountSpec(spec.Github, userServicePaths[ServiceGithub])...)
+	}
+	if changed[ServiceKeycloak] {
+		allErrs = append(allErrs, o.ValidateKeycloakAccountSpec(spec.Keycloak, userServicePaths[ServiceKeycloak])...)
+	}
+	if changed[ServiceKubernetes] {
+		path := userServicePaths[ServiceKubernetes]
+		// The namespace defaults to the one created for the user's AWS account
+		if namespace := spec.Kubernetes.Namespace; namespace != "" && !containsString(o.KubernetesNamespaces, namespace) {
+			allErrs = append(allErrs, field.Forbidden(path.Child("namespace"), "the operator does not allow roles in namespace "+namespace))
+		}
+		allErrs = append(allErrs, o.ValidateKubernetesAccountSpec(spec.Kubernetes, path)...)
+	}
+	return allErrs
+}
+
+// ServiceDisabled reports whether the operator does not manage accounts in the service
+func (o *ServiceOptions) ServiceDisabled(service string) bool {
+	return containsString(o.DisabledServices, service)
+}
+
+// ValidateGithubAccountSpec refuses the admin role unless the options allow it
+func (o *ServiceOptions) ValidateGithubAccountSpec(spec *GithubAccountSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Role == GithubRoleAdmin && !o.GithubAdmin {
+		allErrs = append(allErrs, field.Forbidden(path.Child("role"), "the operator does not allow the admin role"))
+	}
+	return allErrs
+}
+
+// ValidateKeycloakAccountSpec refuses the realm and client roles that the options do not list
+func (o *ServiceOptions) ValidateKeycloakAccountSpec(spec *KeycloakAccountSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, role := range spec.RealmRoles {
+		if !containsString(o.KeycloakRealmRoles, role) {
+			allErrs = append(allErrs, field.NotSupported(path.Child("realmRoles").Index(i), role, o.KeycloakRealmRoles))
+		}
+	}
+	for i, clientRoles := range spec.ClientRoles {
+		for j, role := range clientRoles.Roles {
+			if !containsString(o.KeycloakClientRoles, clientRoles.ClientId+"/"+role) {
+				allErrs = append(allErrs, field.Forbidden(path.Child("clientRoles").Index(i).Child("roles").Index(j),
+					"the operator does not allow role "+role+" of client "+clientRoles.ClientId))
+			}
+		}
+	}
+	return allErrs
+}
+
+// ValidateKubernetesAccountSpec refuses the roles that the options do not list. The namespace of the
+// account is left to the caller, as it also depends on who created the namespace.
+func (o *ServiceOptions) ValidateKubernetesAccountSpec(spec *KubernetesAccountSpec, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if namespaceRole := spec.NamespaceRole; !o.KubernetesNamespaceRoleAllowed(namespaceRole) {
+		allErrs = append(allErrs, field.NotSupported(path.Child("namespaceRole"), namespaceRole, o.KubernetesNamespaceRoles))
+	}
+	for i, clusterRole := range spec.ClusterRoles {
+		if !containsString(o.KubernetesClusterRoles, clusterRole) {
+			allErrs = append(allErrs, field.NotSupported(path.Child("clusterRoles").Index(i), clusterRole, o.KubernetesClusterRoles))
+		}
+	}
+	for i, role := range spec.Roles {
+		if !o.KubernetesRoleAllowed(role) {
+			allErrs = append(allErrs, field.Forbidden(path.Child("roles").Index(i),
+				"the operator does not allow "+role.RoleKind()+" "+role.Name+" in namespace "+role.Namespace))
+		}
+	}
+	return allErrs
+}
+
+// KubernetesNamespaceRoleAllowed reports whether the ClusterRole may be granted in the namespace of an
+// account, an empty name standing for the default role
+func (o *ServiceOptions) KubernetesNamespaceRoleAllowed(namespaceRole string) bool {
+	if namespaceRole == "" {
+		namespaceRole = DefaultKubernetesNamespaceRole
+	}
+	return containsString(o.KubernetesNamespaceRoles, namespaceRole)
+}
+
+// KubernetesRoleAllowed reports whether the role may be granted in its namespace. A Role has to be
+// listed itself, a ClusterRole has to be listed along with the namespace.
+func (o *ServiceOptions) KubernetesRoleAllowed(role KubernetesRoleBinding) bool {
+	if role.RoleKind() == "Role" {
+		return containsString(o.KubernetesRoles, role.Namespace+"/"+role.Name)
+	}
+	return containsString(o.KubernetesNamespaces, role.Namespace) && containsString(o.KubernetesNamespaceRoles, role.Name)
+}
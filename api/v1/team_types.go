@@ -0,0 +1,109 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TeamSpec defines the desired state of Team
+type TeamSpec struct {
+	// Members are the names of Users in the Team's namespace that belong to the team
+	// +optional
+	Members []string `json:"members,omitempty"`
+
+	// MemberSelector selects further Users in the Team's namespace by label
+	// +optional
+	MemberSelector *metav1.LabelSelector `json:"memberSelector,omitempty"`
+
+	// Groups are the IAM groups every member's AwsAccount is added to, on top of its own groups
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+
+	// ManagedPolicyArns are the managed IAM policies attached to every member's IAM user
+	// +optional
+	ManagedPolicyArns []string `json:"managedPolicyArns,omitempty"`
+
+	// Namespaces are shared namespaces every member is given access to
+	// +optional
+	Namespaces []TeamNamespace `json:"namespaces,omitempty"`
+}
+
+// TeamNamespace is a namespace shared by the members of a Team
+type TeamNamespace struct {
+	// Name is the name of the namespace. It is created by kuadra, a namespace that kuadra did not
+	// create for the Team is refused.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// ClusterRole is bound to the members in the namespace. It must be one of the ClusterRoles the
+	// controller allows Teams to bind.
+	// +kubebuilder:default=edit
+	// +optional
+	ClusterRole string `json:"clusterRole,omitempty"`
+}
+
+// TeamStatus defines the observed state of Team
+type TeamStatus struct {
+	// Members are the names of the Users currently in the team
+	// +optional
+	Members []string `json:"members,omitempty"`
+
+	// Namespaces are the shared namespaces the members have been given access to
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the state of the team's namespaces
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Team is the Schema for the teams API
+type Team struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TeamSpec   `json:"spec,omitempty"`
+	Status TeamStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// TeamList contains a list of Team
+type TeamList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Team `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Team{}, &TeamList{})
+}
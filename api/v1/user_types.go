@@ -24,8 +24,63 @@ import (
 type UserSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 	AwsAccount *AwsAccountNestedSpec `json:"awsAccount,omitempty"`
+
+	// Github is the user's membership of the GitHub organization
+	// +optional
+	Github *GithubAccountSpec `json:"github,omitempty"`
+
+	// Quay is the user's membership of the Quay organization. Unless it names a namespace, the
+	// robot account's Secret is written to the namespace of the user's AwsAccount.
+	// +optional
+	Quay *QuayAccountSpec `json:"quay,omitempty"`
+
+	// Keycloak is the user's account in the Keycloak realm. Unless it names a namespace, the
+	// keycloak-login Secret is written to the namespace of the user's AwsAccount.
+	// +optional
+	Keycloak *KeycloakAccountSpec `json:"keycloak,omitempty"`
+
+	// Kubernetes is the user's access to the cluster. Unless it names a namespace, the user's
+	// ServiceAccount is created in the namespace of the user's AwsAccount.
+	// +optional
+	Kubernetes *KubernetesAccountSpec `json:"kubernetes,omitempty"`
+
+	// NamespaceTemplate is the name of a NamespaceTemplate rendered into the user's namespace,
+	// unless the AwsAccount spec names one itself. Whoever sets it must be allowed to bind the
+	// roles the template binds.
+	// +optional
+	NamespaceTemplate string `json:"namespaceTemplate,omitempty"`
+
+	// ExpiresAt is the time the user's access ends, e.g. at the end of a contract or internship.
+	// What happens to the accounts then is set by expirationPolicy.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ExpirationPolicy is what happens to the user's accounts once expiresAt has passed: Suspend
+	// keeps them suspended, Delete deletes them. The User itself is kept either way.
+	// +kubebuilder:validation:Enum=Suspend;Delete
+	// +kubebuilder:default=Suspend
+	// +optional
+	ExpirationPolicy ExpirationPolicy `json:"expirationPolicy,omitempty"`
+
+	// Suspended suspends every account of the user, which is passed down to their specs.
+	// Clearing it reactivates them.
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
 }
 
+// ExpirationPolicy is what happens to a User's accounts once it expires
+type ExpirationPolicy string
+
+const (
+	// ExpirationPolicySuspend suspends the accounts of an expired User
+	ExpirationPolicySuspend ExpirationPolicy = "Suspend"
+	// ExpirationPolicyDelete deletes the accounts of an expired User
+	ExpirationPolicyDelete ExpirationPolicy = "Delete"
+)
+
+// ConditionExpired is True once a User's spec.expiresAt has passed
+const ConditionExpired = "Expired"
+
 type AwsAccountNestedSpec struct {
 	Spec AwsSpec `json:"spec,omitempty"`
 }
@@ -38,10 +93,61 @@ type AwsSpec struct {
 type UserStatus struct {
 	// Important: Run "make" to regenerate code after modifying this file
 	AwsAccountCreated bool `json:"awsAccountCreated"`
+
+	// Services reports the readiness of each account created for the user
+	// +optional
+	Services []ServiceStatus `json:"services,omitempty"`
+
+	// Teams are the Teams the user is a member of
+	// +optional
+	Teams []string `json:"teams,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions summarise the readiness of the user's accounts
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// Services a User can have accounts for
+const (
+	ServiceAws        = "aws"
+	ServiceGithub     = "github"
+	ServiceQuay       = "quay"
+	ServiceKeycloak   = "keycloak"
+	ServiceKubernetes = "kubernetes"
+)
+
+// ServiceStatus is the readiness of one of the accounts created for a User
+type ServiceStatus struct {
+	// Service names the service, e.g. aws
+	Service string `json:"service"`
+	// Kind is the kind of the resource managing the account
+	Kind string `json:"kind"`
+	// Name is the name of the resource managing the account
+	Name string `json:"name"`
+	// Ready is the status of the resource's Ready condition
+	Ready metav1.ConditionStatus `json:"ready"`
+	// Reason is the reason of the resource's Ready condition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message is the message of the resource's Ready condition
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+//+kubebuilder:printcolumn:name="Expires",type="date",JSONPath=".spec.expiresAt"
+//+kubebuilder:printcolumn:name="Suspended",type="string",JSONPath=".status.conditions[?(@.type==\"Suspended\")].status",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // User is the Schema for the users API
 type User struct {
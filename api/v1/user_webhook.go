@@ -0,0 +1,292 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var userlog = logf.Log.WithName("user-resource")
+
+// SetupWebhookWithManager registers the User webhooks. The defaultGroups are given to the AWS
+// accounts of Users that do not list any groups, and the accounts are limited by the options.
+func (r *User) SetupWebhookWithManager(mgr ctrl.Manager, defaultGroups []string, options ServiceOptions) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&userDefaulter{DefaultGroups: defaultGroups}).
+		WithValidator(&userValidator{
+			Client:  mgr.GetClient(),
+			Binder:  &subjectAccessReviewAuthorizer{Client: mgr.GetClient()},
+			Options: options,
+		}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-kuadra-kuadrant-io-v1-user,mutating=true,failurePolicy=fail,sideEffects=None,groups=kuadra.kuadrant.io,resources=users,verbs=create;update,versions=v1,name=muser.kb.io,admissionReviewVersions=v1
+
+// userDefaulter defaults the AWS account nested in a User
+type userDefaulter struct {
+	// DefaultGroups are the IAM groups of AWS accounts that do not list any
+	DefaultGroups []string
+}
+
+var _ webhook.CustomDefaulter = &userDefaulter{}
+
+// Default implements webhook.CustomDefaulter. The IAM user name defaults to the User's name.
+func (d *userDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	user, ok := obj.(*User)
+	if !ok {
+		return fmt.Errorf("expected a User but got a %T", obj)
+	}
+	userlog.Info("default", "name", user.Name)
+
+	if user.Spec.AwsAccount == nil {
+		return nil
+	}
+	awsAccountSpec := &user.Spec.AwsAccount.Spec.User
+	if awsAccountSpec.UserName == "" {
+		awsAccountSpec.UserName = user.Name
+	}
+	if len(awsAccountSpec.Groups) == 0 && len(awsAccountSpec.GroupWindows) == 0 && len(d.DefaultGroups) > 0 {
+		awsAccountSpec.Groups = append([]string{}, d.DefaultGroups...)
+	}
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-kuadra-kuadrant-io-v1-user,mutating=false,failurePolicy=fail,sideEffects=None,groups=kuadra.kuadrant.io,resources=users,verbs=create;update,versions=v1,name=vuser.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &User{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *User) ValidateCreate() error {
+	userlog.Info("validate create", "name", r.Name)
+
+	if r.Spec.AwsAccount == nil {
+		return nil
+	}
+	return r.toInvalidError(validateUserAwsAccountSpec(&r.Spec.AwsAccount.Spec.User, nil, userAwsAccountPath))
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type. Unlike on
+// an AwsAccount, the user name can change: the User controller replaces the AwsAccount then.
+func (r *User) ValidateUpdate(old runtime.Object) error {
+	userlog.Info("validate update", "name", r.Name)
+
+	oldUser, ok := old.(*User)
+	if !ok || r.Spec.AwsAccount == nil {
+		return nil
+	}
+	var oldSpec *AwsAccountSpec
+	if oldUser.Spec.AwsAccount != nil {
+		oldSpec = &oldUser.Spec.AwsAccount.Spec.User
+	}
+	return r.toInvalidError(validateUserAwsAccountSpec(&r.Spec.AwsAccount.Spec.User, oldSpec, userAwsAccountPath))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *User) ValidateDelete() error {
+	return nil
+}
+
+// userValidator validates Users like the User's own webhook.Validator methods, and also refuses the
+// services, IAM groups and managed policies that the AccessPolicies deny in the User's namespace, the
+// accounts that the operator options do not allow, and a NamespaceTemplate that binds roles the
+// requesting user cannot bind
+type userValidator struct {
+	Client  client.Reader
+	Binder  roleBinder
+	Options ServiceOptions
+}
+
+var _ webhook.CustomValidator = &userValidator{}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *userValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	user, ok := obj.(*User)
+	if !ok {
+		return fmt.Errorf("expected a User but got a %T", obj)
+	}
+	if err := user.ValidateCreate(); err != nil {
+		return err
+	}
+	if err := user.toInvalidError(v.Options.ValidateUserSpec(&user.Spec, nil)); err != nil {
+		return err
+	}
+	if err := user.validateNamespaceTemplate(ctx, v.Client, v.Binder); err != nil {
+		return err
+	}
+	return user.validateAccess(ctx, v.Client, user.RequestedAccess())
+}
+
+// ValidateUpdate implements webhook.CustomValidator. Only the services, groups and managed policies
+// added by the update are checked against the AccessPolicies.
+func (v *userValidator) ValidateUpdate(ctx context.Context, oldObj runtime.Object, newObj runtime.Object) error {
+	user, ok := newObj.(*User)
+	if !ok {
+		return fmt.Errorf("expected a User but got a %T", newObj)
+	}
+	oldUser, ok := oldObj.(*User)
+	if !ok {
+		return fmt.Errorf("expected a User but got a %T", oldObj)
+	}
+	if err := user.ValidateUpdate(oldUser); err != nil {
+		return err
+	}
+	if err := user.toInvalidError(v.Options.ValidateUserSpec(&user.Spec, &oldUser.Spec)); err != nil {
+		return err
+	}
+	if user.namespaceTemplateBinding() != oldUser.namespaceTemplateBinding() {
+		if err := user.validateNamespaceTemplate(ctx, v.Client, v.Binder); err != nil {
+			return err
+		}
+	}
+	access, oldAccess := user.RequestedAccess(), oldUser.RequestedAccess()
+	return user.validateAccess(ctx, v.Client, Access{
+		Services:          addedValues(access.Services, oldAccess.Services),
+		Groups:            addedValues(access.Groups, oldAccess.Groups),
+		ManagedPolicyArns: addedValues(access.ManagedPolicyArns, oldAccess.ManagedPolicyArns),
+	})
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *userValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	user, ok := obj.(*User)
+	if !ok {
+		return fmt.Errorf("expected a User but got a %T", obj)
+	}
+	return user.ValidateDelete()
+}
+
+// userServicePaths are the sections of a User spec that ask for an account in each service
+var userServicePaths = map[string]*field.Path{
+	ServiceAws:        field.NewPath("spec", "awsAccount"),
+	ServiceGithub:     field.NewPath("spec", "github"),
+	ServiceQuay:       field.NewPath("spec", "quay"),
+	ServiceKeycloak:   field.NewPath("spec", "keycloak"),
+	ServiceKubernetes: field.NewPath("spec", "kubernetes"),
+}
+
+// RequestedAccess returns the services the User has sections for, and the IAM groups and managed
+// policies of its AWS account
+func (r *User) RequestedAccess() Access {
+	var access Access
+	if r.Spec.AwsAccount != nil {
+		access.Services = append(access.Services, ServiceAws)
+		access.Groups = r.Spec.AwsAccount.Spec.User.GroupNames()
+		access.ManagedPolicyArns = r.Spec.AwsAccount.Spec.User.ManagedPolicyArns
+	}
+	if r.Spec.Github != nil {
+		access.Services = append(access.Services, ServiceGithub)
+	}
+	if r.Spec.Quay != nil {
+		access.Services = append(access.Services, ServiceQuay)
+	}
+	if r.Spec.Keycloak != nil {
+		access.Services = append(access.Services, ServiceKeycloak)
+	}
+	if r.Spec.Kubernetes != nil {
+		access.Services = append(access.Services, ServiceKubernetes)
+	}
+	return access
+}
+
+// namespaceTemplateBinding returns the NamespaceTemplate rendered into the namespace of the User's
+// AwsAccount and the Kubernetes user its RoleBindings bind, as the User controller passes them down
+func (r *User) namespaceTemplateBinding() templateBinding {
+	if r.Spec.AwsAccount == nil {
+		return templateBinding{}
+	}
+	binding := namespaceTemplateBinding(r.Spec.AwsAccount.Spec.User.Namespace)
+	if binding.Template == "" {
+		binding.Template = r.Spec.NamespaceTemplate
+	}
+	return binding
+}
+
+// validateNamespaceTemplate refuses a NamespaceTemplate binding roles that the requesting user cannot bind.
+// This is the only check for the template of the User's AwsAccount, which the AwsAccount webhook admits
+// as the User controller's ServiceAccount.
+func (r *User) validateNamespaceTemplate(ctx context.Context, c client.Reader, binder roleBinder) error {
+	path := field.NewPath("spec", "namespaceTemplate")
+	if r.Spec.AwsAccount != nil && r.Spec.AwsAccount.Spec.User.Namespace != nil && r.Spec.AwsAccount.Spec.User.Namespace.Template != "" {
+		path = userAwsAccountPath.Child("namespace", "template")
+	}
+	allErrs, err := validateNamespaceTemplateBindings(ctx, c, binder, r.namespaceTemplateBinding().Template, path)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	return r.toInvalidError(allErrs)
+}
+
+// validateAccess refuses the access that the AccessPolicies deny in the User's namespace
+func (r *User) validateAccess(ctx context.Context, c client.Reader, access Access) error {
+	allErrs, err := accessPolicyErrors(ctx, c, r.Namespace, access, userServicePaths, userAwsAccountPath)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	return r.toInvalidError(allErrs)
+}
+
+// userAwsAccountPath is the path of the AwsAccount spec nested in a User
+var userAwsAccountPath = field.NewPath("spec", "awsAccount", "spec", "user")
+
+// validateUserAwsAccountSpec checks the AwsAccount spec nested in a User like an AwsAccount's own spec,
+// along with the user name being a valid name for the AwsAccount created from it. Only the fields that
+// changed from oldSpec are checked, so that Users created before a rule was introduced can still be updated.
+func validateUserAwsAccountSpec(spec *AwsAccountSpec, oldSpec *AwsAccountSpec, path *field.Path) field.ErrorList {
+	if oldSpec == nil {
+		oldSpec = &AwsAccountSpec{}
+	}
+	var allErrs field.ErrorList
+	if oldSpec.UserName == "" || spec.UserName != oldSpec.UserName {
+		userNameErrs := validateIamName(spec.UserName, iamUserNameMaxLength, path.Child("userName"))
+		if len(userNameErrs) == 0 {
+			for _, message := range validation.IsDNS1123Subdomain(spec.UserName) {
+				userNameErrs = append(userNameErrs, field.Invalid(path.Child("userName"), spec.UserName, "names the User's AwsAccount: "+message))
+			}
+		}
+		allErrs = append(allErrs, userNameErrs...)
+	}
+	if !reflect.DeepEqual(spec.Groups, oldSpec.Groups) || !reflect.DeepEqual(spec.GroupWindows, oldSpec.GroupWindows) {
+		allErrs = append(allErrs, validateGroupMemberships(spec, path)...)
+	}
+	if !reflect.DeepEqual(spec.InlinePolicies, oldSpec.InlinePolicies) {
+		allErrs = append(allErrs, validateInlinePolicies(spec.InlinePolicies, path.Child("inlinePolicies"))...)
+	}
+	allErrs = append(allErrs, validateNamespace(spec.Namespace, path.Child("namespace"))...)
+	return allErrs
+}
+
+// toInvalidError wraps validation errors in an Invalid API error, or returns nil when there are none
+func (r *User) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("User").GroupKind(), r.Name, allErrs)
+}
@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("User webhook", func() {
+
+	newUser := func(awsAccountSpec AwsAccountSpec) *User {
+		return &User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "jdoe",
+				Namespace: "default",
+			},
+			Spec: UserSpec{
+				AwsAccount: &AwsAccountNestedSpec{Spec: AwsSpec{User: awsAccountSpec}},
+			},
+		}
+	}
+
+	Context("When defaulting a User", func() {
+		defaulter := &userDefaulter{DefaultGroups: []string{"developers"}}
+
+		It("Should default the IAM user name to the User's name and add the default groups", func() {
+			user := newUser(AwsAccountSpec{})
+			Expect(defaulter.Default(context.Background(), user)).Should(Succeed())
+			Expect(user.Spec.AwsAccount.Spec.User.UserName).Should(Equal("jdoe"))
+			Expect(user.Spec.AwsAccount.Spec.User.Groups).Should(Equal([]string{"developers"}))
+		})
+
+		It("Should keep the user name and groups in the spec", func() {
+			user := newUser(AwsAccountSpec{UserName: "john.doe", Groups: []string{"dns-management"}})
+			Expect(defaulter.Default(context.Background(), user)).Should(Succeed())
+			Expect(user.Spec.AwsAccount.Spec.User.UserName).Should(Equal("john.doe"))
+			Expect(user.Spec.AwsAccount.Spec.User.Groups).Should(Equal([]string{"dns-management"}))
+		})
+
+		It("Should leave a User without an AWS account alone", func() {
+			user := newUser(AwsAccountSpec{})
+			user.Spec.AwsAccount = nil
+			Expect(defaulter.Default(context.Background(), user)).Should(Succeed())
+			Expect(user.Spec.AwsAccount).Should(BeNil())
+		})
+	})
+
+	Context("When validating a User", func() {
+		It("Should check the nested AwsAccount spec", func() {
+			user := newUser(AwsAccountSpec{Groups: []string{"developers", "developers"}})
+			err := user.ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.awsAccount.spec.user.userName: Required value"))
+			Expect(err.Error()).Should(ContainSubstring("spec.awsAccount.spec.user.groups[1]"))
+
+			Expect(newUser(AwsAccountSpec{UserName: "jdoe"}).ValidateCreate()).Should(Succeed())
+		})
+
+		It("Should reject user names that cannot name the AwsAccount", func() {
+			err := newUser(AwsAccountSpec{UserName: "John.Doe@example.com"}).ValidateCreate()
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("names the User's AwsAccount"))
+		})
+
+		It("Should allow renaming the IAM user and only check changed fields", func() {
+			old := newUser(AwsAccountSpec{UserName: "jdoe", Groups: []string{"developers", "developers"}})
+			renamed := old.DeepCopy()
+			renamed.Spec.AwsAccount.Spec.User.UserName = "john.doe"
+			Expect(renamed.ValidateUpdate(old)).Should(Succeed())
+
+			renamed.Spec.AwsAccount.Spec.User.UserName = ""
+			Expect(apierrors.IsInvalid(renamed.ValidateUpdate(old))).Should(BeTrue())
+		})
+
+		It("Should only accept a namespace template binding roles the requesting user can bind", func() {
+			template := &NamespaceTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "edit-template"},
+				Spec: NamespaceTemplateSpec{RoleBindings: []RoleBindingTemplate{{
+					Name:    "edit",
+					RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+				}}},
+			}
+			validator := &userValidator{
+				Client: newFakeClient(template),
+				Binder: &fakeRoleBinder{bindings: map[string][]string{"platform-admin": {"edit"}}},
+			}
+			user := newUser(AwsAccountSpec{UserName: "jdoe"})
+			user.Spec.NamespaceTemplate = "edit-template"
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: "jdoe"},
+			}})
+			err := validator.ValidateCreate(ctx, user)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.namespaceTemplate"))
+
+			ctx = admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: "platform-admin"},
+			}})
+			Expect(validator.ValidateCreate(ctx, user)).Should(Succeed())
+		})
+	})
+
+	Context("When the operator options limit the accounts", func() {
+		options := ServiceOptions{DisabledServices: []string{ServiceQuay}}
+
+		It("Should reject the sections of disabled services and the GitHub admin role", func() {
+			user := newUser(AwsAccountSpec{UserName: "jdoe"})
+			user.Spec.Quay = &QuayAccountSpec{Username: "jdoe"}
+			user.Spec.Github = &GithubAccountSpec{Login: "jdoe", Role: GithubRoleAdmin}
+			allErrs := options.ValidateUserSpec(&user.Spec, nil)
+			Expect(allErrs).Should(HaveLen(2))
+			Expect(allErrs.ToAggregate().Error()).Should(ContainSubstring("spec.quay"))
+			Expect(allErrs.ToAggregate().Error()).Should(ContainSubstring("spec.github.role"))
+
+			allowAdmin := ServiceOptions{GithubAdmin: true}
+			Expect(allowAdmin.ValidateUserSpec(&user.Spec, nil)).Should(BeEmpty())
+		})
+
+		It("Should only check the sections changed by an update", func() {
+			old := newUser(AwsAccountSpec{UserName: "jdoe"})
+			old.Spec.Quay = &QuayAccountSpec{Username: "jdoe"}
+			updated := old.DeepCopy()
+			updated.Spec.Suspended = true
+			Expect(options.ValidateUserSpec(&updated.Spec, &old.Spec)).Should(BeEmpty())
+
+			updated.Spec.Quay.Teams = []string{"developers"}
+			Expect(options.ValidateUserSpec(&updated.Spec, &old.Spec)).Should(HaveLen(1))
+		})
+
+		It("Should reject Keycloak roles that are not listed", func() {
+			options := ServiceOptions{KeycloakRealmRoles: []string{"developer"}, KeycloakClientRoles: []string{"kubernetes/view"}}
+			user := newUser(AwsAccountSpec{UserName: "jdoe"})
+			user.Spec.Keycloak = &KeycloakAccountSpec{
+				Username:    "jdoe",
+				RealmRoles:  []string{"developer", "realm-admin"},
+				ClientRoles: []KeycloakClientRoles{{ClientId: "kubernetes", Roles: []string{"view", "cluster-admin"}}},
+			}
+			allErrs := options.ValidateUserSpec(&user.Spec, nil)
+			Expect(allErrs).Should(HaveLen(2))
+			Expect(allErrs[0].Field).Should(Equal("spec.keycloak.realmRoles[1]"))
+			Expect(allErrs[1].Field).Should(Equal("spec.keycloak.clientRoles[0].roles[1]"))
+		})
+
+		It("Should reject Kubernetes roles and namespaces that are not listed", func() {
+			options := ServiceOptions{
+				KubernetesClusterRoles:   []string{"view"},
+				KubernetesNamespaceRoles: []string{"admin", "edit"},
+				KubernetesRoles:          []string{"shared/deployer"},
+				KubernetesNamespaces:     []string{"shared"},
+			}
+			user := newUser(AwsAccountSpec{UserName: "jdoe"})
+			user.Spec.Kubernetes = &KubernetesAccountSpec{
+				ClusterRoles: []string{"view"},
+				Roles: []KubernetesRoleBinding{
+					{Namespace: "shared", Kind: "Role", Name: "deployer"},
+					{Namespace: "shared", Name: "edit"},
+				},
+			}
+			Expect(options.ValidateUserSpec(&user.Spec, nil)).Should(BeEmpty())
+
+			user.Spec.Kubernetes = &KubernetesAccountSpec{
+				Namespace:     "kube-system",
+				NamespaceRole: "cluster-admin",
+				ClusterRoles:  []string{"cluster-admin"},
+				Roles: []KubernetesRoleBinding{
+					{Namespace: "kube-system", Name: "edit"},
+					{Namespace: "shared", Kind: "Role", Name: "admin"},
+				},
+			}
+			allErrs := options.ValidateUserSpec(&user.Spec, nil)
+			Expect(allErrs).Should(HaveLen(5))
+			Expect(allErrs[0].Field).Should(Equal("spec.kubernetes.namespace"))
+			Expect(allErrs[1].Field).Should(Equal("spec.kubernetes.namespaceRole"))
+			Expect(allErrs[2].Field).Should(Equal("spec.kubernetes.clusterRoles[0]"))
+			Expect(allErrs[3].Field).Should(Equal("spec.kubernetes.roles[0]"))
+			Expect(allErrs[4].Field).Should(Equal("spec.kubernetes.roles[1]"))
+		})
+	})
+})
@@ -99,7 +99,17 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = (&AwsAccount{}).SetupWebhookWithManager(mgr)
+	// registered by the AwsAccountReconciler in the operator
+	err = mgr.GetFieldIndexer().IndexField(ctx, &AwsAccount{}, AwsAccountUserNameField, AwsAccountUserNameIndex)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&AwsAccount{}).SetupWebhookWithManager(mgr, nil)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&User{}).SetupWebhookWithManager(mgr, nil, ServiceOptions{})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&AccessRequest{}).SetupWebhookWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:webhook
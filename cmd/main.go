@@ -18,7 +18,10 @@ package main
 
 import (
 	"flag"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -34,6 +37,10 @@ import (
 	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
 	"github.com/Kuadrant/kuadra/internal/controller"
 	"github.com/Kuadrant/kuadra/pkg/aws"
+	"github.com/Kuadrant/kuadra/pkg/github"
+	"github.com/Kuadrant/kuadra/pkg/keycloak"
+	"github.com/Kuadrant/kuadra/pkg/kubernetes"
+	"github.com/Kuadrant/kuadra/pkg/quay"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -53,11 +60,88 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var defaultPermissionsBoundaryArn string
+	var permissionsBoundaryArns string
+	var clusterId string
+	var githubOrganization string
+	var githubApiUrl string
+	var quayOrganization string
+	var quayUrl string
+	var keycloakUrl string
+	var keycloakRealm string
+	var kubeconfigServer string
+	var iamGroupCacheTTL time.Duration
+	var defaultUserGroups string
+	var parentHostedZoneIds string
+	var teamClusterRoles string
+	var userConfigNamespaces string
+	var userConfigNamespaceTemplates string
+	var githubAllowAdmin bool
+	var keycloakRealmRoles string
+	var keycloakClientRoles string
+	var kubernetesClusterRoles string
+	var kubernetesNamespaceRoles string
+	var kubernetesRoles string
+	var kubernetesNamespaces string
+	var awsAccountNamespaces string
+	var namespaceTemplateClusterRoles string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&defaultPermissionsBoundaryArn, "default-permissions-boundary-arn", "",
+		"The managed policy applied as permissions boundary to IAM users that do not set one in their AwsAccount.")
+	flag.StringVar(&permissionsBoundaryArns, "permissions-boundary-arns", "",
+		"Comma separated managed policies that AwsAccounts may set as permissions boundary instead of the default.")
+	flag.StringVar(&clusterId, "cluster-id", "",
+		"Identifies this cluster in the tags of the IAM users it creates, when several clusters share an AWS account.")
+	flag.StringVar(&githubOrganization, "github-organization", "",
+		"The GitHub organization users are invited to. GitHub accounts are not managed unless it is set, "+
+			"the token is read from the GITHUB_TOKEN environment variable.")
+	flag.StringVar(&githubApiUrl, "github-api-url", github.DefaultBaseURL, "The URL of the GitHub REST API.")
+	flag.BoolVar(&githubAllowAdmin, "github-allow-admin", false,
+		"Allow GitHub accounts with the admin role, which owns the organization.")
+	flag.StringVar(&quayOrganization, "quay-organization", "",
+		"The Quay organization users get robot accounts in. Quay accounts are not managed unless it is set, "+
+			"the OAuth token is read from the QUAY_TOKEN environment variable.")
+	flag.StringVar(&quayUrl, "quay-url", quay.DefaultBaseURL, "The address of the Quay registry and its API.")
+	flag.StringVar(&keycloakUrl, "keycloak-url", "",
+		"The address of the Keycloak server users are created in. Keycloak accounts are not managed unless it is set, "+
+			"the client credentials are read from the KEYCLOAK_CLIENT_ID and KEYCLOAK_CLIENT_SECRET environment variables.")
+	flag.StringVar(&keycloakRealm, "keycloak-realm", "", "The Keycloak realm users are created in.")
+	flag.StringVar(&keycloakRealmRoles, "keycloak-realm-roles", "",
+		"Comma separated realm roles that Keycloak accounts may be assigned.")
+	flag.StringVar(&keycloakClientRoles, "keycloak-client-roles", "",
+		"Comma separated client roles that Keycloak accounts may be assigned, each as <clientId>/<role>.")
+	flag.StringVar(&kubernetesClusterRoles, "kubernetes-cluster-roles", "",
+		"Comma separated ClusterRoles that Kubernetes accounts may be granted cluster wide.")
+	flag.StringVar(&kubernetesNamespaceRoles, "kubernetes-namespace-roles", "admin,edit,view",
+		"Comma separated ClusterRoles that Kubernetes accounts may be granted in their own namespace and the namespaces of --kubernetes-namespaces.")
+	flag.StringVar(&kubernetesRoles, "kubernetes-roles", "",
+		"Comma separated Roles that Kubernetes accounts may be granted, each as <namespace>/<name>.")
+	flag.StringVar(&kubernetesNamespaces, "kubernetes-namespaces", "",
+		"Comma separated namespaces that Kubernetes accounts may be granted ClusterRoles in, besides the namespaces of their users.")
+	flag.StringVar(&kubeconfigServer, "kubeconfig-server", "",
+		"The address of the API server written to the kubeconfig of users, defaults to the address the operator connects to.")
+	flag.DurationVar(&iamGroupCacheTTL, "iam-group-cache-ttl", 5*time.Minute,
+		"How long the list of IAM groups that the groups of AwsAccounts are validated against is cached.")
+	flag.StringVar(&defaultUserGroups, "default-user-groups", "",
+		"Comma separated IAM groups given to the AWS accounts of Users that do not list any groups.")
+	flag.StringVar(&parentHostedZoneIds, "parent-hosted-zone-ids", "",
+		"Comma separated Route53 hosted zones that the hosted zones of AwsAccounts may be delegated from.")
+	flag.StringVar(&awsAccountNamespaces, "aws-account-namespaces", "",
+		"Comma separated existing namespaces that AwsAccounts may put their Secrets in, besides the namespaces kuadra creates for them.")
+	flag.StringVar(&namespaceTemplateClusterRoles, "namespace-template-cluster-roles", "admin,edit,view",
+		"Comma separated ClusterRoles that the RoleBindings of NamespaceTemplates may reference.")
+	flag.StringVar(&teamClusterRoles, "team-cluster-roles", "edit,view",
+		"Comma separated ClusterRoles that Teams may bind to their members in the team namespaces.")
+	flag.StringVar(&userConfigNamespaces, "user-config-namespaces", "",
+		"Comma separated namespaces whose user config ConfigMaps are turned into Users. "+
+			"Only give write access to ConfigMaps there to those allowed to create Users.")
+	flag.StringVar(&userConfigNamespaceTemplates, "user-config-namespace-templates", "",
+		"Comma separated NamespaceTemplates that users in user config ConfigMaps may ask for, each as <namespace>/<name> "+
+			"where namespace is the namespace of the ConfigMap. Only list templates whose roles anyone writing ConfigMaps there may bind.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -66,7 +150,8 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	restConfig := ctrl.GetConfigOrDie()
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
@@ -90,34 +175,185 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Set up clients for IAM and (TODO) Route53
+	// Set up clients for IAM and Route53
 	iamWrapper, err := aws.NewIamWrapper()
 	if err != nil {
 		setupLog.Error(err, "couldn't load AWS configuration")
 		os.Exit(1)
 	}
+	route53Wrapper, err := aws.NewRoute53Wrapper()
+	if err != nil {
+		setupLog.Error(err, "couldn't load AWS configuration")
+		os.Exit(1)
+	}
+	iamGroupCache := aws.NewGroupCache(*iamWrapper, iamGroupCacheTTL)
+
+	enableWebhooks := os.Getenv("ENABLE_WEBHOOKS") != "false"
+	serviceOptions := kuadrav1.ServiceOptions{
+		GithubAdmin:              githubAllowAdmin,
+		KeycloakRealmRoles:       splitList(keycloakRealmRoles),
+		KeycloakClientRoles:      splitList(keycloakClientRoles),
+		KubernetesClusterRoles:   splitList(kubernetesClusterRoles),
+		KubernetesNamespaceRoles: splitList(kubernetesNamespaceRoles),
+		KubernetesRoles:          splitList(kubernetesRoles),
+		KubernetesNamespaces:     splitList(kubernetesNamespaces),
+	}
+	if githubOrganization == "" {
+		serviceOptions.DisabledServices = append(serviceOptions.DisabledServices, kuadrav1.ServiceGithub)
+	}
+	if quayOrganization == "" {
+		serviceOptions.DisabledServices = append(serviceOptions.DisabledServices, kuadrav1.ServiceQuay)
+	}
+	if keycloakUrl == "" {
+		serviceOptions.DisabledServices = append(serviceOptions.DisabledServices, kuadrav1.ServiceKeycloak)
+	}
 
 	if err = (&controller.AwsAccountReconciler{
-		Client:     mgr.GetClient(),
-		Scheme:     mgr.GetScheme(),
-		IamWrapper: *iamWrapper,
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		IamWrapper:     *iamWrapper,
+		Route53Wrapper: *route53Wrapper,
+		IamGroupCache:  iamGroupCache,
+		Recorder:       mgr.GetEventRecorderFor("awsaccount-controller"),
+
+		ClusterId:                     clusterId,
+		DefaultPermissionsBoundaryArn: defaultPermissionsBoundaryArn,
+		PermissionsBoundaryArns:       splitList(permissionsBoundaryArns),
+		ParentHostedZoneIds:           splitList(parentHostedZoneIds),
+		Namespaces:                    splitList(awsAccountNamespaces),
+		NamespaceTemplateClusterRoles: splitList(namespaceTemplateClusterRoles),
+		GrantAccessRequests:           enableWebhooks,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AwsAccount")
 		os.Exit(1)
 	}
-	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err = (&kuadrav1.AwsAccount{}).SetupWebhookWithManager(mgr); err != nil {
+	if enableWebhooks {
+		if err = (&kuadrav1.AwsAccount{}).SetupWebhookWithManager(mgr, iamGroupCache); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "CronJob")
 			os.Exit(1)
 		}
+		if err = (&kuadrav1.User{}).SetupWebhookWithManager(mgr, splitList(defaultUserGroups), serviceOptions); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "User")
+			os.Exit(1)
+		}
+		if err = (&kuadrav1.AccessRequest{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AccessRequest")
+			os.Exit(1)
+		}
+	}
+	if err = (&controller.AccessRequestReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("accessrequest-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AccessRequest")
+		os.Exit(1)
 	}
 	if err = (&controller.UserReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("user-controller"),
+		Options:  serviceOptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "User")
 		os.Exit(1)
 	}
+	if err = (&controller.TeamReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		ClusterRoles: splitList(teamClusterRoles),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Team")
+		os.Exit(1)
+	}
+	if err = (&controller.UserConfigReconciler{
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Recorder:           mgr.GetEventRecorderFor("userconfig-controller"),
+		Namespaces:         splitList(userConfigNamespaces),
+		NamespaceTemplates: splitList(userConfigNamespaceTemplates),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "UserConfig")
+		os.Exit(1)
+	}
+	if githubOrganization != "" {
+		githubWrapper, err := github.NewGithubWrapper(githubApiUrl, githubOrganization, os.Getenv("GITHUB_TOKEN"))
+		if err != nil {
+			setupLog.Error(err, "couldn't set up GitHub client")
+			os.Exit(1)
+		}
+		if err = (&controller.GithubAccountReconciler{
+			Client:        mgr.GetClient(),
+			Scheme:        mgr.GetScheme(),
+			GithubWrapper: githubWrapper,
+			Recorder:      mgr.GetEventRecorderFor("githubaccount-controller"),
+			Options:       serviceOptions,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GithubAccount")
+			os.Exit(1)
+		}
+	}
+	if quayOrganization != "" {
+		quayWrapper, err := quay.NewQuayWrapper(quayUrl, quayOrganization, os.Getenv("QUAY_TOKEN"))
+		if err != nil {
+			setupLog.Error(err, "couldn't set up Quay client")
+			os.Exit(1)
+		}
+		registry, err := url.Parse(quayUrl)
+		if err != nil {
+			setupLog.Error(err, "invalid Quay address")
+			os.Exit(1)
+		}
+		if err = (&controller.QuayAccountReconciler{
+			Client:      mgr.GetClient(),
+			Scheme:      mgr.GetScheme(),
+			QuayWrapper: quayWrapper,
+			Recorder:    mgr.GetEventRecorderFor("quayaccount-controller"),
+			Registry:    registry.Host,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "QuayAccount")
+			os.Exit(1)
+		}
+	}
+	if kubeconfigServer == "" {
+		kubeconfigServer = restConfig.Host
+	}
+	caData := restConfig.CAData
+	if len(caData) == 0 && restConfig.CAFile != "" {
+		if caData, err = os.ReadFile(restConfig.CAFile); err != nil {
+			setupLog.Error(err, "couldn't read the certificate authority of the API server")
+			os.Exit(1)
+		}
+	}
+	if err = (&controller.KubernetesAccountReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		TokenRequester: kubernetes.NewTokenRequester(mgr.GetClient()),
+		Recorder:       mgr.GetEventRecorderFor("kubernetesaccount-controller"),
+		APIServer:      kubeconfigServer,
+		CAData:         caData,
+		Options:        serviceOptions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KubernetesAccount")
+		os.Exit(1)
+	}
+	if keycloakUrl != "" {
+		keycloakWrapper, err := keycloak.NewKeycloakWrapper(keycloakUrl, keycloakRealm, os.Getenv("KEYCLOAK_CLIENT_ID"), os.Getenv("KEYCLOAK_CLIENT_SECRET"))
+		if err != nil {
+			setupLog.Error(err, "couldn't set up Keycloak client")
+			os.Exit(1)
+		}
+		if err = (&controller.KeycloakAccountReconciler{
+			Client:          mgr.GetClient(),
+			Scheme:          mgr.GetScheme(),
+			KeycloakWrapper: keycloakWrapper,
+			Recorder:        mgr.GetEventRecorderFor("keycloakaccount-controller"),
+			Options:         serviceOptions,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "KeycloakAccount")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -135,3 +371,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated flag value, dropping blank items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sTypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+// rotateAccessKey replaces the access key in the aws-credentials Secret once it is older than
+// spec.accessKeyRotation.maxAge. The replaced key stays active for the grace period so that
+// workloads can pick up the new Secret, then it is deactivated and deleted.
+func (r *AwsAccountReconciler) rotateAccessKey(ctx context.Context, awsAccount *kuadrav1.AwsAccount) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	rotation := awsAccount.Spec.AccessKeyRotation
+	if rotation == nil {
+		awsAccount.Status.AccessKeyRotation = nil
+		return ctrl.Result{}, nil
+	}
+	if awsAccount.Status.AccessKeyRotation == nil {
+		awsAccount.Status.AccessKeyRotation = &kuadrav1.AccessKeyRotationStatus{}
+	}
+	rotationStatus := awsAccount.Status.AccessKeyRotation
+	now := time.Now()
+
+	if rotationStatus.CurrentAccessKeyId == "" {
+		currentAccessKeyId, err := r.getStoredAccessKeyId(ctx, awsAccount)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		rotationStatus.CurrentAccessKeyId = currentAccessKeyId
+	}
+
+	accessKeys, err := r.IamWrapper.ListAccessKeys(ctx, awsAccount.Spec.UserName)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	var current *types.AccessKeyMetadata
+	var previous []types.AccessKeyMetadata
+	for i := range accessKeys {
+		if *accessKeys[i].AccessKeyId == rotationStatus.CurrentAccessKeyId {
+			current = &accessKeys[i]
+		} else {
+			previous = append(previous, accessKeys[i])
+		}
+	}
+	if current == nil {
+		// The stored key is unknown to IAM, leave the keys alone rather than guess which one workloads use
+		log.Info("current access key not found, skipping rotation", "accessKeyId", rotationStatus.CurrentAccessKeyId)
+		rotationStatus.CurrentAccessKeyId = ""
+		rotationStatus.CurrentAccessKeyCreated = nil
+		return ctrl.Result{}, nil
+	}
+	if current.CreateDate != nil {
+		rotationStatus.CurrentAccessKeyCreated = &metav1.Time{Time: *current.CreateDate}
+	}
+
+	if len(previous) > 0 {
+		if rotationStatus.LastRotationTime == nil {
+			// Keys found when rotation is enabled get the same grace period as replaced keys, starting now
+			rotationStatus.LastRotationTime = &metav1.Time{Time: now}
+		}
+		graceEnd := rotationStatus.LastRotationTime.Add(rotation.GracePeriod.Duration)
+		if now.Before(graceEnd) {
+			return ctrl.Result{RequeueAfter: graceEnd.Sub(now)}, nil
+		}
+		for _, accessKey := range previous {
+			if err := r.IamWrapper.UpdateAccessKeyStatus(ctx, awsAccount.Spec.UserName, *accessKey.AccessKeyId, types.StatusTypeInactive); err != nil {
+				log.Error(err, "unable to deactivate previous access key", "accessKeyId", *accessKey.AccessKeyId)
+				return ctrl.Result{}, err
+			}
+			if err := r.IamWrapper.DeleteAccessKeyIfExists(ctx, awsAccount.Spec.UserName, *accessKey.AccessKeyId); err != nil {
+				log.Error(err, "unable to delete previous access key", "accessKeyId", *accessKey.AccessKeyId)
+				return ctrl.Result{}, err
+			}
+			log.V(1).Info("deleted previous access key", "accessKeyId", *accessKey.AccessKeyId)
+		}
+		rotationStatus.PreviousAccessKeyId = ""
+		rotationStatus.PreviousAccessKeyDeleted = &metav1.Time{Time: now}
+	}
+
+	if rotationStatus.CurrentAccessKeyCreated == nil {
+		return ctrl.Result{}, nil
+	}
+	rotateAt := rotationStatus.CurrentAccessKeyCreated.Add(rotation.MaxAge.Duration)
+	if now.Before(rotateAt) {
+		return ctrl.Result{RequeueAfter: rotateAt.Sub(now)}, nil
+	}
+
+	accessKey, err := r.IamWrapper.CreateAccessKeyPair(ctx, awsAccount.Spec.UserName)
+	if err != nil {
+		log.Error(err, "unable to create access key")
+		return ctrl.Result{}, err
+	}
+	if err := r.createOrUpdateSecret(ctx, awsAccount, accessKeySecretData(accessKey), "aws-credentials", awsAccount.Status.Namespace); err != nil {
+		log.Error(err, "unable to update secret for AWS credentials")
+		return ctrl.Result{}, err
+	}
+	log.V(1).Info("rotated access key", "accessKeyId", *accessKey.AccessKeyId, "previousAccessKeyId", rotationStatus.CurrentAccessKeyId)
+
+	rotationStatus.PreviousAccessKeyId = rotationStatus.CurrentAccessKeyId
+	rotationStatus.CurrentAccessKeyId = *accessKey.AccessKeyId
+	rotationStatus.CurrentAccessKeyCreated = &metav1.Time{Time: now}
+	if accessKey.CreateDate != nil {
+		rotationStatus.CurrentAccessKeyCreated = &metav1.Time{Time: *accessKey.CreateDate}
+	}
+	rotationStatus.LastRotationTime = &metav1.Time{Time: now}
+
+	// Requeue at the end of the grace period to clean up the previous key
+	return ctrl.Result{RequeueAfter: rotation.GracePeriod.Duration + time.Second}, nil
+}
+
+// getStoredAccessKeyId returns the access key id held in the aws-credentials Secret written for the AwsAccount
+func (r *AwsAccountReconciler) getStoredAccessKeyId(ctx context.Context, awsAccount *kuadrav1.AwsAccount) (string, error) {
+	secret := &v1.Secret{}
+	if err := r.Get(ctx, k8sTypes.NamespacedName{Name: "aws-credentials", Namespace: awsAccount.Status.Namespace}, secret); err != nil {
+		return "", err
+	}
+	if secret.Annotations[ownerAnnotationKey] != objectOwner(awsAccount) {
+		return "", fmt.Errorf("%w: %s/%s", errSecretNotManaged, secret.Namespace, secret.Name)
+	}
+	return string(secret.Data["AWS_ACCESS_KEY_ID"]), nil
+}
+
+func accessKeySecretData(accessKey *types.AccessKey) map[string]string {
+	return map[string]string{
+		"AWS_ACCESS_KEY_ID":     *accessKey.AccessKeyId,
+		"AWS_SECRET_ACCESS_KEY": *accessKey.SecretAccessKey,
+	}
+}
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+// awsAccountAccess returns the access an AwsAccount asks for
+func awsAccountAccess(awsAccount *kuadrav1.AwsAccount) kuadrav1.Access {
+	return kuadrav1.Access{
+		Services:          []string{kuadrav1.ServiceAws},
+		Groups:            awsAccount.Spec.GroupNames(),
+		ManagedPolicyArns: awsAccount.Spec.ManagedPolicyArns,
+	}
+}
+
+// allowedAwsAccount returns a copy of the AwsAccount without the denied groups and managed policies
+func allowedAwsAccount(awsAccount *kuadrav1.AwsAccount, denied kuadrav1.Access) *kuadrav1.AwsAccount {
+	allowed := awsAccount.DeepCopy()
+	allowed.Spec.Groups = slice.GetLeftDifference(awsAccount.Spec.Groups, denied.Groups)
+	allowed.Spec.GroupWindows = nil
+	for _, window := range awsAccount.Spec.GroupWindows {
+		if !slice.Contains(denied.Groups, window.Name) {
+			allowed.Spec.GroupWindows = append(allowed.Spec.GroupWindows, *window.DeepCopy())
+		}
+	}
+	allowed.Spec.ManagedPolicyArns = slice.GetLeftDifference(awsAccount.Spec.ManagedPolicyArns, denied.ManagedPolicyArns)
+	return allowed
+}
+
+// setAccessAllowedCondition reports whether the AccessPolicies allow all of the AwsAccount's groups and
+// managed policies, emitting an event when they stop doing so
+func (r *AwsAccountReconciler) setAccessAllowedCondition(awsAccount *kuadrav1.AwsAccount, denied kuadrav1.Access) {
+	conditions := &awsAccount.Status.Conditions
+	if denied.IsEmpty() {
+		setConditionTrue(conditions, awsAccount.Generation, kuadrav1.ConditionAccessAllowed, "The AccessPolicies allow the AwsAccount's access")
+		return
+	}
+	message := "Not allowed by any AccessPolicy: " + denied.String()
+	if !meta.IsStatusConditionFalse(*conditions, kuadrav1.ConditionAccessAllowed) {
+		r.Recorder.Event(awsAccount, v1.EventTypeWarning, kuadrav1.ReasonAccessPolicyDenied, message)
+	}
+	setCondition(conditions, awsAccount.Generation, kuadrav1.ConditionAccessAllowed, metav1.ConditionFalse, kuadrav1.ReasonAccessPolicyDenied, message)
+}
+
+// setAccessDeniedConditions reports an AwsAccount that is not reconciled as no AccessPolicy allows AWS
+// accounts in its namespace
+func (r *AwsAccountReconciler) setAccessDeniedConditions(awsAccount *kuadrav1.AwsAccount, denied kuadrav1.Access) {
+	r.setAccessAllowedCondition(awsAccount, denied)
+	setCondition(&awsAccount.Status.Conditions, awsAccount.Generation, kuadrav1.ConditionReady, metav1.ConditionFalse, kuadrav1.ReasonAccessPolicyDenied,
+		"No AccessPolicy allows AWS accounts in namespace "+awsAccount.Namespace)
+}
+
+// revokeIamAccess removes the IAM user of an AwsAccount that no AccessPolicy allows from its groups and
+// detaches its managed policies. The user and its Secrets are kept for when a policy allows it again,
+// and a user the AwsAccount does not manage is left untouched.
+func (r *AwsAccountReconciler) revokeIamAccess(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	userName := awsAccount.Spec.UserName
+
+	userExists, err := r.IamWrapper.IsExistingUser(ctx, userName)
+	if err != nil || !userExists {
+		return err
+	}
+	tags, err := r.IamWrapper.ListUserTags(ctx, userName)
+	if err != nil {
+		return err
+	}
+	if !r.isOwnedUser(awsAccount, tags) {
+		return fmt.Errorf("%w: the access of %s was not revoked", errUserNotManaged, userName)
+	}
+
+	groups, err := r.IamWrapper.ListGroupsForUser(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if _, err := r.IamWrapper.RemoveUserFromGroup(ctx, *group.GroupName, userName); err != nil {
+			return err
+		}
+		log.V(1).Info("removed user from group", "groupName", *group.GroupName)
+	}
+	awsAccount.Status.UserGroups = nil
+
+	attachedPolicies, err := r.IamWrapper.ListAttachedUserPolicies(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, policy := range attachedPolicies {
+		if err := r.IamWrapper.DetachUserPolicyIfAttached(ctx, userName, *policy.PolicyArn); err != nil {
+			return err
+		}
+		log.V(1).Info("detached policy from user", "policyArn", *policy.PolicyArn)
+	}
+	awsAccount.Status.ManagedPolicyArns = nil
+
+	message := "No AccessPolicy allows AWS accounts in namespace " + awsAccount.Namespace
+	for _, conditionType := range []string{kuadrav1.ConditionGroupsSynced, kuadrav1.ConditionPoliciesSynced} {
+		setCondition(&awsAccount.Status.Conditions, awsAccount.Generation, conditionType, metav1.ConditionFalse, kuadrav1.ReasonAccessPolicyDenied, message)
+	}
+	return nil
+}
+
+// awsAccountsForAccessPolicy maps an AccessPolicy to every AwsAccount, as its namespace selector may
+// have selected or may select any namespace
+func (r *AwsAccountReconciler) awsAccountsForAccessPolicy(accessPolicy client.Object) []reconcile.Request {
+	var awsAccounts kuadrav1.AwsAccountList
+	if err := r.List(context.Background(), &awsAccounts); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(awsAccounts.Items))
+	for i := range awsAccounts.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&awsAccounts.Items[i])})
+	}
+	return requests
+}
+
+// serviceDenied reports whether no AccessPolicy allows accounts in the service in the namespace. The
+// webhooks only check Users, this catches account resources created directly or without webhooks.
+func serviceDenied(ctx context.Context, c client.Reader, namespace string, service string) (bool, error) {
+	denied, err := kuadrav1.DeniedAccessInNamespace(ctx, c, namespace, kuadrav1.Access{Services: []string{service}})
+	if err != nil {
+		return false, err
+	}
+	return slice.Contains(denied.Services, service), nil
+}
+
+// setServiceDeniedConditions reports an account whose access was withdrawn, as for a suspension, as no
+// AccessPolicy allows the service in its namespace
+func setServiceDeniedConditions(conditions *[]metav1.Condition, generation int64, namespace string, service string, revokeErr error) {
+	if revokeErr != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionReady, revokeErr)
+		return
+	}
+	setCondition(conditions, generation, kuadrav1.ConditionReady, metav1.ConditionFalse, kuadrav1.ReasonAccessPolicyDenied,
+		"No AccessPolicy allows "+service+" accounts in namespace "+namespace)
+}
+
+// accountsForAccessPolicy maps an AccessPolicy to every account of the list's kind, as its namespace
+// selector may have selected or may select any namespace
+func accountsForAccessPolicy(c client.Reader, list client.ObjectList) handler.MapFunc {
+	return func(accessPolicy client.Object) []reconcile.Request {
+		accounts := list.DeepCopyObject().(client.ObjectList)
+		if err := c.List(context.Background(), accounts); err != nil {
+			return nil
+		}
+		items, err := meta.ExtractList(accounts)
+		if err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			if account, ok := item.(client.Object); ok {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(account)})
+			}
+		}
+		return requests
+	}
+}
+
+// reconcileAllowedService reconciles one of the User's accounts unless no AccessPolicy allows the
+// service in the User's namespace. The account of a denied service is left as it is, its controller
+// withdrawing its access, and reported as not ready.
+func reconcileAllowedService(user *kuadrav1.User, denied kuadrav1.Access, service string, kind string, name string,
+	reconcileService func() (*kuadrav1.ServiceStatus, error)) (*kuadrav1.ServiceStatus, error) {
+	if !slice.Contains(denied.Services, service) {
+		return reconcileService()
+	}
+	return &kuadrav1.ServiceStatus{
+		Service: service,
+		Kind:    kind,
+		Name:    name,
+		Ready:   metav1.ConditionFalse,
+		Reason:  kuadrav1.ReasonAccessPolicyDenied,
+		Message: "No AccessPolicy allows the service in namespace " + user.Namespace,
+	}, nil
+}
+
+// usersForAccessPolicy maps an AccessPolicy to every User
+func (r *UserReconciler) usersForAccessPolicy(accessPolicy client.Object) []reconcile.Request {
+	var users kuadrav1.UserList
+	if err := r.List(context.Background(), &users); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(users.Items))
+	for i := range users.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&users.Items[i])})
+	}
+	return requests
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+// accessRequestAccess returns the access an AccessRequest asks for
+func accessRequestAccess(accessRequest *kuadrav1.AccessRequest) kuadrav1.Access {
+	return kuadrav1.Access{
+		Groups:            accessRequest.Spec.Groups,
+		ManagedPolicyArns: accessRequest.Spec.ManagedPolicyArns,
+	}
+}
+
+// untrustedApproval explains why the approval of an AccessRequest cannot be trusted, or returns an
+// empty string. The webhook records the requester and approver and refuses self-approval, this catches
+// AccessRequests that did not go through it.
+func untrustedApproval(accessRequest *kuadrav1.AccessRequest) string {
+	switch {
+	case accessRequest.Spec.RequestedBy == "":
+		return "it has no requester"
+	case accessRequest.Status.Approval.Approver == "":
+		return "its approval has no approver"
+	case accessRequest.Status.Approval.Approver == accessRequest.Spec.RequestedBy:
+		return "it was approved by its requester " + accessRequest.Spec.RequestedBy
+	}
+	return ""
+}
+
+// activeGrants returns the access granted to the AwsAccount by the AccessRequests that are approved
+// and have not expired at now, sorted by AccessRequest name. Nothing is granted without the webhooks,
+// nor by AccessRequests whose approval cannot be trusted.
+func (r *AwsAccountReconciler) activeGrants(ctx context.Context, awsAccount *kuadrav1.AwsAccount, now time.Time) ([]kuadrav1.AccessGrant, error) {
+	if !r.GrantAccessRequests {
+		return nil, nil
+	}
+	var accessRequests kuadrav1.AccessRequestList
+	if err := r.List(ctx, &accessRequests, client.InNamespace(awsAccount.Namespace)); err != nil {
+		return nil, err
+	}
+	var grants []kuadrav1.AccessGrant
+	for i := range accessRequests.Items {
+		accessRequest := &accessRequests.Items[i]
+		if accessRequest.Spec.AwsAccount != awsAccount.Name || !accessRequest.GrantActive(now) {
+			continue
+		}
+		if reason := untrustedApproval(accessRequest); reason != "" {
+			log.FromContext(ctx).Info("not granting AccessRequest", "accessRequest", accessRequest.Name, "reason", reason)
+			r.Recorder.Event(accessRequest, v1.EventTypeWarning, kuadrav1.ReasonApprovalNotTrusted, "Nothing is granted as "+reason)
+			continue
+		}
+		grants = append(grants, kuadrav1.AccessGrant{
+			AccessRequest:     accessRequest.Name,
+			Groups:            accessRequest.Spec.Groups,
+			ManagedPolicyArns: accessRequest.Spec.ManagedPolicyArns,
+			ExpiresAt:         metav1.NewTime(accessRequest.GrantExpiresAt()),
+		})
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].AccessRequest < grants[j].AccessRequest })
+	return grants, nil
+}
+
+// withoutGrantedAccess leaves the groups and managed policies granted by AccessRequests out of the
+// access, as the approval of an AccessRequest overrides the AccessPolicies
+func withoutGrantedAccess(access kuadrav1.Access, grants []kuadrav1.AccessGrant) kuadrav1.Access {
+	for _, grant := range grants {
+		access.Groups = slice.GetLeftDifference(access.Groups, grant.Groups)
+		access.ManagedPolicyArns = slice.GetLeftDifference(access.ManagedPolicyArns, grant.ManagedPolicyArns)
+	}
+	return access
+}
+
+// addGrants adds the groups and managed policies granted by AccessRequests to the AwsAccount's spec
+func addGrants(awsAccount *kuadrav1.AwsAccount, grants []kuadrav1.AccessGrant) {
+	for _, grant := range grants {
+		addGroups(&awsAccount.Spec, grant.Groups...)
+		awsAccount.Spec.ManagedPolicyArns = appendMissing(awsAccount.Spec.ManagedPolicyArns, grant.ManagedPolicyArns...)
+	}
+}
+
+// setGrants records the grants the IAM user now has, emitting an event for each grant that starts
+// or ends
+func (r *AwsAccountReconciler) setGrants(awsAccount *kuadrav1.AwsAccount, grants []kuadrav1.AccessGrant) {
+	for _, previous := range awsAccount.Status.Grants {
+		if slice.IndexOf(grants, func(g kuadrav1.AccessGrant) bool { return g.AccessRequest == previous.AccessRequest }) < 0 {
+			r.Recorder.Event(awsAccount, v1.EventTypeNormal, kuadrav1.ReasonAccessRevoked,
+				"Access granted by AccessRequest "+previous.AccessRequest+" ended: "+grantAccess(previous).String())
+		}
+	}
+	for _, grant := range grants {
+		if slice.IndexOf(awsAccount.Status.Grants, func(g kuadrav1.AccessGrant) bool { return g.AccessRequest == grant.AccessRequest }) < 0 {
+			r.Recorder.Event(awsAccount, v1.EventTypeNormal, kuadrav1.ReasonAccessGranted,
+				"AccessRequest "+grant.AccessRequest+" grants "+grantAccess(grant).String()+" until "+grant.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+	}
+	awsAccount.Status.Grants = grants
+}
+
+// grantAccess returns the access of a grant
+func grantAccess(grant kuadrav1.AccessGrant) kuadrav1.Access {
+	return kuadrav1.Access{Groups: grant.Groups, ManagedPolicyArns: grant.ManagedPolicyArns}
+}
+
+// grantRequeue returns how long until the first of the grants ends, so that the access is revoked
+// on time, or zero when there are no grants
+func grantRequeue(grants []kuadrav1.AccessGrant, now time.Time) time.Duration {
+	var requeue time.Duration
+	for _, grant := range grants {
+		if untilExpiry := grant.ExpiresAt.Sub(now); requeue == 0 || untilExpiry < requeue {
+			requeue = untilExpiry
+		}
+	}
+	return requeue
+}
+
+// awsAccountForAccessRequest maps an AccessRequest to the AwsAccount it asks access for
+func (r *AwsAccountReconciler) awsAccountForAccessRequest(accessRequest client.Object) []reconcile.Request {
+	request, ok := accessRequest.(*kuadrav1.AccessRequest)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: request.Namespace, Name: request.Spec.AwsAccount}}}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+// AccessRequestReconciler reconciles an AccessRequest object
+type AccessRequestReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accessrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accessrequests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Reconcile moves an AccessRequest through its phases as it is approved or denied and its access
+// expires, emitting an event at each step. The access itself is granted by the AwsAccountReconciler.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *AccessRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var accessRequest kuadrav1.AccessRequest
+	if err := r.Get(ctx, req.NamespacedName, &accessRequest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	now := time.Now()
+	phase := accessRequest.Phase(now)
+	if phase != accessRequest.Status.Phase {
+		r.recordPhase(&accessRequest, phase)
+	}
+	status := accessRequest.Status.DeepCopy()
+	status.Phase = phase
+	status.ExpiresAt = nil
+	if accessRequest.Approved() {
+		expiresAt := metav1.NewTime(accessRequest.GrantExpiresAt())
+		status.ExpiresAt = &expiresAt
+	}
+
+	if !reflect.DeepEqual(accessRequest.Status, *status) {
+		accessRequest.Status = *status
+		if err := r.Status().Update(ctx, &accessRequest); err != nil {
+			log.Error(err, "unable to update AccessRequest status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+		}
+	}
+
+	if phase == kuadrav1.AccessRequestActive {
+		return ctrl.Result{RequeueAfter: accessRequest.GrantExpiresAt().Sub(now)}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// recordPhase emits the event of the phase the AccessRequest enters
+func (r *AccessRequestReconciler) recordPhase(accessRequest *kuadrav1.AccessRequest, phase kuadrav1.AccessRequestPhase) {
+	access := accessRequestAccess(accessRequest).String()
+	switch phase {
+	case kuadrav1.AccessRequestPending:
+		r.Recorder.Event(accessRequest, v1.EventTypeNormal, kuadrav1.ReasonAccessRequested,
+			"Waiting for approval of "+access+" for AwsAccount "+accessRequest.Spec.AwsAccount+" for "+accessRequest.Spec.Duration.Duration.String())
+	case kuadrav1.AccessRequestActive:
+		r.Recorder.Event(accessRequest, v1.EventTypeNormal, kuadrav1.ReasonAccessApproved,
+			accessRequest.Status.Approval.Approver+" approved "+access+" until "+accessRequest.GrantExpiresAt().UTC().Format(time.RFC3339))
+	case kuadrav1.AccessRequestDenied:
+		r.Recorder.Event(accessRequest, v1.EventTypeNormal, kuadrav1.ReasonAccessDenied,
+			accessRequest.Status.Approval.Approver+" denied "+access)
+	case kuadrav1.AccessRequestExpired:
+		r.Recorder.Event(accessRequest, v1.EventTypeNormal, kuadrav1.ReasonAccessExpired,
+			"Access to "+access+" ended at "+accessRequest.GrantExpiresAt().UTC().Format(time.RFC3339))
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AccessRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kuadrav1.AccessRequest{}).
+		Complete(r)
+}
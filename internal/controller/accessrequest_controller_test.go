@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+var _ = Describe("AccessRequest controller", func() {
+
+	ctx := context.Background()
+
+	newAccessRequest := func(approval *kuadrav1.AccessRequestApproval) *kuadrav1.AccessRequest {
+		return &kuadrav1.AccessRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns-admin", Namespace: "default"},
+			Spec: kuadrav1.AccessRequestSpec{
+				AwsAccount:    "awsaccount-sample",
+				Groups:        []string{"dns-admins"},
+				Justification: "Delegating a hosted zone",
+				Duration:      metav1.Duration{Duration: time.Hour},
+				RequestedBy:   "jdoe",
+			},
+			Status: kuadrav1.AccessRequestStatus{Approval: approval},
+		}
+	}
+
+	reconcileAccessRequest := func(accessRequest *kuadrav1.AccessRequest) (reconcile.Result, *kuadrav1.AccessRequest, *record.FakeRecorder) {
+		k8sClient := fake.NewClientBuilder().WithObjects(accessRequest).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &AccessRequestReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: recorder}
+		lookupKey := k8Types.NamespacedName{Name: accessRequest.Name, Namespace: accessRequest.Namespace}
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		reconciled := &kuadrav1.AccessRequest{}
+		Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+		return result, reconciled, recorder
+	}
+
+	It("Should wait for approval", func() {
+		_, reconciled, recorder := reconcileAccessRequest(newAccessRequest(nil))
+		Expect(reconciled.Status.Phase).Should(Equal(kuadrav1.AccessRequestPending))
+		Expect(recorder.Events).Should(Receive(ContainSubstring("Waiting for approval of IAM groups dns-admins")))
+	})
+
+	It("Should be active until the duration ends once approved", func() {
+		approvedAt := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+		result, reconciled, recorder := reconcileAccessRequest(newAccessRequest(&kuadrav1.AccessRequestApproval{
+			Approved: true,
+			Approver: "jane",
+			Time:     metav1.NewTime(approvedAt),
+		}))
+		Expect(reconciled.Status.Phase).Should(Equal(kuadrav1.AccessRequestActive))
+		Expect(reconciled.Status.ExpiresAt.Time).Should(BeTemporally("==", approvedAt.Add(time.Hour)))
+		Expect(result.RequeueAfter).Should(BeNumerically("~", 50*time.Minute, time.Minute))
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonAccessApproved + " jane approved")))
+	})
+
+	It("Should expire once the duration has passed", func() {
+		accessRequest := newAccessRequest(&kuadrav1.AccessRequestApproval{
+			Approved: true,
+			Approver: "jane",
+			Time:     metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		})
+		accessRequest.Status.Phase = kuadrav1.AccessRequestActive
+		result, reconciled, recorder := reconcileAccessRequest(accessRequest)
+		Expect(reconciled.Status.Phase).Should(Equal(kuadrav1.AccessRequestExpired))
+		Expect(result.RequeueAfter).Should(BeZero())
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonAccessExpired)))
+	})
+
+	It("Should report a denial", func() {
+		_, reconciled, recorder := reconcileAccessRequest(newAccessRequest(&kuadrav1.AccessRequestApproval{Approver: "jane"}))
+		Expect(reconciled.Status.Phase).Should(Equal(kuadrav1.AccessRequestDenied))
+		Expect(reconciled.Status.ExpiresAt).Should(BeNil())
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonAccessDenied)))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/aws/smithy-go/middleware"
 )
 
@@ -12,7 +13,9 @@ type IamWrapper interface {
 	HasLoginProfile(ctx context.Context, userName string) (bool, error)
 	HasAccessKey(ctx context.Context, userName string) (bool, error)
 	ListGroupsForUser(ctx context.Context, userName string) ([]types.Group, error)
-	CreateUserIfNotExists(ctx context.Context, userName string) error
+	ListGroups(ctx context.Context) ([]types.Group, error)
+	GetGroup(ctx context.Context, groupName string) (*types.Group, error)
+	CreateUserIfNotExists(ctx context.Context, userName string, permissionsBoundaryArn string, tags map[string]string) error
 	CreateLoginProfileIfNotExists(ctx context.Context, password string, userName string, passwordResetRequired bool) error
 	CreateAccessKeyPair(ctx context.Context, userName string) (*types.AccessKey, error)
 	AddUserToGroup(ctx context.Context, groupName string, userName string) (middleware.Metadata, error)
@@ -21,4 +24,33 @@ type IamWrapper interface {
 	DeleteLoginProfileIfExists(ctx context.Context, userName string) error
 	ListAccessKeys(ctx context.Context, userName string) ([]types.AccessKeyMetadata, error)
 	DeleteAccessKeyIfExists(ctx context.Context, userName string, keyId string) error
+	UpdateAccessKeyStatus(ctx context.Context, userName string, keyId string, status types.StatusType) error
+	GetUserPolicy(ctx context.Context, userName string, policyName string) (string, error)
+	PutUserPolicy(ctx context.Context, userName string, policyName string, policyDocument string) error
+	DeleteUserPolicyIfExists(ctx context.Context, userName string, policyName string) error
+	ListUserPolicies(ctx context.Context, userName string) ([]string, error)
+	ListAttachedUserPolicies(ctx context.Context, userName string) ([]types.AttachedPolicy, error)
+	AttachUserPolicy(ctx context.Context, userName string, policyArn string) error
+	DetachUserPolicyIfAttached(ctx context.Context, userName string, policyArn string) error
+	GetUserPermissionsBoundary(ctx context.Context, userName string) (string, error)
+	PutUserPermissionsBoundary(ctx context.Context, userName string, permissionsBoundaryArn string) error
+	DeleteUserPermissionsBoundaryIfExists(ctx context.Context, userName string) error
+	ListUserTags(ctx context.Context, userName string) (map[string]string, error)
+	TagUser(ctx context.Context, userName string, tags map[string]string) error
+	UntagUser(ctx context.Context, userName string, tagKeys []string) error
+}
+
+// IamGroupCache checks group names against the account's IAM groups without listing them on every reconcile
+type IamGroupCache interface {
+	GroupExists(ctx context.Context, groupName string) (bool, error)
+	ClosestGroupName(ctx context.Context, groupName string) (string, error)
+}
+
+type Route53Wrapper interface {
+	GetHostedZone(ctx context.Context, hostedZoneId string) (*route53types.HostedZone, []string, error)
+	ListHostedZonesByName(ctx context.Context, name string) ([]route53types.HostedZone, error)
+	CreateHostedZone(ctx context.Context, name string, callerReference string) (*route53types.HostedZone, []string, error)
+	DeleteHostedZoneIfExists(ctx context.Context, hostedZoneId string) error
+	UpsertNameServerRecord(ctx context.Context, hostedZoneId string, name string, nameServers []string) error
+	DeleteNameServerRecordIfExists(ctx context.Context, hostedZoneId string, name string) error
 }
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+// awsAccountsClaiming lists the AwsAccounts claiming an IAM user, through the spec.userName index
+func (r *AwsAccountReconciler) awsAccountsClaiming(ctx context.Context, userName string) ([]kuadrav1.AwsAccount, error) {
+	var awsAccounts kuadrav1.AwsAccountList
+	if err := r.List(ctx, &awsAccounts, client.MatchingFields{kuadrav1.AwsAccountUserNameField: strings.ToLower(userName)}); err != nil {
+		return nil, err
+	}
+	return awsAccounts.Items, nil
+}
+
+// conflictingAwsAccount returns the AwsAccount that claims the same IAM user and takes precedence,
+// or nil when the AwsAccount is the only or the oldest claimant. Ties are broken by namespace and name.
+func (r *AwsAccountReconciler) conflictingAwsAccount(ctx context.Context, awsAccount *kuadrav1.AwsAccount) (*kuadrav1.AwsAccount, error) {
+	claimants, err := r.awsAccountsClaiming(ctx, awsAccount.Spec.UserName)
+	if err != nil {
+		return nil, err
+	}
+	var oldest *kuadrav1.AwsAccount
+	for i := range claimants {
+		claimant := &claimants[i]
+		if claimant.DeletionTimestamp != nil && meta.IsStatusConditionTrue(claimant.Status.Conditions, kuadrav1.ConditionConflict) {
+			// A deleted loser of the conflict does not hold on to the IAM user
+			continue
+		}
+		if oldest == nil || claimsBefore(claimant, oldest) {
+			oldest = claimant
+		}
+	}
+	if oldest == nil || (oldest.Namespace == awsAccount.Namespace && oldest.Name == awsAccount.Name) {
+		return nil, nil
+	}
+	return oldest, nil
+}
+
+// claimsBefore reports whether an AwsAccount's claim on an IAM user precedes another's
+func claimsBefore(a *kuadrav1.AwsAccount, b *kuadrav1.AwsAccount) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return objectOwner(a) < objectOwner(b)
+}
+
+// setConflictCondition reports that the AwsAccount is not reconciled as the claimant holds its IAM user
+func (r *AwsAccountReconciler) setConflictCondition(awsAccount *kuadrav1.AwsAccount, claimant *kuadrav1.AwsAccount) {
+	conditions := &awsAccount.Status.Conditions
+	message := "IAM user " + awsAccount.Spec.UserName + " is claimed by AwsAccount " + objectOwner(claimant)
+	if !meta.IsStatusConditionTrue(*conditions, kuadrav1.ConditionConflict) {
+		r.Recorder.Event(awsAccount, v1.EventTypeWarning, kuadrav1.ReasonConflict, message)
+	}
+	setCondition(conditions, awsAccount.Generation, kuadrav1.ConditionConflict, metav1.ConditionTrue, kuadrav1.ReasonConflict, message)
+	setCondition(conditions, awsAccount.Generation, kuadrav1.ConditionReady, metav1.ConditionFalse, kuadrav1.ReasonConflict, message)
+}
+
+// awsAccountsForUserName maps an AwsAccount to the other AwsAccounts claiming the same IAM user, so
+// that the next claimant takes over once the current one is deleted
+func (r *AwsAccountReconciler) awsAccountsForUserName(awsAccount client.Object) []reconcile.Request {
+	userNames := kuadrav1.AwsAccountUserNameIndex(awsAccount)
+	if len(userNames) == 0 {
+		return nil
+	}
+	claimants, err := r.awsAccountsClaiming(context.Background(), userNames[0])
+	if err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range claimants {
+		if claimants[i].Namespace != awsAccount.GetNamespace() || claimants[i].Name != awsAccount.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&claimants[i])})
+		}
+	}
+	return requests
+}
@@ -18,17 +18,26 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/sethvargo/go-password/password"
 
@@ -38,13 +47,48 @@ import (
 
 const (
 	AwsAccountFinalizer = "kuadra.kuadrant.io/aws-account"
+
+	// unknownGroupRetryInterval is how often an AwsAccount referring to IAM groups that do not exist
+	// checks whether they have been created
+	unknownGroupRetryInterval = 10 * time.Minute
 )
 
 // AwsAccountReconciler reconciles a AwsAccount object
 type AwsAccountReconciler struct {
 	client.Client
-	Scheme     *runtime.Scheme
-	IamWrapper IamWrapper
+	Scheme         *runtime.Scheme
+	IamWrapper     IamWrapper
+	Route53Wrapper Route53Wrapper
+	Recorder       record.EventRecorder
+
+	// IamGroupCache, when set, is used to check that the groups in the spec exist before adding the
+	// IAM user to them
+	IamGroupCache IamGroupCache
+
+	// ClusterId is tagged on the IAM users created from this cluster, so that clusters sharing
+	// an AWS account do not manage each other's users
+	ClusterId string
+
+	// DefaultPermissionsBoundaryArn is applied to IAM users that do not set spec.permissionsBoundaryArn
+	DefaultPermissionsBoundaryArn string
+
+	// PermissionsBoundaryArns are the boundaries spec.permissionsBoundaryArn may choose instead of the default
+	PermissionsBoundaryArns []string
+
+	// ParentHostedZoneIds are the hosted zones that spec.hostedZone may be delegated from
+	ParentHostedZoneIds []string
+
+	// Namespaces are the existing namespaces that spec.namespace.name may choose although kuadra
+	// did not create them for the AwsAccount
+	Namespaces []string
+
+	// NamespaceTemplateClusterRoles are the ClusterRoles that the RoleBindings of NamespaceTemplates may
+	// reference. The controller is only granted bind on these roles.
+	NamespaceTemplateClusterRoles []string
+
+	// GrantAccessRequests grants the access of approved AccessRequests. It needs the webhooks, which
+	// record who requested and who approved an AccessRequest.
+	GrantAccessRequests bool
 }
 
 //+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=awsaccounts,verbs=get;list;watch;create;update;patch;delete
@@ -52,6 +96,15 @@ type AwsAccountReconciler struct {
 //+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=awsaccounts/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=namespacetemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accesspolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accessrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=resourcequotas;limitranges,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=admin;edit;view
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -71,12 +124,7 @@ func (r *AwsAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	if awsAccount.DeletionTimestamp != nil && !awsAccount.DeletionTimestamp.IsZero() {
-		if err := r.deleteNamespace(ctx, awsAccount.Spec.UserName); err != nil {
-			log.Error(err, "Failed to delete namespace", "namespace", awsAccount.Spec.UserName)
-			return ctrl.Result{}, err
-		}
-		if err := r.deleteIamUser(ctx, awsAccount.Spec.UserName); err != nil {
-			log.Error(err, "Failed to delete IAM user", "userName", awsAccount.Spec.UserName)
+		if err := r.finalizeAwsAccount(ctx, &awsAccount); err != nil {
 			return ctrl.Result{}, err
 		}
 		controllerutil.RemoveFinalizer(&awsAccount, AwsAccountFinalizer)
@@ -94,81 +142,207 @@ func (r *AwsAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 
-	refreshedStatus, err := r.getRefreshedStatus(ctx, awsAccount)
+	// AwsAccounts admitted while webhooks were off may claim the same IAM user, only the oldest is reconciled
+	claimant, err := r.conflictingAwsAccount(ctx, &awsAccount)
+	if err != nil {
+		log.Error(err, "unable to list AwsAccounts claiming the IAM user")
+		return ctrl.Result{}, err
+	}
+
+	if claimant == nil {
+		meta.RemoveStatusCondition(&awsAccount.Status.Conditions, kuadrav1.ConditionConflict)
+	}
+
+	now := time.Now()
+	grants, err := r.activeGrants(ctx, &awsAccount, now)
+	if err != nil {
+		log.Error(err, "unable to list AccessRequests")
+		return ctrl.Result{}, err
+	}
+
+	// The webhooks refuse what the AccessPolicies deny, this catches AwsAccounts admitted before a policy changed
+	denied, err := kuadrav1.DeniedAccessInNamespace(ctx, r.Client, awsAccount.Namespace, withoutGrantedAccess(awsAccountAccess(&awsAccount), grants))
+	if err != nil {
+		log.Error(err, "unable to evaluate AccessPolicies")
+		return ctrl.Result{}, err
+	}
+
+	var result ctrl.Result
+	var reconcileErr error
+	if claimant != nil {
+		r.setConflictCondition(&awsAccount, claimant)
+	} else if awsAccount.Spec.Suspended {
+		if reconcileErr = r.suspendIamUser(ctx, &awsAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to suspend IAM user", "userName", awsAccount.Spec.UserName)
+			if errors.Is(reconcileErr, errUserNotManaged) {
+				r.Recorder.Event(&awsAccount, v1.EventTypeWarning, kuadrav1.ReasonUserNotManaged, reconcileErr.Error())
+			}
+		}
+		setSuspendedConditions(r.Recorder, &awsAccount, &awsAccount.Status.Conditions, awsAccount.Generation, reconcileErr)
+	} else if slice.Contains(denied.Services, kuadrav1.ServiceAws) {
+		if reconcileErr = r.revokeIamAccess(ctx, &awsAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to revoke the IAM user's access", "userName", awsAccount.Spec.UserName)
+			if errors.Is(reconcileErr, errUserNotManaged) {
+				r.Recorder.Event(&awsAccount, v1.EventTypeWarning, kuadrav1.ReasonUserNotManaged, reconcileErr.Error())
+			}
+		}
+		r.setAccessDeniedConditions(&awsAccount, denied)
+	} else {
+		// Groups and managed policies that are denied are left out, removing the IAM user from them, as
+		// are groups outside their window. Those granted by AccessRequests are added until the grants expire.
+		allowed := allowedAwsAccount(&awsAccount, denied)
+		allowed.Spec.Groups = activeGroups(&allowed.Spec, now)
+		allowed.Spec.GroupWindows = nil
+		addGrants(allowed, grants)
+		result, reconcileErr = r.reconcileAwsAccount(ctx, allowed)
+		awsAccount.Status = allowed.Status
+		awsAccount.Status.GroupWindows = groupWindows(awsAccount.Spec.GroupWindows, now)
+		if reconcileErr == nil {
+			r.setGrants(&awsAccount, grants)
+			requeueWithin(&result, grantRequeue(grants, now))
+			requeueWithin(&result, groupWindowRequeue(awsAccount.Spec.GroupWindows, now))
+		}
+		r.setAccessAllowedCondition(&awsAccount, denied)
+		setReadyCondition(&awsAccount.Status.Conditions, awsAccount.Generation, awsAccountComponentConditions(&awsAccount), reconcileErr)
+		if reconcileErr == nil {
+			setReactivatedCondition(r.Recorder, &awsAccount, &awsAccount.Status.Conditions, awsAccount.Generation)
+		}
+	}
+	awsAccount.Status.ObservedGeneration = awsAccount.Generation
+
+	var latest kuadrav1.AwsAccount
+	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, awsAccount.Status) {
+		if err := r.Status().Update(ctx, &awsAccount); err != nil {
+			log.Error(err, "unable to update awsAccount status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+		}
+	}
+
+	return result, reconcileErr
+}
+
+// reconcileAwsAccount moves the IAM user and namespace towards the spec,
+// recording a condition for each step on the AwsAccount status
+func (r *AwsAccountReconciler) reconcileAwsAccount(ctx context.Context, awsAccount *kuadrav1.AwsAccount) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	conditions := &awsAccount.Status.Conditions
+	generation := awsAccount.Generation
+
+	refreshedStatus, err := r.getRefreshedStatus(ctx, *awsAccount)
 	if err != nil {
 		log.Error(err, "unable to get refreshed status")
 		return ctrl.Result{}, err
 	}
 	awsAccount.Status = *refreshedStatus
 
-	if !awsAccount.Status.NamespaceCreated {
-		if err := r.createNamespaceIfNotExists(ctx, awsAccount.Spec.UserName); err != nil {
-			log.Error(err, "unable to create namespace")
-			return ctrl.Result{}, err
-		}
-		log.V(1).Info("created namespace", "namespace", awsAccount.Spec.UserName)
-		awsAccount.Status.NamespaceCreated = true
+	if err := r.reconcileNamespace(ctx, awsAccount); err != nil {
+		log.Error(err, "unable to reconcile namespace", "namespace", targetNamespace(awsAccount))
+		setConditionFromError(conditions, generation, kuadrav1.ConditionNamespaceReady, err)
+		return ctrl.Result{}, err
 	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionNamespaceReady, "Namespace "+awsAccount.Status.Namespace+" exists")
 
+	permissionsBoundaryArn, err := r.permissionsBoundaryArn(awsAccount)
+	if err != nil {
+		log.Error(err, "refusing permissions boundary", "permissionsBoundaryArn", awsAccount.Spec.PermissionsBoundaryArn)
+		setConditionFromError(conditions, generation, kuadrav1.ConditionIamUserReady, err)
+		return ctrl.Result{}, err
+	}
 	if !awsAccount.Status.UserCreated {
-		if err := r.IamWrapper.CreateUserIfNotExists(ctx, awsAccount.Spec.UserName); err != nil {
+		if err := r.IamWrapper.CreateUserIfNotExists(ctx, awsAccount.Spec.UserName, permissionsBoundaryArn, r.userTags(awsAccount)); err != nil {
 			log.Error(err, "unable to create IAM user")
+			setConditionFromError(conditions, generation, kuadrav1.ConditionIamUserReady, err)
 			return ctrl.Result{}, err
 		}
 		log.V(1).Info("created user", "userName", awsAccount.Spec.UserName)
 		awsAccount.Status.UserCreated = true
+		awsAccount.Status.PermissionsBoundaryArn = permissionsBoundaryArn
 	}
 
-	if !awsAccount.Status.LoginProfileCreated {
-		pass, err := password.Generate(20, 3, 3, false, true)
-		if err != nil {
-			log.Error(err, "unable to generate password")
-			return ctrl.Result{}, err
-		}
-		secretData := map[string]string{
-			"userName": awsAccount.Spec.UserName,
-			"password": pass,
-		}
-		if err := r.createSecretIfNotExists(ctx, secretData, "aws-login", awsAccount.Spec.UserName); err != nil {
-			log.Error(err, "unable to create secret for AWS password")
-			return ctrl.Result{}, err
+	// Nothing is changed on an IAM user that this AwsAccount does not manage
+	tags, err := r.checkUserOwnership(ctx, awsAccount)
+	if err != nil {
+		log.Error(err, "refusing to manage IAM user", "userName", awsAccount.Spec.UserName)
+		setConditionFromError(conditions, generation, kuadrav1.ConditionIamUserReady, err)
+		if errors.Is(err, errUserNotManaged) {
+			r.Recorder.Event(awsAccount, v1.EventTypeWarning, kuadrav1.ReasonUserNotManaged, err.Error())
 		}
-		// Use password value from retrieved secret so that possible creation errors do not cause incorrect password to be set
-		retrievedSecret := &v1.Secret{}
-		if err := r.Get(ctx, types.NamespacedName{Name: "aws-login", Namespace: awsAccount.Spec.UserName}, retrievedSecret); err != nil {
-			log.Error(err, "unable to get secret for AWS password")
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileUserTags(ctx, awsAccount, tags); err != nil {
+		log.Error(err, "unable to tag IAM user")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionIamUserReady, err)
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reactivateAccessKeys(ctx, awsAccount); err != nil {
+		log.Error(err, "unable to reactivate access keys")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionAccessKeyReady, err)
+		return ctrl.Result{}, err
+	}
+
+	if awsAccount.Status.PermissionsBoundaryArn != permissionsBoundaryArn {
+		if err := r.reconcilePermissionsBoundary(ctx, awsAccount.Spec.UserName, permissionsBoundaryArn); err != nil {
+			log.Error(err, "unable to update permissions boundary", "permissionsBoundaryArn", permissionsBoundaryArn)
+			setConditionFromError(conditions, generation, kuadrav1.ConditionIamUserReady, err)
 			return ctrl.Result{}, err
 		}
-		if err := r.IamWrapper.CreateLoginProfileIfNotExists(ctx, string(retrievedSecret.Data["password"]), awsAccount.Spec.UserName, true); err != nil {
-			log.Error(err, "unable to create login profile")
+		log.V(1).Info("updated permissions boundary", "permissionsBoundaryArn", permissionsBoundaryArn)
+		awsAccount.Status.PermissionsBoundaryArn = permissionsBoundaryArn
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionIamUserReady, "IAM user "+awsAccount.Spec.UserName+" exists")
+
+	if !awsAccount.Status.LoginProfileCreated {
+		if err := r.createLoginProfile(ctx, awsAccount); err != nil {
+			setConditionFromError(conditions, generation, kuadrav1.ConditionLoginProfileReady, err)
 			return ctrl.Result{}, err
 		}
 		log.V(1).Info("created login profile")
 		awsAccount.Status.LoginProfileCreated = true
 	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionLoginProfileReady, "Login profile exists, password is stored in Secret aws-login")
 
 	if !awsAccount.Status.AccessKeyCreated {
 		accessKey, err := r.IamWrapper.CreateAccessKeyPair(ctx, awsAccount.Spec.UserName)
 		if err != nil {
 			log.Error(err, "unable to create access key")
+			setConditionFromError(conditions, generation, kuadrav1.ConditionAccessKeyReady, err)
 			return ctrl.Result{}, err
 		}
-		secretData := map[string]string{
-			"AWS_ACCESS_KEY_ID":     *accessKey.AccessKeyId,
-			"AWS_SECRET_ACCESS_KEY": *accessKey.SecretAccessKey,
-		}
-		if err := r.createSecretIfNotExists(ctx, secretData, "aws-credentials", awsAccount.Spec.UserName); err != nil {
+		if err := r.createOrUpdateSecret(ctx, awsAccount, accessKeySecretData(accessKey), "aws-credentials", awsAccount.Status.Namespace); err != nil {
 			log.Error(err, "unable to create secret for AWS credentials")
+			setConditionFromError(conditions, generation, kuadrav1.ConditionAccessKeyReady, err)
 			return ctrl.Result{}, err
 		}
 		log.V(1).Info("created access key", "accessKeyId", accessKey.AccessKeyId)
 		awsAccount.Status.AccessKeyCreated = true
+		if awsAccount.Spec.AccessKeyRotation != nil {
+			awsAccount.Status.AccessKeyRotation = &kuadrav1.AccessKeyRotationStatus{CurrentAccessKeyId: *accessKey.AccessKeyId}
+		}
+	}
+
+	result, err := r.rotateAccessKey(ctx, awsAccount)
+	if err != nil {
+		log.Error(err, "unable to rotate access key")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionAccessKeyReady, err)
+		return ctrl.Result{}, err
 	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionAccessKeyReady, "Access key exists, credentials are stored in Secret aws-credentials")
 
 	groupsToAddUserTo := slice.GetLeftDifference(awsAccount.Spec.Groups, awsAccount.Status.UserGroups)
-	for _, group := range groupsToAddUserTo {
+	unknownGroups, err := r.unknownGroups(ctx, groupsToAddUserTo)
+	if err != nil {
+		log.Error(err, "unable to look up IAM groups")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionGroupsSynced, err)
+		return ctrl.Result{}, err
+	}
+	for _, group := range slice.GetLeftDifference(groupsToAddUserTo, unknownGroups) {
 		if _, err := r.IamWrapper.AddUserToGroup(ctx, group, awsAccount.Spec.UserName); err != nil {
 			log.Error(err, "unable to add user to group", "groupName", group)
+			setConditionFromError(conditions, generation, kuadrav1.ConditionGroupsSynced, err)
 			return ctrl.Result{}, err
 		}
 		log.V(1).Info("Added user to group", "group name:", group)
@@ -179,68 +353,172 @@ func (r *AwsAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	for _, group := range groupsToRemoveUserFrom {
 		if _, err := r.IamWrapper.RemoveUserFromGroup(ctx, group, awsAccount.Spec.UserName); err != nil {
 			log.Error(err, "unable to remove user from group", "groupName", group)
+			setConditionFromError(conditions, generation, kuadrav1.ConditionGroupsSynced, err)
 			return ctrl.Result{}, err
 		}
 		log.V(1).Info("removed user from group", "groupName", group)
 		awsAccount.Status.UserGroups = slice.Remove(awsAccount.Status.UserGroups, func(g string) bool { return g == group })
 	}
+	if len(unknownGroups) > 0 {
+		// Retrying right away would not help, the rest of the account is reconciled in the meantime
+		setCondition(conditions, generation, kuadrav1.ConditionGroupsSynced, metav1.ConditionFalse, kuadrav1.ReasonGroupNotFound, r.unknownGroupsMessage(ctx, unknownGroups))
+		if result.RequeueAfter == 0 || result.RequeueAfter > unknownGroupRetryInterval {
+			result.RequeueAfter = unknownGroupRetryInterval
+		}
+	} else {
+		setConditionTrue(conditions, generation, kuadrav1.ConditionGroupsSynced, "IAM user is a member of every group in the spec")
+	}
 
-	var latest kuadrav1.AwsAccount
-	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	if err := r.reconcilePolicies(ctx, awsAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionPoliciesSynced, err)
+		return ctrl.Result{}, err
 	}
-	if !reflect.DeepEqual(latest.Status, awsAccount.Status) {
-		if err := r.Status().Update(ctx, &awsAccount); err != nil {
-			log.Error(err, "unable to update awsAccount status")
-			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+	setConditionTrue(conditions, generation, kuadrav1.ConditionPoliciesSynced, "IAM user has every policy in the spec")
+
+	if err := r.reconcileHostedZone(ctx, awsAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionHostedZoneReady, err)
+		return ctrl.Result{}, err
+	}
+	if awsAccount.Status.HostedZone != nil {
+		setConditionTrue(conditions, generation, kuadrav1.ConditionHostedZoneReady, "Hosted zone "+awsAccount.Status.HostedZone.Name+" is published in Secret "+hostedZoneSecretName)
+	} else {
+		meta.RemoveStatusCondition(conditions, kuadrav1.ConditionHostedZoneReady)
+	}
+
+	if err := r.reconcileNamespaceTemplate(ctx, awsAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionNamespaceTemplateApplied, err)
+		return ctrl.Result{}, err
+	}
+	if templateName := namespaceTemplateName(awsAccount); templateName != "" {
+		setConditionTrue(conditions, generation, kuadrav1.ConditionNamespaceTemplateApplied, "NamespaceTemplate "+templateName+" is rendered into namespace "+awsAccount.Status.Namespace)
+	} else {
+		meta.RemoveStatusCondition(conditions, kuadrav1.ConditionNamespaceTemplateApplied)
+	}
+
+	return result, nil
+}
+
+// finalizeAwsAccount deletes, disables or keeps the resources of a deleted AwsAccount according to spec.deletionPolicy
+func (r *AwsAccountReconciler) finalizeAwsAccount(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+
+	// The IAM user and namespace belong to the AwsAccount that won the conflict
+	if meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionConflict) {
+		log.Info("not cleaning up IAM user claimed by another AwsAccount", "userName", awsAccount.Spec.UserName)
+		return nil
+	}
+
+	switch awsAccount.Spec.DeletionPolicy {
+	case kuadrav1.DeletionPolicyRetain:
+		log.Info("retaining IAM user and namespace", "userName", awsAccount.Spec.UserName)
+		return nil
+	case kuadrav1.DeletionPolicyDisableOnly:
+		if err := r.disableIamUser(ctx, awsAccount); err != nil {
+			log.Error(err, "Failed to disable IAM user", "userName", awsAccount.Spec.UserName)
+			return err
 		}
+		log.Info("disabled IAM user, retaining it and the namespace", "userName", awsAccount.Spec.UserName)
+		return nil
 	}
 
-	return ctrl.Result{}, nil
+	if err := r.deleteHostedZone(ctx, awsAccount.Status.HostedZone); err != nil {
+		log.Error(err, "Failed to delete hosted zone", "hostedZoneId", awsAccount.Status.HostedZone.Id)
+		return err
+	}
+	if err := r.deleteNamespace(ctx, awsAccount); err != nil {
+		log.Error(err, "Failed to delete namespace", "namespace", secretNamespace(awsAccount))
+		return err
+	}
+	if err := r.deleteIamUser(ctx, awsAccount); err != nil {
+		log.Error(err, "Failed to delete IAM user", "userName", awsAccount.Spec.UserName)
+		return err
+	}
+	return nil
 }
 
-func (r *AwsAccountReconciler) isNamespace(ctx context.Context, namespace string) (bool, error) {
-	ns := &v1.Namespace{}
-	if err := r.Get(ctx, types.NamespacedName{Name: namespace, Namespace: v1.NamespaceAll}, ns); err != nil {
-		return false, client.IgnoreNotFound(err)
+// permissionsBoundaryArn returns the permissions boundary to apply to the IAM user. The spec can only
+// replace the default with one of PermissionsBoundaryArns, so that it cannot lift the boundary.
+func (r *AwsAccountReconciler) permissionsBoundaryArn(awsAccount *kuadrav1.AwsAccount) (string, error) {
+	if awsAccount.Spec.PermissionsBoundaryArn == "" {
+		return r.DefaultPermissionsBoundaryArn, nil
+	}
+	if !slice.Contains(r.PermissionsBoundaryArns, awsAccount.Spec.PermissionsBoundaryArn) {
+		return "", fmt.Errorf("%w: permissions boundary %s is not configured as an alternative to the default", errNotAllowed, awsAccount.Spec.PermissionsBoundaryArn)
+	}
+	return awsAccount.Spec.PermissionsBoundaryArn, nil
+}
+
+func (r *AwsAccountReconciler) reconcilePermissionsBoundary(ctx context.Context, userName string, permissionsBoundaryArn string) error {
+	if permissionsBoundaryArn == "" {
+		return r.IamWrapper.DeleteUserPermissionsBoundaryIfExists(ctx, userName)
+	}
+	return r.IamWrapper.PutUserPermissionsBoundary(ctx, userName, permissionsBoundaryArn)
+}
+
+// createLoginProfile creates the login profile with a generated password that is stored in the aws-login Secret
+func (r *AwsAccountReconciler) createLoginProfile(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+
+	pass, err := password.Generate(20, 3, 3, false, true)
+	if err != nil {
+		log.Error(err, "unable to generate password")
+		return err
+	}
+	secretData := map[string]string{
+		"userName": awsAccount.Spec.UserName,
+		"password": pass,
 	}
-	return true, nil
+	if err := r.createSecretIfNotExists(ctx, awsAccount, secretData, "aws-login", awsAccount.Status.Namespace); err != nil {
+		log.Error(err, "unable to create secret for AWS password")
+		return err
+	}
+	// Use password value from retrieved secret so that possible creation errors do not cause incorrect password to be set
+	retrievedSecret := &v1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "aws-login", Namespace: awsAccount.Status.Namespace}, retrievedSecret); err != nil {
+		log.Error(err, "unable to get secret for AWS password")
+		return err
+	}
+	if err := r.IamWrapper.CreateLoginProfileIfNotExists(ctx, string(retrievedSecret.Data["password"]), awsAccount.Spec.UserName, true); err != nil {
+		log.Error(err, "unable to create login profile")
+		return err
+	}
+	return nil
 }
 
-func (r *AwsAccountReconciler) createNamespaceIfNotExists(ctx context.Context, namespace string) error {
-	ns := &v1.Namespace{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Namespace",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: namespace,
-		},
-	}
-	err := r.Create(ctx, ns)
-	return client.IgnoreAlreadyExists(err)
+// createSecretIfNotExists writes a Secret for the AwsAccount unless it already wrote it, keeping the data
+// of the existing Secret. Secrets are not controlled by the AwsAccount, as spec.deletionPolicy decides
+// whether they are deleted.
+func (r *AwsAccountReconciler) createSecretIfNotExists(ctx context.Context, awsAccount *kuadrav1.AwsAccount, data map[string]string, name string, namespace string) error {
+	return applyOwnedSecret(ctx, r.Client, nil, awsAccount, name, namespace, func(secret *v1.Secret) {
+		if secret.ResourceVersion == "" {
+			secret.StringData = data
+		}
+	})
 }
 
-func (r *AwsAccountReconciler) createSecretIfNotExists(ctx context.Context, data map[string]string, name string, namespace string) error {
-	secret := &v1.Secret{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Secret",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-		},
-		StringData: data,
-	}
-	err := r.Create(ctx, secret)
-	return client.IgnoreAlreadyExists(err)
+// createOrUpdateSecret writes a Secret for the AwsAccount, refusing to overwrite a Secret it did not write
+func (r *AwsAccountReconciler) createOrUpdateSecret(ctx context.Context, awsAccount *kuadrav1.AwsAccount, data map[string]string, name string, namespace string) error {
+	return applyOwnedSecret(ctx, r.Client, nil, awsAccount, name, namespace, func(secret *v1.Secret) {
+		secret.Data = nil
+		secret.StringData = data
+	})
 }
 
 func (r *AwsAccountReconciler) getRefreshedStatus(ctx context.Context, awsAccount kuadrav1.AwsAccount) (*kuadrav1.AwsAccountStatus, error) {
-	var status kuadrav1.AwsAccountStatus
+	// Conditions, the namespace in use, applied tags, rotation, suspension and grant records and the hosted zone are not observed from IAM, so carry them over to be updated by the reconcile steps
+	status := kuadrav1.AwsAccountStatus{
+		Namespace:             recordedNamespace(&awsAccount),
+		Tags:                  awsAccount.Status.Tags,
+		AccessKeyRotation:     awsAccount.Status.AccessKeyRotation,
+		HostedZone:            awsAccount.Status.HostedZone,
+		Suspended:             awsAccount.Status.Suspended,
+		SuspendedAccessKeyIds: awsAccount.Status.SuspendedAccessKeyIds,
+		Grants:                awsAccount.Status.Grants,
+		ObservedGeneration:    awsAccount.Status.ObservedGeneration,
+		Conditions:            awsAccount.Status.Conditions,
+	}
 
-	namespaceExists, err := r.isNamespace(ctx, awsAccount.Spec.UserName)
+	namespaceExists, err := r.isNamespace(ctx, targetNamespace(&awsAccount))
 	if err != nil {
 		return nil, err
 	}
@@ -251,11 +529,17 @@ func (r *AwsAccountReconciler) getRefreshedStatus(ctx context.Context, awsAccoun
 		return nil, err
 	}
 	if !userExists {
-		// Return struct with zero values
+		// Return struct with zero values for everything observed from IAM
 		return &status, nil
 	}
 	status.UserCreated = true
 
+	permissionsBoundaryArn, err := r.IamWrapper.GetUserPermissionsBoundary(ctx, awsAccount.Spec.UserName)
+	if err != nil {
+		return nil, err
+	}
+	status.PermissionsBoundaryArn = permissionsBoundaryArn
+
 	loginProfileExists, err := r.IamWrapper.HasLoginProfile(ctx, awsAccount.Spec.UserName)
 	if err != nil {
 		return nil, err
@@ -276,18 +560,30 @@ func (r *AwsAccountReconciler) getRefreshedStatus(ctx context.Context, awsAccoun
 		status.UserGroups = append(status.UserGroups, *group.GroupName)
 	}
 
-	return &status, nil
-}
+	attachedPolicies, err := r.IamWrapper.ListAttachedUserPolicies(ctx, awsAccount.Spec.UserName)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range attachedPolicies {
+		status.ManagedPolicyArns = append(status.ManagedPolicyArns, *policy.PolicyArn)
+	}
 
-func (r *AwsAccountReconciler) deleteNamespace(ctx context.Context, namespace string) error {
-	ns := &v1.Namespace{}
-	if err := r.Get(ctx, types.NamespacedName{Name: namespace, Namespace: v1.NamespaceAll}, ns); err != nil {
-		return client.IgnoreNotFound(err)
+	inlinePolicyNames, err := r.IamWrapper.ListUserPolicies(ctx, awsAccount.Spec.UserName)
+	if err != nil {
+		return nil, err
 	}
-	return r.Delete(ctx, ns)
+	// The hosted zone policy is managed alongside the hosted zone
+	status.InlinePolicies = slice.Remove(inlinePolicyNames, func(p string) bool { return p == hostedZonePolicyName })
+
+	return &status, nil
 }
 
-func (r *AwsAccountReconciler) deleteIamUser(ctx context.Context, userName string) error {
+// deleteIamUser deletes the IAM user along with everything attached to it,
+// leaving users that are not managed by the AwsAccount untouched
+func (r *AwsAccountReconciler) deleteIamUser(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	userName := awsAccount.Spec.UserName
+
 	userExists, err := r.IamWrapper.IsExistingUser(ctx, userName)
 	if err != nil {
 		return err
@@ -296,6 +592,16 @@ func (r *AwsAccountReconciler) deleteIamUser(ctx context.Context, userName strin
 		return nil
 	}
 
+	tags, err := r.IamWrapper.ListUserTags(ctx, userName)
+	if err != nil {
+		return err
+	}
+	if !r.isOwnedUser(awsAccount, tags) {
+		log.Info("not deleting IAM user that is not managed by this AwsAccount", "userName", userName)
+		r.Recorder.Eventf(awsAccount, v1.EventTypeWarning, kuadrav1.ReasonUserNotManaged, "IAM user %s is not managed by this AwsAccount and was not deleted", userName)
+		return nil
+	}
+
 	groups, err := r.IamWrapper.ListGroupsForUser(ctx, userName)
 	if err != nil {
 		return err
@@ -310,6 +616,26 @@ func (r *AwsAccountReconciler) deleteIamUser(ctx context.Context, userName strin
 		return err
 	}
 
+	// IAM refuses to delete a user that still has policies
+	attachedPolicies, err := r.IamWrapper.ListAttachedUserPolicies(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, policy := range attachedPolicies {
+		if err := r.IamWrapper.DetachUserPolicyIfAttached(ctx, userName, *policy.PolicyArn); err != nil {
+			return err
+		}
+	}
+	inlinePolicyNames, err := r.IamWrapper.ListUserPolicies(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, policyName := range inlinePolicyNames {
+		if err := r.IamWrapper.DeleteUserPolicyIfExists(ctx, userName, policyName); err != nil {
+			return err
+		}
+	}
+
 	accessKeys, err := r.IamWrapper.ListAccessKeys(ctx, userName)
 	if err != nil {
 		return err
@@ -323,9 +649,82 @@ func (r *AwsAccountReconciler) deleteIamUser(ctx context.Context, userName strin
 	return r.IamWrapper.DeleteUser(ctx, userName)
 }
 
+// disableIamUser removes the IAM user's credentials, leaving the user itself in place.
+// Like deleteIamUser, it leaves users that are not managed by the AwsAccount untouched.
+func (r *AwsAccountReconciler) disableIamUser(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	userName := awsAccount.Spec.UserName
+
+	userExists, err := r.IamWrapper.IsExistingUser(ctx, userName)
+	if err != nil {
+		return err
+	}
+	if userExists {
+		tags, err := r.IamWrapper.ListUserTags(ctx, userName)
+		if err != nil {
+			return err
+		}
+		if !r.isOwnedUser(awsAccount, tags) {
+			log.Info("not disabling IAM user that is not managed by this AwsAccount", "userName", userName)
+			r.Recorder.Eventf(awsAccount, v1.EventTypeWarning, kuadrav1.ReasonUserNotManaged, "IAM user %s is not managed by this AwsAccount and was not disabled", userName)
+			return nil
+		}
+
+		if err := r.IamWrapper.DeleteLoginProfileIfExists(ctx, userName); err != nil {
+			return err
+		}
+		accessKeys, err := r.IamWrapper.ListAccessKeys(ctx, userName)
+		if err != nil {
+			return err
+		}
+		for _, accessKey := range accessKeys {
+			if err := r.IamWrapper.DeleteAccessKeyIfExists(ctx, userName, *accessKey.AccessKeyId); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, secretName := range []string{"aws-login", "aws-credentials"} {
+		if err := deleteOwnedSecret(ctx, r.Client, awsAccount, secretName, secretNamespace(awsAccount)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// awsAccountsForConfigMap maps a ConfigMap to the AwsAccounts reading inline policies from it
+func (r *AwsAccountReconciler) awsAccountsForConfigMap(configMap client.Object) []reconcile.Request {
+	var awsAccounts kuadrav1.AwsAccountList
+	if err := r.List(context.Background(), &awsAccounts, client.InNamespace(configMap.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, awsAccount := range awsAccounts.Items {
+		for _, inlinePolicy := range awsAccount.Spec.InlinePolicies {
+			if inlinePolicy.ConfigMapKeyRef != nil && inlinePolicy.ConfigMapKeyRef.Name == configMap.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&awsAccount)})
+				break
+			}
+		}
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *AwsAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &kuadrav1.AwsAccount{}, kuadrav1.AwsAccountUserNameField, kuadrav1.AwsAccountUserNameIndex); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kuadrav1.AwsAccount{}).
+		Watches(&source.Kind{Type: &kuadrav1.AwsAccount{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountsForUserName)).
+		Watches(&source.Kind{Type: &v1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountsForConfigMap)).
+		Watches(&source.Kind{Type: &kuadrav1.NamespaceTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountsForNamespaceTemplate)).
+		Watches(&source.Kind{Type: &kuadrav1.AccessPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountsForAccessPolicy)).
+		Watches(&source.Kind{Type: &kuadrav1.AccessRequest{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountForAccessRequest)).
+		Watches(&source.Kind{Type: &rbacv1.RoleBinding{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountForTemplateObject)).
+		Watches(&source.Kind{Type: &v1.ResourceQuota{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountForTemplateObject)).
+		Watches(&source.Kind{Type: &v1.LimitRange{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountForTemplateObject)).
+		Watches(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.awsAccountForTemplateObject)).
 		Complete(r)
 }
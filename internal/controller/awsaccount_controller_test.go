@@ -3,19 +3,32 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
 	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+	kuadraaws "github.com/Kuadrant/kuadra/pkg/aws"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/aws/smithy-go/middleware"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -66,7 +79,7 @@ var _ = Describe("AwsAccount controller", func() {
 				NamespacedName: awsAccountLookupKey,
 			}
 
-			client := fake.NewClientBuilder().Build()
+			client := newAwsAccountClientBuilder().Build()
 
 			mockIam := mockIamWrapper{
 				Users:        []types.User{},
@@ -81,6 +94,7 @@ var _ = Describe("AwsAccount controller", func() {
 				Client:     client,
 				Scheme:     scheme.Scheme,
 				IamWrapper: &mockIam,
+				Recorder:   record.NewFakeRecorder(10),
 			}
 
 			_, err := r.Reconcile(ctx, req)
@@ -95,53 +109,1878 @@ var _ = Describe("AwsAccount controller", func() {
 					return createdAwsAccount.Status
 				}
 				return createdAwsAccount.Status
-			}, timeout, interval).Should(Equal(kuadrav1.AwsAccountStatus{
-				UserCreated:         true,
-				LoginProfileCreated: true,
-				AccessKeyCreated:    true,
-				UserGroups:          awsController.Spec.Groups,
-				NamespaceCreated:    true,
+			}, timeout, interval).Should(And(
+				HaveField("UserCreated", true),
+				HaveField("LoginProfileCreated", true),
+				HaveField("AccessKeyCreated", true),
+				HaveField("UserGroups", awsController.Spec.Groups),
+				HaveField("NamespaceCreated", true),
+			))
+			Expect(createdAwsAccount.Status.ObservedGeneration).Should(Equal(createdAwsAccount.Generation))
+
+			By("By checking if AwsAccount conditions are correct")
+			for _, conditionType := range []string{
+				kuadrav1.ConditionReady,
+				kuadrav1.ConditionNamespaceReady,
+				kuadrav1.ConditionIamUserReady,
+				kuadrav1.ConditionLoginProfileReady,
+				kuadrav1.ConditionAccessKeyReady,
+				kuadrav1.ConditionGroupsSynced,
+			} {
+				Expect(meta.IsStatusConditionTrue(createdAwsAccount.Status.Conditions, conditionType)).Should(BeTrue(), conditionType)
+			}
+
+			By("By checking created user")
+			Expect(mockIam.Users).Should(Equal([]types.User{
+				{
+					UserName: &awsController.Spec.UserName,
+				},
 			}))
 
-			By("By checking created user")
-			Expect(mockIam.Users).Should(Equal([]types.User{
-				{
-					UserName: &awsController.Spec.UserName,
+			By("By checking if user has login profile")
+			Expect(mockIam.LoginProfile[awsController.Spec.UserName]).Should(Equal(types.LoginProfile{
+				UserName:              &awsController.Spec.UserName,
+				PasswordResetRequired: true,
+			}))
+
+			By("By checking if user has access key")
+			Expect(mockIam.AccessKeys[awsController.Spec.UserName]).Should(ConsistOf(And(
+				HaveField("AccessKeyId", aws.String("AccessKeyId")),
+				HaveField("SecretAccessKey", aws.String("SecretAccessKey")),
+			)))
+
+			By("By checking if user has correct groups")
+			Expect(mockIam.Groups[awsController.Spec.UserName]).Should(Equal([]types.Group{
+				{
+					GroupName: &awsController.Spec.Groups[0],
+				},
+				{
+					GroupName: &awsController.Spec.Groups[1],
+				},
+			}))
+		})
+	})
+})
+
+var _ = Describe("AwsAccount controller conditions", func() {
+
+	ctx := context.Background()
+
+	Context("When adding the IAM user to a group fails", func() {
+		It("Should report the IAM error on the GroupsSynced condition", func() {
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "awsaccount-missing-group",
+					Namespace: "default",
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: "missing-group",
+					Groups:   []string{"does-not-exist"},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().Build()
+			Expect(client.Create(ctx, awsAccount)).Should(Succeed())
+
+			mockIam := mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+				AddUserToGroupErr: &types.NoSuchEntityException{
+					Message: aws.String("The group with name does-not-exist cannot be found."),
+				},
+			}
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: &mockIam,
+				Recorder:   record.NewFakeRecorder(10),
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(HaveOccurred())
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+
+			groupsSynced := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionGroupsSynced)
+			Expect(groupsSynced).ShouldNot(BeNil())
+			Expect(groupsSynced.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(groupsSynced.Reason).Should(Equal("NoSuchEntity"))
+			Expect(groupsSynced.Message).Should(ContainSubstring("does-not-exist"))
+
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionIamUserReady)).Should(BeTrue())
+			ready := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionReady)
+			Expect(ready).ShouldNot(BeNil())
+			Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).Should(Equal("NoSuchEntity"))
+		})
+	})
+})
+
+var _ = Describe("AwsAccount access key rotation", func() {
+
+	ctx := context.Background()
+
+	Context("When the access key is older than maxAge", func() {
+		It("Should create a new key and delete the old one after the grace period", func() {
+			userName := "rotated-user"
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "awsaccount-rotation",
+					Namespace: "default",
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: userName,
+					AccessKeyRotation: &kuadrav1.AccessKeyRotation{
+						MaxAge:      metav1.Duration{Duration: 24 * time.Hour},
+						GracePeriod: metav1.Duration{Duration: time.Hour},
+					},
+				},
+				Status: kuadrav1.AwsAccountStatus{
+					AccessKeyRotation: &kuadrav1.AccessKeyRotationStatus{
+						CurrentAccessKeyId: "OldAccessKeyId",
+					},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().Build()
+			Expect(client.Create(ctx, awsAccount)).Should(Succeed())
+
+			mockIam := mockIamWrapper{
+				Users:        []types.User{{UserName: aws.String(userName)}},
+				LoginProfile: map[string]types.LoginProfile{userName: {UserName: aws.String(userName)}},
+				AccessKeys: map[string][]types.AccessKey{userName: {{
+					AccessKeyId:     aws.String("OldAccessKeyId"),
+					SecretAccessKey: aws.String("OldSecretAccessKey"),
+					CreateDate:      aws.Time(time.Now().Add(-48 * time.Hour)),
+					Status:          types.StatusTypeActive,
+				}}},
+				Groups: map[string][]types.Group{},
+				Tags:   map[string]map[string]string{userName: ownedUserTags(awsAccount)},
+			}
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: &mockIam,
+				Recorder:   record.NewFakeRecorder(10),
+			}
+
+			By("By rotating the expired key")
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RequeueAfter).Should(BeNumerically(">", 59*time.Minute))
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.AccessKeyRotation.PreviousAccessKeyId).Should(Equal("OldAccessKeyId"))
+			newAccessKeyId := reconciled.Status.AccessKeyRotation.CurrentAccessKeyId
+			Expect(newAccessKeyId).ShouldNot(Equal("OldAccessKeyId"))
+			Expect(reconciled.Status.AccessKeyRotation.LastRotationTime).ShouldNot(BeNil())
+			Expect(mockIam.AccessKeys[userName]).Should(HaveLen(2))
+
+			secret := &corev1.Secret{}
+			Expect(client.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: userName}, secret)).Should(Succeed())
+			Expect(secret.StringData).Should(HaveKeyWithValue("AWS_ACCESS_KEY_ID", newAccessKeyId))
+
+			By("By keeping the old key during the grace period")
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.AccessKeys[userName]).Should(HaveLen(2))
+
+			By("By deleting the old key once the grace period is over")
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			reconciled.Status.AccessKeyRotation.LastRotationTime = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+			Expect(client.Status().Update(ctx, reconciled)).Should(Succeed())
+
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.AccessKeys[userName]).Should(ConsistOf(HaveField("AccessKeyId", aws.String(newAccessKeyId))))
+
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.AccessKeyRotation.PreviousAccessKeyId).Should(BeEmpty())
+			Expect(reconciled.Status.AccessKeyRotation.PreviousAccessKeyDeleted).ShouldNot(BeNil())
+		})
+	})
+
+	Context("When rotation is enabled on a user that already has a second key", func() {
+		It("Should keep the second key for the grace period", func() {
+			userName := "second-key-user"
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "awsaccount-second-key",
+					Namespace: "default",
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: userName,
+					AccessKeyRotation: &kuadrav1.AccessKeyRotation{
+						MaxAge:      metav1.Duration{Duration: 24 * time.Hour},
+						GracePeriod: metav1.Duration{Duration: time.Hour},
+					},
+				},
+				Status: kuadrav1.AwsAccountStatus{
+					AccessKeyRotation: &kuadrav1.AccessKeyRotationStatus{
+						CurrentAccessKeyId: "CurrentAccessKeyId",
+					},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().Build()
+			Expect(client.Create(ctx, awsAccount)).Should(Succeed())
+
+			mockIam := mockIamWrapper{
+				Users:        []types.User{{UserName: aws.String(userName)}},
+				LoginProfile: map[string]types.LoginProfile{userName: {UserName: aws.String(userName)}},
+				AccessKeys: map[string][]types.AccessKey{userName: {{
+					AccessKeyId:     aws.String("CurrentAccessKeyId"),
+					SecretAccessKey: aws.String("CurrentSecretAccessKey"),
+					CreateDate:      aws.Time(time.Now()),
+					Status:          types.StatusTypeActive,
+				}, {
+					AccessKeyId:     aws.String("SecondAccessKeyId"),
+					SecretAccessKey: aws.String("SecondSecretAccessKey"),
+					CreateDate:      aws.Time(time.Now().Add(-48 * time.Hour)),
+					Status:          types.StatusTypeActive,
+				}}},
+				Groups: map[string][]types.Group{},
+				Tags:   map[string]map[string]string{userName: ownedUserTags(awsAccount)},
+			}
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: &mockIam,
+				Recorder:   record.NewFakeRecorder(10),
+			}
+
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RequeueAfter).Should(BeNumerically(">", 59*time.Minute))
+			Expect(mockIam.AccessKeys[userName]).Should(HaveLen(2))
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.AccessKeyRotation.LastRotationTime).ShouldNot(BeNil())
+			Expect(reconciled.Status.AccessKeyRotation.PreviousAccessKeyDeleted).Should(BeNil())
+		})
+	})
+})
+
+var _ = Describe("AwsAccount hosted zone", func() {
+
+	ctx := context.Background()
+
+	Context("When the AwsAccount has a hosted zone", func() {
+		It("Should create, delegate, publish and delete the hosted zone", func() {
+			userName := "zone-user"
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "awsaccount-hosted-zone",
+					Namespace: "default",
+					UID:       "0c0ffee",
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: userName,
+					HostedZone: &kuadrav1.HostedZone{
+						Name:               "zone-user.example.com",
+						ParentHostedZoneId: "PARENT",
+					},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().Build()
+			Expect(client.Create(ctx, awsAccount)).Should(Succeed())
+
+			mockRoute53 := newMockRoute53Wrapper()
+			mockRoute53.HostedZones["PARENT"] = route53types.HostedZone{Id: aws.String("PARENT"), Name: aws.String("example.com.")}
+			mockIam := &mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			}
+			r := &AwsAccountReconciler{
+				Client:         client,
+				Scheme:         scheme.Scheme,
+				IamWrapper:     mockIam,
+				Route53Wrapper: mockRoute53,
+				Recorder:       record.NewFakeRecorder(10),
+
+				ParentHostedZoneIds: []string{"PARENT"},
+			}
+
+			By("By creating and delegating the hosted zone")
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockRoute53.HostedZones).Should(HaveLen(2))
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.HostedZone).ShouldNot(BeNil())
+			Expect(reconciled.Status.HostedZone.Name).Should(Equal("zone-user.example.com."))
+			Expect(reconciled.Status.HostedZone.NameServers).Should(Equal(mockNameServers))
+			Expect(reconciled.Status.HostedZone.ParentHostedZoneId).Should(Equal("PARENT"))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionHostedZoneReady)).Should(BeTrue())
+			Expect(mockRoute53.Delegations).Should(HaveKeyWithValue("PARENT/zone-user.example.com.", mockNameServers))
+
+			secret := &corev1.Secret{}
+			Expect(client.Get(ctx, k8Types.NamespacedName{Name: hostedZoneSecretName, Namespace: userName}, secret)).Should(Succeed())
+			Expect(secret.StringData).Should(HaveKeyWithValue("HOSTED_ZONE_ID", reconciled.Status.HostedZone.Id))
+
+			By("By granting the user DNS record rights on the hosted zone only")
+			Expect(reconciled.Status.HostedZone.PolicyName).Should(Equal(hostedZonePolicyName))
+			policy := mockIam.InlinePolicies[userName][hostedZonePolicyName]
+			Expect(policy).Should(ContainSubstring("route53:ChangeResourceRecordSets"))
+			Expect(policy).Should(ContainSubstring(`"arn:aws:route53:::hostedzone/` + reconciled.Status.HostedZone.Id + `"`))
+
+			By("By deleting the hosted zone with the AwsAccount")
+			Expect(client.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockRoute53.HostedZones).Should(ConsistOf(HaveField("Id", aws.String("PARENT"))))
+			Expect(mockRoute53.Delegations).Should(BeEmpty())
+			Expect(mockIam.InlinePolicies[userName]).ShouldNot(HaveKey(hostedZonePolicyName))
+		})
+	})
+
+	Context("When the parent hosted zone cannot be delegated from", func() {
+		It("Should refuse parents that are not configured or not a parent of the zone", func() {
+			userName := "delegated-user"
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "awsaccount-parent-zone",
+					Namespace: "default",
+					UID:       "c0ffee",
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: userName,
+					HostedZone: &kuadrav1.HostedZone{
+						Name:               "example.com",
+						ParentHostedZoneId: "PRODUCTION",
+					},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().Build()
+			Expect(client.Create(ctx, awsAccount)).Should(Succeed())
+
+			mockRoute53 := newMockRoute53Wrapper()
+			mockRoute53.HostedZones["PRODUCTION"] = route53types.HostedZone{Id: aws.String("PRODUCTION"), Name: aws.String("example.com.")}
+			mockRoute53.HostedZones["PARENT"] = route53types.HostedZone{Id: aws.String("PARENT"), Name: aws.String("example.com.")}
+			r := &AwsAccountReconciler{
+				Client: client,
+				Scheme: scheme.Scheme,
+				IamWrapper: &mockIamWrapper{
+					Users:        []types.User{},
+					LoginProfile: map[string]types.LoginProfile{},
+					AccessKeys:   map[string][]types.AccessKey{},
+					Groups:       map[string][]types.Group{},
+				},
+				Route53Wrapper: mockRoute53,
+				Recorder:       record.NewFakeRecorder(10),
+
+				ParentHostedZoneIds: []string{"PARENT"},
+			}
+
+			By("By refusing a parent zone that is not configured")
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("not configured as a parent hosted zone")))
+			Expect(mockRoute53.HostedZones).Should(HaveLen(2))
+			Expect(mockRoute53.Delegations).Should(BeEmpty())
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionHostedZoneReady)
+			Expect(condition).ShouldNot(BeNil())
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+
+			By("By refusing to replace the NS record of the parent zone itself")
+			reconciled.Spec.HostedZone.ParentHostedZoneId = "PARENT"
+			Expect(client.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("not a subdomain of the parent hosted zone example.com.")))
+			Expect(mockRoute53.Delegations).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("AwsAccount policies", func() {
+
+	ctx := context.Background()
+
+	Context("When the AwsAccount lists managed and inline policies", func() {
+		It("Should attach the listed policies and remove the others", func() {
+			userName := "policy-user"
+			bucketPolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["*"]}]}`
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "policies",
+					Namespace: "default",
+				},
+				Data: map[string]string{"bucket.json": bucketPolicy},
+			}
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "awsaccount-policies",
+					Namespace: "default",
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName:          userName,
+					ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+					InlinePolicies: []kuadrav1.InlinePolicy{
+						{
+							Name:     "ec2",
+							Document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["ec2:Describe*"],"Resource":["*"]}]}`,
+						},
+						{
+							Name: "bucket",
+							ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: configMap.Name},
+								Key:                  "bucket.json",
+							},
+						},
+					},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().WithObjects(configMap, awsAccount).Build()
+
+			mockIam := &mockIamWrapper{
+				Users:            []types.User{{UserName: aws.String(userName)}},
+				LoginProfile:     map[string]types.LoginProfile{},
+				AccessKeys:       map[string][]types.AccessKey{},
+				Groups:           map[string][]types.Group{},
+				AttachedPolicies: map[string][]string{userName: {"arn:aws:iam::aws:policy/AdministratorAccess"}},
+				InlinePolicies:   map[string]map[string]string{userName: {"stale": "{}"}},
+				Tags:             map[string]map[string]string{userName: ownedUserTags(awsAccount)},
+			}
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: mockIam,
+				Recorder:   record.NewFakeRecorder(10),
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(mockIam.AttachedPolicies[userName]).Should(ConsistOf("arn:aws:iam::aws:policy/ReadOnlyAccess"))
+			Expect(mockIam.InlinePolicies[userName]).Should(HaveLen(2))
+			Expect(mockIam.InlinePolicies[userName]).Should(HaveKeyWithValue("bucket", bucketPolicy))
+			Expect(mockIam.InlinePolicies[userName]).Should(HaveKey("ec2"))
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.ManagedPolicyArns).Should(ConsistOf("arn:aws:iam::aws:policy/ReadOnlyAccess"))
+			Expect(reconciled.Status.InlinePolicies).Should(ConsistOf("ec2", "bucket"))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionPoliciesSynced)).Should(BeTrue())
+
+			By("By refusing an inline policy named like the hosted zone policy")
+			reconciled.Spec.InlinePolicies = append(reconciled.Spec.InlinePolicies, kuadrav1.InlinePolicy{Name: hostedZonePolicyName, Document: "{}"})
+			Expect(client.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("reserved for the hosted zone policy")))
+			Expect(mockIam.InlinePolicies[userName]).ShouldNot(HaveKey(hostedZonePolicyName))
+
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionPoliciesSynced)
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+		})
+	})
+})
+
+var _ = Describe("AwsAccount permissions boundary", func() {
+
+	ctx := context.Background()
+
+	Context("When a default permissions boundary is configured", func() {
+		It("Should apply the AwsAccount's boundary over the default", func() {
+			userName := "bounded-user"
+			defaultBoundary := "arn:aws:iam::123456789012:policy/default-boundary"
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "awsaccount-boundary",
+					Namespace: "default",
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: userName,
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+			mockIam := &mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			}
+			r := &AwsAccountReconciler{
+				Client:                        client,
+				Scheme:                        scheme.Scheme,
+				IamWrapper:                    mockIam,
+				DefaultPermissionsBoundaryArn: defaultBoundary,
+				PermissionsBoundaryArns:       []string{"arn:aws:iam::123456789012:policy/strict-boundary"},
+				Recorder:                      record.NewFakeRecorder(10),
+			}
+
+			By("By creating the user with the default boundary")
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.PermissionsBoundary).Should(HaveKeyWithValue(userName, defaultBoundary))
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.PermissionsBoundaryArn).Should(Equal(defaultBoundary))
+
+			By("By replacing the boundary set on the AwsAccount")
+			reconciled.Spec.PermissionsBoundaryArn = "arn:aws:iam::123456789012:policy/strict-boundary"
+			Expect(client.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.PermissionsBoundary).Should(HaveKeyWithValue(userName, "arn:aws:iam::123456789012:policy/strict-boundary"))
+
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.PermissionsBoundaryArn).Should(Equal("arn:aws:iam::123456789012:policy/strict-boundary"))
+
+			By("By refusing a boundary that is not configured")
+			reconciled.Spec.PermissionsBoundaryArn = "arn:aws:iam::123456789012:policy/permissive-boundary"
+			Expect(client.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("not configured as an alternative to the default")))
+			Expect(mockIam.PermissionsBoundary).Should(HaveKeyWithValue(userName, "arn:aws:iam::123456789012:policy/strict-boundary"))
+
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionIamUserReady)
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+		})
+	})
+})
+
+var _ = Describe("AwsAccount IAM user ownership", func() {
+
+	ctx := context.Background()
+
+	newAwsAccount := func(userName string, adopt bool) *kuadrav1.AwsAccount {
+		return &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "awsaccount-" + userName,
+				Namespace:  "default",
+				Finalizers: []string{AwsAccountFinalizer},
+			},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName: userName,
+				Tags:     map[string]string{"team": "dns"},
+				Adopt:    adopt,
+			},
+		}
+	}
+
+	Context("When kuadra creates the IAM user", func() {
+		It("Should tag the user and sync spec.tags", func() {
+			awsAccount := newAwsAccount("tagged-user", false)
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+			mockIam := &mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			}
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: mockIam,
+				Recorder:   record.NewFakeRecorder(10),
+				ClusterId:  "test-cluster",
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.Tags["tagged-user"]).Should(Equal(map[string]string{
+				"kuadra.kuadrant.io/managed-by": "kuadra",
+				"kuadra.kuadrant.io/cluster-id": "test-cluster",
+				"kuadra.kuadrant.io/owner":      "default/awsaccount-tagged-user",
+				"team":                          "dns",
+			}))
+
+			By("By removing a tag from the spec")
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Tags).Should(Equal(map[string]string{"team": "dns"}))
+			reconciled.Spec.Tags = map[string]string{"cost-center": "42"}
+			Expect(client.Update(ctx, reconciled)).Should(Succeed())
+
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.Tags["tagged-user"]).Should(HaveKeyWithValue("cost-center", "42"))
+			Expect(mockIam.Tags["tagged-user"]).ShouldNot(HaveKey("team"))
+		})
+	})
+
+	Context("When the IAM user was not created by kuadra", func() {
+		It("Should neither modify nor delete the user", func() {
+			userName := "unmanaged-user"
+			awsAccount := newAwsAccount(userName, false)
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+			mockIam := &mockIamWrapper{
+				Users:        []types.User{{UserName: aws.String(userName)}},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: mockIam,
+				Recorder:   recorder,
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(errUserNotManaged))
+			Expect(mockIam.Tags[userName]).Should(BeEmpty())
+			Expect(mockIam.AccessKeys[userName]).Should(BeEmpty())
+			Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonUserNotManaged)))
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(client.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			iamUserReady := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionIamUserReady)
+			Expect(iamUserReady).ShouldNot(BeNil())
+			Expect(iamUserReady.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(iamUserReady.Reason).Should(Equal(kuadrav1.ReasonUserNotManaged))
+
+			By("By deleting the AwsAccount")
+			Expect(client.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.Users).Should(HaveLen(1))
+		})
+
+		It("Should take over the user when adopt is set", func() {
+			userName := "adopted-user"
+			awsAccount := newAwsAccount(userName, true)
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+			mockIam := &mockIamWrapper{
+				Users:        []types.User{{UserName: aws.String(userName)}},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			}
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: mockIam,
+				Recorder:   record.NewFakeRecorder(10),
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(mockIam.Tags[userName]).Should(HaveKeyWithValue("kuadra.kuadrant.io/owner", "default/awsaccount-adopted-user"))
+			Expect(mockIam.AccessKeys[userName]).Should(HaveLen(1))
+		})
+
+		It("Should not adopt a user managed by another AwsAccount", func() {
+			userName := "claimed-user"
+			awsAccount := newAwsAccount(userName, true)
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+			client := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+			mockIam := &mockIamWrapper{
+				Users:        []types.User{{UserName: aws.String(userName)}},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+				Tags: map[string]map[string]string{userName: {
+					"kuadra.kuadrant.io/managed-by": "kuadra",
+					"kuadra.kuadrant.io/owner":      "other/awsaccount",
+				}},
+			}
+			r := &AwsAccountReconciler{
+				Client:     client,
+				Scheme:     scheme.Scheme,
+				IamWrapper: mockIam,
+				Recorder:   record.NewFakeRecorder(10),
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(errUserNotManaged))
+			Expect(mockIam.Tags[userName]).Should(HaveKeyWithValue("kuadra.kuadrant.io/owner", "other/awsaccount"))
+		})
+	})
+})
+
+var _ = Describe("AwsAccount deletion policy", func() {
+
+	ctx := context.Background()
+
+	// provisionAndDelete reconciles a new AwsAccount, deletes it and runs the finalizer
+	provisionAndDelete := func(userName string, deletionPolicy kuadrav1.DeletionPolicy) (*mockIamWrapper, client.Client) {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "awsaccount-" + userName,
+				Namespace: "default",
+			},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName:       userName,
+				DeletionPolicy: deletionPolicy,
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+		mockIam := &mockIamWrapper{
+			Users:        []types.User{},
+			LoginProfile: map[string]types.LoginProfile{},
+			AccessKeys:   map[string][]types.AccessKey{},
+			Groups:       map[string][]types.Group{},
+		}
+		r := &AwsAccountReconciler{
+			Client:     k8sClient,
+			Scheme:     scheme.Scheme,
+			IamWrapper: mockIam,
+			Recorder:   record.NewFakeRecorder(10),
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.AccessKeys[userName]).Should(HaveLen(1))
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, awsAccount)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(apierrors.IsNotFound(k8sClient.Get(ctx, lookupKey, awsAccount))).Should(BeTrue())
+		return mockIam, k8sClient
+	}
+
+	Context("When the deletion policy is Retain", func() {
+		It("Should keep the IAM user and the namespace", func() {
+			mockIam, k8sClient := provisionAndDelete("retained-user", kuadrav1.DeletionPolicyRetain)
+
+			Expect(mockIam.Users).Should(HaveLen(1))
+			Expect(mockIam.AccessKeys["retained-user"]).Should(HaveLen(1))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "retained-user"}, &corev1.Namespace{})).Should(Succeed())
+		})
+	})
+
+	Context("When the deletion policy is DisableOnly", func() {
+		It("Should remove the credentials and keep the IAM user and the namespace", func() {
+			mockIam, k8sClient := provisionAndDelete("disabled-user", kuadrav1.DeletionPolicyDisableOnly)
+
+			Expect(mockIam.Users).Should(HaveLen(1))
+			Expect(mockIam.AccessKeys["disabled-user"]).Should(BeEmpty())
+			Expect(mockIam.LoginProfile).ShouldNot(HaveKey("disabled-user"))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "disabled-user"}, &corev1.Namespace{})).Should(Succeed())
+			for _, secretName := range []string{"aws-login", "aws-credentials"} {
+				err := k8sClient.Get(ctx, k8Types.NamespacedName{Name: secretName, Namespace: "disabled-user"}, &corev1.Secret{})
+				Expect(apierrors.IsNotFound(err)).Should(BeTrue(), secretName)
+			}
+		})
+	})
+
+	Context("When the deletion policy is Delete", func() {
+		It("Should delete the IAM user and the namespace", func() {
+			mockIam, k8sClient := provisionAndDelete("deleted-user", kuadrav1.DeletionPolicyDelete)
+
+			Expect(mockIam.Users).Should(BeEmpty())
+			err := k8sClient.Get(ctx, k8Types.NamespacedName{Name: "deleted-user"}, &corev1.Namespace{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("AwsAccount suspension", func() {
+
+	ctx := context.Background()
+
+	It("Should revoke the IAM user's access while suspended and restore it on reactivation", func() {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "awsaccount-suspended-user",
+				Namespace: "default",
+			},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName: "suspended-user",
+				Groups:   []string{"developers"},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+		mockIam := &mockIamWrapper{
+			Users:        []types.User{},
+			LoginProfile: map[string]types.LoginProfile{},
+			AccessKeys:   map[string][]types.AccessKey{},
+			Groups:       map[string][]types.Group{},
+		}
+		recorder := record.NewFakeRecorder(10)
+		r := &AwsAccountReconciler{
+			Client:     k8sClient,
+			Scheme:     scheme.Scheme,
+			IamWrapper: mockIam,
+			Recorder:   recorder,
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Groups["suspended-user"]).Should(HaveLen(1))
+
+		By("Suspending the AwsAccount")
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		awsAccount.Spec.Suspended = true
+		Expect(k8sClient.Update(ctx, awsAccount)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(mockIam.Users).Should(HaveLen(1))
+		Expect(mockIam.AccessKeys["suspended-user"]).Should(HaveLen(1))
+		Expect(mockIam.AccessKeys["suspended-user"][0].Status).Should(Equal(types.StatusTypeInactive))
+		Expect(mockIam.LoginProfile).ShouldNot(HaveKey("suspended-user"))
+		Expect(mockIam.Groups["suspended-user"]).Should(BeEmpty())
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonSuspended)))
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Status.SuspendedAccessKeyIds).Should(Equal([]string{"AccessKeyId"}))
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionSuspended)).Should(BeTrue())
+		ready := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionReady)
+		Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).Should(Equal(kuadrav1.ReasonSuspended))
+
+		By("Reactivating the AwsAccount")
+		awsAccount.Spec.Suspended = false
+		Expect(k8sClient.Update(ctx, awsAccount)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(mockIam.AccessKeys["suspended-user"]).Should(HaveLen(1))
+		Expect(mockIam.AccessKeys["suspended-user"][0].Status).Should(Equal(types.StatusTypeActive))
+		Expect(mockIam.LoginProfile).Should(HaveKey("suspended-user"))
+		Expect(mockIam.Groups["suspended-user"]).Should(HaveLen(1))
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonReactivated)))
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Status.Suspended).Should(BeFalse())
+		Expect(awsAccount.Status.SuspendedAccessKeyIds).Should(BeEmpty())
+		suspended := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionSuspended)
+		Expect(suspended.Status).Should(Equal(metav1.ConditionFalse))
+		Expect(suspended.Reason).Should(Equal(kuadrav1.ReasonReactivated))
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+	})
+})
+
+var _ = Describe("AwsAccount groups", func() {
+
+	ctx := context.Background()
+
+	It("Should report groups that do not exist in IAM and add the user to the others", func() {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-unknown-group", Namespace: "default"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName: "unknown-group-user",
+				Groups:   []string{"developers", "dns-managment"},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+		mockIam := &mockIamWrapper{
+			Users:         []types.User{},
+			LoginProfile:  map[string]types.LoginProfile{},
+			AccessKeys:    map[string][]types.AccessKey{},
+			Groups:        map[string][]types.Group{},
+			AccountGroups: []string{"developers", "dns-management"},
+		}
+		r := &AwsAccountReconciler{
+			Client:        k8sClient,
+			Scheme:        scheme.Scheme,
+			IamWrapper:    mockIam,
+			IamGroupCache: kuadraaws.NewGroupCache(mockIam, time.Minute),
+			Recorder:      record.NewFakeRecorder(10),
+		}
+
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(Equal(unknownGroupRetryInterval))
+		Expect(mockIam.Groups["unknown-group-user"]).Should(HaveLen(1))
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Status.UserGroups).Should(Equal([]string{"developers"}))
+		groupsSynced := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionGroupsSynced)
+		Expect(groupsSynced.Status).Should(Equal(metav1.ConditionFalse))
+		Expect(groupsSynced.Reason).Should(Equal(kuadrav1.ReasonGroupNotFound))
+		Expect(groupsSynced.Message).Should(ContainSubstring("dns-managment (did you mean dns-management?)"))
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionPoliciesSynced)).Should(BeTrue())
+
+		By("Adding the user to the group once it is fixed in the spec")
+		awsAccount.Spec.Groups = []string{"developers", "dns-management"}
+		Expect(k8sClient.Update(ctx, awsAccount)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Groups["unknown-group-user"]).Should(HaveLen(2))
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionGroupsSynced)).Should(BeTrue())
+	})
+})
+
+var _ = Describe("AwsAccount access policies", func() {
+
+	ctx := context.Background()
+
+	newAccessPolicyReconciler := func(k8sClient client.Client, mockIam *mockIamWrapper) *AwsAccountReconciler {
+		return &AwsAccountReconciler{
+			Client:     k8sClient,
+			Scheme:     scheme.Scheme,
+			IamWrapper: mockIam,
+			Recorder:   record.NewFakeRecorder(10),
+		}
+	}
+	newMockIam := func() *mockIamWrapper {
+		return &mockIamWrapper{
+			Users:            []types.User{},
+			LoginProfile:     map[string]types.LoginProfile{},
+			AccessKeys:       map[string][]types.AccessKey{},
+			Groups:           map[string][]types.Group{},
+			AttachedPolicies: map[string][]string{},
+		}
+	}
+
+	It("Should leave out the groups and managed policies no AccessPolicy allows", func() {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-restricted", Namespace: "default"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName:          "restricted-user",
+				Groups:            []string{"developers", "admins"},
+				ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/AdministratorAccess"},
+			},
+		}
+		accessPolicy := &kuadrav1.AccessPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers"},
+			Spec: kuadrav1.AccessPolicySpec{
+				Services: []string{"*"},
+				Groups:   []string{"developers"},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount, accessPolicy).Build()
+		mockIam := newMockIam()
+		r := newAccessPolicyReconciler(k8sClient, mockIam)
+		Expect(r.awsAccountsForAccessPolicy(accessPolicy)).Should(Equal([]reconcile.Request{{NamespacedName: lookupKey}}))
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Groups["restricted-user"]).Should(HaveLen(1))
+		Expect(*mockIam.Groups["restricted-user"][0].GroupName).Should(Equal("developers"))
+		Expect(mockIam.AttachedPolicies["restricted-user"]).Should(BeEmpty())
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Spec.Groups).Should(Equal([]string{"developers", "admins"}))
+		accessAllowed := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionAccessAllowed)
+		Expect(accessAllowed.Status).Should(Equal(metav1.ConditionFalse))
+		Expect(accessAllowed.Message).Should(ContainSubstring("IAM groups admins"))
+		ready := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionReady)
+		Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).Should(Equal(kuadrav1.ReasonAccessPolicyDenied))
+
+		By("Adding the user to the group once an AccessPolicy allows it")
+		accessPolicy.Spec.Groups = append(accessPolicy.Spec.Groups, "admins")
+		accessPolicy.Spec.ManagedPolicyArns = []string{"arn:aws:iam::aws:policy/*"}
+		Expect(k8sClient.Update(ctx, accessPolicy)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Groups["restricted-user"]).Should(HaveLen(2))
+		Expect(mockIam.AttachedPolicies["restricted-user"]).Should(HaveLen(1))
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+	})
+
+	It("Should not reconcile an AwsAccount in a namespace no AccessPolicy allows AWS accounts in", func() {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-denied", Namespace: "default"},
+			Spec:       kuadrav1.AwsAccountSpec{UserName: "denied-user"},
+		}
+		accessPolicy := &kuadrav1.AccessPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "platform"},
+			Spec: kuadrav1.AccessPolicySpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+				Services:          []string{kuadrav1.ServiceAws},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount, accessPolicy).Build()
+		mockIam := newMockIam()
+		r := newAccessPolicyReconciler(k8sClient, mockIam)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Users).Should(BeEmpty())
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		ready := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionReady)
+		Expect(ready.Reason).Should(Equal(kuadrav1.ReasonAccessPolicyDenied))
+		Expect(ready.Message).Should(ContainSubstring("namespace default"))
+	})
+
+	It("Should revoke the groups and managed policies of an IAM user once no AccessPolicy allows AWS accounts", func() {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-revoked", Namespace: "default"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName:          "revoked-user",
+				Groups:            []string{"developers"},
+				ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+		}
+		accessPolicy := &kuadrav1.AccessPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers"},
+			Spec: kuadrav1.AccessPolicySpec{
+				Services:          []string{kuadrav1.ServiceAws},
+				Groups:            []string{"developers"},
+				ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/*"},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount, accessPolicy).Build()
+		mockIam := newMockIam()
+		r := newAccessPolicyReconciler(k8sClient, mockIam)
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Groups["revoked-user"]).Should(HaveLen(1))
+		Expect(mockIam.AttachedPolicies["revoked-user"]).Should(HaveLen(1))
+
+		accessPolicy.Spec.NamespaceSelector = metav1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}}
+		Expect(k8sClient.Update(ctx, accessPolicy)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Users).Should(HaveLen(1))
+		Expect(mockIam.Groups["revoked-user"]).Should(BeEmpty())
+		Expect(mockIam.AttachedPolicies["revoked-user"]).Should(BeEmpty())
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		groupsSynced := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionGroupsSynced)
+		Expect(groupsSynced.Reason).Should(Equal(kuadrav1.ReasonAccessPolicyDenied))
+	})
+})
+
+var _ = Describe("AwsAccount time-bound groups", func() {
+
+	ctx := context.Background()
+
+	It("Should only keep the IAM user in a group during its window", func() {
+		now := time.Now().Truncate(time.Second)
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-windows", Namespace: "default"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName: "windows-user",
+				Groups:   []string{"developers"},
+				GroupWindows: []kuadrav1.GroupWindow{
+					{Name: "break-glass", NotAfter: &metav1.Time{Time: now.Add(time.Hour)}},
+					{Name: "on-call", NotBefore: &metav1.Time{Time: now.Add(20 * time.Minute)}},
+					{Name: "incident", NotAfter: &metav1.Time{Time: now.Add(-time.Hour)}},
+				},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+		mockIam := &mockIamWrapper{
+			Users:            []types.User{},
+			LoginProfile:     map[string]types.LoginProfile{},
+			AccessKeys:       map[string][]types.AccessKey{},
+			Groups:           map[string][]types.Group{},
+			AttachedPolicies: map[string][]string{},
+		}
+		r := &AwsAccountReconciler{
+			Client:     k8sClient,
+			Scheme:     scheme.Scheme,
+			IamWrapper: mockIam,
+			Recorder:   record.NewFakeRecorder(10),
+		}
+
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(BeNumerically("~", 20*time.Minute, time.Minute))
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Status.UserGroups).Should(ConsistOf("developers", "break-glass"))
+		Expect(awsAccount.Status.GroupWindows).Should(ConsistOf(
+			And(HaveField("Name", "break-glass"), HaveField("State", kuadrav1.GroupWindowActive)),
+			And(HaveField("Name", "on-call"), HaveField("State", kuadrav1.GroupWindowScheduled)),
+			And(HaveField("Name", "incident"), HaveField("State", kuadrav1.GroupWindowEnded)),
+		))
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionGroupsSynced)).Should(BeTrue())
+
+		By("Removing the IAM user from the group once its window ends")
+		awsAccount.Spec.GroupWindows[0].NotAfter = &metav1.Time{Time: now.Add(-time.Minute)}
+		awsAccount.Spec.GroupWindows[1].NotBefore = &metav1.Time{Time: now.Add(-time.Minute)}
+		Expect(k8sClient.Update(ctx, awsAccount)).Should(Succeed())
+		result, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(BeZero())
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Status.UserGroups).Should(ConsistOf("developers", "on-call"))
+	})
+})
+
+var _ = Describe("AwsAccount access requests", func() {
+
+	ctx := context.Background()
+
+	It("Should grant the access of approved AccessRequests until they expire", func() {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-granted", Namespace: "default"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName: "granted-user",
+				Groups:   []string{"developers"},
+			},
+		}
+		accessPolicy := &kuadrav1.AccessPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "developers"},
+			Spec: kuadrav1.AccessPolicySpec{
+				Services: []string{kuadrav1.ServiceAws},
+				Groups:   []string{"developers"},
+			},
+		}
+		accessRequest := &kuadrav1.AccessRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns-admin", Namespace: "default"},
+			Spec: kuadrav1.AccessRequestSpec{
+				AwsAccount:    awsAccount.Name,
+				Groups:        []string{"dns-admins"},
+				Justification: "Delegating a hosted zone",
+				Duration:      metav1.Duration{Duration: time.Hour},
+				RequestedBy:   "jdoe",
+			},
+			Status: kuadrav1.AccessRequestStatus{Approval: &kuadrav1.AccessRequestApproval{
+				Approved: true,
+				Approver: "jane",
+				Time:     metav1.NewTime(time.Now().Add(-30 * time.Minute)),
+			}},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount, accessPolicy, accessRequest).Build()
+		mockIam := &mockIamWrapper{
+			Users:            []types.User{},
+			LoginProfile:     map[string]types.LoginProfile{},
+			AccessKeys:       map[string][]types.AccessKey{},
+			Groups:           map[string][]types.Group{},
+			AttachedPolicies: map[string][]string{},
+		}
+		recorder := record.NewFakeRecorder(10)
+		r := &AwsAccountReconciler{
+			Client:              k8sClient,
+			Scheme:              scheme.Scheme,
+			IamWrapper:          mockIam,
+			Recorder:            recorder,
+			GrantAccessRequests: true,
+		}
+		Expect(r.awsAccountForAccessRequest(accessRequest)).Should(Equal([]reconcile.Request{{NamespacedName: lookupKey}}))
+
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.RequeueAfter).Should(BeNumerically("~", 30*time.Minute, time.Minute))
+		Expect(mockIam.Groups["granted-user"]).Should(HaveLen(2))
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonAccessGranted + " AccessRequest dns-admin grants IAM groups dns-admins")))
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Spec.Groups).Should(Equal([]string{"developers"}))
+		Expect(awsAccount.Status.UserGroups).Should(ConsistOf("developers", "dns-admins"))
+		Expect(awsAccount.Status.Grants).Should(HaveLen(1))
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionAccessAllowed)).Should(BeTrue())
+		Expect(meta.IsStatusConditionTrue(awsAccount.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+		By("Removing the IAM user from the group once the access expires")
+		accessRequest.Status.Approval.Time = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		Expect(k8sClient.Status().Update(ctx, accessRequest)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Groups["granted-user"]).Should(HaveLen(1))
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonAccessRevoked)))
+
+		Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+		Expect(awsAccount.Status.UserGroups).Should(ConsistOf("developers"))
+		Expect(awsAccount.Status.Grants).Should(BeEmpty())
+	})
+
+	It("Should not grant AccessRequests whose approval did not go through the webhook", func() {
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-self-approved", Namespace: "default"},
+			Spec:       kuadrav1.AwsAccountSpec{UserName: "self-approved-user"},
+		}
+		newApprovedAccessRequest := func(name string, requestedBy string, approver string) *kuadrav1.AccessRequest {
+			return &kuadrav1.AccessRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: kuadrav1.AccessRequestSpec{
+					AwsAccount:    awsAccount.Name,
+					Groups:        []string{"admins"},
+					Justification: "Because",
+					Duration:      metav1.Duration{Duration: time.Hour},
+					RequestedBy:   requestedBy,
+				},
+				Status: kuadrav1.AccessRequestStatus{Approval: &kuadrav1.AccessRequestApproval{
+					Approved: true,
+					Approver: approver,
+					Time:     metav1.Now(),
+				}},
+			}
+		}
+		trusted := newApprovedAccessRequest("trusted", "jdoe", "jane")
+		k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount, trusted,
+			newApprovedAccessRequest("self-approved", "jdoe", "jdoe"),
+			newApprovedAccessRequest("no-requester", "", "jane"),
+			newApprovedAccessRequest("no-approver", "jdoe", "")).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &AwsAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: recorder, GrantAccessRequests: true}
+
+		grants, err := r.activeGrants(ctx, awsAccount, time.Now())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(grants).Should(HaveLen(1))
+		Expect(grants[0].AccessRequest).Should(Equal("trusted"))
+		Expect(recorder.Events).Should(HaveLen(3))
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonApprovalNotTrusted)))
+
+		By("Granting nothing without the webhooks")
+		r.GrantAccessRequests = false
+		grants, err = r.activeGrants(ctx, awsAccount, time.Now())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(grants).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("AwsAccount user name conflict", func() {
+
+	ctx := context.Background()
+
+	It("Should only reconcile the oldest AwsAccount claiming an IAM user", func() {
+		older := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "awsaccount-claimed",
+				Namespace:         "default",
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			},
+			Spec: kuadrav1.AwsAccountSpec{UserName: "claimed-user"},
+		}
+		newer := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "awsaccount-claimed",
+				Namespace:         "team-a",
+				CreationTimestamp: metav1.NewTime(time.Now()),
+			},
+			Spec: kuadrav1.AwsAccountSpec{UserName: "Claimed-User"},
+		}
+		olderKey := k8Types.NamespacedName{Name: older.Name, Namespace: older.Namespace}
+		newerKey := k8Types.NamespacedName{Name: newer.Name, Namespace: newer.Namespace}
+
+		k8sClient := newAwsAccountClientBuilder().WithObjects(older, newer).Build()
+		mockIam := &mockIamWrapper{
+			Users:        []types.User{},
+			LoginProfile: map[string]types.LoginProfile{},
+			AccessKeys:   map[string][]types.AccessKey{},
+			Groups:       map[string][]types.Group{},
+		}
+		recorder := record.NewFakeRecorder(10)
+		r := &AwsAccountReconciler{
+			Client:     k8sClient,
+			Scheme:     scheme.Scheme,
+			IamWrapper: mockIam,
+			Recorder:   recorder,
+		}
+		Expect(r.awsAccountsForUserName(older)).Should(Equal([]reconcile.Request{{NamespacedName: newerKey}}))
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: newerKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Users).Should(BeEmpty())
+		Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonConflict)))
+
+		Expect(k8sClient.Get(ctx, newerKey, newer)).Should(Succeed())
+		Expect(meta.IsStatusConditionTrue(newer.Status.Conditions, kuadrav1.ConditionConflict)).Should(BeTrue())
+		ready := meta.FindStatusCondition(newer.Status.Conditions, kuadrav1.ConditionReady)
+		Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).Should(Equal(kuadrav1.ReasonConflict))
+		Expect(ready.Message).Should(ContainSubstring("default/awsaccount-claimed"))
+
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: olderKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Users).Should(HaveLen(1))
+
+		By("Not cleaning up the IAM user when the newer AwsAccount is deleted")
+		Expect(k8sClient.Delete(ctx, newer)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: newerKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(mockIam.Users).Should(HaveLen(1))
+		Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-login", Namespace: sanitizeNamespaceName("claimed-user")}, &corev1.Secret{})).Should(Succeed())
+
+		By("Reconciling the newer AwsAccount once the older one is gone")
+		newer = &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-claimed", Namespace: "team-b", CreationTimestamp: metav1.Now()},
+			Spec:       kuadrav1.AwsAccountSpec{UserName: "claimed-user"},
+		}
+		newerKey = k8Types.NamespacedName{Name: newer.Name, Namespace: newer.Namespace}
+		Expect(k8sClient.Create(ctx, newer)).Should(Succeed())
+		Expect(k8sClient.Get(ctx, olderKey, older)).Should(Succeed())
+		Expect(k8sClient.Delete(ctx, older)).Should(Succeed())
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: olderKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		// The fake client does not delete the Secrets along with the namespace
+		Expect(k8sClient.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace(sanitizeNamespaceName("claimed-user")))).Should(Succeed())
+
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: newerKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(k8sClient.Get(ctx, newerKey, newer)).Should(Succeed())
+		Expect(meta.FindStatusCondition(newer.Status.Conditions, kuadrav1.ConditionConflict)).Should(BeNil())
+		Expect(meta.IsStatusConditionTrue(newer.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+	})
+})
+
+var _ = Describe("AwsAccount namespace", func() {
+
+	ctx := context.Background()
+
+	newReconciler := func(k8sClient client.Client) *AwsAccountReconciler {
+		return &AwsAccountReconciler{
+			Client: k8sClient,
+			Scheme: scheme.Scheme,
+			IamWrapper: &mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			},
+			Recorder: record.NewFakeRecorder(10),
+		}
+	}
+
+	Context("When sanitizing IAM user names", func() {
+		It("Should keep valid names and map the others to distinct valid names", func() {
+			Expect(sanitizeNamespaceName("jdoe")).Should(Equal("jdoe"))
+
+			for _, userName := range []string{"j.doe@example.com", "J_Doe+dns=1,2", "@@@", strings.Repeat("a.", 32)} {
+				name := sanitizeNamespaceName(userName)
+				Expect(validation.IsDNS1123Label(name)).Should(BeEmpty(), userName)
+			}
+			Expect(sanitizeNamespaceName("j.doe")).ShouldNot(Equal(sanitizeNamespaceName("j_doe")))
+		})
+	})
+
+	Context("When spec.namespace names a namespace", func() {
+		It("Should create it with the labels and annotations and put the Secrets in it", func() {
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-named-namespace", Namespace: "default"},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: "j.doe@example.com",
+					Namespace: &kuadrav1.NamespaceSpec{
+						Name:        "team-dns",
+						Labels:      map[string]string{"team": "dns"},
+						Annotations: map[string]string{"contact": "j.doe@example.com"},
+					},
 				},
-			}))
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+			k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+			r := newReconciler(k8sClient)
 
-			By("By checking if user has login profile")
-			Expect(mockIam.LoginProfile[awsController.Spec.UserName]).Should(Equal(types.LoginProfile{
-				UserName:              &awsController.Spec.UserName,
-				PasswordResetRequired: true,
-			}))
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
 
-			By("By checking if user has access key")
-			Expect(mockIam.AccessKeys[awsController.Spec.UserName]).Should(Equal([]types.AccessKey{
-				{
-					AccessKeyId:     aws.String("AccessKeyId"),
-					SecretAccessKey: aws.String("SecretAccessKey"),
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "team-dns"}, ns)).Should(Succeed())
+			Expect(ns.Labels).Should(HaveKeyWithValue("team", "dns"))
+			Expect(ns.Annotations).Should(HaveKeyWithValue("contact", "j.doe@example.com"))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: "team-dns"}, &corev1.Secret{})).Should(Succeed())
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Namespace).Should(Equal("team-dns"))
+
+			By("By moving the Secrets when the namespace changes")
+			reconciled.Spec.Namespace.Name = "team-dns-2"
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: "team-dns-2"}, &corev1.Secret{})).Should(Succeed())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: "team-dns"}, &corev1.Secret{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("When spec.namespace uses the AwsAccount's namespace", func() {
+		It("Should put the Secrets next to the AwsAccount without creating a namespace", func() {
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-own-namespace", Namespace: "team-a"},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName:  "own-namespace-user",
+					Namespace: &kuadrav1.NamespaceSpec{UseAccountNamespace: true},
 				},
-			}))
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+			k8sClient := newAwsAccountClientBuilder().WithObjects(awsAccount).Build()
+			r := newReconciler(k8sClient)
 
-			By("By checking if user has correct groups")
-			Expect(mockIam.Groups[awsController.Spec.UserName]).Should(Equal([]types.Group{
-				{
-					GroupName: &awsController.Spec.Groups[0],
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			namespaces := &corev1.NamespaceList{}
+			Expect(k8sClient.List(ctx, namespaces)).Should(Succeed())
+			Expect(namespaces.Items).Should(BeEmpty())
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-login", Namespace: "team-a"}, &corev1.Secret{})).Should(Succeed())
+		})
+	})
+
+	Context("When the namespace was not created by kuadra", func() {
+		It("Should refuse it and leave its Secrets alone", func() {
+			existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shared"}}
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-credentials", Namespace: "shared"},
+				StringData: map[string]string{"AWS_ACCESS_KEY_ID": "ProductionAccessKeyId"},
+			}
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "awsaccount-shared-namespace",
+					Namespace:  "default",
+					Finalizers: []string{AwsAccountFinalizer},
 				},
-				{
-					GroupName: &awsController.Spec.Groups[1],
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName:  "shared-namespace-user",
+					Namespace: &kuadrav1.NamespaceSpec{Name: "shared", Labels: map[string]string{"team": "dns"}},
 				},
-			}))
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+			k8sClient := newAwsAccountClientBuilder().WithObjects(existing, credentials, awsAccount).Build()
+			r := newReconciler(k8sClient)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("namespace shared was not created by kuadra")))
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "shared"}, ns)).Should(Succeed())
+			Expect(ns.Labels).ShouldNot(HaveKey("team"))
+
+			Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+			condition := meta.FindStatusCondition(awsAccount.Status.Conditions, kuadrav1.ConditionNamespaceReady)
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+
+			By("By leaving the namespace and its Secrets when the AwsAccount is deleted")
+			Expect(k8sClient.Delete(ctx, awsAccount)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "shared"}, ns)).Should(Succeed())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: "shared"}, secret)).Should(Succeed())
+			Expect(secret.StringData).Should(HaveKeyWithValue("AWS_ACCESS_KEY_ID", "ProductionAccessKeyId"))
+		})
+
+		It("Should use it as it is when the operator allows it", func() {
+			existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dns-shared"}}
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "awsaccount-allowed-namespace",
+					Namespace:  "default",
+					Finalizers: []string{AwsAccountFinalizer},
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName:  "allowed-namespace-user",
+					Namespace: &kuadrav1.NamespaceSpec{Name: "dns-shared", Labels: map[string]string{"team": "dns"}},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+			k8sClient := newAwsAccountClientBuilder().WithObjects(existing, awsAccount).Build()
+			r := newReconciler(k8sClient)
+			r.Namespaces = []string{"dns-shared"}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "dns-shared"}, ns)).Should(Succeed())
+			Expect(ns.Labels).Should(BeEmpty())
+			Expect(ns.Annotations).Should(BeEmpty())
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: "dns-shared"}, &corev1.Secret{})).Should(Succeed())
+
+			By("By deleting only its Secrets when the AwsAccount is deleted")
+			Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, awsAccount)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "dns-shared"}, ns)).Should(Succeed())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: "dns-shared"}, &corev1.Secret{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should not overwrite Secrets it did not write in the AwsAccount's namespace", func() {
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-login", Namespace: "team-a"},
+				StringData: map[string]string{"password": "unrelated"},
+			}
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-foreign-secret", Namespace: "team-a"},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName:  "foreign-secret-user",
+					Namespace: &kuadrav1.NamespaceSpec{UseAccountNamespace: true},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+			k8sClient := newAwsAccountClientBuilder().WithObjects(credentials, awsAccount).Build()
+			r := newReconciler(k8sClient)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("not managed by kuadra: team-a/aws-login")))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-login", Namespace: "team-a"}, secret)).Should(Succeed())
+			Expect(secret.StringData).Should(HaveKeyWithValue("password", "unrelated"))
+		})
+	})
+
+	Context("When the AwsAccount was reconciled before kuadra marked its namespace and Secrets", func() {
+		It("Should adopt the namespace and Secrets the status records", func() {
+			userName := "legacy-user"
+			existing := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: userName}}
+			login := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-login", Namespace: userName},
+				Data:       map[string][]byte{"password": []byte("LegacyPassword")},
+			}
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-credentials", Namespace: userName},
+				Data:       map[string][]byte{"AWS_ACCESS_KEY_ID": []byte("LegacyAccessKeyId")},
+			}
+			awsAccount := &kuadrav1.AwsAccount{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "awsaccount-legacy-user",
+					Namespace:  "default",
+					Finalizers: []string{AwsAccountFinalizer},
+				},
+				Spec: kuadrav1.AwsAccountSpec{
+					UserName: userName,
+					Adopt:    true,
+				},
+				Status: kuadrav1.AwsAccountStatus{
+					UserCreated:         true,
+					LoginProfileCreated: true,
+					AccessKeyCreated:    true,
+					NamespaceCreated:    true,
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+			k8sClient := newAwsAccountClientBuilder().WithObjects(existing, login, credentials, awsAccount).Build()
+			mockIam := &mockIamWrapper{
+				Users:        []types.User{{UserName: aws.String(userName)}},
+				LoginProfile: map[string]types.LoginProfile{userName: {UserName: aws.String(userName)}},
+				AccessKeys:   map[string][]types.AccessKey{userName: {{AccessKeyId: aws.String("LegacyAccessKeyId"), UserName: aws.String(userName)}}},
+				Groups:       map[string][]types.Group{},
+			}
+			r := newReconciler(k8sClient)
+			r.IamWrapper = mockIam
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: userName}, ns)).Should(Succeed())
+			Expect(ns.Labels).Should(HaveKeyWithValue(managedByLabelKey, managedByLabelValue))
+			Expect(ns.Annotations).Should(HaveKeyWithValue(ownerAnnotationKey, "default/awsaccount-legacy-user"))
+			for _, secretName := range []string{"aws-login", "aws-credentials"} {
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: secretName, Namespace: userName}, secret)).Should(Succeed())
+				Expect(secret.Annotations).Should(HaveKeyWithValue(ownerAnnotationKey, "default/awsaccount-legacy-user"), secretName)
+			}
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "aws-credentials", Namespace: userName}, secret)).Should(Succeed())
+			Expect(secret.Data).Should(HaveKeyWithValue("AWS_ACCESS_KEY_ID", []byte("LegacyAccessKeyId")))
+			Expect(mockIam.AccessKeys[userName]).Should(HaveLen(1))
+
+			reconciled := &kuadrav1.AwsAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Namespace).Should(Equal(userName))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionNamespaceReady)).Should(BeTrue())
+
+			By("By deleting the adopted namespace with the AwsAccount")
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: userName}, ns)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
 		})
 	})
 })
 
+var _ = Describe("AwsAccount namespace template", func() {
+
+	ctx := context.Background()
+
+	It("Should render the template into the namespace and keep it in sync", func() {
+		template := &kuadrav1.NamespaceTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "developer"},
+			Spec: kuadrav1.NamespaceTemplateSpec{
+				RoleBindings: []kuadrav1.RoleBindingTemplate{{
+					Name:    "edit",
+					RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+				}},
+				ResourceQuota: &corev1.ResourceQuotaSpec{
+					Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+				},
+				NetworkPolicies: []kuadrav1.NetworkPolicyTemplate{{Name: "deny-all"}},
+			},
+		}
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-templated", Namespace: "default"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName: "templated-user",
+				Namespace: &kuadrav1.NamespaceSpec{
+					Template:       "developer",
+					KubernetesUser: "templated-user@example.com",
+				},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(template, awsAccount).Build()
+		r := &AwsAccountReconciler{
+			Client: k8sClient,
+			Scheme: scheme.Scheme,
+			IamWrapper: &mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			},
+			Recorder: record.NewFakeRecorder(10),
+
+			NamespaceTemplateClusterRoles: []string{"admin", "edit", "view"},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		roleBinding := &rbacv1.RoleBinding{}
+		Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "edit", Namespace: "templated-user"}, roleBinding)).Should(Succeed())
+		Expect(roleBinding.Subjects).Should(ConsistOf(HaveField("Name", "templated-user@example.com")))
+		resourceQuota := &corev1.ResourceQuota{}
+		Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra", Namespace: "templated-user"}, resourceQuota)).Should(Succeed())
+		Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "deny-all", Namespace: "templated-user"}, &networkingv1.NetworkPolicy{})).Should(Succeed())
+
+		reconciled := &kuadrav1.AwsAccount{}
+		Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+		Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionNamespaceTemplateApplied)).Should(BeTrue())
+
+		By("By reverting drift")
+		resourceQuota.Spec.Hard = corev1.ResourceList{corev1.ResourcePods: resource.MustParse("100")}
+		Expect(k8sClient.Update(ctx, resourceQuota)).Should(Succeed())
+		Expect(r.awsAccountForTemplateObject(resourceQuota)).Should(ConsistOf(reconcile.Request{NamespacedName: lookupKey}))
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(resourceQuota), resourceQuota)).Should(Succeed())
+		Expect(resourceQuota.Spec.Hard.Pods().String()).Should(Equal("10"))
+
+		By("By updating the template")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(template), template)).Should(Succeed())
+		template.Spec.RoleBindings[0].RoleRef.Name = "view"
+		template.Spec.NetworkPolicies = nil
+		Expect(k8sClient.Update(ctx, template)).Should(Succeed())
+		Expect(r.awsAccountsForNamespaceTemplate(template)).Should(ConsistOf(reconcile.Request{NamespacedName: lookupKey}))
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(roleBinding), roleBinding)).Should(Succeed())
+		Expect(roleBinding.RoleRef.Name).Should(Equal("view"))
+		err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "deny-all", Namespace: "templated-user"}, &networkingv1.NetworkPolicy{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+	})
+
+	It("Should refuse to take over objects it did not render for the AwsAccount", func() {
+		template := &kuadrav1.NamespaceTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "viewer"},
+			Spec: kuadrav1.NamespaceTemplateSpec{
+				RoleBindings: []kuadrav1.RoleBindingTemplate{{
+					Name:    "view",
+					RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"},
+				}},
+			},
+		}
+		foreign := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "view", Namespace: "team-a"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "team-a-lead"}},
+		}
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-foreign-binding", Namespace: "team-a"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName:  "foreign-binding-user",
+				Namespace: &kuadrav1.NamespaceSpec{UseAccountNamespace: true, Template: "viewer"},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(template, foreign, awsAccount).Build()
+		r := &AwsAccountReconciler{
+			Client: k8sClient,
+			Scheme: scheme.Scheme,
+			IamWrapper: &mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			},
+			Recorder: record.NewFakeRecorder(10),
+
+			NamespaceTemplateClusterRoles: []string{"admin", "edit", "view"},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).Should(MatchError(ContainSubstring("RoleBinding team-a/view exists and was not rendered by kuadra")))
+
+		roleBinding := &rbacv1.RoleBinding{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(foreign), roleBinding)).Should(Succeed())
+		Expect(roleBinding.RoleRef.Name).Should(Equal("admin"))
+		Expect(roleBinding.Subjects).Should(ConsistOf(HaveField("Name", "team-a-lead")))
+
+		reconciled := &kuadrav1.AwsAccount{}
+		Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+		condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionNamespaceTemplateApplied)
+		Expect(condition).ShouldNot(BeNil())
+		Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+	})
+
+	It("Should refuse templates binding roles the operator does not allow", func() {
+		template := &kuadrav1.NamespaceTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+			Spec: kuadrav1.NamespaceTemplateSpec{
+				RoleBindings: []kuadrav1.RoleBindingTemplate{{
+					Name:    "cluster-admin",
+					RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "cluster-admin"},
+				}},
+			},
+		}
+		awsAccount := &kuadrav1.AwsAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "awsaccount-cluster-admin", Namespace: "default"},
+			Spec: kuadrav1.AwsAccountSpec{
+				UserName:  "cluster-admin-user",
+				Namespace: &kuadrav1.NamespaceSpec{Template: "cluster-admin"},
+			},
+		}
+		lookupKey := k8Types.NamespacedName{Name: awsAccount.Name, Namespace: awsAccount.Namespace}
+		k8sClient := newAwsAccountClientBuilder().WithObjects(template, awsAccount).Build()
+		r := &AwsAccountReconciler{
+			Client: k8sClient,
+			Scheme: scheme.Scheme,
+			IamWrapper: &mockIamWrapper{
+				Users:        []types.User{},
+				LoginProfile: map[string]types.LoginProfile{},
+				AccessKeys:   map[string][]types.AccessKey{},
+				Groups:       map[string][]types.Group{},
+			},
+			Recorder: record.NewFakeRecorder(10),
+
+			NamespaceTemplateClusterRoles: []string{"admin", "edit", "view"},
+		}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+		Expect(err).Should(MatchError(ContainSubstring("ClusterRole cluster-admin of NamespaceTemplate cluster-admin cannot be bound")))
+
+		err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "cluster-admin", Namespace: "cluster-admin-user"}, &rbacv1.RoleBinding{})
+		Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+		reconciled := &kuadrav1.AwsAccount{}
+		Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+		condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionNamespaceTemplateApplied)
+		Expect(condition).ShouldNot(BeNil())
+		Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+	})
+})
+
+// ownedUserTags returns the tags marking an existing IAM user as managed by the AwsAccount
+// newAwsAccountClientBuilder returns a fake client builder with the spec.userName index the
+// AwsAccountReconciler registers in SetupWithManager
+func newAwsAccountClientBuilder() *fake.ClientBuilder {
+	return fake.NewClientBuilder().WithIndex(&kuadrav1.AwsAccount{}, kuadrav1.AwsAccountUserNameField, kuadrav1.AwsAccountUserNameIndex)
+}
+
+func ownedUserTags(awsAccount *kuadrav1.AwsAccount) map[string]string {
+	return map[string]string{
+		managedByTagKey: managedByTagValue,
+		clusterIdTagKey: "",
+		ownerTagKey:     awsAccount.Namespace + "/" + awsAccount.Name,
+	}
+}
+
+var mockNameServers = []string{"ns-1.awsdns-01.org", "ns-2.awsdns-02.com"}
+
+type mockRoute53Wrapper struct {
+	HostedZones map[string]route53types.HostedZone
+	Delegations map[string][]string
+}
+
+func newMockRoute53Wrapper() *mockRoute53Wrapper {
+	return &mockRoute53Wrapper{
+		HostedZones: map[string]route53types.HostedZone{},
+		Delegations: map[string][]string{},
+	}
+}
+
+func (c *mockRoute53Wrapper) GetHostedZone(ctx context.Context, hostedZoneId string) (*route53types.HostedZone, []string, error) {
+	hostedZone, exists := c.HostedZones[hostedZoneId]
+	if !exists {
+		return nil, nil, nil
+	}
+	return &hostedZone, mockNameServers, nil
+}
+
+func (c *mockRoute53Wrapper) ListHostedZonesByName(ctx context.Context, name string) ([]route53types.HostedZone, error) {
+	var hostedZones []route53types.HostedZone
+	for _, hostedZone := range c.HostedZones {
+		if *hostedZone.Name == kuadraaws.NormalizeDomainName(name) {
+			hostedZones = append(hostedZones, hostedZone)
+		}
+	}
+	return hostedZones, nil
+}
+
+func (c *mockRoute53Wrapper) CreateHostedZone(ctx context.Context, name string, callerReference string) (*route53types.HostedZone, []string, error) {
+	hostedZone := route53types.HostedZone{
+		Id:              aws.String(fmt.Sprintf("Z%d", len(c.HostedZones)+1)),
+		Name:            aws.String(kuadraaws.NormalizeDomainName(name)),
+		CallerReference: aws.String(callerReference),
+	}
+	c.HostedZones[*hostedZone.Id] = hostedZone
+	return &hostedZone, mockNameServers, nil
+}
+
+func (c *mockRoute53Wrapper) DeleteHostedZoneIfExists(ctx context.Context, hostedZoneId string) error {
+	delete(c.HostedZones, hostedZoneId)
+	return nil
+}
+
+func (c *mockRoute53Wrapper) UpsertNameServerRecord(ctx context.Context, hostedZoneId string, name string, nameServers []string) error {
+	c.Delegations[hostedZoneId+"/"+kuadraaws.NormalizeDomainName(name)] = nameServers
+	return nil
+}
+
+func (c *mockRoute53Wrapper) DeleteNameServerRecordIfExists(ctx context.Context, hostedZoneId string, name string) error {
+	delete(c.Delegations, hostedZoneId+"/"+kuadraaws.NormalizeDomainName(name))
+	return nil
+}
+
 type mockIamWrapper struct {
 	Users        []types.User
 	LoginProfile map[string]types.LoginProfile
 	AccessKeys   map[string][]types.AccessKey
 	Groups       map[string][]types.Group
+
+	InlinePolicies      map[string]map[string]string
+	AttachedPolicies    map[string][]string
+	PermissionsBoundary map[string]string
+	Tags                map[string]map[string]string
+
+	// AccountGroups are the IAM groups of the account
+	AccountGroups []string
+
+	AddUserToGroupErr error
 }
 
 func (c mockIamWrapper) GetUser(userName string) (*types.User, error) {
@@ -182,6 +2021,21 @@ func (c mockIamWrapper) ListGroupsForUser(ctx context.Context, userName string)
 	return c.Groups[userName], nil
 }
 
+func (c mockIamWrapper) ListGroups(ctx context.Context) ([]types.Group, error) {
+	var groups []types.Group
+	for _, groupName := range c.AccountGroups {
+		groups = append(groups, types.Group{GroupName: aws.String(groupName)})
+	}
+	return groups, nil
+}
+
+func (c mockIamWrapper) GetGroup(ctx context.Context, groupName string) (*types.Group, error) {
+	if !slice.Contains(c.AccountGroups, groupName) {
+		return nil, nil
+	}
+	return &types.Group{GroupName: aws.String(groupName)}, nil
+}
+
 func (c *mockIamWrapper) CreateUser(ctx context.Context, userName string) (*types.User, error) {
 	user := types.User{
 		UserName: &userName,
@@ -190,9 +2044,12 @@ func (c *mockIamWrapper) CreateUser(ctx context.Context, userName string) (*type
 	return &user, nil
 }
 
-func (c *mockIamWrapper) CreateUserIfNotExists(ctx context.Context, userName string) error {
+func (c *mockIamWrapper) CreateUserIfNotExists(ctx context.Context, userName string, permissionsBoundaryArn string, tags map[string]string) error {
 	c.CreateUser(ctx, userName)
-	return nil
+	if err := c.TagUser(ctx, userName, tags); err != nil {
+		return err
+	}
+	return c.PutUserPermissionsBoundary(ctx, userName, permissionsBoundaryArn)
 }
 
 func (c mockIamWrapper) ListUsers(ctx context.Context, maxUsers int32) ([]types.User, error) {
@@ -219,9 +2076,15 @@ func (c mockIamWrapper) CreateLoginProfileIfNotExists(ctx context.Context, passw
 }
 
 func (c mockIamWrapper) CreateAccessKeyPair(ctx context.Context, userName string) (*types.AccessKey, error) {
+	accessKeyId := "AccessKeyId"
+	if n := len(c.AccessKeys[userName]); n > 0 {
+		accessKeyId = fmt.Sprintf("AccessKeyId%d", n+1)
+	}
 	accessKey := types.AccessKey{
-		AccessKeyId:     aws.String("AccessKeyId"),
+		AccessKeyId:     aws.String(accessKeyId),
 		SecretAccessKey: aws.String("SecretAccessKey"),
+		CreateDate:      aws.Time(time.Now()),
+		Status:          types.StatusTypeActive,
 	}
 	c.AccessKeys[userName] = append(c.AccessKeys[userName], accessKey)
 
@@ -229,6 +2092,9 @@ func (c mockIamWrapper) CreateAccessKeyPair(ctx context.Context, userName string
 }
 
 func (c mockIamWrapper) AddUserToGroup(ctx context.Context, groupName string, userName string) (middleware.Metadata, error) {
+	if c.AddUserToGroupErr != nil {
+		return middleware.Metadata{}, c.AddUserToGroupErr
+	}
 	userGroup := types.Group{
 		GroupName: &groupName,
 	}
@@ -237,12 +2103,12 @@ func (c mockIamWrapper) AddUserToGroup(ctx context.Context, groupName string, us
 }
 
 func (c *mockIamWrapper) RemoveUserFromGroup(ctx context.Context, groupName string, userName string) (middleware.Metadata, error) {
-	c.Groups[userName] = slice.Remove(c.Groups[userName], func(g types.Group) bool { return g == types.Group{GroupName: &groupName} })
+	c.Groups[userName] = slice.Remove(c.Groups[userName], func(g types.Group) bool { return *g.GroupName == groupName })
 	return middleware.Metadata{}, nil
 }
 
 func (c *mockIamWrapper) DeleteUser(ctx context.Context, userName string) error {
-	c.Users = slice.Remove(c.Users, func(u types.User) bool { return u.UserName == &userName })
+	c.Users = slice.Remove(c.Users, func(u types.User) bool { return *u.UserName == userName })
 	return nil
 }
 
@@ -256,6 +2122,8 @@ func (c *mockIamWrapper) ListAccessKeys(ctx context.Context, userName string) ([
 	for _, accessKey := range c.AccessKeys[userName] {
 		ak := types.AccessKeyMetadata{
 			AccessKeyId: accessKey.AccessKeyId,
+			CreateDate:  accessKey.CreateDate,
+			Status:      accessKey.Status,
 		}
 		accessKeys = append(accessKeys, ak)
 	}
@@ -263,6 +2131,109 @@ func (c *mockIamWrapper) ListAccessKeys(ctx context.Context, userName string) ([
 }
 
 func (c *mockIamWrapper) DeleteAccessKeyIfExists(ctx context.Context, userName string, keyId string) error {
-	c.AccessKeys[userName] = slice.Remove(c.AccessKeys[userName], func(a types.AccessKey) bool { return a.AccessKeyId == &keyId })
+	c.AccessKeys[userName] = slice.Remove(c.AccessKeys[userName], func(a types.AccessKey) bool { return *a.AccessKeyId == keyId })
+	return nil
+}
+
+func (c *mockIamWrapper) GetUserPolicy(ctx context.Context, userName string, policyName string) (string, error) {
+	return c.InlinePolicies[userName][policyName], nil
+}
+
+func (c *mockIamWrapper) PutUserPolicy(ctx context.Context, userName string, policyName string, policyDocument string) error {
+	if c.InlinePolicies == nil {
+		c.InlinePolicies = map[string]map[string]string{}
+	}
+	if c.InlinePolicies[userName] == nil {
+		c.InlinePolicies[userName] = map[string]string{}
+	}
+	c.InlinePolicies[userName][policyName] = policyDocument
+	return nil
+}
+
+func (c *mockIamWrapper) DeleteUserPolicyIfExists(ctx context.Context, userName string, policyName string) error {
+	delete(c.InlinePolicies[userName], policyName)
+	return nil
+}
+
+func (c *mockIamWrapper) ListUserPolicies(ctx context.Context, userName string) ([]string, error) {
+	var policyNames []string
+	for policyName := range c.InlinePolicies[userName] {
+		policyNames = append(policyNames, policyName)
+	}
+	return policyNames, nil
+}
+
+func (c *mockIamWrapper) ListAttachedUserPolicies(ctx context.Context, userName string) ([]types.AttachedPolicy, error) {
+	var attachedPolicies []types.AttachedPolicy
+	for _, policyArn := range c.AttachedPolicies[userName] {
+		attachedPolicies = append(attachedPolicies, types.AttachedPolicy{PolicyArn: aws.String(policyArn)})
+	}
+	return attachedPolicies, nil
+}
+
+func (c *mockIamWrapper) AttachUserPolicy(ctx context.Context, userName string, policyArn string) error {
+	if c.AttachedPolicies == nil {
+		c.AttachedPolicies = map[string][]string{}
+	}
+	c.AttachedPolicies[userName] = append(c.AttachedPolicies[userName], policyArn)
+	return nil
+}
+
+func (c *mockIamWrapper) DetachUserPolicyIfAttached(ctx context.Context, userName string, policyArn string) error {
+	c.AttachedPolicies[userName] = slice.Remove(c.AttachedPolicies[userName], func(p string) bool { return p == policyArn })
+	return nil
+}
+
+func (c *mockIamWrapper) GetUserPermissionsBoundary(ctx context.Context, userName string) (string, error) {
+	return c.PermissionsBoundary[userName], nil
+}
+
+func (c *mockIamWrapper) PutUserPermissionsBoundary(ctx context.Context, userName string, permissionsBoundaryArn string) error {
+	if c.PermissionsBoundary == nil {
+		c.PermissionsBoundary = map[string]string{}
+	}
+	c.PermissionsBoundary[userName] = permissionsBoundaryArn
+	return nil
+}
+
+func (c *mockIamWrapper) DeleteUserPermissionsBoundaryIfExists(ctx context.Context, userName string) error {
+	delete(c.PermissionsBoundary, userName)
+	return nil
+}
+
+func (c *mockIamWrapper) ListUserTags(ctx context.Context, userName string) (map[string]string, error) {
+	tags := map[string]string{}
+	for key, value := range c.Tags[userName] {
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+func (c *mockIamWrapper) TagUser(ctx context.Context, userName string, tags map[string]string) error {
+	if c.Tags == nil {
+		c.Tags = map[string]map[string]string{}
+	}
+	if c.Tags[userName] == nil {
+		c.Tags[userName] = map[string]string{}
+	}
+	for key, value := range tags {
+		c.Tags[userName][key] = value
+	}
+	return nil
+}
+
+func (c *mockIamWrapper) UntagUser(ctx context.Context, userName string, tagKeys []string) error {
+	for _, key := range tagKeys {
+		delete(c.Tags[userName], key)
+	}
+	return nil
+}
+
+func (c *mockIamWrapper) UpdateAccessKeyStatus(ctx context.Context, userName string, keyId string, status types.StatusType) error {
+	for i, accessKey := range c.AccessKeys[userName] {
+		if *accessKey.AccessKeyId == keyId {
+			c.AccessKeys[userName][i].Status = status
+		}
+	}
 	return nil
 }
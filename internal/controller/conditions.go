@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+// awsAccountComponentConditions lists the conditions that must all be True for an AwsAccount to be Ready
+func awsAccountComponentConditions(awsAccount *kuadrav1.AwsAccount) []string {
+	components := []string{
+		kuadrav1.ConditionNamespaceReady,
+		kuadrav1.ConditionIamUserReady,
+		kuadrav1.ConditionLoginProfileReady,
+		kuadrav1.ConditionAccessKeyReady,
+		kuadrav1.ConditionGroupsSynced,
+		kuadrav1.ConditionPoliciesSynced,
+		kuadrav1.ConditionAccessAllowed,
+	}
+	if awsAccount.Spec.HostedZone != nil {
+		components = append(components, kuadrav1.ConditionHostedZoneReady)
+	}
+	if namespaceTemplateName(awsAccount) != "" {
+		components = append(components, kuadrav1.ConditionNamespaceTemplateApplied)
+	}
+	return components
+}
+
+var conditionReasonPattern = regexp.MustCompile(`^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$`)
+
+// setCondition records a condition, only bumping its transition time when the status changes
+func setCondition(conditions *[]metav1.Condition, generation int64, conditionType string, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func setConditionTrue(conditions *[]metav1.Condition, generation int64, conditionType string, message string) {
+	setCondition(conditions, generation, conditionType, metav1.ConditionTrue, kuadrav1.ReasonProvisioned, message)
+}
+
+func setConditionFromError(conditions *[]metav1.Condition, generation int64, conditionType string, err error) {
+	setCondition(conditions, generation, conditionType, metav1.ConditionFalse, errorReason(err), err.Error())
+}
+
+// errNotAllowed is wrapped by the errors of specs asking for something the operator's configuration
+// does not allow
+var errNotAllowed = errors.New("not allowed")
+
+// notAllowedError wraps the errors of a spec validated against the operator's configuration in
+// errNotAllowed, or returns nil when there are none
+func notAllowedError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errNotAllowed, allErrs.ToAggregate())
+}
+
+// errorCoder is implemented by the API errors of the AWS SDK and of the other service clients
+type errorCoder interface {
+	ErrorCode() string
+}
+
+// errorReason returns the error code of a service API error, so that conditions
+// say e.g. NoSuchEntity or AccessDenied rather than a generic failure
+func errorReason(err error) string {
+	if errors.Is(err, errUserNotManaged) || errors.Is(err, errKeycloakUserNotManaged) {
+		return kuadrav1.ReasonUserNotManaged
+	}
+	if errors.Is(err, errNotAllowed) {
+		return kuadrav1.ReasonNotAllowed
+	}
+	var apiError errorCoder
+	if errors.As(err, &apiError) && conditionReasonPattern.MatchString(apiError.ErrorCode()) {
+		return apiError.ErrorCode()
+	}
+	return kuadrav1.ReasonFailed
+}
+
+// setReadyCondition summarises the component conditions into the Ready condition
+func setReadyCondition(conditions *[]metav1.Condition, generation int64, components []string, reconcileErr error) {
+	for _, conditionType := range components {
+		condition := meta.FindStatusCondition(*conditions, conditionType)
+		if condition == nil {
+			setCondition(conditions, generation, kuadrav1.ConditionReady, metav1.ConditionFalse, kuadrav1.ReasonProvisioning, conditionType+" has not been reconciled yet")
+			return
+		}
+		if condition.Status != metav1.ConditionTrue {
+			setCondition(conditions, generation, kuadrav1.ConditionReady, metav1.ConditionFalse, condition.Reason, conditionType+": "+condition.Message)
+			return
+		}
+	}
+	if reconcileErr != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionReady, reconcileErr)
+		return
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionReady, "All resources are provisioned")
+}
+
+// setSuspendedConditions reports a suspended account: Suspended is True and Ready is False for as long
+// as the suspension lasts. An event is emitted when the suspension takes effect.
+func setSuspendedConditions(recorder record.EventRecorder, object client.Object, conditions *[]metav1.Condition, generation int64, suspendErr error) {
+	if suspendErr != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionSuspended, suspendErr)
+		setConditionFromError(conditions, generation, kuadrav1.ConditionReady, suspendErr)
+		return
+	}
+	if !meta.IsStatusConditionTrue(*conditions, kuadrav1.ConditionSuspended) {
+		recorder.Event(object, v1.EventTypeNormal, kuadrav1.ReasonSuspended, "Access is suspended")
+	}
+	setCondition(conditions, generation, kuadrav1.ConditionSuspended, metav1.ConditionTrue, kuadrav1.ReasonSuspended, "Access is suspended")
+	setCondition(conditions, generation, kuadrav1.ConditionReady, metav1.ConditionFalse, kuadrav1.ReasonSuspended, "Access is suspended")
+}
+
+// setReactivatedCondition ends the suspension of an account whose access has been restored, emitting
+// an event. A suspension that never took effect is simply forgotten.
+func setReactivatedCondition(recorder record.EventRecorder, object client.Object, conditions *[]metav1.Condition, generation int64) {
+	condition := meta.FindStatusCondition(*conditions, kuadrav1.ConditionSuspended)
+	if condition == nil || condition.Reason == kuadrav1.ReasonReactivated {
+		return
+	}
+	if condition.Status != metav1.ConditionTrue {
+		meta.RemoveStatusCondition(conditions, kuadrav1.ConditionSuspended)
+		return
+	}
+	recorder.Event(object, v1.EventTypeNormal, kuadrav1.ReasonReactivated, "Access is restored")
+	setCondition(conditions, generation, kuadrav1.ConditionSuspended, metav1.ConditionFalse, kuadrav1.ReasonReactivated, "Access is restored")
+}
+
+// setUserReadyCondition summarises the readiness of a User's accounts into the Ready condition
+func setUserReadyCondition(conditions *[]metav1.Condition, generation int64, services []kuadrav1.ServiceStatus, reconcileErr error) {
+	if reconcileErr != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionReady, reconcileErr)
+		return
+	}
+	for _, service := range services {
+		if service.Ready != metav1.ConditionTrue {
+			status := metav1.ConditionFalse
+			if service.Ready == metav1.ConditionUnknown {
+				status = metav1.ConditionUnknown
+			}
+			setCondition(conditions, generation, kuadrav1.ConditionReady, status, service.Reason, service.Service+": "+service.Message)
+			return
+		}
+	}
+	if len(services) == 0 {
+		setConditionTrue(conditions, generation, kuadrav1.ConditionReady, "No services are configured")
+		return
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionReady, "All services are ready")
+}
@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/Kuadrant/kuadra/pkg/github"
+)
+
+type GithubWrapper interface {
+	GetOrgMembership(ctx context.Context, login string) (*github.Membership, error)
+	SetOrgMembership(ctx context.Context, login string, role string) (*github.Membership, error)
+	RemoveOrgMembership(ctx context.Context, login string) error
+	AddTeamMembership(ctx context.Context, teamSlug string, login string) error
+	RemoveTeamMembership(ctx context.Context, teamSlug string, login string) error
+}
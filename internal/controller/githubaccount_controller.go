@@ -0,0 +1,269 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+	"github.com/Kuadrant/kuadra/pkg/github"
+)
+
+const (
+	GithubAccountFinalizer = "kuadra.kuadrant.io/github-account"
+
+	// githubInvitationPollInterval is how often a pending invitation is checked for acceptance
+	githubInvitationPollInterval = 10 * time.Minute
+)
+
+// GithubAccountReconciler reconciles a GithubAccount object
+type GithubAccountReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	GithubWrapper GithubWrapper
+	Recorder      record.EventRecorder
+	// Options limit the roles GitHub accounts can ask for
+	Options kuadrav1.ServiceOptions
+}
+
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=githubaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=githubaccounts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=githubaccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accesspolicies,verbs=get;list;watch
+
+// Reconcile invites the user to the GitHub organization and keeps their team memberships in
+// sync with the spec. A user kuadra invited is removed from the organization on deletion.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *GithubAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var githubAccount kuadrav1.GithubAccount
+	if err := r.Get(ctx, req.NamespacedName, &githubAccount); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if githubAccount.DeletionTimestamp != nil && !githubAccount.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(&githubAccount, GithubAccountFinalizer) {
+			if err := r.finalizeGithubAccount(ctx, &githubAccount); err != nil {
+				log.Error(err, "Failed to remove GitHub memberships", "login", githubAccount.Spec.Login)
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(&githubAccount, GithubAccountFinalizer)
+			if err := r.Update(ctx, &githubAccount); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&githubAccount, GithubAccountFinalizer) {
+		controllerutil.AddFinalizer(&githubAccount, GithubAccountFinalizer)
+		if err := r.Update(ctx, &githubAccount); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	denied, err := serviceDenied(ctx, r.Client, githubAccount.Namespace, kuadrav1.ServiceGithub)
+	if err != nil {
+		log.Error(err, "unable to evaluate AccessPolicies")
+		return ctrl.Result{}, err
+	}
+
+	var result ctrl.Result
+	var reconcileErr error
+	if githubAccount.Spec.Suspended {
+		if reconcileErr = r.suspendGithubAccount(ctx, &githubAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to suspend GitHub memberships", "login", githubAccount.Spec.Login)
+		}
+		setSuspendedConditions(r.Recorder, &githubAccount, &githubAccount.Status.Conditions, githubAccount.Generation, reconcileErr)
+	} else if denied {
+		if reconcileErr = r.suspendGithubAccount(ctx, &githubAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to remove GitHub memberships", "login", githubAccount.Spec.Login)
+		}
+		setServiceDeniedConditions(&githubAccount.Status.Conditions, githubAccount.Generation, githubAccount.Namespace, kuadrav1.ServiceGithub, reconcileErr)
+	} else {
+		result, reconcileErr = r.reconcileGithubAccount(ctx, &githubAccount)
+		setReadyCondition(&githubAccount.Status.Conditions, githubAccount.Generation, []string{
+			kuadrav1.ConditionMembershipReady,
+			kuadrav1.ConditionTeamsSynced,
+		}, reconcileErr)
+		if reconcileErr == nil {
+			setReactivatedCondition(r.Recorder, &githubAccount, &githubAccount.Status.Conditions, githubAccount.Generation)
+		}
+	}
+	githubAccount.Status.ObservedGeneration = githubAccount.Generation
+
+	var latest kuadrav1.GithubAccount
+	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, githubAccount.Status) {
+		if err := r.Status().Update(ctx, &githubAccount); err != nil {
+			log.Error(err, "unable to update GithubAccount status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+		}
+	}
+
+	return result, reconcileErr
+}
+
+// githubRole returns the organization role in the spec, defaulting to member
+func githubRole(githubAccount *kuadrav1.GithubAccount) string {
+	if githubAccount.Spec.Role == "" {
+		return github.RoleMember
+	}
+	return githubAccount.Spec.Role
+}
+
+// reconcileGithubAccount moves the user's organization and team memberships towards the spec,
+// recording a condition for each on the GithubAccount status
+func (r *GithubAccountReconciler) reconcileGithubAccount(ctx context.Context, githubAccount *kuadrav1.GithubAccount) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	conditions := &githubAccount.Status.Conditions
+	generation := githubAccount.Generation
+	login := githubAccount.Spec.Login
+	role := githubRole(githubAccount)
+
+	if err := notAllowedError(r.Options.ValidateGithubAccountSpec(&githubAccount.Spec, field.NewPath("spec"))); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionMembershipReady, err)
+		return ctrl.Result{}, err
+	}
+
+	membership, err := r.GithubWrapper.GetOrgMembership(ctx, login)
+	if err != nil {
+		log.Error(err, "unable to get organization membership", "login", login)
+		setConditionFromError(conditions, generation, kuadrav1.ConditionMembershipReady, err)
+		return ctrl.Result{}, err
+	}
+	if membership == nil || membership.Role != role {
+		invite := membership == nil
+		membership, err = r.GithubWrapper.SetOrgMembership(ctx, login, role)
+		if err != nil {
+			log.Error(err, "unable to set organization membership", "login", login)
+			setConditionFromError(conditions, generation, kuadrav1.ConditionMembershipReady, err)
+			return ctrl.Result{}, err
+		}
+		if invite {
+			log.Info("invited user to organization", "login", login)
+			r.Recorder.Eventf(githubAccount, v1.EventTypeNormal, "Invited", "Invited %s to the GitHub organization", login)
+			githubAccount.Status.Invited = true
+		}
+	}
+	githubAccount.Status.State = membership.State
+	githubAccount.Status.Role = membership.Role
+
+	if err := r.reconcileTeams(ctx, githubAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionTeamsSynced, err)
+		return ctrl.Result{}, err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionTeamsSynced, "User is a member of every team in the spec")
+
+	if membership.State == github.MembershipStatePending {
+		setCondition(conditions, generation, kuadrav1.ConditionMembershipReady, metav1.ConditionFalse, kuadrav1.ReasonInvitationPending,
+			fmt.Sprintf("%s has not accepted the invitation to the organization yet", login))
+		return ctrl.Result{RequeueAfter: githubInvitationPollInterval}, nil
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionMembershipReady, login+" is a member of the organization")
+	return ctrl.Result{}, nil
+}
+
+// reconcileTeams adds the user to the teams in the spec and removes them from the teams dropped from it
+func (r *GithubAccountReconciler) reconcileTeams(ctx context.Context, githubAccount *kuadrav1.GithubAccount) error {
+	log := log.FromContext(ctx)
+	login := githubAccount.Spec.Login
+
+	for _, team := range slice.GetLeftDifference(githubAccount.Spec.Teams, githubAccount.Status.Teams) {
+		if err := r.GithubWrapper.AddTeamMembership(ctx, team, login); err != nil {
+			log.Error(err, "unable to add user to team", "team", team)
+			return err
+		}
+		log.V(1).Info("added user to team", "team", team)
+		githubAccount.Status.Teams = append(githubAccount.Status.Teams, team)
+	}
+
+	for _, team := range slice.GetLeftDifference(githubAccount.Status.Teams, githubAccount.Spec.Teams) {
+		if err := r.GithubWrapper.RemoveTeamMembership(ctx, team, login); err != nil {
+			log.Error(err, "unable to remove user from team", "team", team)
+			return err
+		}
+		log.V(1).Info("removed user from team", "team", team)
+		githubAccount.Status.Teams = slice.Remove(githubAccount.Status.Teams, func(t string) bool { return t == team })
+	}
+	return nil
+}
+
+// finalizeGithubAccount removes the user from the teams kuadra added them to, and from the
+// organization when kuadra invited them
+func (r *GithubAccountReconciler) finalizeGithubAccount(ctx context.Context, githubAccount *kuadrav1.GithubAccount) error {
+	login := githubAccount.Spec.Login
+	for _, team := range githubAccount.Status.Teams {
+		if err := r.GithubWrapper.RemoveTeamMembership(ctx, team, login); err != nil {
+			return err
+		}
+	}
+	if !githubAccount.Status.Invited {
+		log.FromContext(ctx).Info("keeping organization membership kuadra did not create", "login", login)
+		return nil
+	}
+	if err := r.GithubWrapper.RemoveOrgMembership(ctx, login); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("removed user from organization", "login", login)
+	return nil
+}
+
+// suspendGithubAccount removes the memberships as on deletion. Reconciling the account once it is
+// no longer suspended adds them again, inviting the user anew where kuadra had invited them.
+func (r *GithubAccountReconciler) suspendGithubAccount(ctx context.Context, githubAccount *kuadrav1.GithubAccount) error {
+	if err := r.finalizeGithubAccount(ctx, githubAccount); err != nil {
+		return err
+	}
+	githubAccount.Status.Teams = nil
+	if githubAccount.Status.Invited {
+		githubAccount.Status.State = ""
+		githubAccount.Status.Role = ""
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GithubAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kuadrav1.GithubAccount{}).
+		Watches(&source.Kind{Type: &kuadrav1.AccessPolicy{}}, handler.EnqueueRequestsFromMapFunc(accountsForAccessPolicy(r.Client, &kuadrav1.GithubAccountList{}))).
+		Complete(r)
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	"github.com/Kuadrant/kuadra/pkg/github"
+)
+
+var _ = Describe("GithubAccount controller", func() {
+
+	ctx := context.Background()
+
+	newGithubAccount := func() *kuadrav1.GithubAccount {
+		return &kuadrav1.GithubAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default"},
+			Spec: kuadrav1.GithubAccountSpec{
+				Login: "jdoe",
+				Teams: []string{"dns", "docs"},
+				Role:  github.RoleMember,
+			},
+		}
+	}
+
+	Context("When reconciling a GithubAccount", func() {
+		It("Should invite the user and sync their teams", func() {
+			githubAccount := newGithubAccount()
+			lookupKey := k8Types.NamespacedName{Name: githubAccount.Name, Namespace: githubAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(githubAccount).Build()
+			githubWrapper := newMockGithubWrapper()
+			r := &GithubAccountReconciler{
+				Client:        k8sClient,
+				Scheme:        scheme.Scheme,
+				GithubWrapper: githubWrapper,
+				Recorder:      record.NewFakeRecorder(10),
+				Options:       kuadrav1.ServiceOptions{GithubAdmin: true},
+			}
+
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RequeueAfter).Should(Equal(githubInvitationPollInterval))
+			Expect(githubWrapper.Memberships).Should(HaveKeyWithValue("jdoe", github.Membership{State: github.MembershipStatePending, Role: github.RoleMember}))
+			Expect(githubWrapper.Teams).Should(HaveKeyWithValue("jdoe", ConsistOf("dns", "docs")))
+
+			reconciled := &kuadrav1.GithubAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Invited).Should(BeTrue())
+			ready := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionReady)
+			Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).Should(Equal(kuadrav1.ReasonInvitationPending))
+
+			By("By accepting the invitation and changing the spec")
+			githubWrapper.Memberships["jdoe"] = github.Membership{State: github.MembershipStateActive, Role: github.RoleMember}
+			reconciled.Spec.Teams = []string{"dns"}
+			reconciled.Spec.Role = github.RoleAdmin
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+
+			result, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RequeueAfter).Should(BeZero())
+			Expect(githubWrapper.Memberships["jdoe"].Role).Should(Equal(github.RoleAdmin))
+			Expect(githubWrapper.Teams["jdoe"]).Should(Equal([]string{"dns"}))
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+			Expect(reconciled.Status.State).Should(Equal(github.MembershipStateActive))
+
+			By("By deleting the GithubAccount")
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(githubWrapper.Memberships).ShouldNot(HaveKey("jdoe"))
+			Expect(githubWrapper.Teams["jdoe"]).Should(BeEmpty())
+			err = k8sClient.Get(ctx, lookupKey, reconciled)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should keep an existing member in the organization on deletion", func() {
+			githubAccount := newGithubAccount()
+			lookupKey := k8Types.NamespacedName{Name: githubAccount.Name, Namespace: githubAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(githubAccount).Build()
+			githubWrapper := newMockGithubWrapper()
+			githubWrapper.Memberships["jdoe"] = github.Membership{State: github.MembershipStateActive, Role: github.RoleMember}
+			r := &GithubAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, GithubWrapper: githubWrapper, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			reconciled := &kuadrav1.GithubAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Invited).Should(BeFalse())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(githubWrapper.Memberships).Should(HaveKey("jdoe"))
+			Expect(githubWrapper.Teams["jdoe"]).Should(BeEmpty())
+		})
+
+		It("Should refuse the admin role unless the operator allows it", func() {
+			githubAccount := newGithubAccount()
+			githubAccount.Spec.Role = github.RoleAdmin
+			lookupKey := k8Types.NamespacedName{Name: githubAccount.Name, Namespace: githubAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(githubAccount).Build()
+			githubWrapper := newMockGithubWrapper()
+			r := &GithubAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, GithubWrapper: githubWrapper, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("the operator does not allow the admin role")))
+			Expect(githubWrapper.Memberships).ShouldNot(HaveKey("jdoe"))
+
+			reconciled := &kuadrav1.GithubAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			membershipReady := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionMembershipReady)
+			Expect(membershipReady.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+		})
+
+		It("Should not invite the user when no AccessPolicy allows GitHub accounts", func() {
+			githubAccount := newGithubAccount()
+			accessPolicy := &kuadrav1.AccessPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws"},
+				Spec:       kuadrav1.AccessPolicySpec{Services: []string{kuadrav1.ServiceAws}},
+			}
+			lookupKey := k8Types.NamespacedName{Name: githubAccount.Name, Namespace: githubAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(githubAccount, accessPolicy).Build()
+			githubWrapper := newMockGithubWrapper()
+			r := &GithubAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, GithubWrapper: githubWrapper, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(githubWrapper.Memberships).ShouldNot(HaveKey("jdoe"))
+
+			reconciled := &kuadrav1.GithubAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			ready := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionReady)
+			Expect(ready.Reason).Should(Equal(kuadrav1.ReasonAccessPolicyDenied))
+		})
+
+		It("Should report GitHub API errors on the conditions", func() {
+			githubAccount := newGithubAccount()
+			lookupKey := k8Types.NamespacedName{Name: githubAccount.Name, Namespace: githubAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(githubAccount).Build()
+			githubWrapper := newMockGithubWrapper()
+			githubWrapper.TeamErr = &github.APIError{StatusCode: 404, Message: "Not Found"}
+			r := &GithubAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, GithubWrapper: githubWrapper, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(HaveOccurred())
+
+			reconciled := &kuadrav1.GithubAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			teamsSynced := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionTeamsSynced)
+			Expect(teamsSynced.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(teamsSynced.Reason).Should(Equal("NotFound"))
+		})
+	})
+})
+
+type mockGithubWrapper struct {
+	Memberships map[string]github.Membership
+	Teams       map[string][]string
+
+	TeamErr error
+}
+
+func newMockGithubWrapper() *mockGithubWrapper {
+	return &mockGithubWrapper{
+		Memberships: map[string]github.Membership{},
+		Teams:       map[string][]string{},
+	}
+}
+
+func (c *mockGithubWrapper) GetOrgMembership(ctx context.Context, login string) (*github.Membership, error) {
+	membership, exists := c.Memberships[login]
+	if !exists {
+		return nil, nil
+	}
+	return &membership, nil
+}
+
+func (c *mockGithubWrapper) SetOrgMembership(ctx context.Context, login string, role string) (*github.Membership, error) {
+	membership, exists := c.Memberships[login]
+	if !exists {
+		membership.State = github.MembershipStatePending
+	}
+	membership.Role = role
+	c.Memberships[login] = membership
+	return &membership, nil
+}
+
+func (c *mockGithubWrapper) RemoveOrgMembership(ctx context.Context, login string) error {
+	delete(c.Memberships, login)
+	return nil
+}
+
+func (c *mockGithubWrapper) AddTeamMembership(ctx context.Context, teamSlug string, login string) error {
+	if c.TeamErr != nil {
+		return c.TeamErr
+	}
+	c.Teams[login] = append(c.Teams[login], teamSlug)
+	return nil
+}
+
+func (c *mockGithubWrapper) RemoveTeamMembership(ctx context.Context, teamSlug string, login string) error {
+	var teams []string
+	for _, team := range c.Teams[login] {
+		if team != teamSlug {
+			teams = append(teams, team)
+		}
+	}
+	c.Teams[login] = teams
+	return nil
+}
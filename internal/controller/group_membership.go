@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+// addGroups makes the IAM user a member of the named groups without a window, adding the groups that
+// are not listed yet and dropping the windows of those that have one
+func addGroups(spec *kuadrav1.AwsAccountSpec, names ...string) {
+	for _, name := range names {
+		if !slice.Contains(spec.Groups, name) {
+			spec.Groups = append(spec.Groups, name)
+		}
+		spec.GroupWindows = slice.Remove(spec.GroupWindows, func(w kuadrav1.GroupWindow) bool { return w.Name == name })
+	}
+}
+
+// activeGroups returns the groups of the spec along with the groups of the windows that include now
+func activeGroups(spec *kuadrav1.AwsAccountSpec, now time.Time) []string {
+	active := append([]string{}, spec.Groups...)
+	for _, window := range spec.GroupWindows {
+		if window.ActiveAt(now) {
+			active = append(active, window.Name)
+		}
+	}
+	return active
+}
+
+// groupWindows reports each group window at now
+func groupWindows(windows []kuadrav1.GroupWindow, now time.Time) []kuadrav1.GroupWindowStatus {
+	var statuses []kuadrav1.GroupWindowStatus
+	for _, window := range windows {
+		statuses = append(statuses, kuadrav1.GroupWindowStatus{
+			Name:      window.Name,
+			NotBefore: window.NotBefore,
+			NotAfter:  window.NotAfter,
+			State:     window.WindowState(now),
+		})
+	}
+	return statuses
+}
+
+// groupWindowRequeue returns how long until the next time-bound membership starts or ends, so that
+// the IAM user is added to or removed from the group on time, or zero when no window lies ahead
+func groupWindowRequeue(windows []kuadrav1.GroupWindow, now time.Time) time.Duration {
+	var requeue time.Duration
+	for _, window := range windows {
+		for _, boundary := range []*metav1.Time{window.NotBefore, window.NotAfter} {
+			if boundary == nil || !boundary.After(now) {
+				continue
+			}
+			if untilBoundary := boundary.Sub(now); requeue == 0 || untilBoundary < requeue {
+				requeue = untilBoundary
+			}
+		}
+	}
+	return requeue
+}
+
+// requeueWithin shortens the result's RequeueAfter to after, unless it is zero
+func requeueWithin(result *ctrl.Result, after time.Duration) {
+	if after > 0 && (result.RequeueAfter == 0 || after < result.RequeueAfter) {
+		result.RequeueAfter = after
+	}
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+	kuadraaws "github.com/Kuadrant/kuadra/pkg/aws"
+)
+
+const hostedZoneSecretName = "aws-hosted-zone"
+
+// hostedZoneCallerReferencePrefix marks the hosted zones created for an AwsAccount, so that a zone
+// created before a failed status update is picked up again instead of being created twice
+func hostedZoneCallerReferencePrefix(awsAccount *kuadrav1.AwsAccount) string {
+	return fmt.Sprintf("kuadra:%s:", awsAccount.UID)
+}
+
+// reconcileHostedZone creates the hosted zone in spec.hostedZone, delegates it from the parent zone,
+// publishes it in the aws-hosted-zone Secret and grants the IAM user DNS record rights on it.
+// A zone that is renamed or removed from the spec is deleted along with its policy.
+func (r *AwsAccountReconciler) reconcileHostedZone(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	spec := awsAccount.Spec.HostedZone
+	status := awsAccount.Status.HostedZone
+
+	if status != nil && (spec == nil || kuadraaws.NormalizeDomainName(spec.Name) != status.Name) {
+		if err := r.IamWrapper.DeleteUserPolicyIfExists(ctx, awsAccount.Spec.UserName, hostedZonePolicyName); err != nil {
+			log.Error(err, "unable to delete hosted zone policy")
+			return err
+		}
+		if err := r.deleteHostedZone(ctx, status); err != nil {
+			log.Error(err, "unable to delete hosted zone", "hostedZoneId", status.Id)
+			return err
+		}
+		if err := deleteOwnedSecret(ctx, r.Client, awsAccount, hostedZoneSecretName, awsAccount.Status.Namespace); err != nil {
+			return err
+		}
+		log.V(1).Info("deleted hosted zone", "hostedZoneId", status.Id)
+		awsAccount.Status.HostedZone = nil
+		status = nil
+	}
+	if spec == nil {
+		return nil
+	}
+	if err := r.checkParentHostedZone(ctx, spec); err != nil {
+		log.Error(err, "refusing to delegate hosted zone", "parentHostedZoneId", spec.ParentHostedZoneId)
+		return err
+	}
+
+	if status != nil {
+		hostedZone, nameServers, err := r.Route53Wrapper.GetHostedZone(ctx, status.Id)
+		if err != nil {
+			return err
+		}
+		if hostedZone == nil {
+			log.Info("hosted zone no longer exists, recreating it", "hostedZoneId", status.Id)
+			status = nil
+		} else {
+			status.NameServers = nameServers
+		}
+	}
+
+	if status == nil {
+		hostedZone, nameServers, err := r.findOrCreateHostedZone(ctx, awsAccount)
+		if err != nil {
+			log.Error(err, "unable to create hosted zone", "name", spec.Name)
+			return err
+		}
+		log.V(1).Info("created hosted zone", "hostedZoneId", *hostedZone.Id)
+		status = &kuadrav1.HostedZoneStatus{
+			Id:          *hostedZone.Id,
+			Name:        *hostedZone.Name,
+			NameServers: nameServers,
+		}
+	}
+	awsAccount.Status.HostedZone = status
+
+	if status.ParentHostedZoneId != "" && status.ParentHostedZoneId != spec.ParentHostedZoneId {
+		if err := r.Route53Wrapper.DeleteNameServerRecordIfExists(ctx, status.ParentHostedZoneId, status.Name); err != nil {
+			log.Error(err, "unable to delete delegation", "parentHostedZoneId", status.ParentHostedZoneId)
+			return err
+		}
+		status.ParentHostedZoneId = ""
+	}
+	if spec.ParentHostedZoneId != "" {
+		if err := r.Route53Wrapper.UpsertNameServerRecord(ctx, spec.ParentHostedZoneId, status.Name, status.NameServers); err != nil {
+			log.Error(err, "unable to delegate hosted zone", "parentHostedZoneId", spec.ParentHostedZoneId)
+			return err
+		}
+		status.ParentHostedZoneId = spec.ParentHostedZoneId
+	}
+
+	secretData := map[string]string{
+		"HOSTED_ZONE_ID":   status.Id,
+		"HOSTED_ZONE_NAME": strings.TrimSuffix(status.Name, "."),
+		"NAME_SERVERS":     strings.Join(status.NameServers, ","),
+	}
+	if err := r.createOrUpdateSecret(ctx, awsAccount, secretData, hostedZoneSecretName, awsAccount.Status.Namespace); err != nil {
+		return err
+	}
+
+	if err := r.reconcileHostedZonePolicy(ctx, awsAccount.Spec.UserName, status.Id); err != nil {
+		log.Error(err, "unable to attach hosted zone policy")
+		return err
+	}
+	status.PolicyName = hostedZonePolicyName
+	return nil
+}
+
+// checkParentHostedZone refuses to delegate from a zone that the operator does not list in
+// ParentHostedZoneIds, or that the hosted zone is not a subdomain of, as the NS record would
+// replace records of the parent zone
+func (r *AwsAccountReconciler) checkParentHostedZone(ctx context.Context, spec *kuadrav1.HostedZone) error {
+	if spec.ParentHostedZoneId == "" {
+		return nil
+	}
+	if !slice.Contains(r.ParentHostedZoneIds, spec.ParentHostedZoneId) {
+		return fmt.Errorf("%w: hosted zone %s is not configured as a parent hosted zone", errNotAllowed, spec.ParentHostedZoneId)
+	}
+	parent, _, err := r.Route53Wrapper.GetHostedZone(ctx, spec.ParentHostedZoneId)
+	if err != nil {
+		return err
+	}
+	if parent == nil || parent.Name == nil {
+		return fmt.Errorf("parent hosted zone %s does not exist", spec.ParentHostedZoneId)
+	}
+	parentName := kuadraaws.NormalizeDomainName(*parent.Name)
+	if !strings.HasSuffix(kuadraaws.NormalizeDomainName(spec.Name), "."+parentName) {
+		return fmt.Errorf("%w: %s is not a subdomain of the parent hosted zone %s", errNotAllowed, spec.Name, parentName)
+	}
+	return nil
+}
+
+// reconcileHostedZonePolicy keeps the user's inline policy scoped to the current hosted zone
+func (r *AwsAccountReconciler) reconcileHostedZonePolicy(ctx context.Context, userName string, hostedZoneId string) error {
+	document, err := hostedZonePolicyDocument(hostedZoneId)
+	if err != nil {
+		return err
+	}
+	current, err := r.IamWrapper.GetUserPolicy(ctx, userName, hostedZonePolicyName)
+	if err != nil {
+		return err
+	}
+	if policyDocumentsEqual(current, document) {
+		return nil
+	}
+	return r.IamWrapper.PutUserPolicy(ctx, userName, hostedZonePolicyName, document)
+}
+
+func (r *AwsAccountReconciler) findOrCreateHostedZone(ctx context.Context, awsAccount *kuadrav1.AwsAccount) (*route53types.HostedZone, []string, error) {
+	callerReferencePrefix := hostedZoneCallerReferencePrefix(awsAccount)
+	hostedZones, err := r.Route53Wrapper.ListHostedZonesByName(ctx, awsAccount.Spec.HostedZone.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, hostedZone := range hostedZones {
+		if hostedZone.CallerReference != nil && strings.HasPrefix(*hostedZone.CallerReference, callerReferencePrefix) {
+			return r.Route53Wrapper.GetHostedZone(ctx, *hostedZone.Id)
+		}
+	}
+	callerReference := fmt.Sprintf("%s%d", callerReferencePrefix, time.Now().UnixNano())
+	return r.Route53Wrapper.CreateHostedZone(ctx, awsAccount.Spec.HostedZone.Name, callerReference)
+}
+
+func (r *AwsAccountReconciler) deleteHostedZone(ctx context.Context, status *kuadrav1.HostedZoneStatus) error {
+	if status == nil {
+		return nil
+	}
+	if status.ParentHostedZoneId != "" {
+		if err := r.Route53Wrapper.DeleteNameServerRecordIfExists(ctx, status.ParentHostedZoneId, status.Name); err != nil {
+			return err
+		}
+	}
+	return r.Route53Wrapper.DeleteHostedZoneIfExists(ctx, status.Id)
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"context"
+	"strings"
+)
+
+// unknownGroups returns the groups that do not exist in IAM, so that the IAM user is not added to
+// them. All groups are assumed to exist when no IamGroupCache is set.
+func (r *AwsAccountReconciler) unknownGroups(ctx context.Context, groups []string) ([]string, error) {
+	if r.IamGroupCache == nil {
+		return nil, nil
+	}
+	var unknown []string
+	for _, group := range groups {
+		exists, err := r.IamGroupCache.GroupExists(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			unknown = append(unknown, group)
+		}
+	}
+	return unknown, nil
+}
+
+// unknownGroupsMessage describes the groups that do not exist, suggesting the closest existing group names
+func (r *AwsAccountReconciler) unknownGroupsMessage(ctx context.Context, groups []string) string {
+	descriptions := make([]string, 0, len(groups))
+	for _, group := range groups {
+		description := group
+		if closest, err := r.IamGroupCache.ClosestGroupName(ctx, group); err == nil && closest != "" {
+			description += " (did you mean " + closest + "?)"
+		}
+		descriptions = append(descriptions, description)
+	}
+	return "IAM groups do not exist: " + strings.Join(descriptions, ", ")
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+const (
+	iamPolicyVersion = "2012-10-17"
+
+	// hostedZonePolicyName is the inline policy granting a user DNS record rights on their own hosted zone
+	hostedZonePolicyName = kuadrav1.HostedZonePolicyName
+)
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Sid      string   `json:"Sid,omitempty"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+// hostedZonePolicyDocument allows managing the records of a single hosted zone
+func hostedZonePolicyDocument(hostedZoneId string) (string, error) {
+	document := policyDocument{
+		Version: iamPolicyVersion,
+		Statement: []policyStatement{
+			{
+				Sid:    "ManageHostedZoneRecords",
+				Effect: "Allow",
+				Action: []string{
+					"route53:ChangeResourceRecordSets",
+					"route53:ListResourceRecordSets",
+					"route53:GetHostedZone",
+				},
+				Resource: []string{"arn:aws:route53:::hostedzone/" + hostedZoneId},
+			},
+			{
+				// Record changes are only visible through the change id returned by ChangeResourceRecordSets
+				Sid:      "GetRecordChanges",
+				Effect:   "Allow",
+				Action:   []string{"route53:GetChange"},
+				Resource: []string{"arn:aws:route53:::change/*"},
+			},
+		},
+	}
+	data, err := json.Marshal(document)
+	return string(data), err
+}
+
+// policyDocumentsEqual compares two policy documents ignoring formatting
+func policyDocumentsEqual(a string, b string) bool {
+	var left, right interface{}
+	if err := json.Unmarshal([]byte(a), &left); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(b), &right); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// reconcilePolicies attaches and detaches managed policies and puts and deletes inline policies,
+// diffing the spec against the policies observed on the IAM user the same way groups are diffed
+func (r *AwsAccountReconciler) reconcilePolicies(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	userName := awsAccount.Spec.UserName
+
+	policyArnsToAttach := slice.GetLeftDifference(awsAccount.Spec.ManagedPolicyArns, awsAccount.Status.ManagedPolicyArns)
+	for _, policyArn := range policyArnsToAttach {
+		if err := r.IamWrapper.AttachUserPolicy(ctx, userName, policyArn); err != nil {
+			log.Error(err, "unable to attach policy", "policyArn", policyArn)
+			return err
+		}
+		log.V(1).Info("attached policy", "policyArn", policyArn)
+		awsAccount.Status.ManagedPolicyArns = append(awsAccount.Status.ManagedPolicyArns, policyArn)
+	}
+
+	policyArnsToDetach := slice.GetLeftDifference(awsAccount.Status.ManagedPolicyArns, awsAccount.Spec.ManagedPolicyArns)
+	for _, policyArn := range policyArnsToDetach {
+		if err := r.IamWrapper.DetachUserPolicyIfAttached(ctx, userName, policyArn); err != nil {
+			log.Error(err, "unable to detach policy", "policyArn", policyArn)
+			return err
+		}
+		log.V(1).Info("detached policy", "policyArn", policyArn)
+		awsAccount.Status.ManagedPolicyArns = slice.Remove(awsAccount.Status.ManagedPolicyArns, func(p string) bool { return p == policyArn })
+	}
+
+	var inlinePolicyNames []string
+	for _, inlinePolicy := range awsAccount.Spec.InlinePolicies {
+		// The webhook refuses the name, this catches AwsAccounts admitted without it
+		if inlinePolicy.Name == hostedZonePolicyName {
+			return fmt.Errorf("%w: inline policy name %s is reserved for the hosted zone policy", errNotAllowed, inlinePolicy.Name)
+		}
+		document, err := r.getInlinePolicyDocument(ctx, awsAccount.Namespace, inlinePolicy)
+		if err != nil {
+			return err
+		}
+		current, err := r.IamWrapper.GetUserPolicy(ctx, userName, inlinePolicy.Name)
+		if err != nil {
+			return err
+		}
+		if !policyDocumentsEqual(current, document) {
+			if err := r.IamWrapper.PutUserPolicy(ctx, userName, inlinePolicy.Name, document); err != nil {
+				log.Error(err, "unable to put inline policy", "policyName", inlinePolicy.Name)
+				return err
+			}
+			log.V(1).Info("put inline policy", "policyName", inlinePolicy.Name)
+		}
+		inlinePolicyNames = append(inlinePolicyNames, inlinePolicy.Name)
+		if !slice.Contains(awsAccount.Status.InlinePolicies, inlinePolicy.Name) {
+			awsAccount.Status.InlinePolicies = append(awsAccount.Status.InlinePolicies, inlinePolicy.Name)
+		}
+	}
+
+	inlinePoliciesToDelete := slice.GetLeftDifference(awsAccount.Status.InlinePolicies, inlinePolicyNames)
+	for _, policyName := range inlinePoliciesToDelete {
+		if err := r.IamWrapper.DeleteUserPolicyIfExists(ctx, userName, policyName); err != nil {
+			log.Error(err, "unable to delete inline policy", "policyName", policyName)
+			return err
+		}
+		log.V(1).Info("deleted inline policy", "policyName", policyName)
+		awsAccount.Status.InlinePolicies = slice.Remove(awsAccount.Status.InlinePolicies, func(p string) bool { return p == policyName })
+	}
+
+	return nil
+}
+
+func (r *AwsAccountReconciler) getInlinePolicyDocument(ctx context.Context, namespace string, inlinePolicy kuadrav1.InlinePolicy) (string, error) {
+	document := inlinePolicy.Document
+	if inlinePolicy.ConfigMapKeyRef != nil {
+		configMap := &v1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: inlinePolicy.ConfigMapKeyRef.Name, Namespace: namespace}, configMap); err != nil {
+			return "", fmt.Errorf("unable to get policy document for inline policy %s: %w", inlinePolicy.Name, err)
+		}
+		var exists bool
+		document, exists = configMap.Data[inlinePolicy.ConfigMapKeyRef.Key]
+		if !exists {
+			return "", fmt.Errorf("ConfigMap %s has no key %s for inline policy %s", configMap.Name, inlinePolicy.ConfigMapKeyRef.Key, inlinePolicy.Name)
+		}
+	}
+	if !json.Valid([]byte(document)) {
+		return "", fmt.Errorf("inline policy %s is not a valid JSON document", inlinePolicy.Name)
+	}
+	return document, nil
+}
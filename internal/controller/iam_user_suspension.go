@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+// suspendIamUser revokes the IAM user's access while keeping the user, its policies and its
+// Secrets: active access keys are deactivated, the login profile is deleted and the user is
+// removed from its groups. The deactivated keys are recorded so that only they are activated
+// again by reactivateAccessKeys.
+func (r *AwsAccountReconciler) suspendIamUser(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	userName := awsAccount.Spec.UserName
+
+	userExists, err := r.IamWrapper.IsExistingUser(ctx, userName)
+	if err != nil {
+		return err
+	}
+	if !userExists {
+		awsAccount.Status.Suspended = true
+		return nil
+	}
+
+	// Unlike checkUserOwnership, a suspension never adopts a user
+	tags, err := r.IamWrapper.ListUserTags(ctx, userName)
+	if err != nil {
+		return err
+	}
+	if !r.isOwnedUser(awsAccount, tags) {
+		return fmt.Errorf("%w: %s was not suspended", errUserNotManaged, userName)
+	}
+
+	accessKeys, err := r.IamWrapper.ListAccessKeys(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, accessKey := range accessKeys {
+		if accessKey.Status != types.StatusTypeActive {
+			continue
+		}
+		if err := r.IamWrapper.UpdateAccessKeyStatus(ctx, userName, *accessKey.AccessKeyId, types.StatusTypeInactive); err != nil {
+			return err
+		}
+		log.V(1).Info("deactivated access key", "accessKeyId", *accessKey.AccessKeyId)
+		if !slice.Contains(awsAccount.Status.SuspendedAccessKeyIds, *accessKey.AccessKeyId) {
+			awsAccount.Status.SuspendedAccessKeyIds = append(awsAccount.Status.SuspendedAccessKeyIds, *accessKey.AccessKeyId)
+		}
+	}
+
+	if err := r.IamWrapper.DeleteLoginProfileIfExists(ctx, userName); err != nil {
+		return err
+	}
+	awsAccount.Status.LoginProfileCreated = false
+
+	groups, err := r.IamWrapper.ListGroupsForUser(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if _, err := r.IamWrapper.RemoveUserFromGroup(ctx, *group.GroupName, userName); err != nil {
+			return err
+		}
+		log.V(1).Info("removed user from group", "groupName", *group.GroupName)
+	}
+	awsAccount.Status.UserGroups = nil
+
+	awsAccount.Status.Suspended = true
+	for _, conditionType := range []string{kuadrav1.ConditionLoginProfileReady, kuadrav1.ConditionAccessKeyReady, kuadrav1.ConditionGroupsSynced} {
+		setCondition(&awsAccount.Status.Conditions, awsAccount.Generation, conditionType, metav1.ConditionFalse, kuadrav1.ReasonSuspended, "IAM user is suspended")
+	}
+	return nil
+}
+
+// reactivateAccessKeys activates the access keys deactivated by suspendIamUser that still exist.
+// The login profile and groups are restored by the rest of the reconcile.
+func (r *AwsAccountReconciler) reactivateAccessKeys(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	if !awsAccount.Status.Suspended {
+		return nil
+	}
+	userName := awsAccount.Spec.UserName
+
+	accessKeys, err := r.IamWrapper.ListAccessKeys(ctx, userName)
+	if err != nil {
+		return err
+	}
+	for _, accessKey := range accessKeys {
+		if !slice.Contains(awsAccount.Status.SuspendedAccessKeyIds, *accessKey.AccessKeyId) || accessKey.Status == types.StatusTypeActive {
+			continue
+		}
+		if err := r.IamWrapper.UpdateAccessKeyStatus(ctx, userName, *accessKey.AccessKeyId, types.StatusTypeActive); err != nil {
+			return err
+		}
+		log.FromContext(ctx).V(1).Info("activated access key", "accessKeyId", *accessKey.AccessKeyId)
+	}
+
+	awsAccount.Status.Suspended = false
+	awsAccount.Status.SuspendedAccessKeyIds = nil
+	return nil
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+// Tags marking the IAM users created by kuadra, and the cluster and AwsAccount they belong to
+const (
+	managedByTagKey   = "kuadra.kuadrant.io/managed-by"
+	managedByTagValue = "kuadra"
+	clusterIdTagKey   = "kuadra.kuadrant.io/cluster-id"
+	ownerTagKey       = "kuadra.kuadrant.io/owner"
+)
+
+// errUserNotManaged is returned when an IAM user exists but does not carry the AwsAccount's ownership tags
+var errUserNotManaged = errors.New("IAM user is not managed by this AwsAccount")
+
+// ownershipTags returns the tags identifying the AwsAccount that manages an IAM user
+func (r *AwsAccountReconciler) ownershipTags(awsAccount *kuadrav1.AwsAccount) map[string]string {
+	return map[string]string{
+		managedByTagKey: managedByTagValue,
+		clusterIdTagKey: r.ClusterId,
+		ownerTagKey:     awsAccount.Namespace + "/" + awsAccount.Name,
+	}
+}
+
+// userTags returns spec.tags together with the ownership tags, which take precedence
+func (r *AwsAccountReconciler) userTags(awsAccount *kuadrav1.AwsAccount) map[string]string {
+	tags := map[string]string{}
+	for key, value := range awsAccount.Spec.Tags {
+		tags[key] = value
+	}
+	for key, value := range r.ownershipTags(awsAccount) {
+		tags[key] = value
+	}
+	return tags
+}
+
+// isOwnedUser reports whether the tags of an IAM user mark it as managed by the AwsAccount
+func (r *AwsAccountReconciler) isOwnedUser(awsAccount *kuadrav1.AwsAccount, tags map[string]string) bool {
+	for key, value := range r.ownershipTags(awsAccount) {
+		if current, exists := tags[key]; !exists || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// checkUserOwnership refuses to manage an IAM user that is not tagged as owned by the AwsAccount,
+// and returns the user's tags otherwise. With spec.adopt, a user that no other AwsAccount manages
+// is tagged and taken over.
+func (r *AwsAccountReconciler) checkUserOwnership(ctx context.Context, awsAccount *kuadrav1.AwsAccount) (map[string]string, error) {
+	log := log.FromContext(ctx)
+	userName := awsAccount.Spec.UserName
+
+	tags, err := r.IamWrapper.ListUserTags(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+	if r.isOwnedUser(awsAccount, tags) {
+		return tags, nil
+	}
+	if tags[managedByTagKey] == managedByTagValue {
+		return nil, fmt.Errorf("%w: %s is managed by %s in cluster %q", errUserNotManaged, userName, tags[ownerTagKey], tags[clusterIdTagKey])
+	}
+	if !awsAccount.Spec.Adopt {
+		return nil, fmt.Errorf("%w: %s was not created by kuadra, set spec.adopt to take it over", errUserNotManaged, userName)
+	}
+
+	ownershipTags := r.ownershipTags(awsAccount)
+	if err := r.IamWrapper.TagUser(ctx, userName, ownershipTags); err != nil {
+		return nil, err
+	}
+	log.Info("adopted existing IAM user", "userName", userName)
+	r.Recorder.Eventf(awsAccount, v1.EventTypeNormal, "Adopted", "Adopted existing IAM user %s", userName)
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	for key, value := range ownershipTags {
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// reconcileUserTags applies spec.tags to the IAM user and removes the tags dropped from the spec
+func (r *AwsAccountReconciler) reconcileUserTags(ctx context.Context, awsAccount *kuadrav1.AwsAccount, tags map[string]string) error {
+	userName := awsAccount.Spec.UserName
+	ownershipTags := r.ownershipTags(awsAccount)
+
+	var tagKeysToRemove []string
+	for key := range awsAccount.Status.Tags {
+		if _, exists := awsAccount.Spec.Tags[key]; !exists {
+			if _, isOwnershipTag := ownershipTags[key]; !isOwnershipTag {
+				tagKeysToRemove = append(tagKeysToRemove, key)
+			}
+		}
+	}
+	if len(tagKeysToRemove) > 0 {
+		if err := r.IamWrapper.UntagUser(ctx, userName, tagKeysToRemove); err != nil {
+			return err
+		}
+	}
+
+	tagsToApply := map[string]string{}
+	for key, value := range r.userTags(awsAccount) {
+		if current, exists := tags[key]; !exists || current != value {
+			tagsToApply[key] = value
+		}
+	}
+	if len(tagsToApply) > 0 {
+		if err := r.IamWrapper.TagUser(ctx, userName, tagsToApply); err != nil {
+			return err
+		}
+	}
+
+	awsAccount.Status.Tags = nil
+	for key, value := range awsAccount.Spec.Tags {
+		if _, isOwnershipTag := ownershipTags[key]; isOwnershipTag {
+			continue
+		}
+		if awsAccount.Status.Tags == nil {
+			awsAccount.Status.Tags = map[string]string{}
+		}
+		awsAccount.Status.Tags[key] = value
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/Kuadrant/kuadra/pkg/keycloak"
+)
+
+type KeycloakWrapper interface {
+	GetUser(ctx context.Context, username string) (*keycloak.User, error)
+	CreateUser(ctx context.Context, user keycloak.User) (string, error)
+	UpdateUser(ctx context.Context, user keycloak.User) error
+	DeleteUserIfExists(ctx context.Context, userId string) error
+	ResetPassword(ctx context.Context, userId string, password string, temporary bool) error
+	AddRealmRoles(ctx context.Context, userId string, roleNames []string) error
+	RemoveRealmRoles(ctx context.Context, userId string, roleNames []string) error
+	AddClientRoles(ctx context.Context, userId string, clientId string, roleNames []string) error
+	RemoveClientRoles(ctx context.Context, userId string, clientId string, roleNames []string) error
+}
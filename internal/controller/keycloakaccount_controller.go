@@ -0,0 +1,470 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/sethvargo/go-password/password"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+	"github.com/Kuadrant/kuadra/pkg/keycloak"
+)
+
+const (
+	KeycloakAccountFinalizer = "kuadra.kuadrant.io/keycloak-account"
+
+	// keycloakSecretName names the Secret holding the user's temporary password
+	keycloakSecretName = "keycloak-login"
+)
+
+// errKeycloakUserNotManaged is returned when the realm has a user of the same name that kuadra did not create
+var errKeycloakUserNotManaged = errors.New("Keycloak user exists and is not managed by kuadra")
+
+// KeycloakAccountReconciler reconciles a KeycloakAccount object
+type KeycloakAccountReconciler struct {
+	client.Client
+	Scheme          *runtime.Scheme
+	KeycloakWrapper KeycloakWrapper
+	Recorder        record.EventRecorder
+	// Options limit the roles Keycloak accounts can ask for
+	Options kuadrav1.ServiceOptions
+}
+
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=keycloakaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=keycloakaccounts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=keycloakaccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accesspolicies,verbs=get;list;watch
+
+// Reconcile creates the user in the Keycloak realm with a temporary password stored in the
+// keycloak-login Secret, and assigns the realm and client roles in the spec
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *KeycloakAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var keycloakAccount kuadrav1.KeycloakAccount
+	if err := r.Get(ctx, req.NamespacedName, &keycloakAccount); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if keycloakAccount.DeletionTimestamp != nil && !keycloakAccount.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(&keycloakAccount, KeycloakAccountFinalizer) {
+			if err := r.finalizeKeycloakAccount(ctx, &keycloakAccount); err != nil {
+				log.Error(err, "Failed to delete Keycloak user", "username", keycloakAccount.Spec.Username)
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(&keycloakAccount, KeycloakAccountFinalizer)
+			if err := r.Update(ctx, &keycloakAccount); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&keycloakAccount, KeycloakAccountFinalizer) {
+		controllerutil.AddFinalizer(&keycloakAccount, KeycloakAccountFinalizer)
+		if err := r.Update(ctx, &keycloakAccount); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	denied, err := serviceDenied(ctx, r.Client, keycloakAccount.Namespace, kuadrav1.ServiceKeycloak)
+	if err != nil {
+		log.Error(err, "unable to evaluate AccessPolicies")
+		return ctrl.Result{}, err
+	}
+
+	var reconcileErr error
+	if keycloakAccount.Spec.Suspended {
+		if reconcileErr = r.suspendKeycloakUser(ctx, &keycloakAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to disable Keycloak user", "username", keycloakAccount.Spec.Username)
+			if errors.Is(reconcileErr, errKeycloakUserNotManaged) {
+				r.Recorder.Event(&keycloakAccount, v1.EventTypeWarning, "UserNotManaged", reconcileErr.Error())
+			}
+		}
+		setSuspendedConditions(r.Recorder, &keycloakAccount, &keycloakAccount.Status.Conditions, keycloakAccount.Generation, reconcileErr)
+	} else if denied {
+		if reconcileErr = r.suspendKeycloakUser(ctx, &keycloakAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to disable Keycloak user", "username", keycloakAccount.Spec.Username)
+		}
+		setServiceDeniedConditions(&keycloakAccount.Status.Conditions, keycloakAccount.Generation, keycloakAccount.Namespace, kuadrav1.ServiceKeycloak, reconcileErr)
+	} else {
+		reconcileErr = r.reconcileKeycloakAccount(ctx, &keycloakAccount)
+		setReadyCondition(&keycloakAccount.Status.Conditions, keycloakAccount.Generation, []string{
+			kuadrav1.ConditionKeycloakUserReady,
+			kuadrav1.ConditionPasswordReady,
+			kuadrav1.ConditionRolesSynced,
+		}, reconcileErr)
+		if reconcileErr == nil {
+			setReactivatedCondition(r.Recorder, &keycloakAccount, &keycloakAccount.Status.Conditions, keycloakAccount.Generation)
+		}
+	}
+	keycloakAccount.Status.ObservedGeneration = keycloakAccount.Generation
+
+	var latest kuadrav1.KeycloakAccount
+	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, keycloakAccount.Status) {
+		if err := r.Status().Update(ctx, &keycloakAccount); err != nil {
+			log.Error(err, "unable to update KeycloakAccount status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+		}
+	}
+
+	return ctrl.Result{}, reconcileErr
+}
+
+// keycloakSecretNamespace returns the namespace the spec asks for the keycloak-login Secret
+func keycloakSecretNamespace(keycloakAccount *kuadrav1.KeycloakAccount) string {
+	if keycloakAccount.Spec.SecretNamespace != "" {
+		return keycloakAccount.Spec.SecretNamespace
+	}
+	return keycloakAccount.Namespace
+}
+
+// reconcileKeycloakAccount moves the user, its password and roles towards the spec,
+// recording a condition for each on the KeycloakAccount status
+func (r *KeycloakAccountReconciler) reconcileKeycloakAccount(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount) error {
+	log := log.FromContext(ctx)
+	conditions := &keycloakAccount.Status.Conditions
+	generation := keycloakAccount.Generation
+
+	if err := r.reconcileUser(ctx, keycloakAccount); err != nil {
+		log.Error(err, "unable to reconcile Keycloak user")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionKeycloakUserReady, err)
+		if errors.Is(err, errKeycloakUserNotManaged) {
+			r.Recorder.Event(keycloakAccount, v1.EventTypeWarning, "UserNotManaged", err.Error())
+		}
+		return err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionKeycloakUserReady, "User exists in the realm")
+
+	if err := r.reconcilePassword(ctx, keycloakAccount); err != nil {
+		log.Error(err, "unable to set temporary password", "namespace", keycloakSecretNamespace(keycloakAccount))
+		setConditionFromError(conditions, generation, kuadrav1.ConditionPasswordReady, err)
+		if errors.Is(err, errSecretNotManaged) {
+			r.Recorder.Event(keycloakAccount, v1.EventTypeWarning, "SecretNotManaged", err.Error())
+		}
+		return err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionPasswordReady, "Temporary password is stored in Secret "+keycloakSecretName)
+
+	if err := notAllowedError(r.Options.ValidateKeycloakAccountSpec(&keycloakAccount.Spec, field.NewPath("spec"))); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionRolesSynced, err)
+		return err
+	}
+	if err := r.reconcileRealmRoles(ctx, keycloakAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionRolesSynced, err)
+		return err
+	}
+	if err := r.reconcileClientRoles(ctx, keycloakAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionRolesSynced, err)
+		return err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionRolesSynced, "User has every realm and client role in the spec")
+	return nil
+}
+
+// desiredKeycloakUser returns the user the spec describes, marked as owned by the KeycloakAccount
+func desiredKeycloakUser(keycloakAccount *kuadrav1.KeycloakAccount, existing *keycloak.User) keycloak.User {
+	user := keycloak.User{
+		Username:   keycloakAccount.Spec.Username,
+		Email:      keycloakAccount.Spec.Email,
+		FirstName:  keycloakAccount.Spec.FirstName,
+		LastName:   keycloakAccount.Spec.LastName,
+		Enabled:    true,
+		Attributes: map[string][]string{},
+	}
+	if existing != nil {
+		user.Id = existing.Id
+		for key, values := range existing.Attributes {
+			user.Attributes[key] = values
+		}
+	}
+	user.Attributes[ownerAnnotationKey] = []string{objectOwner(keycloakAccount)}
+	return user
+}
+
+// isOwnedKeycloakUser reports whether the owner attribute of the user names the KeycloakAccount
+func isOwnedKeycloakUser(keycloakAccount *kuadrav1.KeycloakAccount, user *keycloak.User) bool {
+	owner := user.Attributes[ownerAnnotationKey]
+	return len(owner) == 1 && owner[0] == objectOwner(keycloakAccount)
+}
+
+// suspendKeycloakUser disables the user, which keeps its password and roles. Reconciling the account
+// once it is no longer suspended enables the user again.
+func (r *KeycloakAccountReconciler) suspendKeycloakUser(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount) error {
+	existing, err := r.KeycloakWrapper.GetUser(ctx, keycloakAccount.Spec.Username)
+	if err != nil || existing == nil {
+		return err
+	}
+	if !isOwnedKeycloakUser(keycloakAccount, existing) {
+		return fmt.Errorf("%w: %s", errKeycloakUserNotManaged, keycloakAccount.Spec.Username)
+	}
+	if !existing.Enabled {
+		return nil
+	}
+	disabled := *existing
+	disabled.Enabled = false
+	if err := r.KeycloakWrapper.UpdateUser(ctx, disabled); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("disabled Keycloak user", "username", keycloakAccount.Spec.Username)
+	return nil
+}
+
+// reconcileUser creates the user in the realm, or updates its profile when it drifted from the spec.
+// Users that kuadra did not create are left alone.
+func (r *KeycloakAccountReconciler) reconcileUser(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount) error {
+	log := log.FromContext(ctx)
+
+	existing, err := r.KeycloakWrapper.GetUser(ctx, keycloakAccount.Spec.Username)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		userId, err := r.KeycloakWrapper.CreateUser(ctx, desiredKeycloakUser(keycloakAccount, nil))
+		if err != nil {
+			return err
+		}
+		log.Info("created Keycloak user", "username", keycloakAccount.Spec.Username)
+		keycloakAccount.Status.UserId = userId
+		// A new user has no password or roles yet
+		keycloakAccount.Status.PasswordSet = false
+		keycloakAccount.Status.RealmRoles = nil
+		keycloakAccount.Status.ClientRoles = nil
+		return nil
+	}
+
+	if !isOwnedKeycloakUser(keycloakAccount, existing) {
+		return fmt.Errorf("%w: %s", errKeycloakUserNotManaged, keycloakAccount.Spec.Username)
+	}
+	if keycloakAccount.Status.UserId != "" && keycloakAccount.Status.UserId != existing.Id {
+		// The user was recreated outside of kuadra
+		keycloakAccount.Status.PasswordSet = false
+		keycloakAccount.Status.RealmRoles = nil
+		keycloakAccount.Status.ClientRoles = nil
+	}
+	keycloakAccount.Status.UserId = existing.Id
+
+	desired := desiredKeycloakUser(keycloakAccount, existing)
+	if !reflect.DeepEqual(desired, *existing) {
+		if err := r.KeycloakWrapper.UpdateUser(ctx, desired); err != nil {
+			return err
+		}
+		log.V(1).Info("updated Keycloak user", "username", keycloakAccount.Spec.Username)
+	}
+	return nil
+}
+
+// loginPassword returns the password stored in the keycloak-login Secret in the namespace, if any
+func (r *KeycloakAccountReconciler) loginPassword(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount, namespace string) (string, error) {
+	secret := &v1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: keycloakSecretName, Namespace: namespace}, secret); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	if secret.Annotations[ownerAnnotationKey] != objectOwner(keycloakAccount) {
+		return "", nil
+	}
+	return string(secret.Data["password"]), nil
+}
+
+// reconcilePassword stores a temporary password in the keycloak-login Secret and sets it on the user,
+// which has to change it on the first login. The password in the Secret is kept, so it is only
+// set again when the Secret is lost or the user is recreated.
+func (r *KeycloakAccountReconciler) reconcilePassword(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount) error {
+	namespace := keycloakSecretNamespace(keycloakAccount)
+	previous := keycloakAccount.Status.SecretNamespace
+
+	pass, err := r.loginPassword(ctx, keycloakAccount, namespace)
+	if err != nil {
+		return err
+	}
+	if pass == "" && previous != "" && previous != namespace {
+		if pass, err = r.loginPassword(ctx, keycloakAccount, previous); err != nil {
+			return err
+		}
+	}
+	generated := pass == ""
+	if generated {
+		if pass, err = password.Generate(20, 3, 3, false, true); err != nil {
+			return err
+		}
+	}
+
+	err = applyOwnedSecret(ctx, r.Client, r.Scheme, keycloakAccount, keycloakSecretName, namespace, func(secret *v1.Secret) {
+		secret.Data = map[string][]byte{
+			"userName": []byte(keycloakAccount.Spec.Username),
+			"password": []byte(pass),
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if previous != "" && previous != namespace {
+		if err := deleteOwnedSecret(ctx, r.Client, keycloakAccount, keycloakSecretName, previous); err != nil {
+			return err
+		}
+	}
+	keycloakAccount.Status.SecretNamespace = namespace
+
+	if generated || !keycloakAccount.Status.PasswordSet {
+		if err := r.KeycloakWrapper.ResetPassword(ctx, keycloakAccount.Status.UserId, pass, true); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("set temporary Keycloak password", "username", keycloakAccount.Spec.Username)
+		keycloakAccount.Status.PasswordSet = true
+	}
+	return nil
+}
+
+// reconcileRealmRoles assigns the realm roles in the spec and unassigns the roles dropped from it
+func (r *KeycloakAccountReconciler) reconcileRealmRoles(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount) error {
+	log := log.FromContext(ctx)
+	userId := keycloakAccount.Status.UserId
+
+	if added := slice.GetLeftDifference(keycloakAccount.Spec.RealmRoles, keycloakAccount.Status.RealmRoles); len(added) > 0 {
+		if err := r.KeycloakWrapper.AddRealmRoles(ctx, userId, added); err != nil {
+			log.Error(err, "unable to assign realm roles", "roles", added)
+			return err
+		}
+		log.V(1).Info("assigned realm roles", "roles", added)
+		keycloakAccount.Status.RealmRoles = append(keycloakAccount.Status.RealmRoles, added...)
+	}
+
+	if removed := slice.GetLeftDifference(keycloakAccount.Status.RealmRoles, keycloakAccount.Spec.RealmRoles); len(removed) > 0 {
+		if err := r.KeycloakWrapper.RemoveRealmRoles(ctx, userId, removed); err != nil {
+			log.Error(err, "unable to unassign realm roles", "roles", removed)
+			return err
+		}
+		log.V(1).Info("unassigned realm roles", "roles", removed)
+		keycloakAccount.Status.RealmRoles = slice.GetLeftDifference(keycloakAccount.Status.RealmRoles, removed)
+	}
+	return nil
+}
+
+// clientRoles returns the roles of the client in the list
+func clientRoles(roles []kuadrav1.KeycloakClientRoles, clientId string) []string {
+	for _, clientRoles := range roles {
+		if clientRoles.ClientId == clientId {
+			return clientRoles.Roles
+		}
+	}
+	return nil
+}
+
+// setClientRoles replaces the roles of the client in the list, dropping the client when it has no roles left
+func setClientRoles(roles []kuadrav1.KeycloakClientRoles, clientId string, roleNames []string) []kuadrav1.KeycloakClientRoles {
+	var result []kuadrav1.KeycloakClientRoles
+	found := false
+	for _, clientRoles := range roles {
+		if clientRoles.ClientId == clientId {
+			found = true
+			clientRoles.Roles = roleNames
+		}
+		if len(clientRoles.Roles) > 0 {
+			result = append(result, clientRoles)
+		}
+	}
+	if !found && len(roleNames) > 0 {
+		result = append(result, kuadrav1.KeycloakClientRoles{ClientId: clientId, Roles: roleNames})
+	}
+	return result
+}
+
+// reconcileClientRoles assigns the client roles in the spec and unassigns the roles dropped from it
+func (r *KeycloakAccountReconciler) reconcileClientRoles(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount) error {
+	log := log.FromContext(ctx)
+	userId := keycloakAccount.Status.UserId
+
+	var clientIds []string
+	for _, roles := range append(append([]kuadrav1.KeycloakClientRoles{}, keycloakAccount.Spec.ClientRoles...), keycloakAccount.Status.ClientRoles...) {
+		if !slice.Contains(clientIds, roles.ClientId) {
+			clientIds = append(clientIds, roles.ClientId)
+		}
+	}
+
+	for _, clientId := range clientIds {
+		desired := clientRoles(keycloakAccount.Spec.ClientRoles, clientId)
+		assigned := clientRoles(keycloakAccount.Status.ClientRoles, clientId)
+
+		if added := slice.GetLeftDifference(desired, assigned); len(added) > 0 {
+			if err := r.KeycloakWrapper.AddClientRoles(ctx, userId, clientId, added); err != nil {
+				log.Error(err, "unable to assign client roles", "client", clientId, "roles", added)
+				return err
+			}
+			log.V(1).Info("assigned client roles", "client", clientId, "roles", added)
+			assigned = append(append([]string{}, assigned...), added...)
+			keycloakAccount.Status.ClientRoles = setClientRoles(keycloakAccount.Status.ClientRoles, clientId, assigned)
+		}
+
+		if removed := slice.GetLeftDifference(assigned, desired); len(removed) > 0 {
+			if err := r.KeycloakWrapper.RemoveClientRoles(ctx, userId, clientId, removed); err != nil {
+				log.Error(err, "unable to unassign client roles", "client", clientId, "roles", removed)
+				return err
+			}
+			log.V(1).Info("unassigned client roles", "client", clientId, "roles", removed)
+			keycloakAccount.Status.ClientRoles = setClientRoles(keycloakAccount.Status.ClientRoles, clientId, slice.GetLeftDifference(assigned, removed))
+		}
+	}
+	return nil
+}
+
+// finalizeKeycloakAccount deletes the user kuadra created in the realm and the keycloak-login Secret
+func (r *KeycloakAccountReconciler) finalizeKeycloakAccount(ctx context.Context, keycloakAccount *kuadrav1.KeycloakAccount) error {
+	if keycloakAccount.Status.UserId != "" {
+		if err := r.KeycloakWrapper.DeleteUserIfExists(ctx, keycloakAccount.Status.UserId); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("deleted Keycloak user", "username", keycloakAccount.Spec.Username)
+	}
+	if keycloakAccount.Status.SecretNamespace != "" {
+		return deleteOwnedSecret(ctx, r.Client, keycloakAccount, keycloakSecretName, keycloakAccount.Status.SecretNamespace)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KeycloakAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kuadrav1.KeycloakAccount{}).
+		Owns(&v1.Secret{}).
+		Watches(&source.Kind{Type: &kuadrav1.AccessPolicy{}}, handler.EnqueueRequestsFromMapFunc(accountsForAccessPolicy(r.Client, &kuadrav1.KeycloakAccountList{}))).
+		Complete(r)
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+	"github.com/Kuadrant/kuadra/pkg/keycloak"
+)
+
+var _ = Describe("KeycloakAccount controller", func() {
+
+	ctx := context.Background()
+
+	// options allow the roles of newKeycloakAccount
+	options := kuadrav1.ServiceOptions{
+		KeycloakRealmRoles:  []string{"developer"},
+		KeycloakClientRoles: []string{"kubernetes/view", "kubernetes/edit"},
+	}
+
+	newKeycloakAccount := func() *kuadrav1.KeycloakAccount {
+		return &kuadrav1.KeycloakAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default"},
+			Spec: kuadrav1.KeycloakAccountSpec{
+				Username:   "jdoe",
+				Email:      "jdoe@example.com",
+				RealmRoles: []string{"developer"},
+				ClientRoles: []kuadrav1.KeycloakClientRoles{
+					{ClientId: "kubernetes", Roles: []string{"view", "edit"}},
+				},
+				SecretNamespace: "jdoe",
+			},
+		}
+	}
+
+	Context("When reconciling a KeycloakAccount", func() {
+		It("Should create the user with a temporary password and roles", func() {
+			keycloakAccount := newKeycloakAccount()
+			lookupKey := k8Types.NamespacedName{Name: keycloakAccount.Name, Namespace: keycloakAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(keycloakAccount).Build()
+			keycloakWrapper := newMockKeycloakWrapper()
+			r := &KeycloakAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, KeycloakWrapper: keycloakWrapper, Recorder: record.NewFakeRecorder(10), Options: options}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keycloakWrapper.Users).Should(HaveKey("id-jdoe"))
+			Expect(keycloakWrapper.Users["id-jdoe"].Email).Should(Equal("jdoe@example.com"))
+			Expect(keycloakWrapper.Users["id-jdoe"].Attributes).Should(HaveKeyWithValue(ownerAnnotationKey, []string{"default/jdoe"}))
+			Expect(keycloakWrapper.RealmRoles["id-jdoe"]).Should(ConsistOf("developer"))
+			Expect(keycloakWrapper.ClientRoles["id-jdoe/kubernetes"]).Should(ConsistOf("view", "edit"))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: keycloakSecretName, Namespace: "jdoe"}, secret)).Should(Succeed())
+			Expect(secret.Data).Should(HaveKeyWithValue("userName", []byte("jdoe")))
+			Expect(keycloakWrapper.Passwords).Should(HaveKeyWithValue("id-jdoe", string(secret.Data["password"])))
+
+			reconciled := &kuadrav1.KeycloakAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.UserId).Should(Equal("id-jdoe"))
+			Expect(reconciled.Status.PasswordSet).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+			By("By keeping the password and dropping roles")
+			keycloakWrapper.Passwords = map[string]string{}
+			reconciled.Spec.RealmRoles = nil
+			reconciled.Spec.ClientRoles = []kuadrav1.KeycloakClientRoles{{ClientId: "kubernetes", Roles: []string{"view"}}}
+			reconciled.Spec.FirstName = "Jane"
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keycloakWrapper.Passwords).Should(BeEmpty())
+			Expect(keycloakWrapper.Users["id-jdoe"].FirstName).Should(Equal("Jane"))
+			Expect(keycloakWrapper.RealmRoles["id-jdoe"]).Should(BeEmpty())
+			Expect(keycloakWrapper.ClientRoles["id-jdoe/kubernetes"]).Should(ConsistOf("view"))
+
+			By("By deleting the KeycloakAccount")
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keycloakWrapper.Users).Should(BeEmpty())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: keycloakSecretName, Namespace: "jdoe"}, secret)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should not take over a user it did not create", func() {
+			keycloakAccount := newKeycloakAccount()
+			lookupKey := k8Types.NamespacedName{Name: keycloakAccount.Name, Namespace: keycloakAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(keycloakAccount).Build()
+			keycloakWrapper := newMockKeycloakWrapper()
+			keycloakWrapper.Users["existing"] = keycloak.User{Id: "existing", Username: "jdoe", Enabled: true}
+			recorder := record.NewFakeRecorder(10)
+			r := &KeycloakAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, KeycloakWrapper: keycloakWrapper, Recorder: recorder}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(errKeycloakUserNotManaged))
+			Expect(recorder.Events).Should(Receive(ContainSubstring("UserNotManaged")))
+			Expect(keycloakWrapper.Passwords).Should(BeEmpty())
+
+			reconciled := &kuadrav1.KeycloakAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionKeycloakUserReady)
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonUserNotManaged))
+
+			By("By leaving the user in place on deletion")
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keycloakWrapper.Users).Should(HaveKey("existing"))
+		})
+
+		It("Should refuse roles the operator does not allow", func() {
+			keycloakAccount := newKeycloakAccount()
+			keycloakAccount.Spec.RealmRoles = append(keycloakAccount.Spec.RealmRoles, "realm-admin")
+			lookupKey := k8Types.NamespacedName{Name: keycloakAccount.Name, Namespace: keycloakAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(keycloakAccount).Build()
+			keycloakWrapper := newMockKeycloakWrapper()
+			r := &KeycloakAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, KeycloakWrapper: keycloakWrapper, Recorder: record.NewFakeRecorder(10), Options: options}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring(`spec.realmRoles[1]: Unsupported value: "realm-admin"`)))
+			Expect(keycloakWrapper.RealmRoles["id-jdoe"]).Should(BeEmpty())
+
+			reconciled := &kuadrav1.KeycloakAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionRolesSynced)
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+		})
+
+		It("Should disable the user while suspended and enable it again", func() {
+			keycloakAccount := newKeycloakAccount()
+			lookupKey := k8Types.NamespacedName{Name: keycloakAccount.Name, Namespace: keycloakAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(keycloakAccount).Build()
+			keycloakWrapper := newMockKeycloakWrapper()
+			r := &KeycloakAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, KeycloakWrapper: keycloakWrapper, Recorder: record.NewFakeRecorder(10), Options: options}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			password := keycloakWrapper.Passwords["id-jdoe"]
+
+			reconciled := &kuadrav1.KeycloakAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			reconciled.Spec.Suspended = true
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keycloakWrapper.Users["id-jdoe"].Enabled).Should(BeFalse())
+			Expect(keycloakWrapper.RealmRoles["id-jdoe"]).Should(ConsistOf("developer"))
+
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionSuspended)).Should(BeTrue())
+
+			By("By lifting the suspension")
+			reconciled.Spec.Suspended = false
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(keycloakWrapper.Users["id-jdoe"].Enabled).Should(BeTrue())
+			Expect(keycloakWrapper.Passwords["id-jdoe"]).Should(Equal(password))
+
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionFalse(reconciled.Status.Conditions, kuadrav1.ConditionSuspended)).Should(BeTrue())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+		})
+	})
+})
+
+type mockKeycloakWrapper struct {
+	Users       map[string]keycloak.User
+	Passwords   map[string]string
+	RealmRoles  map[string][]string
+	ClientRoles map[string][]string
+}
+
+func newMockKeycloakWrapper() *mockKeycloakWrapper {
+	return &mockKeycloakWrapper{
+		Users:       map[string]keycloak.User{},
+		Passwords:   map[string]string{},
+		RealmRoles:  map[string][]string{},
+		ClientRoles: map[string][]string{},
+	}
+}
+
+func (c *mockKeycloakWrapper) GetUser(ctx context.Context, username string) (*keycloak.User, error) {
+	for _, user := range c.Users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *mockKeycloakWrapper) CreateUser(ctx context.Context, user keycloak.User) (string, error) {
+	user.Id = "id-" + user.Username
+	c.Users[user.Id] = user
+	return user.Id, nil
+}
+
+func (c *mockKeycloakWrapper) UpdateUser(ctx context.Context, user keycloak.User) error {
+	c.Users[user.Id] = user
+	return nil
+}
+
+func (c *mockKeycloakWrapper) DeleteUserIfExists(ctx context.Context, userId string) error {
+	delete(c.Users, userId)
+	return nil
+}
+
+func (c *mockKeycloakWrapper) ResetPassword(ctx context.Context, userId string, password string, temporary bool) error {
+	c.Passwords[userId] = password
+	return nil
+}
+
+func (c *mockKeycloakWrapper) AddRealmRoles(ctx context.Context, userId string, roleNames []string) error {
+	c.RealmRoles[userId] = append(c.RealmRoles[userId], roleNames...)
+	return nil
+}
+
+func (c *mockKeycloakWrapper) RemoveRealmRoles(ctx context.Context, userId string, roleNames []string) error {
+	c.RealmRoles[userId] = slice.GetLeftDifference(c.RealmRoles[userId], roleNames)
+	return nil
+}
+
+func (c *mockKeycloakWrapper) AddClientRoles(ctx context.Context, userId string, clientId string, roleNames []string) error {
+	c.ClientRoles[userId+"/"+clientId] = append(c.ClientRoles[userId+"/"+clientId], roleNames...)
+	return nil
+}
+
+func (c *mockKeycloakWrapper) RemoveClientRoles(ctx context.Context, userId string, clientId string, roleNames []string) error {
+	c.ClientRoles[userId+"/"+clientId] = slice.GetLeftDifference(c.ClientRoles[userId+"/"+clientId], roleNames)
+	return nil
+}
@@ -0,0 +1,10 @@
+package controller
+
+import (
+	"context"
+	"time"
+)
+
+type TokenRequester interface {
+	CreateToken(ctx context.Context, namespace string, serviceAccount string, expiration time.Duration) (string, time.Time, error)
+}
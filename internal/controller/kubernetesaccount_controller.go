@@ -0,0 +1,609 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+const (
+	KubernetesAccountFinalizer = "kuadra.kuadrant.io/kubernetes-account"
+
+	// kubernetesAccountAnnotationKey marks the ServiceAccount and bindings created for a KubernetesAccount
+	kubernetesAccountAnnotationKey = "kuadra.kuadrant.io/kubernetes-account"
+
+	// kubeconfigSecretName names the Secret holding the user's kubeconfig
+	kubeconfigSecretName = "kubeconfig"
+
+	defaultTokenExpiration = 24 * time.Hour
+)
+
+// KubernetesAccountReconciler reconciles a KubernetesAccount object
+type KubernetesAccountReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	TokenRequester TokenRequester
+	Recorder       record.EventRecorder
+
+	// APIServer is the address of the API server written to the kubeconfig
+	APIServer string
+	// CAData is the certificate authority of the API server written to the kubeconfig
+	CAData []byte
+	// Options limit the roles and namespaces the user may be granted
+	Options kuadrav1.ServiceOptions
+}
+
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=kubernetesaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=kubernetesaccounts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=kubernetesaccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts/token,verbs=create
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=awsaccounts,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accesspolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=admin;edit;view
+
+// Reconcile creates a ServiceAccount for the user in their own namespace, binds it to the roles in
+// the spec and writes a kubeconfig with a token of the ServiceAccount to a Secret. The token is
+// requested through the TokenRequest API and rotated before it expires.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *KubernetesAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var kubernetesAccount kuadrav1.KubernetesAccount
+	if err := r.Get(ctx, req.NamespacedName, &kubernetesAccount); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if kubernetesAccount.DeletionTimestamp != nil && !kubernetesAccount.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(&kubernetesAccount, KubernetesAccountFinalizer) {
+			if err := r.finalizeKubernetesAccount(ctx, &kubernetesAccount); err != nil {
+				log.Error(err, "Failed to delete ServiceAccount and bindings")
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(&kubernetesAccount, KubernetesAccountFinalizer)
+			if err := r.Update(ctx, &kubernetesAccount); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&kubernetesAccount, KubernetesAccountFinalizer) {
+		controllerutil.AddFinalizer(&kubernetesAccount, KubernetesAccountFinalizer)
+		if err := r.Update(ctx, &kubernetesAccount); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	denied, err := serviceDenied(ctx, r.Client, kubernetesAccount.Namespace, kuadrav1.ServiceKubernetes)
+	if err != nil {
+		log.Error(err, "unable to evaluate AccessPolicies")
+		return ctrl.Result{}, err
+	}
+
+	var result ctrl.Result
+	var reconcileErr error
+	if kubernetesAccount.Spec.Suspended {
+		if reconcileErr = r.suspendKubernetesAccount(ctx, &kubernetesAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to suspend ServiceAccount")
+		}
+		setSuspendedConditions(r.Recorder, &kubernetesAccount, &kubernetesAccount.Status.Conditions, kubernetesAccount.Generation, reconcileErr)
+	} else if denied {
+		if reconcileErr = r.suspendKubernetesAccount(ctx, &kubernetesAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to delete ServiceAccount")
+		}
+		setServiceDeniedConditions(&kubernetesAccount.Status.Conditions, kubernetesAccount.Generation, kubernetesAccount.Namespace, kuadrav1.ServiceKubernetes, reconcileErr)
+	} else {
+		result, reconcileErr = r.reconcileKubernetesAccount(ctx, &kubernetesAccount)
+		setReadyCondition(&kubernetesAccount.Status.Conditions, kubernetesAccount.Generation, []string{
+			kuadrav1.ConditionServiceAccountReady,
+			kuadrav1.ConditionBindingsSynced,
+			kuadrav1.ConditionKubeconfigReady,
+		}, reconcileErr)
+		if reconcileErr == nil {
+			setReactivatedCondition(r.Recorder, &kubernetesAccount, &kubernetesAccount.Status.Conditions, kubernetesAccount.Generation)
+		}
+	}
+	kubernetesAccount.Status.ObservedGeneration = kubernetesAccount.Generation
+
+	var latest kuadrav1.KubernetesAccount
+	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, kubernetesAccount.Status) {
+		if err := r.Status().Update(ctx, &kubernetesAccount); err != nil {
+			log.Error(err, "unable to update KubernetesAccount status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+		}
+	}
+
+	return result, reconcileErr
+}
+
+// kubernetesAccountNamespace returns the user's own namespace
+func kubernetesAccountNamespace(kubernetesAccount *kuadrav1.KubernetesAccount) string {
+	if kubernetesAccount.Spec.Namespace != "" {
+		return kubernetesAccount.Spec.Namespace
+	}
+	return kubernetesAccount.Namespace
+}
+
+// kubeconfigSecretNamespace returns the namespace the spec asks for the kubeconfig Secret
+func kubeconfigSecretNamespace(kubernetesAccount *kuadrav1.KubernetesAccount) string {
+	if kubernetesAccount.Spec.SecretNamespace != "" {
+		return kubernetesAccount.Spec.SecretNamespace
+	}
+	return kubernetesAccountNamespace(kubernetesAccount)
+}
+
+// tokenExpiration returns the lifetime of the tokens requested for the user
+func tokenExpiration(kubernetesAccount *kuadrav1.KubernetesAccount) time.Duration {
+	if kubernetesAccount.Spec.TokenExpiration != nil && kubernetesAccount.Spec.TokenExpiration.Duration > 0 {
+		return kubernetesAccount.Spec.TokenExpiration.Duration
+	}
+	return defaultTokenExpiration
+}
+
+// tokenRotationTime returns when the token in the kubeconfig is replaced, once two thirds of its
+// lifetime have passed, or the zero time when there is no token yet
+func tokenRotationTime(kubernetesAccount *kuadrav1.KubernetesAccount) time.Time {
+	if kubernetesAccount.Status.TokenExpirationTimestamp == nil {
+		return time.Time{}
+	}
+	return kubernetesAccount.Status.TokenExpirationTimestamp.Add(-tokenExpiration(kubernetesAccount) / 3)
+}
+
+// kubernetesAccountOwner identifies a KubernetesAccount in the annotation of the objects created for it
+func kubernetesAccountOwner(kubernetesAccount *kuadrav1.KubernetesAccount) string {
+	return kubernetesAccount.Namespace + "/" + kubernetesAccount.Name
+}
+
+// reconcileKubernetesAccount moves the user's ServiceAccount, bindings and kubeconfig towards the spec,
+// recording a condition for each on the KubernetesAccount status. It requeues for the next token rotation.
+func (r *KubernetesAccountReconciler) reconcileKubernetesAccount(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	conditions := &kubernetesAccount.Status.Conditions
+	generation := kubernetesAccount.Generation
+
+	if err := r.checkNamespace(ctx, kubernetesAccount); err != nil {
+		log.Error(err, "unable to use namespace", "namespace", kubernetesAccountNamespace(kubernetesAccount))
+		setConditionFromError(conditions, generation, kuadrav1.ConditionServiceAccountReady, err)
+		// The user keeps no role while the namespace is refused
+		if pruneErr := r.pruneBindings(ctx, kubernetesAccount, map[string]bool{}); pruneErr != nil {
+			return ctrl.Result{}, pruneErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileServiceAccount(ctx, kubernetesAccount); err != nil {
+		log.Error(err, "unable to reconcile ServiceAccount", "namespace", kubernetesAccountNamespace(kubernetesAccount))
+		setConditionFromError(conditions, generation, kuadrav1.ConditionServiceAccountReady, err)
+		return ctrl.Result{}, err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionServiceAccountReady,
+		"ServiceAccount "+kubernetesAccount.Status.ServiceAccount+" exists in namespace "+kubernetesAccount.Status.Namespace)
+
+	if err := r.reconcileBindings(ctx, kubernetesAccount); err != nil {
+		log.Error(err, "unable to reconcile role bindings")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionBindingsSynced, err)
+		return ctrl.Result{}, err
+	}
+	// The roles the options do not allow are left out of the bindings
+	if err := notAllowedError(r.Options.ValidateKubernetesAccountSpec(&kubernetesAccount.Spec, field.NewPath("spec"))); err != nil {
+		log.Error(err, "refusing roles")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionBindingsSynced, err)
+		return ctrl.Result{}, err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionBindingsSynced, "ServiceAccount is bound to every role in the spec")
+
+	if err := r.reconcileKubeconfig(ctx, kubernetesAccount); err != nil {
+		log.Error(err, "unable to write kubeconfig", "namespace", kubeconfigSecretNamespace(kubernetesAccount))
+		setConditionFromError(conditions, generation, kuadrav1.ConditionKubeconfigReady, err)
+		if errors.Is(err, errSecretNotManaged) {
+			r.Recorder.Event(kubernetesAccount, v1.EventTypeWarning, "SecretNotManaged", err.Error())
+		}
+		return ctrl.Result{}, err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionKubeconfigReady,
+		"Kubeconfig is stored in Secret "+kubeconfigSecretName+", its token expires at "+kubernetesAccount.Status.TokenExpirationTimestamp.UTC().Format(time.RFC3339))
+
+	return ctrl.Result{RequeueAfter: time.Until(tokenRotationTime(kubernetesAccount))}, nil
+}
+
+// checkNamespace refuses the user's namespace unless it is the namespace of the KubernetesAccount, one the
+// options list, or the namespace kuadra created for the AwsAccount of the User owning the KubernetesAccount
+func (r *KubernetesAccountReconciler) checkNamespace(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount) error {
+	namespace := kubernetesAccountNamespace(kubernetesAccount)
+	if namespace == kubernetesAccount.Namespace || slice.Contains(r.Options.KubernetesNamespaces, namespace) {
+		return nil
+	}
+	refused := fmt.Errorf("%w: the operator does not allow roles in namespace %s", errNotAllowed, namespace)
+
+	user := metav1.GetControllerOf(kubernetesAccount)
+	if user == nil || user.Kind != "User" {
+		return refused
+	}
+	ns := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		return refused
+	}
+	awsAccountNamespace, awsAccountName, err := cache.SplitMetaNamespaceKey(ns.Annotations[ownerAnnotationKey])
+	if err != nil || ns.Labels[managedByLabelKey] != managedByLabelValue || awsAccountNamespace != kubernetesAccount.Namespace {
+		return refused
+	}
+	awsAccount := &kuadrav1.AwsAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: awsAccountName, Namespace: awsAccountNamespace}, awsAccount); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		return refused
+	}
+	if owner := metav1.GetControllerOf(awsAccount); owner == nil || owner.UID != user.UID {
+		return refused
+	}
+	return nil
+}
+
+// reconcileServiceAccount creates the user's ServiceAccount, deleting the previous one when the user's
+// namespace changes, which also invalidates its tokens
+func (r *KubernetesAccountReconciler) reconcileServiceAccount(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount) error {
+	namespace := kubernetesAccountNamespace(kubernetesAccount)
+	owner := kubernetesAccountOwner(kubernetesAccount)
+
+	sa := &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: kubernetesAccount.Name, Namespace: namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, sa, func() error {
+		if sa.ResourceVersion != "" && sa.Annotations[kubernetesAccountAnnotationKey] != owner {
+			return fmt.Errorf("ServiceAccount %s/%s exists and is not managed by kuadra", namespace, sa.Name)
+		}
+		if sa.Labels == nil {
+			sa.Labels = map[string]string{}
+		}
+		sa.Labels[managedByLabelKey] = managedByLabelValue
+		if sa.Annotations == nil {
+			sa.Annotations = map[string]string{}
+		}
+		sa.Annotations[kubernetesAccountAnnotationKey] = owner
+		if namespace == kubernetesAccount.Namespace {
+			return controllerutil.SetControllerReference(kubernetesAccount, sa, r.Scheme)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if previous := kubernetesAccount.Status.Namespace; previous != "" && previous != namespace {
+		if err := r.deleteServiceAccount(ctx, kubernetesAccount, previous); err != nil {
+			return err
+		}
+		// Tokens of the previous ServiceAccount are no longer valid
+		kubernetesAccount.Status.TokenExpirationTimestamp = nil
+	}
+	kubernetesAccount.Status.ServiceAccount = sa.Name
+	kubernetesAccount.Status.Namespace = namespace
+	return nil
+}
+
+func (r *KubernetesAccountReconciler) deleteServiceAccount(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount, namespace string) error {
+	sa := &v1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: kubernetesAccount.Name, Namespace: namespace}, sa); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if sa.Annotations[kubernetesAccountAnnotationKey] != kubernetesAccountOwner(kubernetesAccount) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, sa))
+}
+
+// kubernetesBindingName names a binding created for the KubernetesAccount. RoleBindings in other
+// namespaces and ClusterRoleBindings carry the KubernetesAccount's namespace to stay unique.
+func kubernetesBindingName(kubernetesAccount *kuadrav1.KubernetesAccount, suffix string) string {
+	return "kuadra:" + kubernetesAccount.Namespace + ":" + kubernetesAccount.Name + ":" + suffix
+}
+
+// desiredBindings returns the RoleBindings and ClusterRoleBindings the spec asks for, without their
+// subjects, leaving out the roles the options do not allow
+func desiredBindings(kubernetesAccount *kuadrav1.KubernetesAccount, options *kuadrav1.ServiceOptions) []client.Object {
+	var bindings []client.Object
+	if namespaceRole := kubernetesAccount.Spec.NamespaceRole; options.KubernetesNamespaceRoleAllowed(namespaceRole) {
+		if namespaceRole == "" {
+			namespaceRole = kuadrav1.DefaultKubernetesNamespaceRole
+		}
+		bindings = append(bindings, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: kubernetesBindingName(kubernetesAccount, "namespace"), Namespace: kubernetesAccountNamespace(kubernetesAccount)},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: namespaceRole},
+		})
+	}
+
+	for _, clusterRole := range kubernetesAccount.Spec.ClusterRoles {
+		if !slice.Contains(options.KubernetesClusterRoles, clusterRole) {
+			continue
+		}
+		bindings = append(bindings, &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: kubernetesBindingName(kubernetesAccount, clusterRole)},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole},
+		})
+	}
+
+	for _, role := range kubernetesAccount.Spec.Roles {
+		if !options.KubernetesRoleAllowed(role) {
+			continue
+		}
+		kind := role.RoleKind()
+		bindings = append(bindings, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: kubernetesBindingName(kubernetesAccount, strings.ToLower(kind)+"-"+role.Name), Namespace: role.Namespace},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: kind, Name: role.Name},
+		})
+	}
+	return bindings
+}
+
+// bindingKey identifies a binding by its type, namespace and name
+func bindingKey(object client.Object) string {
+	return fmt.Sprintf("%T/%s/%s", object, object.GetNamespace(), object.GetName())
+}
+
+// reconcileBindings binds the user's ServiceAccount to the roles in the spec and deletes the
+// bindings of the roles dropped from it
+func (r *KubernetesAccountReconciler) reconcileBindings(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount) error {
+	subjects := []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      kubernetesAccount.Status.ServiceAccount,
+		Namespace: kubernetesAccount.Status.Namespace,
+	}}
+
+	desired := map[string]bool{}
+	for _, binding := range desiredBindings(kubernetesAccount, &r.Options) {
+		if err := r.applyBinding(ctx, kubernetesAccount, binding, subjects); err != nil {
+			return fmt.Errorf("unable to bind %s: %w", binding.GetName(), err)
+		}
+		desired[bindingKey(binding)] = true
+	}
+	return r.pruneBindings(ctx, kubernetesAccount, desired)
+}
+
+func (r *KubernetesAccountReconciler) applyBinding(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount, binding client.Object, subjects []rbacv1.Subject) error {
+	var roleRef rbacv1.RoleRef
+	var existing client.Object
+	switch binding := binding.(type) {
+	case *rbacv1.RoleBinding:
+		roleRef = binding.RoleRef
+		existing = &rbacv1.RoleBinding{}
+	case *rbacv1.ClusterRoleBinding:
+		roleRef = binding.RoleRef
+		existing = &rbacv1.ClusterRoleBinding{}
+	}
+
+	// The role of a binding cannot be changed, so the binding is replaced instead
+	err := r.Get(ctx, client.ObjectKeyFromObject(binding), existing)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil {
+		if existing.GetAnnotations()[kubernetesAccountAnnotationKey] != kubernetesAccountOwner(kubernetesAccount) {
+			return fmt.Errorf("%s exists and is not managed by kuadra", binding.GetName())
+		}
+		if existingRoleRef(existing) != roleRef {
+			if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, binding, func() error {
+		switch binding := binding.(type) {
+		case *rbacv1.RoleBinding:
+			binding.RoleRef = roleRef
+			binding.Subjects = subjects
+		case *rbacv1.ClusterRoleBinding:
+			binding.RoleRef = roleRef
+			binding.Subjects = subjects
+		}
+		labels := binding.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[managedByLabelKey] = managedByLabelValue
+		binding.SetLabels(labels)
+		annotations := binding.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[kubernetesAccountAnnotationKey] = kubernetesAccountOwner(kubernetesAccount)
+		binding.SetAnnotations(annotations)
+		if binding.GetNamespace() == kubernetesAccount.Namespace {
+			return controllerutil.SetControllerReference(kubernetesAccount, binding, r.Scheme)
+		}
+		return nil
+	})
+	return err
+}
+
+func existingRoleRef(binding client.Object) rbacv1.RoleRef {
+	switch binding := binding.(type) {
+	case *rbacv1.RoleBinding:
+		return binding.RoleRef
+	case *rbacv1.ClusterRoleBinding:
+		return binding.RoleRef
+	}
+	return rbacv1.RoleRef{}
+}
+
+// pruneBindings deletes the bindings created for the KubernetesAccount that are not desired anymore
+func (r *KubernetesAccountReconciler) pruneBindings(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount, desired map[string]bool) error {
+	var bindings []client.Object
+	var roleBindings rbacv1.RoleBindingList
+	if err := r.List(ctx, &roleBindings, client.MatchingLabels{managedByLabelKey: managedByLabelValue}); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		bindings = append(bindings, &roleBindings.Items[i])
+	}
+	var clusterRoleBindings rbacv1.ClusterRoleBindingList
+	if err := r.List(ctx, &clusterRoleBindings, client.MatchingLabels{managedByLabelKey: managedByLabelValue}); err != nil {
+		return err
+	}
+	for i := range clusterRoleBindings.Items {
+		bindings = append(bindings, &clusterRoleBindings.Items[i])
+	}
+
+	for _, binding := range bindings {
+		if binding.GetAnnotations()[kubernetesAccountAnnotationKey] != kubernetesAccountOwner(kubernetesAccount) || desired[bindingKey(binding)] {
+			continue
+		}
+		if err := r.Delete(ctx, binding); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).V(1).Info("deleted binding", "name", binding.GetName(), "namespace", binding.GetNamespace())
+	}
+	return nil
+}
+
+// kubeconfig returns a kubeconfig authenticating as the ServiceAccount with the token
+func (r *KubernetesAccountReconciler) kubeconfig(kubernetesAccount *kuadrav1.KubernetesAccount, token string) ([]byte, error) {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["kuadra"] = &clientcmdapi.Cluster{
+		Server:                   r.APIServer,
+		CertificateAuthorityData: r.CAData,
+	}
+	config.AuthInfos[kubernetesAccount.Status.ServiceAccount] = &clientcmdapi.AuthInfo{Token: token}
+	config.Contexts["kuadra"] = &clientcmdapi.Context{
+		Cluster:   "kuadra",
+		AuthInfo:  kubernetesAccount.Status.ServiceAccount,
+		Namespace: kubernetesAccount.Status.Namespace,
+	}
+	config.CurrentContext = "kuadra"
+	return clientcmd.Write(*config)
+}
+
+// reconcileKubeconfig writes a kubeconfig with a new token to the Secret when there is no valid token yet
+// or the current one is due for rotation, and moves the Secret when its namespace changes
+func (r *KubernetesAccountReconciler) reconcileKubeconfig(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount) error {
+	log := log.FromContext(ctx)
+	namespace := kubeconfigSecretNamespace(kubernetesAccount)
+	previous := kubernetesAccount.Status.SecretNamespace
+
+	secret := &v1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: kubeconfigSecretName, Namespace: namespace}, secret)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	hasKubeconfig := err == nil && secret.Annotations[ownerAnnotationKey] == objectOwner(kubernetesAccount) && len(secret.Data[kubeconfigSecretName]) > 0
+	if hasKubeconfig && !time.Now().Before(tokenRotationTime(kubernetesAccount)) {
+		hasKubeconfig = false
+		log.Info("rotating ServiceAccount token", "serviceAccount", kubernetesAccount.Status.ServiceAccount)
+	}
+
+	if !hasKubeconfig {
+		token, expiration, err := r.TokenRequester.CreateToken(ctx, kubernetesAccount.Status.Namespace, kubernetesAccount.Status.ServiceAccount, tokenExpiration(kubernetesAccount))
+		if err != nil {
+			return err
+		}
+		kubeconfig, err := r.kubeconfig(kubernetesAccount, token)
+		if err != nil {
+			return err
+		}
+		err = applyOwnedSecret(ctx, r.Client, r.Scheme, kubernetesAccount, kubeconfigSecretName, namespace, func(secret *v1.Secret) {
+			secret.Data = map[string][]byte{kubeconfigSecretName: kubeconfig}
+		})
+		if err != nil {
+			return err
+		}
+		kubernetesAccount.Status.TokenExpirationTimestamp = &metav1.Time{Time: expiration}
+	}
+
+	if previous != "" && previous != namespace {
+		if err := deleteOwnedSecret(ctx, r.Client, kubernetesAccount, kubeconfigSecretName, previous); err != nil {
+			return err
+		}
+	}
+	kubernetesAccount.Status.SecretNamespace = namespace
+	return nil
+}
+
+// finalizeKubernetesAccount deletes the ServiceAccount, which invalidates its tokens, the bindings and the kubeconfig Secret
+func (r *KubernetesAccountReconciler) finalizeKubernetesAccount(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount) error {
+	if err := r.pruneBindings(ctx, kubernetesAccount, nil); err != nil {
+		return err
+	}
+	if kubernetesAccount.Status.Namespace != "" {
+		if err := r.deleteServiceAccount(ctx, kubernetesAccount, kubernetesAccount.Status.Namespace); err != nil {
+			return err
+		}
+	}
+	if kubernetesAccount.Status.SecretNamespace != "" {
+		return deleteOwnedSecret(ctx, r.Client, kubernetesAccount, kubeconfigSecretName, kubernetesAccount.Status.SecretNamespace)
+	}
+	return nil
+}
+
+// suspendKubernetesAccount deletes the ServiceAccount, the bindings and the kubeconfig Secret as on
+// deletion. A new ServiceAccount and token are created once the account is no longer suspended.
+func (r *KubernetesAccountReconciler) suspendKubernetesAccount(ctx context.Context, kubernetesAccount *kuadrav1.KubernetesAccount) error {
+	if err := r.finalizeKubernetesAccount(ctx, kubernetesAccount); err != nil {
+		return err
+	}
+	kubernetesAccount.Status.ServiceAccount = ""
+	kubernetesAccount.Status.Namespace = ""
+	kubernetesAccount.Status.SecretNamespace = ""
+	kubernetesAccount.Status.TokenExpirationTimestamp = nil
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KubernetesAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kuadrav1.KubernetesAccount{}).
+		Owns(&v1.Secret{}).
+		Owns(&v1.ServiceAccount{}).
+		Watches(&source.Kind{Type: &kuadrav1.AccessPolicy{}}, handler.EnqueueRequestsFromMapFunc(accountsForAccessPolicy(r.Client, &kuadrav1.KubernetesAccountList{}))).
+		Complete(r)
+}
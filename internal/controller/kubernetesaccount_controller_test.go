@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+var _ = Describe("KubernetesAccount controller", func() {
+
+	ctx := context.Background()
+
+	options := kuadrav1.ServiceOptions{
+		KubernetesClusterRoles:   []string{"view"},
+		KubernetesNamespaceRoles: []string{"admin"},
+		KubernetesRoles:          []string{"shared/deployer"},
+		KubernetesNamespaces:     []string{"jdoe"},
+	}
+
+	newKubernetesAccount := func() *kuadrav1.KubernetesAccount {
+		return &kuadrav1.KubernetesAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default"},
+			Spec: kuadrav1.KubernetesAccountSpec{
+				Namespace:       "jdoe",
+				ClusterRoles:    []string{"view"},
+				Roles:           []kuadrav1.KubernetesRoleBinding{{Namespace: "shared", Kind: "Role", Name: "deployer"}},
+				TokenExpiration: &metav1.Duration{Duration: time.Hour},
+			},
+		}
+	}
+
+	Context("When reconciling a KubernetesAccount", func() {
+		It("Should create a ServiceAccount, its bindings and a kubeconfig", func() {
+			kubernetesAccount := newKubernetesAccount()
+			lookupKey := k8Types.NamespacedName{Name: kubernetesAccount.Name, Namespace: kubernetesAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(kubernetesAccount).Build()
+			tokenRequester := &mockTokenRequester{}
+			r := &KubernetesAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, TokenRequester: tokenRequester, Recorder: record.NewFakeRecorder(10),
+				APIServer: "https://api.example.com:6443", CAData: []byte("ca"), Options: options}
+
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RequeueAfter).Should(BeNumerically("~", 40*time.Minute, time.Minute))
+			Expect(tokenRequester.Requests).Should(Equal([]string{"jdoe/jdoe"}))
+
+			sa := &corev1.ServiceAccount{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "jdoe", Namespace: "jdoe"}, sa)).Should(Succeed())
+
+			roleBinding := &rbacv1.RoleBinding{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:namespace", Namespace: "jdoe"}, roleBinding)).Should(Succeed())
+			Expect(roleBinding.RoleRef.Name).Should(Equal("admin"))
+			Expect(roleBinding.Subjects).Should(Equal([]rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "jdoe", Namespace: "jdoe"}}))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:role-deployer", Namespace: "shared"}, roleBinding)).Should(Succeed())
+			Expect(roleBinding.RoleRef.Kind).Should(Equal("Role"))
+			clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:view"}, clusterRoleBinding)).Should(Succeed())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: kubeconfigSecretName, Namespace: "jdoe"}, secret)).Should(Succeed())
+			config, err := clientcmd.Load(secret.Data[kubeconfigSecretName])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(config.Clusters["kuadra"].Server).Should(Equal("https://api.example.com:6443"))
+			Expect(config.AuthInfos["jdoe"].Token).Should(Equal("token-1"))
+			Expect(config.Contexts["kuadra"].Namespace).Should(Equal("jdoe"))
+
+			reconciled := &kuadrav1.KubernetesAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+			By("By keeping the token until it is due for rotation")
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tokenRequester.Requests).Should(HaveLen(1))
+
+			By("By rotating the token")
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			reconciled.Status.TokenExpirationTimestamp = &metav1.Time{Time: time.Now().Add(10 * time.Minute)}
+			Expect(k8sClient.Status().Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tokenRequester.Requests).Should(HaveLen(2))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: kubeconfigSecretName, Namespace: "jdoe"}, secret)).Should(Succeed())
+			config, err = clientcmd.Load(secret.Data[kubeconfigSecretName])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(config.AuthInfos["jdoe"].Token).Should(Equal("token-2"))
+
+			By("By dropping a cluster role")
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			reconciled.Spec.ClusterRoles = nil
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:view"}, clusterRoleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+			By("By deleting the KubernetesAccount")
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "jdoe", Namespace: "jdoe"}, sa)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:role-deployer", Namespace: "shared"}, roleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: kubeconfigSecretName, Namespace: "jdoe"}, secret)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should not take over a ServiceAccount it did not create", func() {
+			kubernetesAccount := newKubernetesAccount()
+			lookupKey := k8Types.NamespacedName{Name: kubernetesAccount.Name, Namespace: kubernetesAccount.Namespace}
+			existing := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "jdoe"}}
+			k8sClient := fake.NewClientBuilder().WithObjects(kubernetesAccount, existing).Build()
+			tokenRequester := &mockTokenRequester{}
+			r := &KubernetesAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, TokenRequester: tokenRequester, Recorder: record.NewFakeRecorder(10), Options: options}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(HaveOccurred())
+			Expect(tokenRequester.Requests).Should(BeEmpty())
+
+			reconciled := &kuadrav1.KubernetesAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionFalse(reconciled.Status.Conditions, kuadrav1.ConditionServiceAccountReady)).Should(BeTrue())
+		})
+
+		It("Should not bind roles the operator does not allow", func() {
+			kubernetesAccount := newKubernetesAccount()
+			kubernetesAccount.Spec.ClusterRoles = []string{"view", "cluster-admin"}
+			kubernetesAccount.Spec.Roles = append(kubernetesAccount.Spec.Roles, kuadrav1.KubernetesRoleBinding{Namespace: "kube-system", Kind: "ClusterRole", Name: "admin"})
+			lookupKey := k8Types.NamespacedName{Name: kubernetesAccount.Name, Namespace: kubernetesAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(kubernetesAccount).Build()
+			tokenRequester := &mockTokenRequester{}
+			r := &KubernetesAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, TokenRequester: tokenRequester, Recorder: record.NewFakeRecorder(10), Options: options}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(errNotAllowed))
+			Expect(tokenRequester.Requests).Should(BeEmpty())
+
+			clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:view"}, clusterRoleBinding)).Should(Succeed())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:cluster-admin"}, clusterRoleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			roleBinding := &rbacv1.RoleBinding{}
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:clusterrole-admin", Namespace: "kube-system"}, roleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+			reconciled := &kuadrav1.KubernetesAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionBindingsSynced)
+			Expect(condition.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+
+			By("By dropping every binding when the namespace is not allowed")
+			reconciled.Spec.ClusterRoles = []string{"view"}
+			reconciled.Spec.Namespace = "kube-system"
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(errNotAllowed))
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:view"}, clusterRoleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:namespace", Namespace: "jdoe"}, roleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:namespace", Namespace: "kube-system"}, roleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should withdraw the access once no AccessPolicy allows Kubernetes accounts", func() {
+			kubernetesAccount := newKubernetesAccount()
+			accessPolicy := &kuadrav1.AccessPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "kubernetes"},
+				Spec:       kuadrav1.AccessPolicySpec{Services: []string{kuadrav1.ServiceKubernetes}},
+			}
+			lookupKey := k8Types.NamespacedName{Name: kubernetesAccount.Name, Namespace: kubernetesAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(kubernetesAccount, accessPolicy).Build()
+			r := &KubernetesAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, TokenRequester: &mockTokenRequester{}, Recorder: record.NewFakeRecorder(10), Options: options}
+			Expect(accountsForAccessPolicy(k8sClient, &kuadrav1.KubernetesAccountList{})(accessPolicy)).Should(Equal([]reconcile.Request{{NamespacedName: lookupKey}}))
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			sa := &corev1.ServiceAccount{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "jdoe", Namespace: "jdoe"}, sa)).Should(Succeed())
+
+			accessPolicy.Spec.Services = []string{kuadrav1.ServiceAws}
+			Expect(k8sClient.Update(ctx, accessPolicy)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "jdoe", Namespace: "jdoe"}, sa)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:view"}, clusterRoleBinding)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+			reconciled := &kuadrav1.KubernetesAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			ready := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionReady)
+			Expect(ready.Reason).Should(Equal(kuadrav1.ReasonAccessPolicyDenied))
+			Expect(ready.Message).Should(ContainSubstring("namespace default"))
+		})
+
+		It("Should allow the namespace created for the AWS account of its User", func() {
+			user := &kuadrav1.User{ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"}}
+			awsAccount := &kuadrav1.AwsAccount{ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default"}}
+			Expect(controllerutil.SetControllerReference(user, awsAccount, scheme.Scheme)).Should(Succeed())
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "jdoe-aws",
+				Labels:      map[string]string{managedByLabelKey: managedByLabelValue},
+				Annotations: map[string]string{ownerAnnotationKey: "default/jdoe"}}}
+			kubernetesAccount := newKubernetesAccount()
+			kubernetesAccount.Spec.Namespace = "jdoe-aws"
+			lookupKey := k8Types.NamespacedName{Name: kubernetesAccount.Name, Namespace: kubernetesAccount.Namespace}
+			r := &KubernetesAccountReconciler{Scheme: scheme.Scheme, TokenRequester: &mockTokenRequester{}, Recorder: record.NewFakeRecorder(10), Options: options}
+
+			By("By refusing it for a KubernetesAccount of another owner")
+			r.Client = fake.NewClientBuilder().WithObjects(kubernetesAccount.DeepCopy(), awsAccount, namespace).Build()
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(errNotAllowed))
+
+			Expect(controllerutil.SetControllerReference(user, kubernetesAccount, scheme.Scheme)).Should(Succeed())
+			r.Client = fake.NewClientBuilder().WithObjects(kubernetesAccount, awsAccount, namespace).Build()
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			roleBinding := &rbacv1.RoleBinding{}
+			Expect(r.Get(ctx, k8Types.NamespacedName{Name: "kuadra:default:jdoe:namespace", Namespace: "jdoe-aws"}, roleBinding)).Should(Succeed())
+		})
+	})
+})
+
+type mockTokenRequester struct {
+	Requests []string
+}
+
+func (c *mockTokenRequester) CreateToken(ctx context.Context, namespace string, serviceAccount string, expiration time.Duration) (string, time.Time, error) {
+	c.Requests = append(c.Requests, namespace+"/"+serviceAccount)
+	return fmt.Sprintf("token-%d", len(c.Requests)), time.Now().Add(expiration), nil
+}
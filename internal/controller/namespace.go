@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+// Markers on the namespaces created by kuadra. The owner is an annotation as AwsAccount
+// names can be longer than a label value allows.
+const (
+	managedByLabelKey   = "app.kubernetes.io/managed-by"
+	managedByLabelValue = "kuadra"
+	ownerAnnotationKey  = "kuadra.kuadrant.io/owner"
+)
+
+// awsAccountSecretNames are the Secrets written to the namespace of an AwsAccount
+var awsAccountSecretNames = []string{"aws-login", "aws-credentials", hostedZoneSecretName}
+
+var invalidNamespaceCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// sanitizeNamespaceName maps an IAM user name, which may contain characters such as +=,.@_,
+// to a valid namespace name. Names that had to be changed get a hash suffix so that
+// different user names do not end up in the same namespace.
+func sanitizeNamespaceName(userName string) string {
+	if len(validation.IsDNS1123Label(userName)) == 0 {
+		return userName
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(userName))
+	suffix := fmt.Sprintf("%08x", hash.Sum32())
+
+	name := invalidNamespaceCharacters.ReplaceAllString(strings.ToLower(userName), "-")
+	if maxLength := validation.DNS1123LabelMaxLength - len(suffix) - 1; len(name) > maxLength {
+		name = name[:maxLength]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "user-" + suffix
+	}
+	return name + "-" + suffix
+}
+
+// targetNamespace returns the namespace spec.namespace asks for the AwsAccount's Secrets
+func targetNamespace(awsAccount *kuadrav1.AwsAccount) string {
+	if spec := awsAccount.Spec.Namespace; spec != nil {
+		if spec.UseAccountNamespace {
+			return awsAccount.Namespace
+		}
+		if spec.Name != "" {
+			return spec.Name
+		}
+	}
+	return sanitizeNamespaceName(awsAccount.Spec.UserName)
+}
+
+// secretNamespace returns the namespace currently holding the AwsAccount's Secrets
+func secretNamespace(awsAccount *kuadrav1.AwsAccount) string {
+	if namespace := recordedNamespace(awsAccount); namespace != "" {
+		return namespace
+	}
+	return targetNamespace(awsAccount)
+}
+
+// recordedNamespace returns the namespace the status records for the AwsAccount's Secrets. AwsAccounts
+// reconciled before status.namespace existed only recorded namespaceCreated, for a namespace named
+// after the IAM user.
+func recordedNamespace(awsAccount *kuadrav1.AwsAccount) string {
+	if awsAccount.Status.Namespace == "" && awsAccount.Status.NamespaceCreated {
+		return awsAccount.Spec.UserName
+	}
+	return awsAccount.Status.Namespace
+}
+
+func isOwnedNamespace(awsAccount *kuadrav1.AwsAccount, ns *v1.Namespace) bool {
+	return ns.Labels[managedByLabelKey] == managedByLabelValue &&
+		ns.Annotations[ownerAnnotationKey] == awsAccount.Namespace+"/"+awsAccount.Name
+}
+
+// isLegacyNamespace reports whether ns is the namespace kuadra created for the AwsAccount before it
+// marked its namespaces: named after the IAM user, recorded in the status and created after the AwsAccount
+func isLegacyNamespace(awsAccount *kuadrav1.AwsAccount, ns *v1.Namespace) bool {
+	return ns.Name == awsAccount.Spec.UserName && ns.Name == recordedNamespace(awsAccount) &&
+		ns.Annotations[ownerAnnotationKey] == "" && !ns.CreationTimestamp.Before(&awsAccount.CreationTimestamp)
+}
+
+// reconcileNamespace creates the target namespace, and moves the Secrets over when the target
+// namespace changes. A namespace that kuadra did not create for the AwsAccount is refused, unless
+// the AwsAccount uses its own namespace or the namespace is in r.Namespaces, so that the Secrets
+// cannot be put in any namespace. The namespace and Secrets of AwsAccounts reconciled before kuadra
+// marked them are adopted.
+func (r *AwsAccountReconciler) reconcileNamespace(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	namespace := targetNamespace(awsAccount)
+
+	if awsAccount.Spec.Namespace == nil || !awsAccount.Spec.Namespace.UseAccountNamespace {
+		ns := &v1.Namespace{}
+		err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err != nil {
+			if err := r.createNamespace(ctx, awsAccount, namespace); err != nil {
+				return err
+			}
+			log.V(1).Info("created namespace", "namespace", namespace)
+		} else if isOwnedNamespace(awsAccount, ns) || isLegacyNamespace(awsAccount, ns) {
+			if err := r.updateNamespaceMetadata(ctx, awsAccount, ns); err != nil {
+				return err
+			}
+		} else if !slice.Contains(r.Namespaces, namespace) {
+			return fmt.Errorf("%w: namespace %s was not created by kuadra for this AwsAccount", errNotAllowed, namespace)
+		}
+	}
+
+	if awsAccount.Status.Namespace != "" {
+		if err := r.adoptLegacySecrets(ctx, awsAccount, awsAccount.Status.Namespace); err != nil {
+			return err
+		}
+	}
+	if awsAccount.Status.Namespace != "" && awsAccount.Status.Namespace != namespace {
+		if err := r.moveSecrets(ctx, awsAccount, awsAccount.Status.Namespace, namespace); err != nil {
+			return err
+		}
+		log.Info("moved Secrets to new namespace", "namespace", namespace, "previousNamespace", awsAccount.Status.Namespace)
+	}
+	awsAccount.Status.Namespace = namespace
+	awsAccount.Status.NamespaceCreated = true
+	return nil
+}
+
+// adoptLegacySecrets marks the Secrets that kuadra wrote in a namespace it owns before it marked its
+// Secrets, which are those the status records: the login profile, the access key and the hosted zone
+func (r *AwsAccountReconciler) adoptLegacySecrets(ctx context.Context, awsAccount *kuadrav1.AwsAccount, namespace string) error {
+	ns := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !isOwnedNamespace(awsAccount, ns) {
+		return nil
+	}
+
+	secretNames := []string{}
+	if awsAccount.Status.LoginProfileCreated {
+		secretNames = append(secretNames, "aws-login")
+	}
+	if awsAccount.Status.AccessKeyCreated {
+		secretNames = append(secretNames, "aws-credentials")
+	}
+	if awsAccount.Status.HostedZone != nil {
+		secretNames = append(secretNames, hostedZoneSecretName)
+	}
+	for _, secretName := range secretNames {
+		secret := &v1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, secret)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err != nil || secret.Annotations[ownerAnnotationKey] != "" {
+			continue
+		}
+		patch := client.MergeFrom(secret.DeepCopy())
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		secret.Labels[managedByLabelKey] = managedByLabelValue
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[ownerAnnotationKey] = objectOwner(awsAccount)
+		if err := r.Patch(ctx, secret, patch); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("adopted Secret", "namespace", namespace, "secret", secretName)
+	}
+	return nil
+}
+
+func (r *AwsAccountReconciler) createNamespace(ctx context.Context, awsAccount *kuadrav1.AwsAccount, namespace string) error {
+	ns := &v1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Namespace",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+	}
+	setNamespaceMetadata(awsAccount, ns)
+	return client.IgnoreAlreadyExists(r.Create(ctx, ns))
+}
+
+func (r *AwsAccountReconciler) updateNamespaceMetadata(ctx context.Context, awsAccount *kuadrav1.AwsAccount, ns *v1.Namespace) error {
+	patch := client.MergeFrom(ns.DeepCopy())
+	if !setNamespaceMetadata(awsAccount, ns) {
+		return nil
+	}
+	return r.Patch(ctx, ns, patch)
+}
+
+// setNamespaceMetadata adds the spec labels and annotations along with the ownership markers,
+// and reports whether anything changed
+func setNamespaceMetadata(awsAccount *kuadrav1.AwsAccount, ns *v1.Namespace) bool {
+	labels := map[string]string{}
+	annotations := map[string]string{}
+	if spec := awsAccount.Spec.Namespace; spec != nil {
+		for key, value := range spec.Labels {
+			labels[key] = value
+		}
+		for key, value := range spec.Annotations {
+			annotations[key] = value
+		}
+	}
+	labels[managedByLabelKey] = managedByLabelValue
+	annotations[ownerAnnotationKey] = awsAccount.Namespace + "/" + awsAccount.Name
+
+	changed := false
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	for key, value := range labels {
+		if current, exists := ns.Labels[key]; !exists || current != value {
+			ns.Labels[key] = value
+			changed = true
+		}
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		if current, exists := ns.Annotations[key]; !exists || current != value {
+			ns.Annotations[key] = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// moveSecrets copies the AwsAccount's Secrets to a new namespace and deletes the originals
+func (r *AwsAccountReconciler) moveSecrets(ctx context.Context, awsAccount *kuadrav1.AwsAccount, fromNamespace string, toNamespace string) error {
+	for _, secretName := range awsAccountSecretNames {
+		secret := &v1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: fromNamespace}, secret)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err != nil || secret.Annotations[ownerAnnotationKey] != objectOwner(awsAccount) {
+			continue
+		}
+		data := map[string]string{}
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		for key, value := range secret.StringData {
+			data[key] = value
+		}
+		if err := r.createOrUpdateSecret(ctx, awsAccount, data, secretName, toNamespace); err != nil {
+			return err
+		}
+		if err := deleteOwnedSecret(ctx, r.Client, awsAccount, secretName, fromNamespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *AwsAccountReconciler) isNamespace(ctx context.Context, namespace string) (bool, error) {
+	ns := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace, Namespace: v1.NamespaceAll}, ns); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
+// deleteNamespace deletes the namespace when kuadra created it for the AwsAccount,
+// otherwise only the AwsAccount's Secrets and namespace template objects are deleted from it
+func (r *AwsAccountReconciler) deleteNamespace(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	namespace := secretNamespace(awsAccount)
+	ns := &v1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace, Namespace: v1.NamespaceAll}, ns); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if (isOwnedNamespace(awsAccount, ns) || isLegacyNamespace(awsAccount, ns)) && namespace != awsAccount.Namespace {
+		return r.Delete(ctx, ns)
+	}
+	for _, secretName := range awsAccountSecretNames {
+		if err := deleteOwnedSecret(ctx, r.Client, awsAccount, secretName, namespace); err != nil {
+			return err
+		}
+	}
+	return r.pruneTemplateObjects(ctx, awsAccount, namespace, nil)
+}
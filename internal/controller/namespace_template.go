@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+// namespaceTemplateObjectName names the ResourceQuota and LimitRange rendered from a NamespaceTemplate
+const namespaceTemplateObjectName = "kuadra"
+
+// templateObject is an object rendered from a NamespaceTemplate, with the function setting its desired state
+type templateObject struct {
+	object client.Object
+	mutate func()
+}
+
+// namespaceTemplateName returns the NamespaceTemplate referenced by the AwsAccount, if any
+func namespaceTemplateName(awsAccount *kuadrav1.AwsAccount) string {
+	if awsAccount.Spec.Namespace == nil {
+		return ""
+	}
+	return awsAccount.Spec.Namespace.Template
+}
+
+// kubernetesUser returns the user's Kubernetes identity bound by the template's RoleBindings
+func kubernetesUser(awsAccount *kuadrav1.AwsAccount) string {
+	if awsAccount.Spec.Namespace != nil && awsAccount.Spec.Namespace.KubernetesUser != "" {
+		return awsAccount.Spec.Namespace.KubernetesUser
+	}
+	return awsAccount.Spec.UserName
+}
+
+// renderNamespaceTemplate returns the objects of the template for the given namespace
+func renderNamespaceTemplate(awsAccount *kuadrav1.AwsAccount, template *kuadrav1.NamespaceTemplate, namespace string) []templateObject {
+	if template == nil {
+		return nil
+	}
+	var objects []templateObject
+
+	for i := range template.Spec.RoleBindings {
+		roleBindingTemplate := template.Spec.RoleBindings[i]
+		roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: roleBindingTemplate.Name, Namespace: namespace}}
+		objects = append(objects, templateObject{object: roleBinding, mutate: func() {
+			roleBinding.RoleRef = roleBindingTemplate.RoleRef
+			roleBinding.Subjects = []rbacv1.Subject{{
+				Kind:     rbacv1.UserKind,
+				APIGroup: rbacv1.GroupName,
+				Name:     kubernetesUser(awsAccount),
+			}}
+		}})
+	}
+
+	if template.Spec.ResourceQuota != nil {
+		resourceQuota := &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Name: namespaceTemplateObjectName, Namespace: namespace}}
+		objects = append(objects, templateObject{object: resourceQuota, mutate: func() {
+			resourceQuota.Spec = *template.Spec.ResourceQuota.DeepCopy()
+		}})
+	}
+
+	if template.Spec.LimitRange != nil {
+		limitRange := &v1.LimitRange{ObjectMeta: metav1.ObjectMeta{Name: namespaceTemplateObjectName, Namespace: namespace}}
+		objects = append(objects, templateObject{object: limitRange, mutate: func() {
+			limitRange.Spec = *template.Spec.LimitRange.DeepCopy()
+		}})
+	}
+
+	for i := range template.Spec.NetworkPolicies {
+		networkPolicyTemplate := template.Spec.NetworkPolicies[i]
+		networkPolicy := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: networkPolicyTemplate.Name, Namespace: namespace}}
+		objects = append(objects, templateObject{object: networkPolicy, mutate: func() {
+			networkPolicy.Spec = *networkPolicyTemplate.Spec.DeepCopy()
+		}})
+	}
+
+	return objects
+}
+
+// templateObjectKey identifies a rendered object by its type and name
+func templateObjectKey(object client.Object) string {
+	return fmt.Sprintf("%T/%s", object, object.GetName())
+}
+
+// reconcileNamespaceTemplate renders the objects of spec.namespace.template into the AwsAccount's
+// namespace, reverting any drift, and deletes the objects the template no longer contains
+func (r *AwsAccountReconciler) reconcileNamespaceTemplate(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
+	log := log.FromContext(ctx)
+	namespace := awsAccount.Status.Namespace
+
+	var template *kuadrav1.NamespaceTemplate
+	if name := namespaceTemplateName(awsAccount); name != "" {
+		template = &kuadrav1.NamespaceTemplate{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name}, template); err != nil {
+			return fmt.Errorf("unable to get NamespaceTemplate %s: %w", name, err)
+		}
+		if err := r.validateTemplateRoles(template); err != nil {
+			return err
+		}
+	}
+
+	desired := map[string]bool{}
+	for _, rendered := range renderNamespaceTemplate(awsAccount, template, namespace) {
+		if err := r.applyTemplateObject(ctx, awsAccount, rendered); err != nil {
+			log.Error(err, "unable to apply namespace template object", "name", rendered.object.GetName())
+			return err
+		}
+		desired[templateObjectKey(rendered.object)] = true
+	}
+	return r.pruneTemplateObjects(ctx, awsAccount, namespace, desired)
+}
+
+// validateTemplateRoles refuses templates whose RoleBindings reference a Role, or a ClusterRole that is
+// not in NamespaceTemplateClusterRoles, as the controller is only granted bind on those ClusterRoles
+func (r *AwsAccountReconciler) validateTemplateRoles(template *kuadrav1.NamespaceTemplate) error {
+	for _, roleBinding := range template.Spec.RoleBindings {
+		if roleBinding.RoleRef.Kind != "ClusterRole" || !slice.Contains(r.NamespaceTemplateClusterRoles, roleBinding.RoleRef.Name) {
+			return fmt.Errorf("%w: %s %s of NamespaceTemplate %s cannot be bound by namespace templates",
+				errNotAllowed, roleBinding.RoleRef.Kind, roleBinding.RoleRef.Name, template.Name)
+		}
+	}
+	return nil
+}
+
+// applyTemplateObject creates or updates a rendered object, refusing to take over an object of the same
+// name that kuadra did not render for the AwsAccount
+func (r *AwsAccountReconciler) applyTemplateObject(ctx context.Context, awsAccount *kuadrav1.AwsAccount, rendered templateObject) error {
+	owner := objectOwner(awsAccount)
+	notManaged := fmt.Errorf("%w: %s %s/%s exists and was not rendered by kuadra for this AwsAccount",
+		errNotAllowed, templateObjectKind(rendered.object), rendered.object.GetNamespace(), rendered.object.GetName())
+
+	// The role of a RoleBinding cannot be changed, so the RoleBinding is replaced instead
+	if roleBinding, ok := rendered.object.(*rbacv1.RoleBinding); ok {
+		existing := &rbacv1.RoleBinding{}
+		err := r.Get(ctx, client.ObjectKeyFromObject(roleBinding), existing)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		rendered.mutate()
+		if err == nil && existing.Annotations[ownerAnnotationKey] != owner {
+			return notManaged
+		}
+		if err == nil && existing.RoleRef != roleBinding.RoleRef {
+			if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, rendered.object, func() error {
+		if rendered.object.GetResourceVersion() != "" && rendered.object.GetAnnotations()[ownerAnnotationKey] != owner {
+			return notManaged
+		}
+		rendered.mutate()
+		labels := rendered.object.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[managedByLabelKey] = managedByLabelValue
+		rendered.object.SetLabels(labels)
+		annotations := rendered.object.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[ownerAnnotationKey] = owner
+		rendered.object.SetAnnotations(annotations)
+		// Owner references cannot cross namespaces, the objects in a namespace created for the
+		// AwsAccount are garbage collected with the namespace instead
+		if rendered.object.GetNamespace() == awsAccount.Namespace {
+			return controllerutil.SetControllerReference(awsAccount, rendered.object, r.Scheme)
+		}
+		return nil
+	})
+	return err
+}
+
+// templateObjectKind names the kind of a rendered object in errors
+func templateObjectKind(object client.Object) string {
+	switch object.(type) {
+	case *rbacv1.RoleBinding:
+		return "RoleBinding"
+	case *v1.ResourceQuota:
+		return "ResourceQuota"
+	case *v1.LimitRange:
+		return "LimitRange"
+	case *networkingv1.NetworkPolicy:
+		return "NetworkPolicy"
+	default:
+		return fmt.Sprintf("%T", object)
+	}
+}
+
+// pruneTemplateObjects deletes the objects rendered for the AwsAccount that are not desired anymore
+func (r *AwsAccountReconciler) pruneTemplateObjects(ctx context.Context, awsAccount *kuadrav1.AwsAccount, namespace string, desired map[string]bool) error {
+	for _, list := range []client.ObjectList{
+		&rbacv1.RoleBindingList{},
+		&v1.ResourceQuotaList{},
+		&v1.LimitRangeList{},
+		&networkingv1.NetworkPolicyList{},
+	} {
+		if err := r.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{managedByLabelKey: managedByLabelValue}); err != nil {
+			return err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			object, ok := item.(client.Object)
+			if !ok || object.GetAnnotations()[ownerAnnotationKey] != objectOwner(awsAccount) {
+				continue
+			}
+			if desired[templateObjectKey(object)] {
+				continue
+			}
+			if err := r.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			log.FromContext(ctx).V(1).Info("deleted namespace template object", "name", object.GetName(), "kind", fmt.Sprintf("%T", object))
+		}
+	}
+	return nil
+}
+
+// awsAccountForTemplateObject maps an object rendered from a NamespaceTemplate to the AwsAccount owning it,
+// so that drift is reverted
+func (r *AwsAccountReconciler) awsAccountForTemplateObject(object client.Object) []reconcile.Request {
+	if object.GetLabels()[managedByLabelKey] != managedByLabelValue {
+		return nil
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(object.GetAnnotations()[ownerAnnotationKey])
+	if err != nil || name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
+
+// awsAccountsForNamespaceTemplate maps a NamespaceTemplate to the AwsAccounts using it
+func (r *AwsAccountReconciler) awsAccountsForNamespaceTemplate(template client.Object) []reconcile.Request {
+	var awsAccounts kuadrav1.AwsAccountList
+	if err := r.List(context.Background(), &awsAccounts); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range awsAccounts.Items {
+		if namespaceTemplateName(&awsAccounts.Items[i]) == template.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&awsAccounts.Items[i])})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,16 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/Kuadrant/kuadra/pkg/quay"
+)
+
+type QuayWrapper interface {
+	AddTeamMember(ctx context.Context, team string, userName string) error
+	RemoveTeamMemberIfExists(ctx context.Context, team string, userName string) error
+	CreateRobotIfNotExists(ctx context.Context, shortName string, description string) (*quay.Robot, error)
+	DeleteRobotIfExists(ctx context.Context, shortName string) error
+	SetRepositoryPermission(ctx context.Context, repository string, robotName string, role string) error
+	DeleteRepositoryPermissionIfExists(ctx context.Context, repository string, robotName string) error
+}
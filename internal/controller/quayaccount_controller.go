@@ -0,0 +1,328 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+	"github.com/Kuadrant/kuadra/pkg/quay"
+)
+
+const (
+	QuayAccountFinalizer = "kuadra.kuadrant.io/quay-account"
+
+	// quaySecretName names the dockerconfigjson Secret holding the robot account's credentials
+	quaySecretName = "quay-credentials"
+)
+
+// QuayAccountReconciler reconciles a QuayAccount object
+type QuayAccountReconciler struct {
+	client.Client
+	Scheme      *runtime.Scheme
+	QuayWrapper QuayWrapper
+	Recorder    record.EventRecorder
+
+	// Registry is the registry host the robot account's credentials are written for, e.g. quay.io
+	Registry string
+}
+
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=quayaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=quayaccounts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=quayaccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accesspolicies,verbs=get;list;watch
+
+// Reconcile adds the user to the Quay organization teams in the spec and creates a robot account
+// that can push to the repositories in the spec, storing its credentials in a dockerconfigjson Secret
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *QuayAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var quayAccount kuadrav1.QuayAccount
+	if err := r.Get(ctx, req.NamespacedName, &quayAccount); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if quayAccount.DeletionTimestamp != nil && !quayAccount.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(&quayAccount, QuayAccountFinalizer) {
+			if err := r.finalizeQuayAccount(ctx, &quayAccount); err != nil {
+				log.Error(err, "Failed to remove Quay memberships", "username", quayAccount.Spec.Username)
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(&quayAccount, QuayAccountFinalizer)
+			if err := r.Update(ctx, &quayAccount); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&quayAccount, QuayAccountFinalizer) {
+		controllerutil.AddFinalizer(&quayAccount, QuayAccountFinalizer)
+		if err := r.Update(ctx, &quayAccount); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	denied, err := serviceDenied(ctx, r.Client, quayAccount.Namespace, kuadrav1.ServiceQuay)
+	if err != nil {
+		log.Error(err, "unable to evaluate AccessPolicies")
+		return ctrl.Result{}, err
+	}
+
+	var reconcileErr error
+	if quayAccount.Spec.Suspended {
+		if reconcileErr = r.suspendQuayAccount(ctx, &quayAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to suspend Quay memberships", "username", quayAccount.Spec.Username)
+		}
+		setSuspendedConditions(r.Recorder, &quayAccount, &quayAccount.Status.Conditions, quayAccount.Generation, reconcileErr)
+	} else if denied {
+		if reconcileErr = r.suspendQuayAccount(ctx, &quayAccount); reconcileErr != nil {
+			log.Error(reconcileErr, "unable to remove Quay memberships", "username", quayAccount.Spec.Username)
+		}
+		setServiceDeniedConditions(&quayAccount.Status.Conditions, quayAccount.Generation, quayAccount.Namespace, kuadrav1.ServiceQuay, reconcileErr)
+	} else {
+		reconcileErr = r.reconcileQuayAccount(ctx, &quayAccount)
+		setReadyCondition(&quayAccount.Status.Conditions, quayAccount.Generation, []string{
+			kuadrav1.ConditionTeamsSynced,
+			kuadrav1.ConditionRobotAccountReady,
+			kuadrav1.ConditionRepositoriesSynced,
+		}, reconcileErr)
+		if reconcileErr == nil {
+			setReactivatedCondition(r.Recorder, &quayAccount, &quayAccount.Status.Conditions, quayAccount.Generation)
+		}
+	}
+	quayAccount.Status.ObservedGeneration = quayAccount.Generation
+
+	var latest kuadrav1.QuayAccount
+	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, quayAccount.Status) {
+		if err := r.Status().Update(ctx, &quayAccount); err != nil {
+			log.Error(err, "unable to update QuayAccount status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+		}
+	}
+
+	return ctrl.Result{}, reconcileErr
+}
+
+// quayRobotShortName names the user's robot account within the organization
+func quayRobotShortName(quayAccount *kuadrav1.QuayAccount) string {
+	return "kuadra_" + quayAccount.Spec.Username
+}
+
+// quaySecretNamespace returns the namespace the spec asks for the robot account's Secret
+func quaySecretNamespace(quayAccount *kuadrav1.QuayAccount) string {
+	if quayAccount.Spec.SecretNamespace != "" {
+		return quayAccount.Spec.SecretNamespace
+	}
+	return quayAccount.Namespace
+}
+
+// reconcileQuayAccount moves the user's teams and robot account towards the spec,
+// recording a condition for each on the QuayAccount status
+func (r *QuayAccountReconciler) reconcileQuayAccount(ctx context.Context, quayAccount *kuadrav1.QuayAccount) error {
+	log := log.FromContext(ctx)
+	conditions := &quayAccount.Status.Conditions
+	generation := quayAccount.Generation
+
+	if err := r.reconcileTeams(ctx, quayAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionTeamsSynced, err)
+		return err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionTeamsSynced, "User is a member of every team in the spec")
+
+	robot, err := r.QuayWrapper.CreateRobotIfNotExists(ctx, quayRobotShortName(quayAccount), "Created by kuadra for "+quayAccount.Namespace+"/"+quayAccount.Name)
+	if err != nil {
+		log.Error(err, "unable to create robot account")
+		setConditionFromError(conditions, generation, kuadrav1.ConditionRobotAccountReady, err)
+		return err
+	}
+	quayAccount.Status.Robot = robot.Name
+	if err := r.reconcileRobotSecret(ctx, quayAccount, robot); err != nil {
+		log.Error(err, "unable to write robot account Secret", "namespace", quaySecretNamespace(quayAccount))
+		setConditionFromError(conditions, generation, kuadrav1.ConditionRobotAccountReady, err)
+		if errors.Is(err, errSecretNotManaged) {
+			r.Recorder.Event(quayAccount, v1.EventTypeWarning, "SecretNotManaged", err.Error())
+		}
+		return err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionRobotAccountReady, "Robot account "+robot.Name+" credentials are stored in Secret "+quaySecretName)
+
+	if err := r.reconcileRepositories(ctx, quayAccount); err != nil {
+		setConditionFromError(conditions, generation, kuadrav1.ConditionRepositoriesSynced, err)
+		return err
+	}
+	setConditionTrue(conditions, generation, kuadrav1.ConditionRepositoriesSynced, "Robot account can push to every repository in the spec")
+	return nil
+}
+
+// reconcileTeams adds the user to the teams in the spec and removes them from the teams dropped from it
+func (r *QuayAccountReconciler) reconcileTeams(ctx context.Context, quayAccount *kuadrav1.QuayAccount) error {
+	log := log.FromContext(ctx)
+	username := quayAccount.Spec.Username
+
+	for _, team := range slice.GetLeftDifference(quayAccount.Spec.Teams, quayAccount.Status.Teams) {
+		if err := r.QuayWrapper.AddTeamMember(ctx, team, username); err != nil {
+			log.Error(err, "unable to add user to team", "team", team)
+			return err
+		}
+		log.V(1).Info("added user to team", "team", team)
+		quayAccount.Status.Teams = append(quayAccount.Status.Teams, team)
+	}
+
+	for _, team := range slice.GetLeftDifference(quayAccount.Status.Teams, quayAccount.Spec.Teams) {
+		if err := r.QuayWrapper.RemoveTeamMemberIfExists(ctx, team, username); err != nil {
+			log.Error(err, "unable to remove user from team", "team", team)
+			return err
+		}
+		log.V(1).Info("removed user from team", "team", team)
+		quayAccount.Status.Teams = slice.Remove(quayAccount.Status.Teams, func(t string) bool { return t == team })
+	}
+	return nil
+}
+
+// reconcileRepositories grants the robot account write access to the repositories in the spec
+// and revokes it on the repositories dropped from it
+func (r *QuayAccountReconciler) reconcileRepositories(ctx context.Context, quayAccount *kuadrav1.QuayAccount) error {
+	log := log.FromContext(ctx)
+	robot := quayAccount.Status.Robot
+
+	for _, repository := range slice.GetLeftDifference(quayAccount.Spec.Repositories, quayAccount.Status.Repositories) {
+		if err := r.QuayWrapper.SetRepositoryPermission(ctx, repository, robot, quay.RoleWrite); err != nil {
+			log.Error(err, "unable to grant robot account push access", "repository", repository)
+			return err
+		}
+		log.V(1).Info("granted robot account push access", "repository", repository)
+		quayAccount.Status.Repositories = append(quayAccount.Status.Repositories, repository)
+	}
+
+	for _, repository := range slice.GetLeftDifference(quayAccount.Status.Repositories, quayAccount.Spec.Repositories) {
+		if err := r.QuayWrapper.DeleteRepositoryPermissionIfExists(ctx, repository, robot); err != nil {
+			log.Error(err, "unable to revoke robot account push access", "repository", repository)
+			return err
+		}
+		log.V(1).Info("revoked robot account push access", "repository", repository)
+		quayAccount.Status.Repositories = slice.Remove(quayAccount.Status.Repositories, func(repo string) bool { return repo == repository })
+	}
+	return nil
+}
+
+// dockerConfigJson returns the .dockerconfigjson of the robot account's credentials for the registry
+func dockerConfigJson(registry string, robot *quay.Robot) ([]byte, error) {
+	auth := base64.StdEncoding.EncodeToString([]byte(robot.Name + ":" + robot.Token))
+	return json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			registry: map[string]string{
+				"username": robot.Name,
+				"password": robot.Token,
+				"auth":     auth,
+			},
+		},
+	})
+}
+
+// reconcileRobotSecret writes the robot account's credentials to the Secret namespace, moving the
+// Secret when the namespace changes
+func (r *QuayAccountReconciler) reconcileRobotSecret(ctx context.Context, quayAccount *kuadrav1.QuayAccount, robot *quay.Robot) error {
+	namespace := quaySecretNamespace(quayAccount)
+	dockerConfig, err := dockerConfigJson(r.Registry, robot)
+	if err != nil {
+		return err
+	}
+
+	err = applyOwnedSecret(ctx, r.Client, r.Scheme, quayAccount, quaySecretName, namespace, func(secret *v1.Secret) {
+		secret.Type = v1.SecretTypeDockerConfigJson
+		secret.Data = map[string][]byte{v1.DockerConfigJsonKey: dockerConfig}
+	})
+	if err != nil {
+		return err
+	}
+
+	if previous := quayAccount.Status.SecretNamespace; previous != "" && previous != namespace {
+		if err := deleteOwnedSecret(ctx, r.Client, quayAccount, quaySecretName, previous); err != nil {
+			return err
+		}
+	}
+	quayAccount.Status.SecretNamespace = namespace
+	return nil
+}
+
+// finalizeQuayAccount deletes the robot account and its Secret, and removes the user from the
+// teams kuadra added them to
+func (r *QuayAccountReconciler) finalizeQuayAccount(ctx context.Context, quayAccount *kuadrav1.QuayAccount) error {
+	for _, team := range quayAccount.Status.Teams {
+		if err := r.QuayWrapper.RemoveTeamMemberIfExists(ctx, team, quayAccount.Spec.Username); err != nil {
+			return err
+		}
+	}
+	if quayAccount.Status.Robot != "" {
+		if err := r.QuayWrapper.DeleteRobotIfExists(ctx, quayRobotShortName(quayAccount)); err != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("deleted robot account", "robot", quayAccount.Status.Robot)
+	}
+	if quayAccount.Status.SecretNamespace != "" {
+		return deleteOwnedSecret(ctx, r.Client, quayAccount, quaySecretName, quayAccount.Status.SecretNamespace)
+	}
+	return nil
+}
+
+// suspendQuayAccount removes the team memberships and the robot account as on deletion, so that
+// they are created again with new credentials once the account is no longer suspended
+func (r *QuayAccountReconciler) suspendQuayAccount(ctx context.Context, quayAccount *kuadrav1.QuayAccount) error {
+	if err := r.finalizeQuayAccount(ctx, quayAccount); err != nil {
+		return err
+	}
+	quayAccount.Status.Teams = nil
+	quayAccount.Status.Robot = ""
+	quayAccount.Status.Repositories = nil
+	quayAccount.Status.SecretNamespace = ""
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *QuayAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kuadrav1.QuayAccount{}).
+		Owns(&v1.Secret{}).
+		Watches(&source.Kind{Type: &kuadrav1.AccessPolicy{}}, handler.EnqueueRequestsFromMapFunc(accountsForAccessPolicy(r.Client, &kuadrav1.QuayAccountList{}))).
+		Complete(r)
+}
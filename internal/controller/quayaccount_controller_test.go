@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	"github.com/Kuadrant/kuadra/pkg/quay"
+)
+
+var _ = Describe("QuayAccount controller", func() {
+
+	ctx := context.Background()
+
+	newQuayAccount := func() *kuadrav1.QuayAccount {
+		return &kuadrav1.QuayAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default"},
+			Spec: kuadrav1.QuayAccountSpec{
+				Username:        "jdoe",
+				Teams:           []string{"developers"},
+				Repositories:    []string{"kuadra", "kuadra-operator"},
+				SecretNamespace: "jdoe",
+			},
+		}
+	}
+
+	Context("When reconciling a QuayAccount", func() {
+		It("Should create a robot account with push rights and store its credentials", func() {
+			quayAccount := newQuayAccount()
+			lookupKey := k8Types.NamespacedName{Name: quayAccount.Name, Namespace: quayAccount.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(quayAccount).Build()
+			quayWrapper := newMockQuayWrapper()
+			r := &QuayAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, QuayWrapper: quayWrapper, Recorder: record.NewFakeRecorder(10), Registry: "quay.io"}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quayWrapper.TeamMembers).Should(HaveKeyWithValue("developers", []string{"jdoe"}))
+			Expect(quayWrapper.Robots).Should(HaveKey("kuadra_jdoe"))
+			Expect(quayWrapper.Permissions).Should(Equal(map[string]string{
+				"kuadra/kuadrant+kuadra_jdoe":          quay.RoleWrite,
+				"kuadra-operator/kuadrant+kuadra_jdoe": quay.RoleWrite,
+			}))
+
+			reconciled := &kuadrav1.QuayAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Robot).Should(Equal("kuadrant+kuadra_jdoe"))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: quaySecretName, Namespace: "jdoe"}, secret)).Should(Succeed())
+			Expect(secret.Type).Should(Equal(corev1.SecretTypeDockerConfigJson))
+			var dockerConfig struct {
+				Auths map[string]struct {
+					Username string `json:"username"`
+					Password string `json:"password"`
+				} `json:"auths"`
+			}
+			Expect(json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &dockerConfig)).Should(Succeed())
+			Expect(dockerConfig.Auths["quay.io"].Username).Should(Equal("kuadrant+kuadra_jdoe"))
+			Expect(dockerConfig.Auths["quay.io"].Password).Should(Equal("token-kuadra_jdoe"))
+
+			By("By dropping a repository and moving the Secret")
+			reconciled.Spec.Repositories = []string{"kuadra"}
+			reconciled.Spec.SecretNamespace = ""
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quayWrapper.Permissions).ShouldNot(HaveKey("kuadra-operator/kuadrant+kuadra_jdoe"))
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: quaySecretName, Namespace: "jdoe"}, secret)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: quaySecretName, Namespace: "default"}, secret)).Should(Succeed())
+			Expect(metav1.IsControlledBy(secret, reconciled)).Should(BeTrue())
+
+			By("By deleting the QuayAccount")
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(quayWrapper.Robots).ShouldNot(HaveKey("kuadra_jdoe"))
+			Expect(quayWrapper.TeamMembers["developers"]).Should(BeEmpty())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: quaySecretName, Namespace: "default"}, secret)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should not overwrite a Secret it did not write", func() {
+			quayAccount := newQuayAccount()
+			lookupKey := k8Types.NamespacedName{Name: quayAccount.Name, Namespace: quayAccount.Namespace}
+			existing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: quaySecretName, Namespace: "jdoe"}, Data: map[string][]byte{"key": []byte("value")}}
+			k8sClient := fake.NewClientBuilder().WithObjects(quayAccount, existing).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &QuayAccountReconciler{Client: k8sClient, Scheme: scheme.Scheme, QuayWrapper: newMockQuayWrapper(), Recorder: recorder, Registry: "quay.io"}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(errSecretNotManaged))
+			Expect(recorder.Events).Should(Receive(ContainSubstring("SecretNotManaged")))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(existing), secret)).Should(Succeed())
+			Expect(secret.Data).Should(HaveKeyWithValue("key", []byte("value")))
+
+			reconciled := &kuadrav1.QuayAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionFalse(reconciled.Status.Conditions, kuadrav1.ConditionRobotAccountReady)).Should(BeTrue())
+		})
+	})
+})
+
+type mockQuayWrapper struct {
+	TeamMembers map[string][]string
+	Robots      map[string]quay.Robot
+	Permissions map[string]string
+}
+
+func newMockQuayWrapper() *mockQuayWrapper {
+	return &mockQuayWrapper{
+		TeamMembers: map[string][]string{},
+		Robots:      map[string]quay.Robot{},
+		Permissions: map[string]string{},
+	}
+}
+
+func (c *mockQuayWrapper) AddTeamMember(ctx context.Context, team string, userName string) error {
+	c.TeamMembers[team] = append(c.TeamMembers[team], userName)
+	return nil
+}
+
+func (c *mockQuayWrapper) RemoveTeamMemberIfExists(ctx context.Context, team string, userName string) error {
+	var members []string
+	for _, member := range c.TeamMembers[team] {
+		if member != userName {
+			members = append(members, member)
+		}
+	}
+	c.TeamMembers[team] = members
+	return nil
+}
+
+func (c *mockQuayWrapper) CreateRobotIfNotExists(ctx context.Context, shortName string, description string) (*quay.Robot, error) {
+	robot, exists := c.Robots[shortName]
+	if !exists {
+		robot = quay.Robot{Name: "kuadrant+" + shortName, Token: "token-" + shortName}
+		c.Robots[shortName] = robot
+	}
+	return &robot, nil
+}
+
+func (c *mockQuayWrapper) DeleteRobotIfExists(ctx context.Context, shortName string) error {
+	delete(c.Robots, shortName)
+	return nil
+}
+
+func (c *mockQuayWrapper) SetRepositoryPermission(ctx context.Context, repository string, robotName string, role string) error {
+	c.Permissions[repository+"/"+robotName] = role
+	return nil
+}
+
+func (c *mockQuayWrapper) DeleteRepositoryPermissionIfExists(ctx context.Context, repository string, robotName string) error {
+	delete(c.Permissions, repository+"/"+robotName)
+	return nil
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// errSecretNotManaged is returned when a Secret would overwrite a Secret kuadra did not write
+var errSecretNotManaged = errors.New("Secret exists and is not managed by kuadra")
+
+// objectOwner identifies an object in the owner annotation of the objects created for it
+func objectOwner(owner client.Object) string {
+	return owner.GetNamespace() + "/" + owner.GetName()
+}
+
+// applyOwnedSecret creates or updates a Secret written for the owner, refusing to touch a Secret of the
+// same name that the owner did not write. The Secret is controlled by the owner when they share a
+// namespace and a scheme is given, otherwise it has to be deleted with deleteOwnedSecret.
+func applyOwnedSecret(ctx context.Context, c client.Client, scheme *runtime.Scheme, owner client.Object, name string, namespace string, mutate func(secret *v1.Secret)) error {
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, c, secret, func() error {
+		if secret.ResourceVersion != "" && secret.Annotations[ownerAnnotationKey] != objectOwner(owner) {
+			return fmt.Errorf("%w: %s/%s", errSecretNotManaged, namespace, name)
+		}
+		mutate(secret)
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		secret.Labels[managedByLabelKey] = managedByLabelValue
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[ownerAnnotationKey] = objectOwner(owner)
+		if scheme != nil && namespace == owner.GetNamespace() {
+			return controllerutil.SetControllerReference(owner, secret, scheme)
+		}
+		return nil
+	})
+	return err
+}
+
+// deleteOwnedSecret deletes a Secret from the namespace when it was written for the owner
+func deleteOwnedSecret(ctx context.Context, c client.Client, owner client.Object, name string, namespace string) error {
+	secret := &v1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if secret.Annotations[ownerAnnotationKey] != objectOwner(owner) {
+		return nil
+	}
+	return client.IgnoreNotFound(c.Delete(ctx, secret))
+}
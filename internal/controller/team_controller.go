@@ -0,0 +1,347 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+const (
+	TeamFinalizer = "kuadra.kuadrant.io/team"
+
+	// teamAnnotationKey marks the shared namespaces and RoleBindings created for a Team
+	teamAnnotationKey = "kuadra.kuadrant.io/team"
+)
+
+// TeamReconciler reconciles a Team object
+type TeamReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// ClusterRoles are the ClusterRoles that Teams may bind in their namespaces
+	ClusterRoles []string
+}
+
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=teams,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=teams/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=teams/finalizers,verbs=update
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=users,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=edit;view
+
+// Reconcile binds the members of a Team to the team's ClusterRole in each of its shared
+// namespaces. The team's IAM groups and policies are applied to the members' AwsAccounts
+// by the UserReconciler.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *TeamReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	team := kuadrav1.Team{}
+	if err := r.Get(ctx, req.NamespacedName, &team); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if team.DeletionTimestamp != nil && !team.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(&team, TeamFinalizer) {
+			if err := r.pruneTeamNamespaces(ctx, &team, nil); err != nil {
+				log.Error(err, "unable to clean up Team namespaces")
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(&team, TeamFinalizer)
+			if err := r.Update(ctx, &team); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(&team, TeamFinalizer) {
+		controllerutil.AddFinalizer(&team, TeamFinalizer)
+		if err := r.Update(ctx, &team); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	reconcileErr := r.reconcileTeam(ctx, &team)
+	if reconcileErr != nil {
+		setConditionFromError(&team.Status.Conditions, team.Generation, kuadrav1.ConditionReady, reconcileErr)
+	} else {
+		setConditionTrue(&team.Status.Conditions, team.Generation, kuadrav1.ConditionReady, "Members have access to the team namespaces")
+	}
+	team.Status.ObservedGeneration = team.Generation
+
+	var latest kuadrav1.Team
+	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, team.Status) {
+		if err := r.Status().Update(ctx, &team); err != nil {
+			log.Error(err, "unable to update Team status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
+		}
+	}
+
+	return ctrl.Result{}, reconcileErr
+}
+
+func (r *TeamReconciler) reconcileTeam(ctx context.Context, team *kuadrav1.Team) error {
+	members, err := teamMembers(ctx, r.Client, team)
+	if err != nil {
+		return err
+	}
+	team.Status.Members = nil
+	for _, member := range members {
+		team.Status.Members = append(team.Status.Members, member.Name)
+	}
+
+	desired := map[string]bool{}
+	for _, teamNamespace := range team.Spec.Namespaces {
+		if err := r.reconcileTeamNamespace(ctx, team, teamNamespace, members); err != nil {
+			return err
+		}
+		desired[teamNamespace.Name] = true
+	}
+	if err := r.pruneTeamNamespaces(ctx, team, desired); err != nil {
+		return err
+	}
+
+	team.Status.Namespaces = nil
+	for _, teamNamespace := range team.Spec.Namespaces {
+		team.Status.Namespaces = append(team.Status.Namespaces, teamNamespace.Name)
+	}
+	return nil
+}
+
+// teamOwner identifies a Team in the annotations of the objects created for it
+func teamOwner(team *kuadrav1.Team) string {
+	return team.Namespace + "/" + team.Name
+}
+
+// teamRoleBindingName names the RoleBinding granting a Team access to a shared namespace. Namespace
+// names cannot contain dots, so Teams of the same name in different namespaces get distinct names.
+func teamRoleBindingName(team *kuadrav1.Team) string {
+	return "kuadra-team-" + team.Namespace + "." + team.Name
+}
+
+// reconcileTeamNamespace creates the shared namespace, and binds the members to the team's ClusterRole
+// in it. Suspended and expired members are left out of the binding. ClusterRoles that are not allowed
+// are refused, as are namespaces and RoleBindings that kuadra did not create for the Team.
+func (r *TeamReconciler) reconcileTeamNamespace(ctx context.Context, team *kuadrav1.Team, teamNamespace kuadrav1.TeamNamespace, members []kuadrav1.User) error {
+	clusterRole := teamNamespace.ClusterRole
+	if clusterRole == "" {
+		clusterRole = "edit"
+	}
+	if !slice.Contains(r.ClusterRoles, clusterRole) {
+		return fmt.Errorf("%w: ClusterRole %s cannot be bound by Teams", errNotAllowed, clusterRole)
+	}
+
+	ns := &v1.Namespace{}
+	err := r.Get(ctx, types.NamespacedName{Name: teamNamespace.Name}, ns)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && ns.Annotations[teamAnnotationKey] != teamOwner(team) {
+		return fmt.Errorf("%w: namespace %s was not created by kuadra for this Team", errNotAllowed, teamNamespace.Name)
+	}
+	if err != nil {
+		ns = &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        teamNamespace.Name,
+				Labels:      map[string]string{managedByLabelKey: managedByLabelValue},
+				Annotations: map[string]string{teamAnnotationKey: teamOwner(team)},
+			},
+		}
+		if err := r.Create(ctx, ns); err != nil {
+			return err
+		}
+		log.FromContext(ctx).V(1).Info("created team namespace", "namespace", teamNamespace.Name)
+	}
+
+	roleRef := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: clusterRole}
+	var subjects []rbacv1.Subject
+	now := time.Now()
+	for i := range members {
+		if !userActive(&members[i], now) {
+			continue
+		}
+		subjects = append(subjects, rbacv1.Subject{
+			Kind:     rbacv1.UserKind,
+			APIGroup: rbacv1.GroupName,
+			Name:     userKubernetesUser(&members[i]),
+		})
+	}
+
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: teamRoleBindingName(team), Namespace: teamNamespace.Name}}
+	// The role of a RoleBinding cannot be changed, so the RoleBinding is replaced instead
+	existing := &rbacv1.RoleBinding{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(roleBinding), existing)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && existing.Annotations[teamAnnotationKey] != teamOwner(team) {
+		return fmt.Errorf("%w: RoleBinding %s/%s was not created by kuadra for this Team", errNotAllowed, existing.Namespace, existing.Name)
+	}
+	if err == nil && existing.RoleRef != roleRef {
+		if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+		roleBinding.RoleRef = roleRef
+		roleBinding.Subjects = subjects
+		if roleBinding.Labels == nil {
+			roleBinding.Labels = map[string]string{}
+		}
+		roleBinding.Labels[managedByLabelKey] = managedByLabelValue
+		if roleBinding.Annotations == nil {
+			roleBinding.Annotations = map[string]string{}
+		}
+		roleBinding.Annotations[teamAnnotationKey] = teamOwner(team)
+		return nil
+	})
+	return err
+}
+
+// pruneTeamNamespaces removes the team's access to the namespaces in its status that are not desired
+// anymore, deleting the namespaces the team created
+func (r *TeamReconciler) pruneTeamNamespaces(ctx context.Context, team *kuadrav1.Team, desired map[string]bool) error {
+	for _, namespace := range team.Status.Namespaces {
+		if desired[namespace] {
+			continue
+		}
+		ns := &v1.Namespace{}
+		if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		if ns.Labels[managedByLabelKey] == managedByLabelValue && ns.Annotations[teamAnnotationKey] == teamOwner(team) {
+			if err := r.Delete(ctx, ns); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			log.FromContext(ctx).V(1).Info("deleted team namespace", "namespace", namespace)
+			continue
+		}
+		roleBinding := &rbacv1.RoleBinding{}
+		err := r.Get(ctx, types.NamespacedName{Name: teamRoleBindingName(team), Namespace: namespace}, roleBinding)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil && roleBinding.Annotations[teamAnnotationKey] == teamOwner(team) {
+			if err := r.Delete(ctx, roleBinding); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTeamMember reports whether the User is listed in the Team's members or selected by its member selector
+func isTeamMember(team *kuadrav1.Team, user *kuadrav1.User) bool {
+	if team.Namespace != user.Namespace {
+		return false
+	}
+	for _, member := range team.Spec.Members {
+		if member == user.Name {
+			return true
+		}
+	}
+	if team.Spec.MemberSelector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(team.Spec.MemberSelector)
+	if err != nil || selector.Empty() {
+		return false
+	}
+	return selector.Matches(labels.Set(user.Labels))
+}
+
+// teamMembers returns the Users that are members of the Team, sorted by name
+func teamMembers(ctx context.Context, c client.Client, team *kuadrav1.Team) ([]kuadrav1.User, error) {
+	if team.Spec.MemberSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(team.Spec.MemberSelector); err != nil {
+			return nil, err
+		}
+	}
+	var users kuadrav1.UserList
+	if err := c.List(ctx, &users, client.InNamespace(team.Namespace)); err != nil {
+		return nil, err
+	}
+	var members []kuadrav1.User
+	for i := range users.Items {
+		if users.Items[i].DeletionTimestamp == nil && isTeamMember(team, &users.Items[i]) {
+			members = append(members, users.Items[i])
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members, nil
+}
+
+// userKubernetesUser returns the Kubernetes identity of a User, which is its IAM user name. The
+// kubernetesUser of the AwsAccount spec is ignored, as the User's author could otherwise have the Team
+// bind any identity, such as a ServiceAccount.
+func userKubernetesUser(user *kuadrav1.User) string {
+	if user.Spec.AwsAccount != nil && user.Spec.AwsAccount.Spec.User.UserName != "" {
+		return user.Spec.AwsAccount.Spec.User.UserName
+	}
+	return user.Name
+}
+
+// teamsForUser maps a User to the Teams in its namespace, so that membership changes are picked up
+func (r *TeamReconciler) teamsForUser(user client.Object) []reconcile.Request {
+	var teams kuadrav1.TeamList
+	if err := r.List(context.Background(), &teams, client.InNamespace(user.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range teams.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&teams.Items[i])})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TeamReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&kuadrav1.Team{}).
+		Watches(&source.Kind{Type: &kuadrav1.User{}}, handler.EnqueueRequestsFromMapFunc(r.teamsForUser)).
+		Complete(r)
+}
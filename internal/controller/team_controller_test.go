@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+var _ = Describe("Team controller", func() {
+
+	ctx := context.Background()
+
+	newUser := func(name string, userName string, labels map[string]string) *kuadrav1.User {
+		user := &kuadrav1.User{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+		if userName != "" {
+			user.Spec.AwsAccount = &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{
+				UserName: userName,
+				Groups:   []string{"developers"},
+			}}}
+		}
+		return user
+	}
+
+	newTeam := func() *kuadrav1.Team {
+		return &kuadrav1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "default"},
+			Spec: kuadrav1.TeamSpec{
+				Members:           []string{"alice"},
+				MemberSelector:    &metav1.LabelSelector{MatchLabels: map[string]string{"team": "dns"}},
+				Groups:            []string{"dns-management", "developers"},
+				ManagedPolicyArns: []string{"arn:aws:iam::aws:policy/AmazonRoute53ReadOnlyAccess"},
+				Namespaces:        []kuadrav1.TeamNamespace{{Name: "team-dns", ClusterRole: "edit"}},
+			},
+		}
+	}
+
+	Context("When resolving members", func() {
+		It("Should match Users by name and by label", func() {
+			team := newTeam()
+			Expect(isTeamMember(team, newUser("alice", "", nil))).Should(BeTrue())
+			Expect(isTeamMember(team, newUser("bob", "", map[string]string{"team": "dns"}))).Should(BeTrue())
+			Expect(isTeamMember(team, newUser("carol", "", map[string]string{"team": "web"}))).Should(BeFalse())
+
+			otherNamespace := newUser("alice", "", nil)
+			otherNamespace.Namespace = "other"
+			Expect(isTeamMember(team, otherNamespace)).Should(BeFalse())
+		})
+	})
+
+	Context("When reconciling a Team", func() {
+		It("Should give the members access to the team namespaces", func() {
+			team := newTeam()
+			lookupKey := k8Types.NamespacedName{Name: team.Name, Namespace: team.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(
+				team,
+				newUser("alice", "alice-iam", nil),
+				newUser("bob", "", map[string]string{"team": "dns"}),
+				newUser("carol", "carol-iam", nil),
+			).Build()
+			r := &TeamReconciler{Client: k8sClient, Scheme: scheme.Scheme, ClusterRoles: []string{"edit", "view"}}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			reconciled := &kuadrav1.Team{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Finalizers).Should(ContainElement(TeamFinalizer))
+			Expect(reconciled.Status.Members).Should(Equal([]string{"alice", "bob"}))
+			Expect(reconciled.Status.Namespaces).Should(Equal([]string{"team-dns"}))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+			ns := &v1.Namespace{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "team-dns"}, ns)).Should(Succeed())
+			Expect(ns.Annotations[teamAnnotationKey]).Should(Equal("default/dns"))
+
+			roleBinding := &rbacv1.RoleBinding{}
+			roleBindingKey := k8Types.NamespacedName{Name: "kuadra-team-default.dns", Namespace: "team-dns"}
+			Expect(k8sClient.Get(ctx, roleBindingKey, roleBinding)).Should(Succeed())
+			Expect(roleBinding.RoleRef.Name).Should(Equal("edit"))
+			Expect(roleBinding.Subjects).Should(HaveLen(2))
+			Expect(roleBinding.Subjects[0].Name).Should(Equal("alice-iam"))
+			Expect(roleBinding.Subjects[1].Name).Should(Equal("bob"))
+
+			By("By granting a different role")
+			reconciled.Spec.Namespaces = []kuadrav1.TeamNamespace{{Name: "team-dns", ClusterRole: "view"}}
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, roleBindingKey, roleBinding)).Should(Succeed())
+			Expect(roleBinding.RoleRef.Name).Should(Equal("view"))
+
+			By("By deleting the Team")
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "team-dns"}, ns)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			err = k8sClient.Get(ctx, lookupKey, reconciled)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("When a Team asks for access it cannot be given", func() {
+		reconcileRefused := func(team *kuadrav1.Team, objects ...client.Object) (client.Client, error) {
+			k8sClient := fake.NewClientBuilder().WithObjects(append(objects, team, newUser("alice", "alice-iam", nil))...).Build()
+			r := &TeamReconciler{Client: k8sClient, Scheme: scheme.Scheme, ClusterRoles: []string{"edit", "view"}}
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(team)})
+			reconciled := &kuadrav1.Team{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(team), reconciled)).Should(Succeed())
+			condition := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionReady)
+			Expect(condition).ShouldNot(BeNil())
+			Expect(condition.Reason).Should(Equal(kuadrav1.ReasonNotAllowed))
+			return k8sClient, err
+		}
+
+		It("Should refuse ClusterRoles the controller does not allow", func() {
+			team := newTeam()
+			team.Spec.Namespaces[0].ClusterRole = "cluster-admin"
+			k8sClient, err := reconcileRefused(team)
+			Expect(err).Should(MatchError(ContainSubstring("ClusterRole cluster-admin cannot be bound by Teams")))
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "team-dns"}, &v1.Namespace{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should refuse namespaces it did not create for the Team", func() {
+			team := newTeam()
+			team.Spec.Namespaces = []kuadrav1.TeamNamespace{{Name: "kube-system", ClusterRole: "edit"}}
+			k8sClient, err := reconcileRefused(team, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}})
+			Expect(err).Should(MatchError(ContainSubstring("namespace kube-system was not created by kuadra for this Team")))
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra-team-default.dns", Namespace: "kube-system"}, &rbacv1.RoleBinding{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+
+		It("Should refuse RoleBindings it did not create for the Team", func() {
+			team := newTeam()
+			ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team-dns",
+				Annotations: map[string]string{teamAnnotationKey: "default/dns"},
+			}}
+			foreign := &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "kuadra-team-default.dns", Namespace: "team-dns"},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "lead"}},
+			}
+			k8sClient, err := reconcileRefused(team, ns, foreign)
+			Expect(err).Should(MatchError(ContainSubstring("RoleBinding team-dns/kuadra-team-default.dns was not created by kuadra for this Team")))
+			roleBinding := &rbacv1.RoleBinding{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(foreign), roleBinding)).Should(Succeed())
+			Expect(roleBinding.RoleRef.Name).Should(Equal("admin"))
+			Expect(roleBinding.Subjects).Should(ConsistOf(HaveField("Name", "lead")))
+		})
+	})
+
+	Context("When a member is suspended or expired", func() {
+		It("Should leave them out of the RoleBinding while keeping them a member", func() {
+			team := newTeam()
+			lookupKey := k8Types.NamespacedName{Name: team.Name, Namespace: team.Namespace}
+			suspended := newUser("bob", "", map[string]string{"team": "dns"})
+			suspended.Spec.Suspended = true
+			expired := newUser("dave", "", map[string]string{"team": "dns"})
+			expired.Spec.ExpiresAt = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			k8sClient := fake.NewClientBuilder().WithObjects(team, newUser("alice", "alice-iam", nil), suspended, expired).Build()
+			r := &TeamReconciler{Client: k8sClient, Scheme: scheme.Scheme, ClusterRoles: []string{"edit", "view"}}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			reconciled := &kuadrav1.Team{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Members).Should(Equal([]string{"alice", "bob", "dave"}))
+
+			roleBinding := &rbacv1.RoleBinding{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra-team-default.dns", Namespace: "team-dns"}, roleBinding)).Should(Succeed())
+			Expect(roleBinding.Subjects).Should(HaveLen(1))
+			Expect(roleBinding.Subjects[0].Name).Should(Equal("alice-iam"))
+		})
+	})
+
+	Context("When a member names another Kubernetes identity", func() {
+		It("Should bind their IAM user name instead", func() {
+			team := newTeam()
+			lookupKey := k8Types.NamespacedName{Name: team.Name, Namespace: team.Namespace}
+			alice := newUser("alice", "alice-iam", nil)
+			alice.Spec.AwsAccount.Spec.User.Namespace = &kuadrav1.NamespaceSpec{KubernetesUser: "system:serviceaccount:kube-system:default"}
+			k8sClient := fake.NewClientBuilder().WithObjects(team, alice).Build()
+			r := &TeamReconciler{Client: k8sClient, Scheme: scheme.Scheme, ClusterRoles: []string{"edit", "view"}}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			roleBinding := &rbacv1.RoleBinding{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "kuadra-team-default.dns", Namespace: "team-dns"}, roleBinding)).Should(Succeed())
+			Expect(roleBinding.Subjects).Should(ConsistOf(HaveField("Name", "alice-iam")))
+		})
+	})
+
+	Context("When a User is a team member", func() {
+		It("Should add the team's groups and policies to the User's AwsAccount", func() {
+			user := newUser("alice", "alice-iam", nil)
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user, newTeam()).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			awsAccount := &kuadrav1.AwsAccount{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "alice-iam", Namespace: "default"}, awsAccount)).Should(Succeed())
+			Expect(awsAccount.Spec.Groups).Should(Equal([]string{"developers", "dns-management"}))
+			Expect(awsAccount.Spec.ManagedPolicyArns).Should(Equal([]string{"arn:aws:iam::aws:policy/AmazonRoute53ReadOnlyAccess"}))
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Teams).Should(Equal([]string{"dns"}))
+		})
+	})
+})
@@ -0,0 +1,350 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/yaml"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
+)
+
+// UserConfigLabelKey marks the ConfigMaps holding a list of users. Every key of such a
+// ConfigMap holds a YAML or JSON list of userConfigEntry.
+const UserConfigLabelKey = "kuadra.kuadrant.io/user-config"
+
+// userConfigEntry is a user listed in a user config ConfigMap
+type userConfigEntry struct {
+	// Name is the name of the User object, defaults to the user name made a valid object name
+	Name string `json:"name,omitempty"`
+	// UserName is the user's name in each service
+	UserName string `json:"userName"`
+	// Groups are the user's IAM groups
+	Groups []string `json:"groups,omitempty"`
+	// GroupWindows are the user's IAM groups limited to a window of time
+	GroupWindows []kuadrav1.GroupWindow `json:"groupWindows,omitempty"`
+	// Services are the services the user gets an account in, defaults to all of them
+	Services []string `json:"services,omitempty"`
+	// GithubLogin is the user's GitHub login, the user is invited to the GitHub organization when set
+	GithubLogin string `json:"githubLogin,omitempty"`
+	// GithubTeams are the GitHub organization teams of the user
+	GithubTeams []string `json:"githubTeams,omitempty"`
+	// QuayUsername is the user's Quay username, the user gets a robot account in the Quay organization when set
+	QuayUsername string `json:"quayUsername,omitempty"`
+	// QuayTeams are the Quay organization teams of the user
+	QuayTeams []string `json:"quayTeams,omitempty"`
+	// QuayRepositories are the Quay repositories the user's robot account can push to
+	QuayRepositories []string `json:"quayRepositories,omitempty"`
+	// Email is the user's email address in Keycloak
+	Email string `json:"email,omitempty"`
+	// KeycloakRealmRoles are the Keycloak realm roles of the user
+	KeycloakRealmRoles []string `json:"keycloakRealmRoles,omitempty"`
+	// Labels are set on the User, e.g. to select it as a Team member
+	Labels map[string]string `json:"labels,omitempty"`
+	// NamespaceTemplate is rendered into the user's namespace
+	NamespaceTemplate string `json:"namespaceTemplate,omitempty"`
+	// ExpiresAt is the RFC 3339 time the user's access ends
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// ExpirationPolicy is Suspend or Delete, see the User spec
+	ExpirationPolicy kuadrav1.ExpirationPolicy `json:"expirationPolicy,omitempty"`
+	// Suspended suspends the user's accounts
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// userConfigServices are the services a user config entry can list
+var userConfigServices = []string{kuadrav1.ServiceAws, kuadrav1.ServiceGithub, kuadrav1.ServiceQuay, kuadrav1.ServiceKeycloak}
+
+// UserConfigReconciler creates a User for each entry of the user config ConfigMaps and
+// deletes the Users whose entry is removed
+type UserConfigReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Namespaces are the namespaces whose user config ConfigMaps are honoured. Writing a
+	// ConfigMap there is as good as creating Users, so the RBAC of these namespaces has to
+	// restrict ConfigMaps like Users.
+	Namespaces []string
+	// NamespaceTemplates are the NamespaceTemplates the entries of the ConfigMaps in a namespace
+	// may ask for, each as <namespace>/<name>. The Users are written by the controller, so the
+	// User webhook cannot check that the ConfigMap's author may bind the roles of a template.
+	NamespaceTemplates []string
+}
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=users,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile syncs the Users owned by a user config ConfigMap with its entries. A ConfigMap that
+// cannot be parsed is reported with a Warning event and leaves its Users untouched, while a
+// ConfigMap outside of the configured namespaces is reported and ignored. NamespaceTemplates that
+// are not allowed and Users refused by the webhook are reported with Warning events too.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
+func (r *UserConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	configMap := v1.ConfigMap{}
+	if err := r.Get(ctx, req.NamespacedName, &configMap); err != nil {
+		// The Users are owned by the ConfigMap and garbage collected with it
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if configMap.DeletionTimestamp != nil && !configMap.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	var desired []kuadrav1.User
+	if configMap.Labels[UserConfigLabelKey] == "true" && !slice.Contains(r.Namespaces, configMap.Namespace) {
+		r.Recorder.Eventf(&configMap, v1.EventTypeWarning, "UserConfigNotAllowed", "User configs are not honoured in namespace %s", configMap.Namespace)
+	} else if configMap.Labels[UserConfigLabelKey] == "true" {
+		entries, err := parseUserConfig(&configMap)
+		if err != nil {
+			log.Error(err, "invalid user config")
+			r.Recorder.Eventf(&configMap, v1.EventTypeWarning, "InvalidUserConfig", "Unable to parse user config: %v", err)
+			return ctrl.Result{}, nil
+		}
+		r.refuseNamespaceTemplates(&configMap, entries)
+		desired = usersFromConfig(&configMap, entries)
+	}
+
+	keep := map[string]bool{}
+	for i := range desired {
+		user := &desired[i]
+		keep[user.Name] = true
+		if err := r.applyUser(ctx, &configMap, user); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.pruneUsers(ctx, &configMap, keep)
+}
+
+// parseUserConfig reads the user config entries from every key of the ConfigMap
+func parseUserConfig(configMap *v1.ConfigMap) ([]userConfigEntry, error) {
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var entries []userConfigEntry
+	names := map[string]string{}
+	for _, key := range keys {
+		var keyEntries []userConfigEntry
+		if err := yaml.UnmarshalStrict([]byte(configMap.Data[key]), &keyEntries); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		for i, entry := range keyEntries {
+			if err := validateUserConfigEntry(entry); err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+			}
+			name := userConfigObjectName(entry)
+			if previous, exists := names[name]; exists {
+				return nil, fmt.Errorf("%s[%d]: user %s is also listed in %s", key, i, name, previous)
+			}
+			names[name] = fmt.Sprintf("%s[%d]", key, i)
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func validateUserConfigEntry(entry userConfigEntry) error {
+	if entry.UserName == "" {
+		return fmt.Errorf("userName is required")
+	}
+	if entry.Name != "" {
+		if errs := validation.IsDNS1123Subdomain(entry.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid name %q: %s", entry.Name, strings.Join(errs, ", "))
+		}
+	}
+	for _, service := range entry.Services {
+		if !slice.Contains(userConfigServices, service) {
+			return fmt.Errorf("unknown service %q, expected one of %s", service, strings.Join(userConfigServices, ", "))
+		}
+	}
+	if slice.Contains(entry.Services, kuadrav1.ServiceGithub) && entry.GithubLogin == "" {
+		return fmt.Errorf("githubLogin is required for the github service")
+	}
+	if slice.Contains(entry.Services, kuadrav1.ServiceQuay) && entry.QuayUsername == "" {
+		return fmt.Errorf("quayUsername is required for the quay service")
+	}
+	return nil
+}
+
+// userConfigObjectName returns the name of the User created for an entry
+func userConfigObjectName(entry userConfigEntry) string {
+	if entry.Name != "" {
+		return entry.Name
+	}
+	return sanitizeNamespaceName(entry.UserName)
+}
+
+// hasService reports whether the entry asks for an account in the service
+func (entry userConfigEntry) hasService(service string) bool {
+	return len(entry.Services) == 0 || slice.Contains(entry.Services, service)
+}
+
+// refuseNamespaceTemplates drops the NamespaceTemplates that the entries of the ConfigMap may not ask
+// for, reporting each refused entry with a Warning event
+func (r *UserConfigReconciler) refuseNamespaceTemplates(configMap *v1.ConfigMap, entries []userConfigEntry) {
+	for i := range entries {
+		entry := &entries[i]
+		if entry.NamespaceTemplate == "" || slice.Contains(r.NamespaceTemplates, configMap.Namespace+"/"+entry.NamespaceTemplate) {
+			continue
+		}
+		r.Recorder.Eventf(configMap, v1.EventTypeWarning, "NamespaceTemplateNotAllowed",
+			"User %s cannot use NamespaceTemplate %s in namespace %s, the User is created without it", userConfigObjectName(*entry), entry.NamespaceTemplate, configMap.Namespace)
+		entry.NamespaceTemplate = ""
+	}
+}
+
+// usersFromConfig returns the Users described by the entries of a user config ConfigMap
+func usersFromConfig(configMap *v1.ConfigMap, entries []userConfigEntry) []kuadrav1.User {
+	users := make([]kuadrav1.User, 0, len(entries))
+	for _, entry := range entries {
+		user := kuadrav1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userConfigObjectName(entry),
+				Namespace: configMap.Namespace,
+				Labels:    entry.Labels,
+			},
+			Spec: kuadrav1.UserSpec{
+				NamespaceTemplate: entry.NamespaceTemplate,
+				ExpiresAt:         entry.ExpiresAt,
+				ExpirationPolicy:  entry.ExpirationPolicy,
+				Suspended:         entry.Suspended,
+			},
+		}
+		if entry.hasService(kuadrav1.ServiceAws) {
+			user.Spec.AwsAccount = &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{
+				UserName:     entry.UserName,
+				Groups:       entry.Groups,
+				GroupWindows: entry.GroupWindows,
+			}}}
+		}
+		if entry.hasService(kuadrav1.ServiceGithub) && entry.GithubLogin != "" {
+			user.Spec.Github = &kuadrav1.GithubAccountSpec{Login: entry.GithubLogin, Teams: entry.GithubTeams}
+		}
+		if entry.hasService(kuadrav1.ServiceQuay) && entry.QuayUsername != "" {
+			user.Spec.Quay = &kuadrav1.QuayAccountSpec{Username: entry.QuayUsername, Teams: entry.QuayTeams, Repositories: entry.QuayRepositories}
+		}
+		// Keycloak users are only created for entries that list the service, as the realm
+		// usually has users of its own
+		if slice.Contains(entry.Services, kuadrav1.ServiceKeycloak) {
+			user.Spec.Keycloak = &kuadrav1.KeycloakAccountSpec{Username: entry.UserName, Email: entry.Email, RealmRoles: entry.KeycloakRealmRoles}
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+// applyUser creates or updates a User from the ConfigMap, refusing to take over a User that
+// the ConfigMap does not own
+func (r *UserConfigReconciler) applyUser(ctx context.Context, configMap *v1.ConfigMap, desired *kuadrav1.User) error {
+	user := &kuadrav1.User{ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: desired.Namespace}}
+	err := r.Get(ctx, client.ObjectKeyFromObject(user), user)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && !metav1.IsControlledBy(user, configMap) {
+		r.Recorder.Eventf(configMap, v1.EventTypeWarning, "UserConflict", "User %s already exists and is not managed by this ConfigMap", user.Name)
+		return nil
+	}
+
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, user, func() error {
+		user.Labels = desired.Labels
+		user.Spec = desired.Spec
+		return controllerutil.SetControllerReference(configMap, user, r.Scheme)
+	})
+	if apierrors.IsInvalid(err) || apierrors.IsForbidden(err) {
+		// Refused by the User webhook, which the ConfigMap has to be changed for
+		log.FromContext(ctx).Error(err, "User from user config refused", "name", user.Name)
+		r.Recorder.Eventf(configMap, v1.EventTypeWarning, "UserRefused", "User %s was refused: %v", user.Name, err)
+		return nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to apply User from user config", "name", user.Name)
+		return err
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).V(1).Info("reconciled User from user config", "name", user.Name, "operation", result)
+	}
+	return nil
+}
+
+// pruneUsers deletes the Users owned by the ConfigMap that are no longer listed in it
+func (r *UserConfigReconciler) pruneUsers(ctx context.Context, configMap *v1.ConfigMap, keep map[string]bool) error {
+	var users kuadrav1.UserList
+	if err := r.List(ctx, &users, client.InNamespace(configMap.Namespace)); err != nil {
+		return err
+	}
+	for i := range users.Items {
+		user := &users.Items[i]
+		if keep[user.Name] || !metav1.IsControlledBy(user, configMap) {
+			continue
+		}
+		if err := r.Delete(ctx, user); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("deleted User removed from user config", "name", user.Name)
+	}
+	return nil
+}
+
+// userConfigPredicate selects the user config ConfigMaps, including the ones the label was just
+// removed from so that their Users are pruned
+func userConfigPredicate() predicate.Funcs {
+	isUserConfig := func(object client.Object) bool {
+		_, exists := object.GetLabels()[UserConfigLabelKey]
+		return exists
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return isUserConfig(e.Object) },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isUserConfig(e.ObjectOld) || isUserConfig(e.ObjectNew)
+		},
+		DeleteFunc:  func(e event.DeleteEvent) bool { return isUserConfig(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return isUserConfig(e.Object) },
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *UserConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("userconfig").
+		For(&v1.ConfigMap{}, builder.WithPredicates(userConfigPredicate())).
+		Owns(&kuadrav1.User{}).
+		Complete(r)
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+var _ = Describe("User config controller", func() {
+
+	ctx := context.Background()
+
+	newConfigMap := func(users string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "roster",
+				Namespace: "default",
+				UID:       "roster-uid",
+				Labels:    map[string]string{UserConfigLabelKey: "true"},
+			},
+			Data: map[string]string{"users.yaml": users},
+		}
+	}
+
+	Context("When parsing a user config", func() {
+		It("Should accept YAML and JSON lists", func() {
+			configMap := newConfigMap(`
+- userName: alice
+  groups: [dns-management]
+  expiresAt: "2024-08-31T17:00:00Z"
+- userName: Bob.Smith@example.com
+  name: bob
+  services: [aws]
+  labels:
+    team: dns
+`)
+			configMap.Data["more.json"] = `[{"userName": "carol", "namespaceTemplate": "developer"}]`
+			entries, err := parseUserConfig(configMap)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(entries).Should(HaveLen(3))
+			Expect(entries[0].UserName).Should(Equal("carol"))
+			Expect(entries[1].Groups).Should(Equal([]string{"dns-management"}))
+			Expect(entries[1].ExpiresAt.UTC()).Should(Equal(time.Date(2024, 8, 31, 17, 0, 0, 0, time.UTC)))
+			Expect(entries[2].Labels).Should(HaveKeyWithValue("team", "dns"))
+		})
+
+		It("Should accept time-bound groups", func() {
+			entries, err := parseUserConfig(newConfigMap(`
+- userName: alice
+  groups: [dns-management]
+  groupWindows:
+    - name: break-glass
+      notAfter: "2024-08-31T17:00:00Z"
+`))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(entries[0].Groups).Should(Equal([]string{"dns-management"}))
+			Expect(entries[0].GroupWindows).Should(HaveLen(1))
+			Expect(entries[0].GroupWindows[0].Name).Should(Equal("break-glass"))
+			Expect(entries[0].GroupWindows[0].NotAfter.UTC()).Should(Equal(time.Date(2024, 8, 31, 17, 0, 0, 0, time.UTC)))
+		})
+
+		It("Should reject invalid entries", func() {
+			for _, users := range []string{
+				`userName: alice`,
+				`- groups: [dns-management]`,
+				`- {userName: alice, service: [aws]}`,
+				`- {userName: alice, services: [ftp]}`,
+				`- {userName: alice, name: Alice}`,
+				"- userName: alice\n- userName: alice",
+			} {
+				_, err := parseUserConfig(newConfigMap(users))
+				Expect(err).Should(HaveOccurred(), users)
+			}
+		})
+	})
+
+	Context("When reconciling a user config ConfigMap", func() {
+		It("Should create and prune the listed Users", func() {
+			configMap := newConfigMap(`
+- userName: alice
+  groups: [dns-management]
+- userName: bob
+`)
+			unmanaged := &kuadrav1.User{ObjectMeta: metav1.ObjectMeta{Name: "carol", Namespace: "default"}}
+			lookupKey := k8Types.NamespacedName{Name: configMap.Name, Namespace: configMap.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(configMap, unmanaged).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &UserConfigReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: recorder, Namespaces: []string{"default"}}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			user := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "alice", Namespace: "default"}, user)).Should(Succeed())
+			Expect(metav1.IsControlledBy(user, configMap)).Should(BeTrue())
+			Expect(user.Spec.AwsAccount.Spec.User.UserName).Should(Equal("alice"))
+			Expect(user.Spec.AwsAccount.Spec.User.Groups).Should(Equal([]string{"dns-management"}))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "bob", Namespace: "default"}, user)).Should(Succeed())
+
+			By("By removing a user and listing an existing unmanaged User")
+			configMap.Data["users.yaml"] = "- userName: alice\n- userName: carol\n"
+			Expect(k8sClient.Update(ctx, configMap)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "bob", Namespace: "default"}, user)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "carol", Namespace: "default"}, user)).Should(Succeed())
+			Expect(user.Spec.AwsAccount).Should(BeNil())
+			Expect(recorder.Events).Should(Receive(ContainSubstring("UserConflict")))
+
+			By("By breaking the user config")
+			configMap.Data["users.yaml"] = "- userName: [alice"
+			Expect(k8sClient.Update(ctx, configMap)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(recorder.Events).Should(Receive(ContainSubstring("InvalidUserConfig")))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "alice", Namespace: "default"}, user)).Should(Succeed())
+
+			By("By removing the label")
+			delete(configMap.Labels, UserConfigLabelKey)
+			Expect(k8sClient.Update(ctx, configMap)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "alice", Namespace: "default"}, user)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "carol", Namespace: "default"}, user)).Should(Succeed())
+		})
+
+		It("Should only pass on the NamespaceTemplates allowed in the ConfigMap's namespace", func() {
+			configMap := newConfigMap(`
+- userName: alice
+  namespaceTemplate: developer
+- userName: mallory
+  namespaceTemplate: cluster-admin
+`)
+			k8sClient := fake.NewClientBuilder().WithObjects(configMap).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &UserConfigReconciler{
+				Client:             k8sClient,
+				Scheme:             scheme.Scheme,
+				Recorder:           recorder,
+				Namespaces:         []string{"default"},
+				NamespaceTemplates: []string{"default/developer", "team-a/cluster-admin"},
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(configMap)})
+			Expect(err).ShouldNot(HaveOccurred())
+			user := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "alice", Namespace: "default"}, user)).Should(Succeed())
+			Expect(user.Spec.NamespaceTemplate).Should(Equal("developer"))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "mallory", Namespace: "default"}, user)).Should(Succeed())
+			Expect(user.Spec.NamespaceTemplate).Should(BeEmpty())
+			Expect(recorder.Events).Should(Receive(ContainSubstring("NamespaceTemplateNotAllowed")))
+		})
+
+		It("Should report the Users the webhook refuses", func() {
+			configMap := newConfigMap("- userName: alice\n- userName: mallory\n")
+			k8sClient := refusingClient{Client: fake.NewClientBuilder().WithObjects(configMap).Build(), refused: "mallory"}
+			recorder := record.NewFakeRecorder(10)
+			r := &UserConfigReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: recorder, Namespaces: []string{"default"}}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(configMap)})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(recorder.Events).Should(Receive(And(ContainSubstring("UserRefused"), ContainSubstring("mallory"))))
+			Expect(k8sClient.Get(ctx, k8Types.NamespacedName{Name: "alice", Namespace: "default"}, &kuadrav1.User{})).Should(Succeed())
+		})
+
+		It("Should ignore user configs outside of the configured namespaces", func() {
+			configMap := newConfigMap("- userName: mallory\n")
+			configMap.Namespace = "team-a"
+			k8sClient := fake.NewClientBuilder().WithObjects(configMap).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &UserConfigReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: recorder, Namespaces: []string{"default"}}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(configMap)})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(recorder.Events).Should(Receive(ContainSubstring("UserConfigNotAllowed")))
+			err = k8sClient.Get(ctx, k8Types.NamespacedName{Name: "mallory", Namespace: "team-a"}, &kuadrav1.User{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+})
+
+// refusingClient refuses to create a User, like the User webhook does
+type refusingClient struct {
+	client.Client
+	refused string
+}
+
+func (c refusingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if user, ok := obj.(*kuadrav1.User); ok && user.Name == c.refused {
+		return apierrors.NewForbidden(kuadrav1.GroupVersion.WithResource("users").GroupResource(), user.Name, errors.New("denied by the webhook"))
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
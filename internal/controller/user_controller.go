@@ -18,35 +18,57 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
 
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+	slice "github.com/Kuadrant/kuadra/pkg/_internal"
 )
 
 // UserReconciler reconciles a User object
 type UserReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Options limit the accounts that Users can ask for
+	Options kuadrav1.ServiceOptions
 }
 
 //+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=users,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=users/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=users/finalizers,verbs=update
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=awsaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=githubaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=quayaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=keycloakaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=kubernetesaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=teams,verbs=get;list;watch
+//+kubebuilder:rbac:groups=kuadra.kuadrant.io,resources=accesspolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the User object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It creates an account resource for each service in the User spec, deletes the
+// ones removed from the spec and reports their readiness on the User status.
+// A suspended or expired User has its accounts suspended, or deleted under the
+// Delete expiration policy.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
@@ -55,84 +77,474 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 	user := kuadrav1.User{}
 	if err := r.Get(ctx, req.NamespacedName, &user); err != nil {
-		log.Error(err, "unable to fetch User")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if user.DeletionTimestamp != nil && !user.DeletionTimestamp.IsZero() {
+		// The accounts are owned by the User and garbage collected with it
+		return ctrl.Result{}, nil
+	}
 
-	awsAccount := r.createAwsAccountScheme(&user, req.Namespace)
+	teams, err := r.memberTeams(ctx, &user)
+	if err != nil {
+		log.Error(err, "unable to list Teams")
+		return ctrl.Result{}, err
+	}
+	user.Status.Teams = nil
+	for _, team := range teams {
+		user.Status.Teams = append(user.Status.Teams, team.Name)
+	}
 
-	if err := controllerutil.SetControllerReference(&user, awsAccount, r.Scheme); err != nil {
-		log.Error(err, "Failed to set owner reference for AwsAccount")
-		return reconcile.Result{}, err
+	now := time.Now()
+	r.setLifecycleConditions(&user, now)
+	if userExpired(&user, now) && expirationPolicy(&user) == kuadrav1.ExpirationPolicyDelete {
+		return r.reconcileDeletedAccounts(ctx, &user)
 	}
 
-	existingAwsAccount, err := r.getExistingAwsAccount(ctx, awsAccount)
+	denied, err := kuadrav1.DeniedAccessInNamespace(ctx, r.Client, user.Namespace, user.RequestedAccess())
 	if err != nil {
-		return reconcile.Result{}, err
+		log.Error(err, "unable to evaluate AccessPolicies")
+		return ctrl.Result{}, err
+	}
+
+	var services []kuadrav1.ServiceStatus
+	awsService, awsErr := r.reconcileEnabledService(&user, denied, kuadrav1.ServiceAws, "AwsAccount", awsAccountName(&user), func() (*kuadrav1.ServiceStatus, error) {
+		return r.reconcileAwsAccount(ctx, &user, teams)
+	})
+	if awsService != nil {
+		services = append(services, *awsService)
+	}
+	githubService, githubErr := r.reconcileEnabledService(&user, denied, kuadrav1.ServiceGithub, "GithubAccount", user.Name, func() (*kuadrav1.ServiceStatus, error) {
+		return r.reconcileGithubAccount(ctx, &user)
+	})
+	if githubService != nil {
+		services = append(services, *githubService)
+	}
+	quayService, quayErr := r.reconcileEnabledService(&user, denied, kuadrav1.ServiceQuay, "QuayAccount", user.Name, func() (*kuadrav1.ServiceStatus, error) {
+		return r.reconcileQuayAccount(ctx, &user, teams)
+	})
+	if quayService != nil {
+		services = append(services, *quayService)
 	}
-	if existingAwsAccount == nil {
-		err := r.createAwsAccount(ctx, awsAccount)
-		if err != nil {
-			return reconcile.Result{}, err
+	keycloakService, keycloakErr := r.reconcileEnabledService(&user, denied, kuadrav1.ServiceKeycloak, "KeycloakAccount", user.Name, func() (*kuadrav1.ServiceStatus, error) {
+		return r.reconcileKeycloakAccount(ctx, &user, teams)
+	})
+	if keycloakService != nil {
+		services = append(services, *keycloakService)
+	}
+	kubernetesService, kubernetesErr := r.reconcileEnabledService(&user, denied, kuadrav1.ServiceKubernetes, "KubernetesAccount", user.Name, func() (*kuadrav1.ServiceStatus, error) {
+		return r.reconcileKubernetesAccount(ctx, &user, teams)
+	})
+	if kubernetesService != nil {
+		services = append(services, *kubernetesService)
+	}
+	reconcileErr := utilerrors.NewAggregate([]error{awsErr, githubErr, quayErr, keycloakErr, kubernetesErr})
+
+	user.Status.Services = services
+	user.Status.AwsAccountCreated = awsService != nil
+	setUserReadyCondition(&user.Status.Conditions, user.Generation, services, reconcileErr)
+	user.Status.ObservedGeneration = user.Generation
+
+	var latest kuadrav1.User
+	if err := r.Get(ctx, req.NamespacedName, &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, user.Status) {
+		if err := r.Status().Update(ctx, &user); err != nil {
+			log.Error(err, "unable to update User status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
 		}
+	}
+
+	return ctrl.Result{RequeueAfter: expiryRequeue(&user, now)}, reconcileErr
+}
+
+// reconcileEnabledService reconciles one of the User's accounts unless the operator does not manage
+// the service. No account is created for a disabled service, as no controller would act on it.
+func (r *UserReconciler) reconcileEnabledService(user *kuadrav1.User, denied kuadrav1.Access, service string, kind string, name string,
+	reconcileService func() (*kuadrav1.ServiceStatus, error)) (*kuadrav1.ServiceStatus, error) {
+	if !r.Options.ServiceDisabled(service) || !slice.Contains(user.RequestedAccess().Services, service) {
+		return reconcileAllowedService(user, denied, service, kind, name, reconcileService)
+	}
+	return &kuadrav1.ServiceStatus{
+		Service: service,
+		Kind:    kind,
+		Name:    name,
+		Ready:   metav1.ConditionFalse,
+		Reason:  kuadrav1.ReasonNotAllowed,
+		Message: "The operator does not manage " + service + " accounts",
+	}, nil
+}
+
+// reconcileDeletedAccounts deletes the accounts of a User that expired under the Delete policy,
+// keeping the User with a Ready condition saying so
+func (r *UserReconciler) reconcileDeletedAccounts(ctx context.Context, user *kuadrav1.User) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	deleteErr := r.deleteAccounts(ctx, user)
+	if deleteErr != nil {
+		log.Error(deleteErr, "Failed to delete the accounts of expired User")
+		setConditionFromError(&user.Status.Conditions, user.Generation, kuadrav1.ConditionReady, deleteErr)
 	} else {
-		err := r.updateAwsAccount(ctx, awsAccount, existingAwsAccount)
-		if err != nil {
-			return reconcile.Result{}, err
+		setCondition(&user.Status.Conditions, user.Generation, kuadrav1.ConditionReady, metav1.ConditionFalse, kuadrav1.ReasonExpired, "User has expired and its accounts are deleted")
+	}
+	user.Status.Services = nil
+	user.Status.AwsAccountCreated = false
+	user.Status.ObservedGeneration = user.Generation
+
+	var latest kuadrav1.User
+	if err := r.Get(ctx, client.ObjectKeyFromObject(user), &latest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !reflect.DeepEqual(latest.Status, user.Status) {
+		if err := r.Status().Update(ctx, user); err != nil {
+			log.Error(err, "unable to update User status")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, err
 		}
 	}
+	return ctrl.Result{}, deleteErr
+}
 
-	return ctrl.Result{}, nil
+// awsAccountName returns the name of the User's AwsAccount, the IAM user name, or an empty string
+// when the User has no AWS account
+func awsAccountName(user *kuadrav1.User) string {
+	if user.Spec.AwsAccount == nil {
+		return ""
+	}
+	return user.Spec.AwsAccount.Spec.User.UserName
 }
 
-func (r *UserReconciler) createAwsAccountScheme(user *kuadrav1.User, namespace string) *kuadrav1.AwsAccount {
-	return &kuadrav1.AwsAccount{
-		TypeMeta: v1.TypeMeta{},
-		ObjectMeta: v1.ObjectMeta{
-			Name:      user.Spec.AwsAccount.Spec.User.UserName,
-			Namespace: namespace,
+// reconcileAwsAccount creates or updates the AwsAccount for spec.awsAccount, or deletes it when the
+// section is removed. It returns the AwsAccount's readiness, or nil when the User has no AWS account.
+func (r *UserReconciler) reconcileAwsAccount(ctx context.Context, user *kuadrav1.User, teams []kuadrav1.Team) (*kuadrav1.ServiceStatus, error) {
+	log := log.FromContext(ctx)
+
+	desiredName := awsAccountName(user)
+	if err := r.deleteOwnedAwsAccounts(ctx, user, desiredName); err != nil {
+		log.Error(err, "Failed to delete AwsAccount")
+		return nil, err
+	}
+	if user.Spec.AwsAccount == nil {
+		return nil, nil
+	}
+
+	awsAccount := &kuadrav1.AwsAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      desiredName,
+			Namespace: user.Namespace,
 		},
-		Spec: kuadrav1.AwsAccountSpec{
-			UserName: user.Spec.AwsAccount.Spec.User.UserName,
-			Groups:   user.Spec.AwsAccount.Spec.User.Groups,
+	}
+	// Only the spec is set so that the AwsAccount's finalizer and status are left alone
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, awsAccount, func() error {
+		awsAccount.Spec = r.awsAccountSpec(user, teams)
+		return controllerutil.SetControllerReference(user, awsAccount, r.Scheme)
+	})
+	if err != nil {
+		log.Error(err, "Failed to create or update AwsAccount")
+		return failedServiceStatus(kuadrav1.ServiceAws, "AwsAccount", desiredName, err), err
+	}
+	if result != controllerutil.OperationResultNone {
+		log.V(1).Info("reconciled AwsAccount", "name", awsAccount.Name, "operation", result)
+	}
+
+	return serviceStatus(kuadrav1.ServiceAws, "AwsAccount", awsAccount, awsAccount.Generation, awsAccount.Status.ObservedGeneration, awsAccount.Status.Conditions), nil
+}
+
+// reconcileGithubAccount creates or updates the GithubAccount for spec.github, or deletes it when the
+// section is removed. It returns the GithubAccount's readiness, or nil when the User has no GitHub account.
+func (r *UserReconciler) reconcileGithubAccount(ctx context.Context, user *kuadrav1.User) (*kuadrav1.ServiceStatus, error) {
+	log := log.FromContext(ctx)
+
+	githubAccount := &kuadrav1.GithubAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      user.Name,
+			Namespace: user.Namespace,
 		},
 	}
+	if user.Spec.Github == nil {
+		if err := r.deleteOwnedObject(ctx, user, githubAccount); err != nil {
+			log.Error(err, "Failed to delete GithubAccount")
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, githubAccount, func() error {
+		githubAccount.Spec = *user.Spec.Github.DeepCopy()
+		githubAccount.Spec.Suspended = githubAccount.Spec.Suspended || !userActive(user, time.Now())
+		return controllerutil.SetControllerReference(user, githubAccount, r.Scheme)
+	})
+	if err != nil {
+		log.Error(err, "Failed to create or update GithubAccount")
+		return failedServiceStatus(kuadrav1.ServiceGithub, "GithubAccount", githubAccount.Name, err), err
+	}
+
+	return serviceStatus(kuadrav1.ServiceGithub, "GithubAccount", githubAccount, githubAccount.Generation, githubAccount.Status.ObservedGeneration, githubAccount.Status.Conditions), nil
 }
 
-// getExistingAwsAccount retrieves the existing AwsAccount object, if it exists.
-func (r *UserReconciler) getExistingAwsAccount(ctx context.Context, awsAccount *kuadrav1.AwsAccount) (*kuadrav1.AwsAccount, error) {
-	existingAwsAccount := &kuadrav1.AwsAccount{}
-	err := r.Get(ctx, types.NamespacedName{Name: awsAccount.Spec.UserName, Namespace: awsAccount.Namespace}, existingAwsAccount)
+// reconcileQuayAccount creates or updates the QuayAccount for spec.quay, or deletes it when the
+// section is removed. It returns the QuayAccount's readiness, or nil when the User has no Quay account.
+func (r *UserReconciler) reconcileQuayAccount(ctx context.Context, user *kuadrav1.User, teams []kuadrav1.Team) (*kuadrav1.ServiceStatus, error) {
+	log := log.FromContext(ctx)
+
+	quayAccount := &kuadrav1.QuayAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      user.Name,
+			Namespace: user.Namespace,
+		},
+	}
+	if user.Spec.Quay == nil {
+		if err := r.deleteOwnedObject(ctx, user, quayAccount); err != nil {
+			log.Error(err, "Failed to delete QuayAccount")
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, quayAccount, func() error {
+		quayAccount.Spec = *user.Spec.Quay.DeepCopy()
+		quayAccount.Spec.Suspended = quayAccount.Spec.Suspended || !userActive(user, time.Now())
+		if quayAccount.Spec.SecretNamespace == "" {
+			quayAccount.Spec.SecretNamespace = r.userSecretNamespace(user, teams)
+		}
+		return controllerutil.SetControllerReference(user, quayAccount, r.Scheme)
+	})
 	if err != nil {
-		return nil, client.IgnoreNotFound(err)
+		log.Error(err, "Failed to create or update QuayAccount")
+		return failedServiceStatus(kuadrav1.ServiceQuay, "QuayAccount", quayAccount.Name, err), err
 	}
-	return existingAwsAccount, nil
+
+	return serviceStatus(kuadrav1.ServiceQuay, "QuayAccount", quayAccount, quayAccount.Generation, quayAccount.Status.ObservedGeneration, quayAccount.Status.Conditions), nil
 }
 
-// updateAwsAccount updates the existing AwsAccount object.
-func (r *UserReconciler) updateAwsAccount(ctx context.Context, awsAccount, existingAwsAccount *kuadrav1.AwsAccount) error {
-	awsAccount.ObjectMeta.ResourceVersion = existingAwsAccount.ObjectMeta.ResourceVersion
-	err := r.Update(ctx, awsAccount)
+// reconcileKeycloakAccount creates or updates the KeycloakAccount for spec.keycloak, or deletes it when the
+// section is removed. It returns the KeycloakAccount's readiness, or nil when the User has no Keycloak account.
+func (r *UserReconciler) reconcileKeycloakAccount(ctx context.Context, user *kuadrav1.User, teams []kuadrav1.Team) (*kuadrav1.ServiceStatus, error) {
+	log := log.FromContext(ctx)
+
+	keycloakAccount := &kuadrav1.KeycloakAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      user.Name,
+			Namespace: user.Namespace,
+		},
+	}
+	if user.Spec.Keycloak == nil {
+		if err := r.deleteOwnedObject(ctx, user, keycloakAccount); err != nil {
+			log.Error(err, "Failed to delete KeycloakAccount")
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, keycloakAccount, func() error {
+		keycloakAccount.Spec = *user.Spec.Keycloak.DeepCopy()
+		keycloakAccount.Spec.Suspended = keycloakAccount.Spec.Suspended || !userActive(user, time.Now())
+		if keycloakAccount.Spec.SecretNamespace == "" {
+			keycloakAccount.Spec.SecretNamespace = r.userSecretNamespace(user, teams)
+		}
+		return controllerutil.SetControllerReference(user, keycloakAccount, r.Scheme)
+	})
+	if err != nil {
+		log.Error(err, "Failed to create or update KeycloakAccount")
+		return failedServiceStatus(kuadrav1.ServiceKeycloak, "KeycloakAccount", keycloakAccount.Name, err), err
+	}
+
+	return serviceStatus(kuadrav1.ServiceKeycloak, "KeycloakAccount", keycloakAccount, keycloakAccount.Generation, keycloakAccount.Status.ObservedGeneration, keycloakAccount.Status.Conditions), nil
+}
+
+// reconcileKubernetesAccount creates or updates the KubernetesAccount for spec.kubernetes, or deletes it when the
+// section is removed. It returns the KubernetesAccount's readiness, or nil when the User has no Kubernetes access.
+func (r *UserReconciler) reconcileKubernetesAccount(ctx context.Context, user *kuadrav1.User, teams []kuadrav1.Team) (*kuadrav1.ServiceStatus, error) {
+	log := log.FromContext(ctx)
+
+	kubernetesAccount := &kuadrav1.KubernetesAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      user.Name,
+			Namespace: user.Namespace,
+		},
+	}
+	if user.Spec.Kubernetes == nil {
+		if err := r.deleteOwnedObject(ctx, user, kubernetesAccount); err != nil {
+			log.Error(err, "Failed to delete KubernetesAccount")
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	// The user is granted admin rights in their own namespace, which must not default to the
+	// namespace holding the User itself
+	namespace := user.Spec.Kubernetes.Namespace
+	if namespace == "" {
+		namespace = r.userSecretNamespace(user, teams)
+	}
+	if namespace == "" {
+		err := errors.New("spec.kubernetes.namespace is required for a User without an AWS account")
+		return failedServiceStatus(kuadrav1.ServiceKubernetes, "KubernetesAccount", kubernetesAccount.Name, err), err
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, kubernetesAccount, func() error {
+		kubernetesAccount.Spec = *user.Spec.Kubernetes.DeepCopy()
+		kubernetesAccount.Spec.Namespace = namespace
+		kubernetesAccount.Spec.Suspended = kubernetesAccount.Spec.Suspended || !userActive(user, time.Now())
+		return controllerutil.SetControllerReference(user, kubernetesAccount, r.Scheme)
+	})
 	if err != nil {
-		log.Log.Error(err, "Failed to update AwsAccount")
+		log.Error(err, "Failed to create or update KubernetesAccount")
+		return failedServiceStatus(kuadrav1.ServiceKubernetes, "KubernetesAccount", kubernetesAccount.Name, err), err
+	}
+
+	return serviceStatus(kuadrav1.ServiceKubernetes, "KubernetesAccount", kubernetesAccount, kubernetesAccount.Generation, kubernetesAccount.Status.ObservedGeneration, kubernetesAccount.Status.Conditions), nil
+}
+
+// userSecretNamespace returns the namespace of the User's AwsAccount, where the Secrets of the
+// other accounts are written too, or an empty string when the User has no AWS account
+func (r *UserReconciler) userSecretNamespace(user *kuadrav1.User, teams []kuadrav1.Team) string {
+	if user.Spec.AwsAccount == nil {
+		return ""
+	}
+	return targetNamespace(&kuadrav1.AwsAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: user.Spec.AwsAccount.Spec.User.UserName, Namespace: user.Namespace},
+		Spec:       r.awsAccountSpec(user, teams),
+	})
+}
+
+// deleteOwnedObject deletes the object when it exists and is controlled by the User
+func (r *UserReconciler) deleteOwnedObject(ctx context.Context, user *kuadrav1.User, object client.Object) error {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(object, user) {
+		return nil
+	}
+	if err := r.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
 		return err
 	}
+	log.FromContext(ctx).V(1).Info("deleted "+fmt.Sprintf("%T", object), "name", object.GetName())
 	return nil
 }
 
-// createAwsAccount creates a new AwsAccount object.
-func (r *UserReconciler) createAwsAccount(ctx context.Context, awsAccount *kuadrav1.AwsAccount) error {
-	err := r.Create(ctx, awsAccount)
-	if err != nil && client.IgnoreAlreadyExists(err) != nil {
-		log.Log.Error(err, "Failed to create AwsAccount")
+// failedServiceStatus reports a service whose resource could not be created or updated
+func failedServiceStatus(service string, kind string, name string, err error) *kuadrav1.ServiceStatus {
+	return &kuadrav1.ServiceStatus{
+		Service: service,
+		Kind:    kind,
+		Name:    name,
+		Ready:   metav1.ConditionFalse,
+		Reason:  kuadrav1.ReasonFailed,
+		Message: err.Error(),
+	}
+}
+
+// awsAccountSpec returns the AwsAccount spec nested in the User, with the User's namespace template,
+// the IAM groups and policies of the User's Teams and the User's suspension
+func (r *UserReconciler) awsAccountSpec(user *kuadrav1.User, teams []kuadrav1.Team) kuadrav1.AwsAccountSpec {
+	spec := *user.Spec.AwsAccount.Spec.User.DeepCopy()
+	spec.Suspended = spec.Suspended || !userActive(user, time.Now())
+	for _, team := range teams {
+		addGroups(&spec, team.Spec.Groups...)
+		spec.ManagedPolicyArns = appendMissing(spec.ManagedPolicyArns, team.Spec.ManagedPolicyArns...)
+	}
+	if user.Spec.NamespaceTemplate != "" {
+		if spec.Namespace == nil {
+			spec.Namespace = &kuadrav1.NamespaceSpec{}
+		}
+		if spec.Namespace.Template == "" {
+			spec.Namespace.Template = user.Spec.NamespaceTemplate
+		}
+	}
+	return spec
+}
+
+// appendMissing appends the values that are not in the list yet
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !slice.Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// memberTeams returns the Teams the User is a member of, sorted by name
+func (r *UserReconciler) memberTeams(ctx context.Context, user *kuadrav1.User) ([]kuadrav1.Team, error) {
+	var teams kuadrav1.TeamList
+	if err := r.List(ctx, &teams, client.InNamespace(user.Namespace)); err != nil {
+		return nil, err
+	}
+	var memberTeams []kuadrav1.Team
+	for i := range teams.Items {
+		if teams.Items[i].DeletionTimestamp == nil && isTeamMember(&teams.Items[i], user) {
+			memberTeams = append(memberTeams, teams.Items[i])
+		}
+	}
+	sort.Slice(memberTeams, func(i, j int) bool { return memberTeams[i].Name < memberTeams[j].Name })
+	return memberTeams, nil
+}
+
+// usersForTeam maps a Team to the Users in its namespace, so that both current and former
+// members pick up the change
+func (r *UserReconciler) usersForTeam(team client.Object) []reconcile.Request {
+	var users kuadrav1.UserList
+	if err := r.List(context.Background(), &users, client.InNamespace(team.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range users.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&users.Items[i])})
+	}
+	return requests
+}
+
+// deleteOwnedAwsAccounts deletes the AwsAccounts controlled by the User other than the one named keep,
+// e.g. after the AWS section is removed or the user name changes
+func (r *UserReconciler) deleteOwnedAwsAccounts(ctx context.Context, user *kuadrav1.User, keep string) error {
+	var awsAccounts kuadrav1.AwsAccountList
+	if err := r.List(ctx, &awsAccounts, client.InNamespace(user.Namespace)); err != nil {
 		return err
 	}
+	for i := range awsAccounts.Items {
+		awsAccount := &awsAccounts.Items[i]
+		if awsAccount.Name == keep || !metav1.IsControlledBy(awsAccount, user) {
+			continue
+		}
+		if err := r.Delete(ctx, awsAccount); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).V(1).Info("deleted AwsAccount", "name", awsAccount.Name)
+	}
 	return nil
 }
 
+// serviceStatus reports the Ready condition of a child resource, treating a condition that has not
+// caught up with the latest generation as still provisioning
+func serviceStatus(service string, kind string, object metav1.Object, generation int64, observedGeneration int64, conditions []metav1.Condition) *kuadrav1.ServiceStatus {
+	status := &kuadrav1.ServiceStatus{
+		Service: service,
+		Kind:    kind,
+		Name:    object.GetName(),
+		Ready:   metav1.ConditionUnknown,
+		Reason:  kuadrav1.ReasonProvisioning,
+		Message: kind + " has not been reconciled yet",
+	}
+	ready := meta.FindStatusCondition(conditions, kuadrav1.ConditionReady)
+	if ready == nil || observedGeneration != generation {
+		return status
+	}
+	status.Ready = ready.Status
+	status.Reason = ready.Reason
+	status.Message = ready.Message
+	return status
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kuadrav1.User{}).
+		Owns(&kuadrav1.AwsAccount{}).
+		Owns(&kuadrav1.GithubAccount{}).
+		Owns(&kuadrav1.QuayAccount{}).
+		Owns(&kuadrav1.KeycloakAccount{}).
+		Owns(&kuadrav1.KubernetesAccount{}).
+		Watches(&source.Kind{Type: &kuadrav1.Team{}}, handler.EnqueueRequestsFromMapFunc(r.usersForTeam)).
+		Watches(&source.Kind{Type: &kuadrav1.AccessPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.usersForAccessPolicy)).
 		Complete(r)
 }
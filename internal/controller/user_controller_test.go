@@ -0,0 +1,451 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8Types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+var _ = Describe("User controller", func() {
+
+	ctx := context.Background()
+
+	Context("When the User has an AWS section", func() {
+		It("Should manage the AwsAccount and report its readiness", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					AwsAccount: &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{
+						UserName: "jdoe",
+						Groups:   []string{"dns-management"},
+					}}},
+					NamespaceTemplate: "developer",
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			awsAccount := &kuadrav1.AwsAccount{}
+			awsAccountKey := k8Types.NamespacedName{Name: "jdoe", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, awsAccountKey, awsAccount)).Should(Succeed())
+			Expect(metav1.IsControlledBy(awsAccount, user)).Should(BeTrue())
+			Expect(awsAccount.Spec.Groups).Should(Equal([]string{"dns-management"}))
+			Expect(awsAccount.Spec.Namespace.Template).Should(Equal("developer"))
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.AwsAccountCreated).Should(BeTrue())
+			Expect(reconciled.Status.Services).Should(ConsistOf(And(
+				HaveField("Service", kuadrav1.ServiceAws),
+				HaveField("Ready", metav1.ConditionUnknown),
+			)))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeFalse())
+
+			By("By reporting the AwsAccount as ready")
+			awsAccount.Finalizers = []string{AwsAccountFinalizer}
+			Expect(k8sClient.Update(ctx, awsAccount)).Should(Succeed())
+			awsAccount.Status.ObservedGeneration = awsAccount.Generation
+			meta.SetStatusCondition(&awsAccount.Status.Conditions, metav1.Condition{
+				Type:   kuadrav1.ConditionReady,
+				Status: metav1.ConditionTrue,
+				Reason: kuadrav1.ReasonProvisioned,
+			})
+			Expect(k8sClient.Status().Update(ctx, awsAccount)).Should(Succeed())
+
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+			By("By updating the spec without dropping the AwsAccount's finalizer")
+			reconciled.Spec.AwsAccount.Spec.User.Groups = []string{"dns-management", "developers"}
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, awsAccountKey, awsAccount)).Should(Succeed())
+			Expect(awsAccount.Spec.Groups).Should(ContainElement("developers"))
+			Expect(awsAccount.Finalizers).Should(ContainElement(AwsAccountFinalizer))
+
+			By("By removing the AWS section")
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			reconciled.Spec.AwsAccount = nil
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, awsAccountKey, awsAccount)).Should(Succeed())
+			Expect(awsAccount.DeletionTimestamp).ShouldNot(BeNil())
+
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.AwsAccountCreated).Should(BeFalse())
+			Expect(reconciled.Status.Services).Should(BeEmpty())
+		})
+	})
+
+	Context("When the User has no AWS section", func() {
+		It("Should not create an AwsAccount", func() {
+			user := &kuadrav1.User{ObjectMeta: metav1.ObjectMeta{Name: "no-aws", Namespace: "default"}}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			awsAccounts := &kuadrav1.AwsAccountList{}
+			Expect(k8sClient.List(ctx, awsAccounts)).Should(Succeed())
+			Expect(awsAccounts.Items).Should(BeEmpty())
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: k8Types.NamespacedName{Name: "missing", Namespace: "default"}})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("When the User has a GitHub section", func() {
+		It("Should manage the GithubAccount", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					Github: &kuadrav1.GithubAccountSpec{Login: "jdoe", Teams: []string{"dns"}},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			githubAccount := &kuadrav1.GithubAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, githubAccount)).Should(Succeed())
+			Expect(metav1.IsControlledBy(githubAccount, user)).Should(BeTrue())
+			Expect(githubAccount.Spec.Teams).Should(Equal([]string{"dns"}))
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Services).Should(ConsistOf(HaveField("Service", kuadrav1.ServiceGithub)))
+
+			By("By removing the GitHub section")
+			reconciled.Spec.Github = nil
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, lookupKey, githubAccount)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+
+	Context("When no AccessPolicy allows a service", func() {
+		It("Should not create the account and report the service as denied", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					AwsAccount: &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{UserName: "jdoe"}}},
+					Github:     &kuadrav1.GithubAccountSpec{Login: "jdoe"},
+				},
+			}
+			accessPolicy := &kuadrav1.AccessPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-only"},
+				Spec:       kuadrav1.AccessPolicySpec{Services: []string{kuadrav1.ServiceAws}},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user, accessPolicy).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+			Expect(r.usersForAccessPolicy(accessPolicy)).Should(Equal([]reconcile.Request{{NamespacedName: lookupKey}}))
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, lookupKey, &kuadrav1.AwsAccount{})).Should(Succeed())
+			err = k8sClient.Get(ctx, lookupKey, &kuadrav1.GithubAccount{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Services).Should(ContainElement(And(
+				HaveField("Service", kuadrav1.ServiceGithub),
+				HaveField("Reason", kuadrav1.ReasonAccessPolicyDenied),
+			)))
+			ready := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionReady)
+			Expect(ready.Status).ShouldNot(Equal(metav1.ConditionTrue))
+		})
+	})
+
+	Context("When the operator does not manage a service", func() {
+		It("Should not create the account and report the service as not allowed", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec:       kuadrav1.UserSpec{Github: &kuadrav1.GithubAccountSpec{Login: "jdoe"}},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{
+				Client:   k8sClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(10),
+				Options:  kuadrav1.ServiceOptions{DisabledServices: []string{kuadrav1.ServiceGithub}},
+			}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, lookupKey, &kuadrav1.GithubAccount{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Services).Should(ConsistOf(And(
+				HaveField("Service", kuadrav1.ServiceGithub),
+				HaveField("Reason", kuadrav1.ReasonNotAllowed),
+			)))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeFalse())
+		})
+	})
+
+	Context("When the User has a Quay section", func() {
+		It("Should write the robot account's Secret to the AWS account namespace", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					AwsAccount: &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{UserName: "J.Doe"}}},
+					Quay:       &kuadrav1.QuayAccountSpec{Username: "jdoe"},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			quayAccount := &kuadrav1.QuayAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, quayAccount)).Should(Succeed())
+			Expect(quayAccount.Spec.SecretNamespace).Should(Equal(sanitizeNamespaceName("J.Doe")))
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Services).Should(HaveLen(2))
+		})
+	})
+	Context("When the User has a Keycloak section", func() {
+		It("Should create the KeycloakAccount and delete it with the section", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					AwsAccount: &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{UserName: "jdoe"}}},
+					Keycloak:   &kuadrav1.KeycloakAccountSpec{Username: "jdoe", RealmRoles: []string{"developer"}},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			keycloakAccount := &kuadrav1.KeycloakAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, keycloakAccount)).Should(Succeed())
+			Expect(keycloakAccount.Spec.RealmRoles).Should(Equal([]string{"developer"}))
+			Expect(keycloakAccount.Spec.SecretNamespace).Should(Equal("jdoe"))
+			Expect(metav1.IsControlledBy(keycloakAccount, user)).Should(BeTrue())
+
+			By("By removing the Keycloak section")
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			reconciled.Spec.Keycloak = nil
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			err = k8sClient.Get(ctx, lookupKey, keycloakAccount)
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+		})
+	})
+	Context("When the User has a Kubernetes section", func() {
+		It("Should grant access in the AWS account namespace", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					AwsAccount: &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{UserName: "J.Doe"}}},
+					Kubernetes: &kuadrav1.KubernetesAccountSpec{ClusterRoles: []string{"view"}},
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			kubernetesAccount := &kuadrav1.KubernetesAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, kubernetesAccount)).Should(Succeed())
+			Expect(kubernetesAccount.Spec.Namespace).Should(Equal(sanitizeNamespaceName("J.Doe")))
+			Expect(kubernetesAccount.Spec.ClusterRoles).Should(Equal([]string{"view"}))
+		})
+
+		It("Should require a namespace without an AWS account", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec:       kuadrav1.UserSpec{Kubernetes: &kuadrav1.KubernetesAccountSpec{}},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).Should(MatchError(ContainSubstring("spec.kubernetes.namespace is required")))
+			err = k8sClient.Get(ctx, lookupKey, &kuadrav1.KubernetesAccount{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionFalse(reconciled.Status.Conditions, kuadrav1.ConditionReady)).Should(BeTrue())
+		})
+	})
+
+	Context("When the User is suspended", func() {
+		It("Should suspend every account and reactivate them when the suspension is lifted", func() {
+			user := &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					AwsAccount: &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{UserName: "jdoe"}}},
+					Github:     &kuadrav1.GithubAccountSpec{Login: "jdoe"},
+					Suspended:  true,
+				},
+			}
+			lookupKey := k8Types.NamespacedName{Name: user.Name, Namespace: user.Namespace}
+			accountKey := k8Types.NamespacedName{Name: "jdoe", Namespace: "default"}
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: recorder}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			awsAccount := &kuadrav1.AwsAccount{}
+			Expect(k8sClient.Get(ctx, accountKey, awsAccount)).Should(Succeed())
+			Expect(awsAccount.Spec.Suspended).Should(BeTrue())
+			githubAccount := &kuadrav1.GithubAccount{}
+			Expect(k8sClient.Get(ctx, accountKey, githubAccount)).Should(Succeed())
+			Expect(githubAccount.Spec.Suspended).Should(BeTrue())
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			suspended := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionSuspended)
+			Expect(suspended.Status).Should(Equal(metav1.ConditionTrue))
+			Expect(suspended.Reason).Should(Equal(kuadrav1.ReasonSuspended))
+			Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonSuspended)))
+
+			By("By lifting the suspension")
+			reconciled.Spec.Suspended = false
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, accountKey, awsAccount)).Should(Succeed())
+			Expect(awsAccount.Spec.Suspended).Should(BeFalse())
+			Expect(k8sClient.Get(ctx, accountKey, githubAccount)).Should(Succeed())
+			Expect(githubAccount.Spec.Suspended).Should(BeFalse())
+
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			suspended = meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionSuspended)
+			Expect(suspended.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(suspended.Reason).Should(Equal(kuadrav1.ReasonReactivated))
+			Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonReactivated)))
+		})
+	})
+
+	Context("When the User expires", func() {
+		newExpiringUser := func(expiresAt time.Time, policy kuadrav1.ExpirationPolicy) *kuadrav1.User {
+			return &kuadrav1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: "intern", Namespace: "default", UID: "user-uid"},
+				Spec: kuadrav1.UserSpec{
+					AwsAccount:       &kuadrav1.AwsAccountNestedSpec{Spec: kuadrav1.AwsSpec{User: kuadrav1.AwsAccountSpec{UserName: "intern"}}},
+					ExpiresAt:        &metav1.Time{Time: expiresAt},
+					ExpirationPolicy: policy,
+				},
+			}
+		}
+		lookupKey := k8Types.NamespacedName{Name: "intern", Namespace: "default"}
+
+		It("Should requeue until the expiry", func() {
+			k8sClient := fake.NewClientBuilder().WithObjects(newExpiringUser(time.Now().Add(time.Hour), "")).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RequeueAfter).Should(BeNumerically("~", time.Hour, time.Minute))
+
+			awsAccount := &kuadrav1.AwsAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+			Expect(awsAccount.Spec.Suspended).Should(BeFalse())
+		})
+
+		It("Should suspend the accounts under the Suspend policy", func() {
+			k8sClient := fake.NewClientBuilder().WithObjects(newExpiringUser(time.Now().Add(-time.Minute), "")).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: recorder}
+
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RequeueAfter).Should(BeZero())
+
+			awsAccount := &kuadrav1.AwsAccount{}
+			Expect(k8sClient.Get(ctx, lookupKey, awsAccount)).Should(Succeed())
+			Expect(awsAccount.Spec.Suspended).Should(BeTrue())
+
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionExpired)).Should(BeTrue())
+			suspended := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionSuspended)
+			Expect(suspended.Status).Should(Equal(metav1.ConditionTrue))
+			Expect(suspended.Reason).Should(Equal(kuadrav1.ReasonExpired))
+			Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonExpired)))
+			Expect(recorder.Events).Should(Receive(ContainSubstring(kuadrav1.ReasonSuspended)))
+		})
+
+		It("Should delete the accounts and keep the User under the Delete policy", func() {
+			user := newExpiringUser(time.Now().Add(time.Hour), kuadrav1.ExpirationPolicyDelete)
+			k8sClient := fake.NewClientBuilder().WithObjects(user).Build()
+			r := &UserReconciler{Client: k8sClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(k8sClient.Get(ctx, lookupKey, &kuadrav1.AwsAccount{})).Should(Succeed())
+
+			By("By passing the expiry")
+			reconciled := &kuadrav1.User{}
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			reconciled.Spec.ExpiresAt = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+			Expect(k8sClient.Update(ctx, reconciled)).Should(Succeed())
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: lookupKey})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			err = k8sClient.Get(ctx, lookupKey, &kuadrav1.AwsAccount{})
+			Expect(apierrors.IsNotFound(err)).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, lookupKey, reconciled)).Should(Succeed())
+			Expect(reconciled.Status.Services).Should(BeEmpty())
+			ready := meta.FindStatusCondition(reconciled.Status.Conditions, kuadrav1.ConditionReady)
+			Expect(ready.Status).Should(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).Should(Equal(kuadrav1.ReasonExpired))
+			Expect(meta.IsStatusConditionTrue(reconciled.Status.Conditions, kuadrav1.ConditionSuspended)).Should(BeFalse())
+		})
+	})
+})
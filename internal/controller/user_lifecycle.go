@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kuadrav1 "github.com/Kuadrant/kuadra/api/v1"
+)
+
+// userExpired reports whether the User's spec.expiresAt has passed
+func userExpired(user *kuadrav1.User, now time.Time) bool {
+	return user.Spec.ExpiresAt != nil && !now.Before(user.Spec.ExpiresAt.Time)
+}
+
+// userActive reports whether the User has access, i.e. is neither suspended nor expired
+func userActive(user *kuadrav1.User, now time.Time) bool {
+	return !user.Spec.Suspended && !userExpired(user, now)
+}
+
+// expirationPolicy returns the User's expiration policy, defaulting to Suspend
+func expirationPolicy(user *kuadrav1.User) kuadrav1.ExpirationPolicy {
+	if user.Spec.ExpirationPolicy == "" {
+		return kuadrav1.ExpirationPolicySuspend
+	}
+	return user.Spec.ExpirationPolicy
+}
+
+// expiryRequeue returns how long until the User expires, so that the expiry is acted upon on time,
+// or zero when it has no expiry ahead
+func expiryRequeue(user *kuadrav1.User, now time.Time) time.Duration {
+	if user.Spec.ExpiresAt == nil || userExpired(user, now) {
+		return 0
+	}
+	return user.Spec.ExpiresAt.Sub(now)
+}
+
+// setLifecycleConditions records the User's expiry and suspension in its conditions, emitting an
+// event on each transition
+func (r *UserReconciler) setLifecycleConditions(user *kuadrav1.User, now time.Time) {
+	conditions := &user.Status.Conditions
+	generation := user.Generation
+
+	expired := userExpired(user, now)
+	if expired {
+		message := "User expired at " + user.Spec.ExpiresAt.UTC().Format(time.RFC3339)
+		if !meta.IsStatusConditionTrue(*conditions, kuadrav1.ConditionExpired) {
+			r.Recorder.Event(user, v1.EventTypeNormal, kuadrav1.ReasonExpired, message)
+		}
+		setCondition(conditions, generation, kuadrav1.ConditionExpired, metav1.ConditionTrue, kuadrav1.ReasonExpired, message)
+	} else {
+		meta.RemoveStatusCondition(conditions, kuadrav1.ConditionExpired)
+	}
+
+	if user.Spec.Suspended || (expired && expirationPolicy(user) == kuadrav1.ExpirationPolicySuspend) {
+		reason := kuadrav1.ReasonSuspended
+		if !user.Spec.Suspended {
+			reason = kuadrav1.ReasonExpired
+		}
+		if !meta.IsStatusConditionTrue(*conditions, kuadrav1.ConditionSuspended) {
+			r.Recorder.Event(user, v1.EventTypeNormal, kuadrav1.ReasonSuspended, "The User's accounts are suspended")
+		}
+		setCondition(conditions, generation, kuadrav1.ConditionSuspended, metav1.ConditionTrue, reason, "The User's accounts are suspended")
+		return
+	}
+	if meta.IsStatusConditionTrue(*conditions, kuadrav1.ConditionSuspended) {
+		r.Recorder.Event(user, v1.EventTypeNormal, kuadrav1.ReasonReactivated, "The User's accounts are reactivated")
+		setCondition(conditions, generation, kuadrav1.ConditionSuspended, metav1.ConditionFalse, kuadrav1.ReasonReactivated, "The User's accounts are reactivated")
+	}
+}
+
+// deleteAccounts deletes every account controlled by the User, once it has expired under the Delete policy
+func (r *UserReconciler) deleteAccounts(ctx context.Context, user *kuadrav1.User) error {
+	if err := r.deleteOwnedAwsAccounts(ctx, user, ""); err != nil {
+		return err
+	}
+	objectMeta := metav1.ObjectMeta{Name: user.Name, Namespace: user.Namespace}
+	for _, account := range []client.Object{
+		&kuadrav1.GithubAccount{ObjectMeta: objectMeta},
+		&kuadrav1.QuayAccount{ObjectMeta: objectMeta},
+		&kuadrav1.KeycloakAccount{ObjectMeta: objectMeta},
+		&kuadrav1.KubernetesAccount{ObjectMeta: objectMeta},
+	} {
+		if err := r.deleteOwnedObject(ctx, user, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// missingGroupTTL is how long a group that IAM reported missing is taken as missing, bounded by the
+// cache's TTL. It is short so that a group created after a failed admission is found soon.
+const missingGroupTTL = 30 * time.Second
+
+// GroupLister looks up IAM groups, it is implemented by the IAM wrapper
+type GroupLister interface {
+	ListGroups(ctx context.Context) ([]types.Group, error)
+	GetGroup(ctx context.Context, groupName string) (*types.Group, error)
+}
+
+// GroupCache keeps the names of the account's IAM groups for a TTL, so that validating the groups of
+// an AwsAccount does not call IAM on every admission or reconcile
+type GroupCache struct {
+	lister GroupLister
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	names   []string
+	expires time.Time
+	// missing maps the lowercased names of the groups IAM reported missing to when that expires
+	missing map[string]time.Time
+}
+
+func NewGroupCache(lister GroupLister, ttl time.Duration) *GroupCache {
+	return &GroupCache{lister: lister, ttl: ttl, now: time.Now, missing: map[string]time.Time{}}
+}
+
+// GroupNames returns the names of the IAM groups, listing them again once the TTL has passed
+func (c *GroupCache) GroupNames(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.names != nil && c.now().Before(c.expires) {
+		return c.names, nil
+	}
+	groups, err := c.lister.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, *group.GroupName)
+	}
+	c.names = names
+	c.expires = c.now().Add(c.ttl)
+	return names, nil
+}
+
+// GroupExists reports whether the IAM group exists. IAM group names are case-insensitive. A group
+// missing from the cache is looked up in IAM, as it may have been created since the groups were
+// listed, in which case the cache is refreshed on the next call. A group that IAM reports missing is
+// not looked up again for missingGroupTTL.
+func (c *GroupCache) GroupExists(ctx context.Context, groupName string) (bool, error) {
+	names, err := c.GroupNames(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		if strings.EqualFold(name, groupName) {
+			return true, nil
+		}
+	}
+	if c.isMissing(groupName) {
+		return false, nil
+	}
+	group, err := c.lister.GetGroup(ctx, groupName)
+	if err != nil {
+		return false, err
+	}
+	if group == nil {
+		c.setMissing(groupName)
+		return false, nil
+	}
+	c.Invalidate()
+	return true, nil
+}
+
+// isMissing reports whether IAM recently reported the group missing
+func (c *GroupCache) isMissing(groupName string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires, ok := c.missing[strings.ToLower(groupName)]
+	return ok && c.now().Before(expires)
+}
+
+// setMissing records that IAM reported the group missing, dropping the records that expired
+func (c *GroupCache) setMissing(groupName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for name, expires := range c.missing {
+		if !now.Before(expires) {
+			delete(c.missing, name)
+		}
+	}
+	ttl := missingGroupTTL
+	if c.ttl < ttl {
+		ttl = c.ttl
+	}
+	c.missing[strings.ToLower(groupName)] = now.Add(ttl)
+}
+
+// ClosestGroupName returns the name of the IAM group closest to a group name that does not exist, to
+// suggest it to the user, or "" when no group name is close enough to be a likely typo
+func (c *GroupCache) ClosestGroupName(ctx context.Context, groupName string) (string, error) {
+	names, err := c.GroupNames(ctx)
+	if err != nil {
+		return "", err
+	}
+	return closestName(groupName, names), nil
+}
+
+// Invalidate drops the cached group names and the groups recorded as missing
+func (c *GroupCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = nil
+	c.missing = map[string]time.Time{}
+}
+
+// closestName returns the name with the smallest edit distance to the given one, if that distance is
+// at most a third of the name's length
+func closestName(name string, names []string) string {
+	closest := ""
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	best := maxDistance + 1
+	for _, candidate := range names {
+		if distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate)); distance < best {
+			closest = candidate
+			best = distance
+		}
+	}
+	return closest
+}
+
+// levenshtein returns the number of single character insertions, deletions and substitutions
+// turning a into b
+func levenshtein(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(br)]
+}
@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// countingGroupLister lists a fixed set of groups and counts the calls
+type countingGroupLister struct {
+	names     []string
+	listCalls int
+	getCalls  int
+}
+
+func (l *countingGroupLister) ListGroups(ctx context.Context) ([]types.Group, error) {
+	l.listCalls++
+	var groups []types.Group
+	for _, name := range l.names {
+		groups = append(groups, types.Group{GroupName: aws.String(name)})
+	}
+	return groups, nil
+}
+
+func (l *countingGroupLister) GetGroup(ctx context.Context, groupName string) (*types.Group, error) {
+	l.getCalls++
+	for _, name := range l.names {
+		if name == groupName {
+			return &types.Group{GroupName: aws.String(name)}, nil
+		}
+	}
+	return nil, nil
+}
+
+var _ = Describe("GroupCache", func() {
+
+	ctx := context.Background()
+
+	It("Should list the groups again once the TTL has passed", func() {
+		lister := &countingGroupLister{names: []string{"developers"}}
+		cache := NewGroupCache(lister, time.Minute)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		Expect(cache.GroupExists(ctx, "developers")).Should(BeTrue())
+		Expect(cache.GroupExists(ctx, "Developers")).Should(BeTrue())
+		Expect(lister.listCalls).Should(Equal(1))
+
+		now = now.Add(time.Minute)
+		Expect(cache.GroupNames(ctx)).Should(Equal([]string{"developers"}))
+		Expect(lister.listCalls).Should(Equal(2))
+	})
+
+	It("Should look up groups created since the groups were listed", func() {
+		lister := &countingGroupLister{names: []string{"developers"}}
+		cache := NewGroupCache(lister, time.Hour)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		Expect(cache.GroupExists(ctx, "qa")).Should(BeFalse())
+		lister.names = append(lister.names, "qa")
+		now = now.Add(missingGroupTTL)
+		Expect(cache.GroupExists(ctx, "qa")).Should(BeTrue())
+		Expect(lister.getCalls).Should(Equal(2))
+
+		Expect(cache.GroupExists(ctx, "qa")).Should(BeTrue())
+		Expect(lister.getCalls).Should(Equal(2))
+	})
+
+	It("Should look up a missing group once until the missing group TTL has passed", func() {
+		lister := &countingGroupLister{names: []string{"developers"}}
+		cache := NewGroupCache(lister, time.Hour)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		Expect(cache.GroupExists(ctx, "qa")).Should(BeFalse())
+		Expect(cache.GroupExists(ctx, "qa")).Should(BeFalse())
+		Expect(cache.GroupExists(ctx, "QA")).Should(BeFalse())
+		Expect(lister.getCalls).Should(Equal(1))
+		Expect(lister.listCalls).Should(Equal(1))
+
+		now = now.Add(missingGroupTTL)
+		Expect(cache.GroupExists(ctx, "qa")).Should(BeFalse())
+		Expect(lister.getCalls).Should(Equal(2))
+	})
+
+	It("Should suggest the closest group name only when it is a likely typo", func() {
+		cache := NewGroupCache(&countingGroupLister{names: []string{"developers", "dns-management", "admins"}}, time.Hour)
+
+		Expect(cache.ClosestGroupName(ctx, "dns-managment")).Should(Equal("dns-management"))
+		Expect(cache.ClosestGroupName(ctx, "Develpers")).Should(Equal("developers"))
+		Expect(cache.ClosestGroupName(ctx, "billing")).Should(BeEmpty())
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net/url"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -25,6 +26,26 @@ func isNoSuchEntityException(err error) bool {
 	}
 }
 
+func isEntityAlreadyExistsException(err error) bool {
+	var apiError smithy.APIError
+	errors.As(err, &apiError)
+	switch apiError.(type) {
+	case *types.EntityAlreadyExistsException:
+		return true
+	default:
+		return false
+	}
+}
+
+// toIamTags converts a tag map to IAM tags
+func toIamTags(tags map[string]string) []types.Tag {
+	var iamTags []types.Tag
+	for key, value := range tags {
+		iamTags = append(iamTags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return iamTags
+}
+
 type iamWrapper struct {
 	IamClient *iam.Client
 }
@@ -101,6 +122,35 @@ func (wrapper iamWrapper) ListGroupsForUser(ctx context.Context, userName string
 	return result.Groups, nil
 }
 
+// ListGroups lists every IAM group of the account
+func (wrapper iamWrapper) ListGroups(ctx context.Context) ([]types.Group, error) {
+	var groups []types.Group
+	paginator := iam.NewListGroupsPaginator(wrapper.IamClient, &iam.ListGroupsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, page.Groups...)
+	}
+	return groups, nil
+}
+
+// GetGroup returns the IAM group, or nil when it does not exist
+func (wrapper iamWrapper) GetGroup(ctx context.Context, groupName string) (*types.Group, error) {
+	result, err := wrapper.IamClient.GetGroup(ctx, &iam.GetGroupInput{
+		GroupName: aws.String(groupName),
+		MaxItems:  aws.Int32(1),
+	})
+	if isNoSuchEntityException(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.Group, nil
+}
+
 func (wrapper iamWrapper) CreateUser(ctx context.Context, userName string) (*types.User, error) {
 	var user *types.User
 	result, err := wrapper.IamClient.CreateUser(ctx, &iam.CreateUserInput{
@@ -114,11 +164,16 @@ func (wrapper iamWrapper) CreateUser(ctx context.Context, userName string) (*typ
 	return user, err
 }
 
-func (wrapper iamWrapper) CreateUserIfNotExists(ctx context.Context, userName string) error {
-	_, err := wrapper.IamClient.CreateUser(ctx, &iam.CreateUserInput{
+func (wrapper iamWrapper) CreateUserIfNotExists(ctx context.Context, userName string, permissionsBoundaryArn string, tags map[string]string) error {
+	input := &iam.CreateUserInput{
 		UserName: aws.String(userName),
-	})
-	if err != nil && !isNoSuchEntityException(err) {
+		Tags:     toIamTags(tags),
+	}
+	if permissionsBoundaryArn != "" {
+		input.PermissionsBoundary = aws.String(permissionsBoundaryArn)
+	}
+	_, err := wrapper.IamClient.CreateUser(ctx, input)
+	if err != nil && !isEntityAlreadyExistsException(err) {
 		return err
 	}
 	return nil
@@ -158,7 +213,7 @@ func (wrapper iamWrapper) CreateLoginProfileIfNotExists(ctx context.Context, pas
 		UserName:              &userName,
 		PasswordResetRequired: passwordResetRequired,
 	})
-	if err != nil && !isNoSuchEntityException(err) {
+	if err != nil && !isEntityAlreadyExistsException(err) {
 		return err
 	}
 	return nil
@@ -239,3 +294,151 @@ func (wrapper iamWrapper) DeleteAccessKeyIfExists(ctx context.Context, userName
 	}
 	return err
 }
+
+func (wrapper iamWrapper) UpdateAccessKeyStatus(ctx context.Context, userName string, keyId string, status types.StatusType) error {
+	_, err := wrapper.IamClient.UpdateAccessKey(ctx, &iam.UpdateAccessKeyInput{
+		AccessKeyId: aws.String(keyId),
+		UserName:    aws.String(userName),
+		Status:      status,
+	})
+	return err
+}
+
+// GetUserPolicy returns the decoded document of an inline user policy, or an empty string if it does not exist
+func (wrapper iamWrapper) GetUserPolicy(ctx context.Context, userName string, policyName string) (string, error) {
+	result, err := wrapper.IamClient.GetUserPolicy(ctx, &iam.GetUserPolicyInput{
+		PolicyName: aws.String(policyName),
+		UserName:   aws.String(userName),
+	})
+	if isNoSuchEntityException(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// IAM returns policy documents URL encoded
+	return url.QueryUnescape(*result.PolicyDocument)
+}
+
+func (wrapper iamWrapper) PutUserPolicy(ctx context.Context, userName string, policyName string, policyDocument string) error {
+	_, err := wrapper.IamClient.PutUserPolicy(ctx, &iam.PutUserPolicyInput{
+		PolicyDocument: aws.String(policyDocument),
+		PolicyName:     aws.String(policyName),
+		UserName:       aws.String(userName),
+	})
+	return err
+}
+
+func (wrapper iamWrapper) DeleteUserPolicyIfExists(ctx context.Context, userName string, policyName string) error {
+	_, err := wrapper.IamClient.DeleteUserPolicy(ctx, &iam.DeleteUserPolicyInput{
+		PolicyName: aws.String(policyName),
+		UserName:   aws.String(userName),
+	})
+	if isNoSuchEntityException(err) {
+		return nil
+	}
+	return err
+}
+
+func (wrapper iamWrapper) ListUserPolicies(ctx context.Context, userName string) ([]string, error) {
+	result, err := wrapper.IamClient.ListUserPolicies(ctx, &iam.ListUserPoliciesInput{
+		UserName: aws.String(userName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.PolicyNames, nil
+}
+
+func (wrapper iamWrapper) ListAttachedUserPolicies(ctx context.Context, userName string) ([]types.AttachedPolicy, error) {
+	result, err := wrapper.IamClient.ListAttachedUserPolicies(ctx, &iam.ListAttachedUserPoliciesInput{
+		UserName: aws.String(userName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.AttachedPolicies, nil
+}
+
+func (wrapper iamWrapper) AttachUserPolicy(ctx context.Context, userName string, policyArn string) error {
+	_, err := wrapper.IamClient.AttachUserPolicy(ctx, &iam.AttachUserPolicyInput{
+		PolicyArn: aws.String(policyArn),
+		UserName:  aws.String(userName),
+	})
+	return err
+}
+
+func (wrapper iamWrapper) DetachUserPolicyIfAttached(ctx context.Context, userName string, policyArn string) error {
+	_, err := wrapper.IamClient.DetachUserPolicy(ctx, &iam.DetachUserPolicyInput{
+		PolicyArn: aws.String(policyArn),
+		UserName:  aws.String(userName),
+	})
+	if isNoSuchEntityException(err) {
+		return nil
+	}
+	return err
+}
+
+// GetUserPermissionsBoundary returns the ARN of the user's permissions boundary, or an empty string if it has none
+func (wrapper iamWrapper) GetUserPermissionsBoundary(ctx context.Context, userName string) (string, error) {
+	user, err := wrapper.GetUser(ctx, userName)
+	if err != nil || user == nil || user.PermissionsBoundary == nil {
+		return "", err
+	}
+	return aws.ToString(user.PermissionsBoundary.PermissionsBoundaryArn), nil
+}
+
+func (wrapper iamWrapper) PutUserPermissionsBoundary(ctx context.Context, userName string, permissionsBoundaryArn string) error {
+	_, err := wrapper.IamClient.PutUserPermissionsBoundary(ctx, &iam.PutUserPermissionsBoundaryInput{
+		PermissionsBoundary: aws.String(permissionsBoundaryArn),
+		UserName:            aws.String(userName),
+	})
+	return err
+}
+
+func (wrapper iamWrapper) DeleteUserPermissionsBoundaryIfExists(ctx context.Context, userName string) error {
+	_, err := wrapper.IamClient.DeleteUserPermissionsBoundary(ctx, &iam.DeleteUserPermissionsBoundaryInput{
+		UserName: aws.String(userName),
+	})
+	if isNoSuchEntityException(err) {
+		return nil
+	}
+	return err
+}
+
+// ListUserTags returns the tags of the user, or nil if the user does not exist
+func (wrapper iamWrapper) ListUserTags(ctx context.Context, userName string) (map[string]string, error) {
+	paginator := iam.NewListUserTagsPaginator(wrapper.IamClient, &iam.ListUserTagsInput{
+		UserName: aws.String(userName),
+	})
+	tags := map[string]string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if isNoSuchEntityException(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+func (wrapper iamWrapper) TagUser(ctx context.Context, userName string, tags map[string]string) error {
+	_, err := wrapper.IamClient.TagUser(ctx, &iam.TagUserInput{
+		Tags:     toIamTags(tags),
+		UserName: aws.String(userName),
+	})
+	return err
+}
+
+func (wrapper iamWrapper) UntagUser(ctx context.Context, userName string, tagKeys []string) error {
+	_, err := wrapper.IamClient.UntagUser(ctx, &iam.UntagUserInput{
+		TagKeys:  tagKeys,
+		UserName: aws.String(userName),
+	})
+	return err
+}
@@ -0,0 +1,211 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+const nameServerRecordTTL = 300
+
+func isNoSuchHostedZoneException(err error) bool {
+	var apiError smithy.APIError
+	errors.As(err, &apiError)
+	switch apiError.(type) {
+	case *types.NoSuchHostedZone:
+		return true
+	default:
+		return false
+	}
+}
+
+// NormalizeDomainName returns a fully qualified domain name as Route53 reports it
+func NormalizeDomainName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+}
+
+// trimHostedZoneId strips the /hostedzone/ prefix Route53 puts on hosted zone ids
+func trimHostedZoneId(hostedZone *types.HostedZone) {
+	if hostedZone != nil && hostedZone.Id != nil {
+		hostedZone.Id = aws.String(strings.TrimPrefix(*hostedZone.Id, "/hostedzone/"))
+	}
+}
+
+type route53Wrapper struct {
+	Route53Client *route53.Client
+}
+
+func NewRoute53Wrapper() (*route53Wrapper, error) {
+	// TODO: take config/credentials in this constructor
+	sdkConfig, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("us-west-2"))
+	if err != nil {
+		return nil, err
+	}
+
+	route53Wrapper := route53Wrapper{
+		Route53Client: route53.NewFromConfig(sdkConfig),
+	}
+	return &route53Wrapper, nil
+}
+
+func (wrapper route53Wrapper) GetHostedZone(ctx context.Context, hostedZoneId string) (*types.HostedZone, []string, error) {
+	result, err := wrapper.Route53Client.GetHostedZone(ctx, &route53.GetHostedZoneInput{
+		Id: aws.String(hostedZoneId),
+	})
+	if isNoSuchHostedZoneException(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	trimHostedZoneId(result.HostedZone)
+	var nameServers []string
+	if result.DelegationSet != nil {
+		nameServers = result.DelegationSet.NameServers
+	}
+	return result.HostedZone, nameServers, nil
+}
+
+func (wrapper route53Wrapper) ListHostedZonesByName(ctx context.Context, name string) ([]types.HostedZone, error) {
+	result, err := wrapper.Route53Client.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Zones are listed in name order starting at DNSName, so only keep the exact matches
+	var hostedZones []types.HostedZone
+	for _, hostedZone := range result.HostedZones {
+		if *hostedZone.Name != NormalizeDomainName(name) {
+			break
+		}
+		trimHostedZoneId(&hostedZone)
+		hostedZones = append(hostedZones, hostedZone)
+	}
+	return hostedZones, nil
+}
+
+func (wrapper route53Wrapper) CreateHostedZone(ctx context.Context, name string, callerReference string) (*types.HostedZone, []string, error) {
+	result, err := wrapper.Route53Client.CreateHostedZone(ctx, &route53.CreateHostedZoneInput{
+		Name:            aws.String(name),
+		CallerReference: aws.String(callerReference),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	trimHostedZoneId(result.HostedZone)
+	var nameServers []string
+	if result.DelegationSet != nil {
+		nameServers = result.DelegationSet.NameServers
+	}
+	return result.HostedZone, nameServers, nil
+}
+
+// DeleteHostedZoneIfExists deletes every record set apart from the apex SOA and NS records, which
+// Route53 requires before the zone itself can be deleted
+func (wrapper route53Wrapper) DeleteHostedZoneIfExists(ctx context.Context, hostedZoneId string) error {
+	hostedZone, _, err := wrapper.GetHostedZone(ctx, hostedZoneId)
+	if err != nil || hostedZone == nil {
+		return err
+	}
+
+	paginator := route53.NewListResourceRecordSetsPaginator(wrapper.Route53Client, &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(hostedZoneId),
+	})
+	var changes []types.Change
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range page.ResourceRecordSets {
+			recordSet := page.ResourceRecordSets[i]
+			isApex := *recordSet.Name == *hostedZone.Name
+			if isApex && (recordSet.Type == types.RRTypeSoa || recordSet.Type == types.RRTypeNs) {
+				continue
+			}
+			changes = append(changes, types.Change{
+				Action:            types.ChangeActionDelete,
+				ResourceRecordSet: &recordSet,
+			})
+		}
+	}
+	if len(changes) > 0 {
+		if _, err := wrapper.Route53Client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(hostedZoneId),
+			ChangeBatch:  &types.ChangeBatch{Changes: changes},
+		}); err != nil {
+			return err
+		}
+	}
+
+	_, err = wrapper.Route53Client.DeleteHostedZone(ctx, &route53.DeleteHostedZoneInput{
+		Id: aws.String(hostedZoneId),
+	})
+	if isNoSuchHostedZoneException(err) {
+		return nil
+	}
+	return err
+}
+
+func (wrapper route53Wrapper) UpsertNameServerRecord(ctx context.Context, hostedZoneId string, name string, nameServers []string) error {
+	var resourceRecords []types.ResourceRecord
+	for _, nameServer := range nameServers {
+		resourceRecords = append(resourceRecords, types.ResourceRecord{Value: aws.String(nameServer)})
+	}
+	_, err := wrapper.Route53Client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(hostedZoneId),
+		ChangeBatch: &types.ChangeBatch{
+			Comment: aws.String("Delegation managed by kuadra"),
+			Changes: []types.Change{{
+				Action: types.ChangeActionUpsert,
+				ResourceRecordSet: &types.ResourceRecordSet{
+					Name:            aws.String(NormalizeDomainName(name)),
+					Type:            types.RRTypeNs,
+					TTL:             aws.Int64(nameServerRecordTTL),
+					ResourceRecords: resourceRecords,
+				},
+			}},
+		},
+	})
+	return err
+}
+
+func (wrapper route53Wrapper) DeleteNameServerRecordIfExists(ctx context.Context, hostedZoneId string, name string) error {
+	result, err := wrapper.Route53Client.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(hostedZoneId),
+		StartRecordName: aws.String(NormalizeDomainName(name)),
+		StartRecordType: types.RRTypeNs,
+		MaxItems:        aws.Int32(1),
+	})
+	if isNoSuchHostedZoneException(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(result.ResourceRecordSets) == 0 {
+		return nil
+	}
+	recordSet := result.ResourceRecordSets[0]
+	if *recordSet.Name != NormalizeDomainName(name) || recordSet.Type != types.RRTypeNs {
+		return nil
+	}
+	_, err = wrapper.Route53Client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(hostedZoneId),
+		ChangeBatch: &types.ChangeBatch{
+			Changes: []types.Change{{
+				Action:            types.ChangeActionDelete,
+				ResourceRecordSet: &recordSet,
+			}},
+		},
+	})
+	return err
+}
@@ -0,0 +1,14 @@
+package aws
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAws(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "AWS Client Suite")
+}
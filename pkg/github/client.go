@@ -0,0 +1,179 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the GitHub REST API endpoint of github.com
+const DefaultBaseURL = "https://api.github.com"
+
+// Organization membership states and roles
+const (
+	MembershipStateActive  = "active"
+	MembershipStatePending = "pending"
+
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+)
+
+// Membership is a user's membership of an organization or team
+type Membership struct {
+	State string `json:"state"`
+	Role  string `json:"role"`
+}
+
+// APIError is an error response of the GitHub REST API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API error %d: %s", e.StatusCode, e.Message)
+}
+
+// ErrorCode names the HTTP status of the error, e.g. NotFound
+func (e *APIError) ErrorCode() string {
+	return strings.ReplaceAll(http.StatusText(e.StatusCode), " ", "")
+}
+
+func isNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound
+}
+
+type githubWrapper struct {
+	BaseURL      string
+	Organization string
+	Token        string
+	HttpClient   *http.Client
+}
+
+// NewGithubWrapper returns a client managing the members of an organization through the REST API at baseURL
+func NewGithubWrapper(baseURL string, organization string, token string) (*githubWrapper, error) {
+	if organization == "" {
+		return nil, errors.New("a GitHub organization is required")
+	}
+	if token == "" {
+		return nil, errors.New("a GitHub token is required")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, err
+	}
+
+	githubWrapper := githubWrapper{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		Organization: organization,
+		Token:        token,
+		HttpClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+	return &githubWrapper, nil
+}
+
+func (wrapper githubWrapper) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var requestBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		requestBody = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, wrapper.BaseURL+path, requestBody)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", "Bearer "+wrapper.Token)
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := wrapper.HttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		apiError := &APIError{StatusCode: response.StatusCode}
+		var errorBody struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&errorBody); err == nil {
+			apiError.Message = errorBody.Message
+		}
+		return apiError
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func (wrapper githubWrapper) orgMembershipPath(login string) string {
+	return fmt.Sprintf("/orgs/%s/memberships/%s", url.PathEscape(wrapper.Organization), url.PathEscape(login))
+}
+
+func (wrapper githubWrapper) teamMembershipPath(teamSlug string, login string) string {
+	return fmt.Sprintf("/orgs/%s/teams/%s/memberships/%s", url.PathEscape(wrapper.Organization), url.PathEscape(teamSlug), url.PathEscape(login))
+}
+
+// GetOrgMembership returns the user's membership of the organization, or nil when the user
+// is neither a member nor invited
+func (wrapper githubWrapper) GetOrgMembership(ctx context.Context, login string) (*Membership, error) {
+	membership := &Membership{}
+	err := wrapper.do(ctx, http.MethodGet, wrapper.orgMembershipPath(login), nil, membership)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// SetOrgMembership invites the user to the organization, or updates the role of a member
+func (wrapper githubWrapper) SetOrgMembership(ctx context.Context, login string, role string) (*Membership, error) {
+	membership := &Membership{}
+	if err := wrapper.do(ctx, http.MethodPut, wrapper.orgMembershipPath(login), map[string]string{"role": role}, membership); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// RemoveOrgMembership removes the user from the organization, or cancels their invitation
+func (wrapper githubWrapper) RemoveOrgMembership(ctx context.Context, login string) error {
+	err := wrapper.do(ctx, http.MethodDelete, wrapper.orgMembershipPath(login), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// AddTeamMembership adds the user to a team of the organization, pending their invitation if need be
+func (wrapper githubWrapper) AddTeamMembership(ctx context.Context, teamSlug string, login string) error {
+	return wrapper.do(ctx, http.MethodPut, wrapper.teamMembershipPath(teamSlug, login), map[string]string{"role": RoleMember}, nil)
+}
+
+// RemoveTeamMembership removes the user from a team of the organization
+func (wrapper githubWrapper) RemoveTeamMembership(ctx context.Context, teamSlug string, login string) error {
+	err := wrapper.do(ctx, http.MethodDelete, wrapper.teamMembershipPath(teamSlug, login), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeGithub is a stand-in for the membership endpoints of the GitHub REST API
+type fakeGithub struct {
+	memberships     map[string]Membership
+	teamMemberships map[string]bool
+	requests        []string
+}
+
+func (f *fakeGithub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"message": "Bad credentials"}`))
+		return
+	}
+
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "Not Found"}`))
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/orgs/kuadrant/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "memberships":
+		login := parts[1]
+		switch r.Method {
+		case http.MethodGet:
+			membership, exists := f.memberships[login]
+			if !exists {
+				notFound()
+				return
+			}
+			_ = json.NewEncoder(w).Encode(membership)
+		case http.MethodPut:
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			membership, exists := f.memberships[login]
+			if !exists {
+				membership.State = MembershipStatePending
+			}
+			membership.Role = body["role"]
+			f.memberships[login] = membership
+			_ = json.NewEncoder(w).Encode(membership)
+		case http.MethodDelete:
+			if _, exists := f.memberships[login]; !exists {
+				notFound()
+				return
+			}
+			delete(f.memberships, login)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(parts) == 4 && parts[0] == "teams" && parts[2] == "memberships":
+		key := parts[1] + "/" + parts[3]
+		switch r.Method {
+		case http.MethodPut:
+			if parts[1] == "missing" {
+				notFound()
+				return
+			}
+			f.teamMemberships[key] = true
+			_ = json.NewEncoder(w).Encode(Membership{State: MembershipStatePending, Role: RoleMember})
+		case http.MethodDelete:
+			if !f.teamMemberships[key] {
+				notFound()
+				return
+			}
+			delete(f.teamMemberships, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		notFound()
+	}
+}
+
+var _ = Describe("GitHub client", func() {
+
+	ctx := context.Background()
+
+	var fake *fakeGithub
+	var server *httptest.Server
+	var wrapper *githubWrapper
+
+	BeforeEach(func() {
+		fake = &fakeGithub{memberships: map[string]Membership{}, teamMemberships: map[string]bool{}}
+		server = httptest.NewServer(fake)
+		var err error
+		wrapper, err = NewGithubWrapper(server.URL+"/", "kuadrant", "token")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Should require an organization and a token", func() {
+		_, err := NewGithubWrapper(server.URL, "", "token")
+		Expect(err).Should(HaveOccurred())
+		_, err = NewGithubWrapper(server.URL, "kuadrant", "")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("Should invite, update and remove organization members", func() {
+		membership, err := wrapper.GetOrgMembership(ctx, "jdoe")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(membership).Should(BeNil())
+
+		membership, err = wrapper.SetOrgMembership(ctx, "jdoe", RoleMember)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(*membership).Should(Equal(Membership{State: MembershipStatePending, Role: RoleMember}))
+
+		membership, err = wrapper.SetOrgMembership(ctx, "jdoe", RoleAdmin)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(membership.Role).Should(Equal(RoleAdmin))
+
+		membership, err = wrapper.GetOrgMembership(ctx, "jdoe")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(membership.Role).Should(Equal(RoleAdmin))
+
+		Expect(wrapper.RemoveOrgMembership(ctx, "jdoe")).Should(Succeed())
+		Expect(fake.memberships).ShouldNot(HaveKey("jdoe"))
+		Expect(wrapper.RemoveOrgMembership(ctx, "jdoe")).Should(Succeed())
+	})
+
+	It("Should add and remove team members", func() {
+		Expect(wrapper.AddTeamMembership(ctx, "dns", "jdoe")).Should(Succeed())
+		Expect(fake.teamMemberships).Should(HaveKey("dns/jdoe"))
+		Expect(wrapper.RemoveTeamMembership(ctx, "dns", "jdoe")).Should(Succeed())
+		Expect(fake.teamMemberships).ShouldNot(HaveKey("dns/jdoe"))
+		Expect(wrapper.RemoveTeamMembership(ctx, "dns", "jdoe")).Should(Succeed())
+		Expect(fake.requests).Should(ContainElement("PUT /orgs/kuadrant/teams/dns/memberships/jdoe"))
+	})
+
+	It("Should return API errors with their code", func() {
+		err := wrapper.AddTeamMembership(ctx, "missing", "jdoe")
+		var apiError *APIError
+		Expect(err).Should(BeAssignableToTypeOf(apiError))
+		Expect(err.(*APIError).ErrorCode()).Should(Equal("NotFound"))
+
+		wrapper.Token = "wrong"
+		_, err = wrapper.GetOrgMembership(ctx, "jdoe")
+		Expect(err).Should(MatchError(ContainSubstring("Bad credentials")))
+	})
+})
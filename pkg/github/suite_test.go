@@ -0,0 +1,14 @@
+package github
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGithub(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "GitHub Client Suite")
+}
@@ -0,0 +1,313 @@
+package keycloak
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// User is a user of a realm
+type User struct {
+	Id         string              `json:"id,omitempty"`
+	Username   string              `json:"username"`
+	Email      string              `json:"email,omitempty"`
+	FirstName  string              `json:"firstName,omitempty"`
+	LastName   string              `json:"lastName,omitempty"`
+	Enabled    bool                `json:"enabled"`
+	Attributes map[string][]string `json:"attributes,omitempty"`
+}
+
+// role is a realm or client role as the admin API represents it
+type role struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type client struct {
+	Id       string `json:"id"`
+	ClientId string `json:"clientId"`
+}
+
+// APIError is an error response of the Keycloak admin API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Keycloak API error %d: %s", e.StatusCode, e.Message)
+}
+
+// ErrorCode names the HTTP status of the error, e.g. NotFound
+func (e *APIError) ErrorCode() string {
+	return strings.ReplaceAll(http.StatusText(e.StatusCode), " ", "")
+}
+
+func isNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound
+}
+
+type keycloakWrapper struct {
+	BaseURL      string
+	Realm        string
+	ClientId     string
+	ClientSecret string
+	HttpClient   *http.Client
+
+	tokenLock   *sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewKeycloakWrapper returns a client managing the users of a realm through the admin API of the
+// Keycloak at baseURL. It authenticates with the client credentials of a confidential client of
+// the realm whose service account has the manage-users role of realm-management.
+func NewKeycloakWrapper(baseURL string, realm string, clientId string, clientSecret string) (*keycloakWrapper, error) {
+	if baseURL == "" || realm == "" {
+		return nil, errors.New("a Keycloak URL and realm are required")
+	}
+	if clientId == "" || clientSecret == "" {
+		return nil, errors.New("Keycloak client credentials are required")
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, err
+	}
+
+	keycloakWrapper := keycloakWrapper{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		Realm:        realm,
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		HttpClient:   &http.Client{Timeout: 30 * time.Second},
+		tokenLock:    &sync.Mutex{},
+	}
+	return &keycloakWrapper, nil
+}
+
+// accessToken returns a token of the client's service account, requesting a new one when it expires
+func (wrapper *keycloakWrapper) accessToken(ctx context.Context) (string, error) {
+	wrapper.tokenLock.Lock()
+	defer wrapper.tokenLock.Unlock()
+	if wrapper.token != "" && time.Now().Before(wrapper.tokenExpiry) {
+		return wrapper.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {wrapper.ClientId},
+		"client_secret": {wrapper.ClientSecret},
+	}
+	tokenURL := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/token", wrapper.BaseURL, url.PathEscape(wrapper.Realm))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := wrapper.HttpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return "", responseError(response)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	wrapper.token = token.AccessToken
+	// Renew the token a little before it expires
+	wrapper.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - 10*time.Second)
+	return wrapper.token, nil
+}
+
+func responseError(response *http.Response) error {
+	apiError := &APIError{StatusCode: response.StatusCode}
+	var errorBody struct {
+		ErrorMessage     string `json:"errorMessage"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&errorBody); err == nil {
+		for _, message := range []string{errorBody.ErrorMessage, errorBody.ErrorDescription, errorBody.Error} {
+			if message != "" {
+				apiError.Message = message
+				break
+			}
+		}
+	}
+	return apiError
+}
+
+// do calls the admin API of the realm and returns the response headers
+func (wrapper *keycloakWrapper) do(ctx context.Context, method string, path string, body interface{}, result interface{}) (http.Header, error) {
+	token, err := wrapper.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var requestBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		requestBody = bytes.NewReader(encoded)
+	}
+	adminURL := fmt.Sprintf("%s/admin/realms/%s%s", wrapper.BaseURL, url.PathEscape(wrapper.Realm), path)
+	request, err := http.NewRequestWithContext(ctx, method, adminURL, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := wrapper.HttpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, responseError(response)
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent || response.StatusCode == http.StatusCreated {
+		return response.Header, nil
+	}
+	return response.Header, json.NewDecoder(response.Body).Decode(result)
+}
+
+// GetUser returns the user with the exact username, or nil when the realm has no such user
+func (wrapper *keycloakWrapper) GetUser(ctx context.Context, username string) (*User, error) {
+	var users []User
+	query := url.Values{"username": {username}, "exact": {"true"}}
+	if _, err := wrapper.do(ctx, http.MethodGet, "/users?"+query.Encode(), nil, &users); err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if strings.EqualFold(users[i].Username, username) {
+			return &users[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateUser creates the user and returns its id
+func (wrapper *keycloakWrapper) CreateUser(ctx context.Context, user User) (string, error) {
+	header, err := wrapper.do(ctx, http.MethodPost, "/users", user, nil)
+	if err != nil {
+		return "", err
+	}
+	location := header.Get("Location")
+	id := location[strings.LastIndex(location, "/")+1:]
+	if id == "" {
+		return "", fmt.Errorf("Keycloak did not return the id of user %s", user.Username)
+	}
+	return id, nil
+}
+
+// UpdateUser updates the profile and attributes of the user
+func (wrapper *keycloakWrapper) UpdateUser(ctx context.Context, user User) error {
+	_, err := wrapper.do(ctx, http.MethodPut, "/users/"+url.PathEscape(user.Id), user, nil)
+	return err
+}
+
+// DeleteUserIfExists deletes the user
+func (wrapper *keycloakWrapper) DeleteUserIfExists(ctx context.Context, userId string) error {
+	_, err := wrapper.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(userId), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// ResetPassword sets the user's password, which has to be changed on the next login when temporary
+func (wrapper *keycloakWrapper) ResetPassword(ctx context.Context, userId string, password string, temporary bool) error {
+	credential := map[string]interface{}{"type": "password", "value": password, "temporary": temporary}
+	_, err := wrapper.do(ctx, http.MethodPut, "/users/"+url.PathEscape(userId)+"/reset-password", credential, nil)
+	return err
+}
+
+func (wrapper *keycloakWrapper) getRoles(ctx context.Context, rolesPath string, roleNames []string) ([]role, error) {
+	roles := make([]role, 0, len(roleNames))
+	for _, roleName := range roleNames {
+		var role role
+		if _, err := wrapper.do(ctx, http.MethodGet, rolesPath+"/"+url.PathEscape(roleName), nil, &role); err != nil {
+			return nil, fmt.Errorf("unable to get role %s: %w", roleName, err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// clientPath returns the admin API path of the client with the given client id
+func (wrapper *keycloakWrapper) clientPath(ctx context.Context, clientId string) (string, error) {
+	var clients []client
+	query := url.Values{"clientId": {clientId}}
+	if _, err := wrapper.do(ctx, http.MethodGet, "/clients?"+query.Encode(), nil, &clients); err != nil {
+		return "", err
+	}
+	for _, client := range clients {
+		if client.ClientId == clientId {
+			return "/clients/" + url.PathEscape(client.Id), nil
+		}
+	}
+	return "", &APIError{StatusCode: http.StatusNotFound, Message: "client " + clientId + " not found"}
+}
+
+func (wrapper *keycloakWrapper) mapRealmRoles(ctx context.Context, method string, userId string, roleNames []string) error {
+	roles, err := wrapper.getRoles(ctx, "/roles", roleNames)
+	if err != nil {
+		return err
+	}
+	_, err = wrapper.do(ctx, method, "/users/"+url.PathEscape(userId)+"/role-mappings/realm", roles, nil)
+	return err
+}
+
+func (wrapper *keycloakWrapper) mapClientRoles(ctx context.Context, method string, userId string, clientId string, roleNames []string) error {
+	clientPath, err := wrapper.clientPath(ctx, clientId)
+	if err != nil {
+		return err
+	}
+	roles, err := wrapper.getRoles(ctx, clientPath+"/roles", roleNames)
+	if err != nil {
+		return err
+	}
+	_, err = wrapper.do(ctx, method, "/users/"+url.PathEscape(userId)+"/role-mappings"+clientPath, roles, nil)
+	return err
+}
+
+// AddRealmRoles assigns realm roles to the user
+func (wrapper *keycloakWrapper) AddRealmRoles(ctx context.Context, userId string, roleNames []string) error {
+	return wrapper.mapRealmRoles(ctx, http.MethodPost, userId, roleNames)
+}
+
+// RemoveRealmRoles unassigns realm roles from the user
+func (wrapper *keycloakWrapper) RemoveRealmRoles(ctx context.Context, userId string, roleNames []string) error {
+	return wrapper.mapRealmRoles(ctx, http.MethodDelete, userId, roleNames)
+}
+
+// AddClientRoles assigns roles of a client to the user
+func (wrapper *keycloakWrapper) AddClientRoles(ctx context.Context, userId string, clientId string, roleNames []string) error {
+	return wrapper.mapClientRoles(ctx, http.MethodPost, userId, clientId, roleNames)
+}
+
+// RemoveClientRoles unassigns roles of a client from the user
+func (wrapper *keycloakWrapper) RemoveClientRoles(ctx context.Context, userId string, clientId string, roleNames []string) error {
+	return wrapper.mapClientRoles(ctx, http.MethodDelete, userId, clientId, roleNames)
+}
@@ -0,0 +1,196 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeKeycloak is a stand-in for the token endpoint and the user and role mapping endpoints
+// of the Keycloak admin API for the realm kuadrant
+type fakeKeycloak struct {
+	tokenRequests int
+	users         map[string]User
+	passwords     map[string]map[string]interface{}
+	roleMappings  map[string]bool
+}
+
+func (f *fakeKeycloak) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	notFound := func(message string) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error": "` + message + `"}`))
+	}
+
+	if r.URL.Path == "/realms/kuadrant/protocol/openid-connect/token" {
+		_ = r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": "unauthorized_client", "error_description": "Invalid client secret"}`))
+			return
+		}
+		f.tokenRequests++
+		_, _ = w.Write([]byte(`{"access_token": "token", "expires_in": 300}`))
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/realms/kuadrant/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "users":
+		switch r.Method {
+		case http.MethodGet:
+			users := []User{}
+			for _, user := range f.users {
+				// Keycloak matches usernames case insensitively
+				if strings.EqualFold(user.Username, r.URL.Query().Get("username")) {
+					users = append(users, user)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(users)
+		case http.MethodPost:
+			var user User
+			_ = json.NewDecoder(r.Body).Decode(&user)
+			for _, existing := range f.users {
+				if existing.Username == user.Username {
+					w.WriteHeader(http.StatusConflict)
+					_, _ = w.Write([]byte(`{"errorMessage": "User exists with same username"}`))
+					return
+				}
+			}
+			user.Id = "id-" + user.Username
+			f.users[user.Id] = user
+			w.Header().Set("Location", "http://"+r.Host+"/admin/realms/kuadrant/users/"+user.Id)
+			w.WriteHeader(http.StatusCreated)
+		}
+	case len(path) == 2 && path[0] == "users":
+		if _, exists := f.users[path[1]]; !exists {
+			notFound("User not found")
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			var user User
+			_ = json.NewDecoder(r.Body).Decode(&user)
+			f.users[path[1]] = user
+		case http.MethodDelete:
+			delete(f.users, path[1])
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(path) == 3 && path[0] == "users" && path[2] == "reset-password":
+		var credential map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&credential)
+		f.passwords[path[1]] = credential
+		w.WriteHeader(http.StatusNoContent)
+	case len(path) == 2 && path[0] == "roles":
+		if path[1] == "missing" {
+			notFound("Could not find role")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(role{Id: "realm-" + path[1], Name: path[1]})
+	case len(path) == 1 && path[0] == "clients":
+		_ = json.NewEncoder(w).Encode([]client{{Id: "uuid-" + r.URL.Query().Get("clientId"), ClientId: r.URL.Query().Get("clientId")}})
+	case len(path) == 4 && path[0] == "clients" && path[2] == "roles":
+		_ = json.NewEncoder(w).Encode(role{Id: path[1] + "-" + path[3], Name: path[3]})
+	case len(path) >= 4 && path[0] == "users" && path[2] == "role-mappings":
+		var roles []role
+		_ = json.NewDecoder(r.Body).Decode(&roles)
+		for _, role := range roles {
+			key := path[1] + "/" + strings.Join(path[3:], "/") + "/" + role.Id
+			if r.Method == http.MethodPost {
+				f.roleMappings[key] = true
+			} else {
+				delete(f.roleMappings, key)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		notFound("Not found")
+	}
+}
+
+var _ = Describe("Keycloak client", func() {
+
+	ctx := context.Background()
+
+	var fake *fakeKeycloak
+	var server *httptest.Server
+	var wrapper *keycloakWrapper
+
+	BeforeEach(func() {
+		fake = &fakeKeycloak{users: map[string]User{}, passwords: map[string]map[string]interface{}{}, roleMappings: map[string]bool{}}
+		server = httptest.NewServer(fake)
+		var err error
+		wrapper, err = NewKeycloakWrapper(server.URL, "kuadrant", "kuadra", "secret")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Should create, update and delete users", func() {
+		user, err := wrapper.GetUser(ctx, "jdoe")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(user).Should(BeNil())
+
+		id, err := wrapper.CreateUser(ctx, User{Username: "jdoe", Email: "jdoe@example.com", Enabled: true})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(id).Should(Equal("id-jdoe"))
+
+		user, err = wrapper.GetUser(ctx, "jdoe")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(user.Id).Should(Equal("id-jdoe"))
+
+		user.FirstName = "Jane"
+		Expect(wrapper.UpdateUser(ctx, *user)).Should(Succeed())
+		Expect(fake.users["id-jdoe"].FirstName).Should(Equal("Jane"))
+
+		Expect(wrapper.ResetPassword(ctx, id, "password", true)).Should(Succeed())
+		Expect(fake.passwords[id]).Should(Equal(map[string]interface{}{"type": "password", "value": "password", "temporary": true}))
+
+		Expect(wrapper.DeleteUserIfExists(ctx, id)).Should(Succeed())
+		Expect(fake.users).Should(BeEmpty())
+		Expect(wrapper.DeleteUserIfExists(ctx, id)).Should(Succeed())
+		Expect(fake.tokenRequests).Should(Equal(1))
+	})
+
+	It("Should assign and unassign realm and client roles", func() {
+		Expect(wrapper.AddRealmRoles(ctx, "id-jdoe", []string{"developer"})).Should(Succeed())
+		Expect(wrapper.AddClientRoles(ctx, "id-jdoe", "kubernetes", []string{"view"})).Should(Succeed())
+		Expect(fake.roleMappings).Should(Equal(map[string]bool{
+			"id-jdoe/realm/realm-developer":                        true,
+			"id-jdoe/clients/uuid-kubernetes/uuid-kubernetes-view": true,
+		}))
+
+		Expect(wrapper.RemoveRealmRoles(ctx, "id-jdoe", []string{"developer"})).Should(Succeed())
+		Expect(wrapper.RemoveClientRoles(ctx, "id-jdoe", "kubernetes", []string{"view"})).Should(Succeed())
+		Expect(fake.roleMappings).Should(BeEmpty())
+	})
+
+	It("Should return API errors with their code and message", func() {
+		err := wrapper.AddRealmRoles(ctx, "id-jdoe", []string{"missing"})
+		Expect(err).Should(MatchError(ContainSubstring("Could not find role")))
+		var apiError *APIError
+		Expect(errors.As(err, &apiError)).Should(BeTrue())
+		Expect(apiError.ErrorCode()).Should(Equal("NotFound"))
+
+		_, err = wrapper.CreateUser(ctx, User{Username: "jdoe"})
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = wrapper.CreateUser(ctx, User{Username: "jdoe"})
+		Expect(err).Should(MatchError(ContainSubstring("User exists with same username")))
+
+		wrapper, err = NewKeycloakWrapper(server.URL, "kuadrant", "kuadra", "wrong")
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = wrapper.GetUser(ctx, "jdoe")
+		Expect(err).Should(MatchError(ContainSubstring("Invalid client secret")))
+	})
+})
@@ -0,0 +1,14 @@
+package keycloak
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestKeycloak(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Keycloak Client Suite")
+}
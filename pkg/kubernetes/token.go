@@ -0,0 +1,36 @@
+package kubernetes
+
+import (
+	"context"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type tokenRequester struct {
+	Client client.Client
+}
+
+// NewTokenRequester returns a client requesting ServiceAccount tokens through the TokenRequest API
+func NewTokenRequester(c client.Client) *tokenRequester {
+	return &tokenRequester{Client: c}
+}
+
+// CreateToken requests a token of the ServiceAccount and returns it along with its expiration time,
+// which the API server may set earlier than asked for
+func (requester *tokenRequester) CreateToken(ctx context.Context, namespace string, serviceAccount string, expiration time.Duration) (string, time.Time, error) {
+	expirationSeconds := int64(expiration.Seconds())
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+	sa := &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccount, Namespace: namespace}}
+	if err := requester.Client.SubResource("token").Create(ctx, sa, tokenRequest); err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenRequest.Status.Token, tokenRequest.Status.ExpirationTimestamp.Time, nil
+}
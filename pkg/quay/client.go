@@ -0,0 +1,206 @@
+package quay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the address of quay.io
+const DefaultBaseURL = "https://quay.io"
+
+// Repository permission roles
+const (
+	RoleRead  = "read"
+	RoleWrite = "write"
+	RoleAdmin = "admin"
+)
+
+// Robot is a robot account of an organization
+type Robot struct {
+	// Name is the full name of the robot, <organization>+<short name>
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// APIError is an error response of the Quay API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Quay API error %d: %s", e.StatusCode, e.Message)
+}
+
+// ErrorCode names the HTTP status of the error, e.g. NotFound
+func (e *APIError) ErrorCode() string {
+	return strings.ReplaceAll(http.StatusText(e.StatusCode), " ", "")
+}
+
+func isNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound
+}
+
+type quayWrapper struct {
+	BaseURL      string
+	Organization string
+	Token        string
+	HttpClient   *http.Client
+}
+
+// NewQuayWrapper returns a client managing the teams and robot accounts of an organization
+// through the Quay API at baseURL
+func NewQuayWrapper(baseURL string, organization string, token string) (*quayWrapper, error) {
+	if organization == "" {
+		return nil, errors.New("a Quay organization is required")
+	}
+	if token == "" {
+		return nil, errors.New("a Quay token is required")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, err
+	}
+
+	quayWrapper := quayWrapper{
+		BaseURL:      strings.TrimSuffix(baseURL, "/"),
+		Organization: organization,
+		Token:        token,
+		HttpClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+	return &quayWrapper, nil
+}
+
+func (wrapper quayWrapper) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var requestBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		requestBody = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, wrapper.BaseURL+"/api/v1"+path, requestBody)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Authorization", "Bearer "+wrapper.Token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := wrapper.HttpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		apiError := &APIError{StatusCode: response.StatusCode}
+		var errorBody struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+			Error   string `json:"error_message"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&errorBody); err == nil {
+			for _, message := range []string{errorBody.Error, errorBody.Detail, errorBody.Message} {
+				if message != "" {
+					apiError.Message = message
+					break
+				}
+			}
+		}
+		return apiError
+	}
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func (wrapper quayWrapper) teamMemberPath(team string, userName string) string {
+	return fmt.Sprintf("/organization/%s/team/%s/members/%s", url.PathEscape(wrapper.Organization), url.PathEscape(team), url.PathEscape(userName))
+}
+
+func (wrapper quayWrapper) robotPath(shortName string) string {
+	return fmt.Sprintf("/organization/%s/robots/%s", url.PathEscape(wrapper.Organization), url.PathEscape(shortName))
+}
+
+func (wrapper quayWrapper) robotPermissionPath(repository string, robotName string) string {
+	return fmt.Sprintf("/repository/%s/%s/permissions/user/%s", url.PathEscape(wrapper.Organization), url.PathEscape(repository), url.PathEscape(robotName))
+}
+
+// AddTeamMember adds a user to a team of the organization
+func (wrapper quayWrapper) AddTeamMember(ctx context.Context, team string, userName string) error {
+	return wrapper.do(ctx, http.MethodPut, wrapper.teamMemberPath(team, userName), nil, nil)
+}
+
+// RemoveTeamMemberIfExists removes a user from a team of the organization
+func (wrapper quayWrapper) RemoveTeamMemberIfExists(ctx context.Context, team string, userName string) error {
+	err := wrapper.do(ctx, http.MethodDelete, wrapper.teamMemberPath(team, userName), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// GetRobot returns the robot account with its token, or nil when it does not exist
+func (wrapper quayWrapper) GetRobot(ctx context.Context, shortName string) (*Robot, error) {
+	robot := &Robot{}
+	err := wrapper.do(ctx, http.MethodGet, wrapper.robotPath(shortName), nil, robot)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return robot, nil
+}
+
+// CreateRobotIfNotExists returns the robot account, creating it first if need be
+func (wrapper quayWrapper) CreateRobotIfNotExists(ctx context.Context, shortName string, description string) (*Robot, error) {
+	robot, err := wrapper.GetRobot(ctx, shortName)
+	if err != nil || robot != nil {
+		return robot, err
+	}
+	robot = &Robot{}
+	if err := wrapper.do(ctx, http.MethodPut, wrapper.robotPath(shortName), map[string]string{"description": description}, robot); err != nil {
+		return nil, err
+	}
+	return robot, nil
+}
+
+// DeleteRobotIfExists deletes the robot account and its repository permissions
+func (wrapper quayWrapper) DeleteRobotIfExists(ctx context.Context, shortName string) error {
+	err := wrapper.do(ctx, http.MethodDelete, wrapper.robotPath(shortName), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// SetRepositoryPermission grants the robot account a role on a repository of the organization
+func (wrapper quayWrapper) SetRepositoryPermission(ctx context.Context, repository string, robotName string, role string) error {
+	return wrapper.do(ctx, http.MethodPut, wrapper.robotPermissionPath(repository, robotName), map[string]string{"role": role}, nil)
+}
+
+// DeleteRepositoryPermissionIfExists revokes the robot account's role on a repository of the organization
+func (wrapper quayWrapper) DeleteRepositoryPermissionIfExists(ctx context.Context, repository string, robotName string) error {
+	err := wrapper.do(ctx, http.MethodDelete, wrapper.robotPermissionPath(repository, robotName), nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
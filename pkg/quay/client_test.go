@@ -0,0 +1,165 @@
+package quay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeQuay is a stand-in for the team, robot and permission endpoints of the Quay API
+type fakeQuay struct {
+	teamMembers map[string]bool
+	robots      map[string]Robot
+	permissions map[string]string
+}
+
+func (f *fakeQuay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error_message": "Invalid token"}`))
+		return
+	}
+
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"detail": "Not Found"}`))
+	}
+	var body map[string]string
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	switch {
+	case len(path) == 6 && path[0] == "organization" && path[1] == "kuadrant" && path[2] == "team" && path[4] == "members":
+		key := path[3] + "/" + path[5]
+		switch r.Method {
+		case http.MethodPut:
+			if path[3] == "missing" {
+				notFound()
+				return
+			}
+			f.teamMembers[key] = true
+			_, _ = w.Write([]byte(`{"name": "` + path[5] + `", "kind": "user"}`))
+		case http.MethodDelete:
+			if !f.teamMembers[key] {
+				notFound()
+				return
+			}
+			delete(f.teamMembers, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(path) == 4 && path[0] == "organization" && path[1] == "kuadrant" && path[2] == "robots":
+		shortName := path[3]
+		robot, exists := f.robots[shortName]
+		switch r.Method {
+		case http.MethodGet:
+			if !exists {
+				notFound()
+				return
+			}
+			_ = json.NewEncoder(w).Encode(robot)
+		case http.MethodPut:
+			if exists {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error_message": "Existing robot with name"}`))
+				return
+			}
+			robot = Robot{Name: "kuadrant+" + shortName, Token: "robot-token"}
+			f.robots[shortName] = robot
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(robot)
+		case http.MethodDelete:
+			if !exists {
+				notFound()
+				return
+			}
+			delete(f.robots, shortName)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(path) == 6 && path[0] == "repository" && path[1] == "kuadrant" && path[3] == "permissions" && path[4] == "user":
+		key := path[2] + "/" + path[5]
+		switch r.Method {
+		case http.MethodPut:
+			f.permissions[key] = body["role"]
+			_ = json.NewEncoder(w).Encode(map[string]string{"role": body["role"]})
+		case http.MethodDelete:
+			if _, exists := f.permissions[key]; !exists {
+				notFound()
+				return
+			}
+			delete(f.permissions, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		notFound()
+	}
+}
+
+var _ = Describe("Quay client", func() {
+
+	ctx := context.Background()
+
+	var fake *fakeQuay
+	var server *httptest.Server
+	var wrapper *quayWrapper
+
+	BeforeEach(func() {
+		fake = &fakeQuay{teamMembers: map[string]bool{}, robots: map[string]Robot{}, permissions: map[string]string{}}
+		server = httptest.NewServer(fake)
+		var err error
+		wrapper, err = NewQuayWrapper(server.URL, "kuadrant", "token")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Should add and remove team members", func() {
+		Expect(wrapper.AddTeamMember(ctx, "developers", "jdoe")).Should(Succeed())
+		Expect(fake.teamMembers).Should(HaveKey("developers/jdoe"))
+		Expect(wrapper.RemoveTeamMemberIfExists(ctx, "developers", "jdoe")).Should(Succeed())
+		Expect(fake.teamMembers).Should(BeEmpty())
+		Expect(wrapper.RemoveTeamMemberIfExists(ctx, "developers", "jdoe")).Should(Succeed())
+	})
+
+	It("Should create robot accounts once", func() {
+		robot, err := wrapper.CreateRobotIfNotExists(ctx, "kuadra_jdoe", "test")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(*robot).Should(Equal(Robot{Name: "kuadrant+kuadra_jdoe", Token: "robot-token"}))
+
+		robot, err = wrapper.CreateRobotIfNotExists(ctx, "kuadra_jdoe", "test")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(robot.Name).Should(Equal("kuadrant+kuadra_jdoe"))
+
+		Expect(wrapper.DeleteRobotIfExists(ctx, "kuadra_jdoe")).Should(Succeed())
+		Expect(fake.robots).Should(BeEmpty())
+		Expect(wrapper.DeleteRobotIfExists(ctx, "kuadra_jdoe")).Should(Succeed())
+		robot, err = wrapper.GetRobot(ctx, "kuadra_jdoe")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(robot).Should(BeNil())
+	})
+
+	It("Should grant and revoke repository permissions", func() {
+		Expect(wrapper.SetRepositoryPermission(ctx, "kuadra", "kuadrant+kuadra_jdoe", RoleWrite)).Should(Succeed())
+		Expect(fake.permissions).Should(HaveKeyWithValue("kuadra/kuadrant+kuadra_jdoe", RoleWrite))
+		Expect(wrapper.DeleteRepositoryPermissionIfExists(ctx, "kuadra", "kuadrant+kuadra_jdoe")).Should(Succeed())
+		Expect(fake.permissions).Should(BeEmpty())
+		Expect(wrapper.DeleteRepositoryPermissionIfExists(ctx, "kuadra", "kuadrant+kuadra_jdoe")).Should(Succeed())
+	})
+
+	It("Should return API errors with their code and message", func() {
+		err := wrapper.AddTeamMember(ctx, "missing", "jdoe")
+		Expect(err).Should(BeAssignableToTypeOf(&APIError{}))
+		Expect(err.(*APIError).ErrorCode()).Should(Equal("NotFound"))
+
+		wrapper.Token = "wrong"
+		_, err = wrapper.GetRobot(ctx, "kuadra_jdoe")
+		Expect(err).Should(MatchError(ContainSubstring("Invalid token")))
+	})
+})
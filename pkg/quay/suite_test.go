@@ -0,0 +1,14 @@
+package quay
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestQuay(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Quay Client Suite")
+}